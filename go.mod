@@ -5,7 +5,7 @@ go 1.14
 require (
 	github.com/buger/jsonparser v1.0.1-0.20200528031959-277c1bf2e485
 	github.com/gocql/gocql v0.0.0-20200926162733-393f0c961220
-	github.com/golang/snappy v0.0.2 // indirect
+	github.com/golang/snappy v0.0.2
 	github.com/google/uuid v1.1.2 // indirect
 	github.com/json-iterator/go v1.1.10
 	github.com/julienschmidt/httprouter v1.3.0
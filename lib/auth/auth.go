@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Token based authentication: the tokens are scoped to keysets and operations,
+// stored in scylla and cached in memory by all nodes.
+//
+
+// Operation - an operation allowed by a token
+type Operation string
+
+const (
+	// OperationWrite - writes points
+	OperationWrite Operation = "write"
+
+	// OperationRead - queries points and metadata
+	OperationRead Operation = "read"
+
+	// OperationDelete - deletes timeseries
+	OperationDelete Operation = "delete"
+
+	// OperationAdmin - manages keysets, keyspaces and the node configuration
+	OperationAdmin Operation = "admin"
+
+	// AllKeysets - the keyset scope allowing all keysets
+	AllKeysets string = "*"
+
+	// HandshakeCommand - the command authenticating the telnet and pickle connections ("auth <token>")
+	HandshakeCommand string = "auth"
+
+	cTokenSeparator         string        = "."
+	cDefaultRefreshInterval time.Duration = time.Minute
+)
+
+var operations = []Operation{OperationWrite, OperationRead, OperationDelete, OperationAdmin}
+
+// Token - a token and its permissions (the secret is never stored, only its hash)
+type Token struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Keysets    []string    `json:"keysets"`
+	Operations []Operation `json:"operations"`
+	Created    time.Time   `json:"creationDate"`
+	hash       string
+}
+
+// SharedSecret - the identity of the points received with the udp shared secret
+var SharedSecret = &Token{
+	ID:         "shared-secret",
+	Name:       "udp shared secret",
+	Keysets:    []string{AllKeysets},
+	Operations: []Operation{OperationWrite},
+}
+
+// Allows - checks if the token allows the operation on the keyset (an empty keyset requires all keysets)
+func (t *Token) Allows(keyset string, op Operation) bool {
+
+	if !t.AllowsOperation(op) {
+		return false
+	}
+
+	for _, k := range t.Keysets {
+		if k == AllKeysets || (keyset != constants.StringsEmpty && k == keyset) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsOperation - checks if the token allows the operation on any keyset
+func (t *Token) AllowsOperation(op Operation) bool {
+
+	for _, o := range t.Operations {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Service - authenticates and authorizes the tokens (a nil service allows everything)
+type Service struct {
+	session         *gocql.Session
+	keyspace        string
+	conf            *structs.AuthConfiguration
+	admin           *Token
+	udpSecret       []byte
+	tokens          map[string]*Token
+	mutex           sync.RWMutex
+	logger          *logh.ContextualLogger
+	timelineManager *tlmanager.Instance
+}
+
+// New - creates the service and the token table, nil is returned if the authentication is disabled
+func New(conf *structs.AuthConfiguration, session *gocql.Session, keyspace string, timelineManager *tlmanager.Instance) (*Service, error) {
+
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	refreshInterval := conf.RefreshInterval.Duration
+	if refreshInterval <= 0 {
+		refreshInterval = cDefaultRefreshInterval
+	}
+
+	s := &Service{
+		session:         session,
+		keyspace:        keyspace,
+		conf:            conf,
+		tokens:          map[string]*Token{},
+		logger:          logh.CreateContextualLogger(constants.StringsPKG, cPackage),
+		timelineManager: timelineManager,
+	}
+
+	if conf.AdminToken != constants.StringsEmpty {
+		s.admin = &Token{
+			ID:         "admin",
+			Name:       "configured admin token",
+			Keysets:    []string{AllKeysets},
+			Operations: operations,
+			hash:       hashSecret(conf.AdminToken),
+		}
+	}
+
+	if conf.UDPSecret != constants.StringsEmpty {
+		s.udpSecret = []byte(conf.UDPSecret)
+	} else if logh.WarnEnabled {
+		s.logger.Warn().Msg("no udp shared secret configured, all udp packets will be rejected")
+	}
+
+	if err := session.Query(s.cql(cqlCreateTokenTable)).Exec(); err != nil {
+		return nil, fmt.Errorf("error creating the token table: %s", err.Error())
+	}
+
+	if err := s.cacheTokens(); err != nil {
+		return nil, fmt.Errorf("error loading the tokens: %s", err.Error())
+	}
+
+	go func() {
+		for {
+			<-time.After(refreshInterval)
+			if err := s.cacheTokens(); err != nil && logh.ErrorEnabled {
+				s.logger.Error().Str(constants.StringsFunc, "cacheTokens").Err(err).Msg("error refreshing the cached tokens")
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+// hashSecret - returns the hex encoded sha256 of the secret
+func hashSecret(secret string) string {
+
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// equalHashes - compares the hashes in constant time
+func equalHashes(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+const cFuncAuthenticate string = "Authenticate"
+
+// Authenticate - returns the token identified by the raw token "<id>.<secret>" (or the configured admin token)
+func (s *Service) Authenticate(raw string) (*Token, gobol.Error) {
+
+	if raw == constants.StringsEmpty {
+		return nil, errUnauthorized(cFuncAuthenticate, "no token informed")
+	}
+
+	if s.admin != nil && equalHashes(hashSecret(raw), s.admin.hash) {
+		return s.admin, nil
+	}
+
+	sep := strings.Index(raw, cTokenSeparator)
+	if sep <= 0 {
+		return nil, errUnauthorized(cFuncAuthenticate, "invalid token")
+	}
+
+	s.mutex.RLock()
+	token, ok := s.tokens[raw[:sep]]
+	s.mutex.RUnlock()
+
+	if !ok || !equalHashes(hashSecret(raw[sep+1:]), token.hash) {
+		return nil, errUnauthorized(cFuncAuthenticate, "invalid token")
+	}
+
+	return token, nil
+}
+
+const cFuncHandshake string = "Handshake"
+
+// Handshake - authenticates the connection handshake line ("auth <token>")
+func (s *Service) Handshake(line string) (*Token, gobol.Error) {
+
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != HandshakeCommand {
+		return nil, errUnauthorized(cFuncHandshake, "authentication required, the first line must be: "+HandshakeCommand+" <token>")
+	}
+
+	return s.Authenticate(fields[1])
+}
+
+// IsHandshake - checks if the line is an authentication handshake
+func IsHandshake(line string) bool {
+	return strings.HasPrefix(line, HandshakeCommand+" ")
+}
+
+const cFuncAuthorize string = "Authorize"
+
+// Authorize - checks if the token allows the operation on the keyset
+func (s *Service) Authorize(token *Token, keyset string, op Operation, source string) gobol.Error {
+
+	if s == nil {
+		return nil
+	}
+
+	if token == nil {
+		s.statsDenied(source, op, constants.StringsEmpty)
+		return errUnauthorized(cFuncAuthorize, "no token informed")
+	}
+
+	if !token.Allows(keyset, op) {
+		s.statsDenied(source, op, token.ID)
+		if keyset == constants.StringsEmpty {
+			return errForbidden(cFuncAuthorize, fmt.Sprintf("the token does not allow the operation \"%s\" on all keysets", op))
+		}
+		return errForbidden(cFuncAuthorize, fmt.Sprintf("the token does not allow the operation \"%s\" on keyset \"%s\"", op, keyset))
+	}
+
+	return nil
+}
+
+// VerifySecret - checks the shared secret in the first line of the udp packet, returning the packet without it
+func (s *Service) VerifySecret(packet []byte) ([]byte, bool) {
+
+	if s == nil {
+		return packet, true
+	}
+
+	if len(s.udpSecret) == 0 {
+		return nil, false
+	}
+
+	line := len(s.udpSecret)
+	if len(packet) <= line || packet[line] != '\n' || subtle.ConstantTimeCompare(packet[:line], s.udpSecret) != 1 {
+		return nil, false
+	}
+
+	return packet[line+1:], true
+}
+
+// Enabled - checks if the authentication is enabled
+func (s *Service) Enabled() bool {
+	return s != nil
+}
+
+// NodeToken - the token sent on the requests to the other nodes (the configured admin token)
+func (s *Service) NodeToken() string {
+
+	if s == nil {
+		return constants.StringsEmpty
+	}
+
+	return s.conf.AdminToken
+}
+
+type contextKey struct{}
+
+// WithToken - returns a context carrying the token
+func WithToken(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, token)
+}
+
+// FromContext - returns the token of the context (nil if there is none)
+func FromContext(ctx context.Context) *Token {
+
+	token, _ := ctx.Value(contextKey{}).(*Token)
+
+	return token
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// newTestService - a service with the admin token "root" and the cached token "t1.secret"
+// writing on keyset "ks" and reading all keysets
+func newTestService() *Service {
+
+	return &Service{
+		conf: &structs.AuthConfiguration{Enabled: true},
+		admin: &Token{
+			ID:         "admin",
+			Keysets:    []string{AllKeysets},
+			Operations: operations,
+			hash:       hashSecret("root"),
+		},
+		udpSecret: []byte("s3cr3t"),
+		tokens: map[string]*Token{
+			"t1": {ID: "t1", Name: "writer", Keysets: []string{"ks"}, Operations: []Operation{OperationWrite}, hash: hashSecret("secret")},
+			"t2": {ID: "t2", Name: "reader", Keysets: []string{AllKeysets}, Operations: []Operation{OperationRead}, hash: hashSecret("other")},
+		},
+		timelineManager: &tlmanager.Instance{},
+	}
+}
+
+func TestTokenAllows(t *testing.T) {
+
+	writer := &Token{Keysets: []string{"ks", "other"}, Operations: []Operation{OperationWrite}}
+
+	assert.True(t, writer.Allows("ks", OperationWrite))
+	assert.True(t, writer.Allows("other", OperationWrite))
+	assert.False(t, writer.Allows("ks", OperationRead), "not allowed operation")
+	assert.False(t, writer.Allows("unknown", OperationWrite), "not allowed keyset")
+	assert.False(t, writer.Allows(constants.StringsEmpty, OperationWrite), "not all keysets")
+
+	all := &Token{Keysets: []string{AllKeysets}, Operations: []Operation{OperationRead}}
+
+	assert.True(t, all.Allows("ks", OperationRead))
+	assert.True(t, all.Allows(constants.StringsEmpty, OperationRead))
+	assert.True(t, all.AllowsOperation(OperationRead))
+	assert.False(t, all.AllowsOperation(OperationAdmin))
+}
+
+func TestAuthenticate(t *testing.T) {
+
+	s := newTestService()
+
+	token, gerr := s.Authenticate("root")
+	assert.NoError(t, gerr)
+	assert.Equal(t, "admin", token.ID)
+
+	token, gerr = s.Authenticate("t1.secret")
+	assert.NoError(t, gerr)
+	assert.Equal(t, "writer", token.Name)
+
+	for _, raw := range []string{"", "t1", ".secret", "t1.other", "t3.secret", "t1.secret."} {
+		_, gerr := s.Authenticate(raw)
+		if assert.Error(t, gerr, raw) {
+			assert.Equal(t, http.StatusUnauthorized, gerr.StatusCode(), raw)
+		}
+	}
+
+	token, gerr = s.Handshake("auth t1.secret")
+	assert.NoError(t, gerr)
+	assert.Equal(t, "t1", token.ID)
+
+	_, gerr = s.Handshake("put cpu 1 1 host=a")
+	assert.EqualError(t, gerr, "authentication required, the first line must be: auth <token>")
+
+	_, gerr = s.Handshake("auth t1.secret extra")
+	assert.Error(t, gerr)
+
+	assert.True(t, IsHandshake("auth t1.secret"))
+	assert.False(t, IsHandshake("authors 1"))
+}
+
+func TestAuthorize(t *testing.T) {
+
+	s := newTestService()
+	writer, _ := s.GetToken("t1")
+
+	assert.NoError(t, s.Authorize(writer, "ks", OperationWrite, "telnet"))
+
+	gerr := s.Authorize(writer, "other", OperationWrite, "telnet")
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusForbidden, gerr.StatusCode())
+		assert.Equal(t, `the token does not allow the operation "write" on keyset "other"`, gerr.Message())
+	}
+
+	gerr = s.Authorize(writer, constants.StringsEmpty, OperationWrite, "http")
+	assert.EqualError(t, gerr, `the token does not allow the operation "write" on all keysets`)
+
+	gerr = s.Authorize(nil, "ks", OperationWrite, "udp")
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusUnauthorized, gerr.StatusCode())
+	}
+
+	var disabled *Service
+	assert.NoError(t, disabled.Authorize(nil, "ks", OperationAdmin, "http"), "a disabled service allows everything")
+	assert.False(t, disabled.Enabled())
+	assert.True(t, s.Enabled())
+}
+
+func TestVerifySecret(t *testing.T) {
+
+	s := newTestService()
+
+	packet, ok := s.VerifySecret([]byte("s3cr3t\n{\"metric\":\"cpu\"}"))
+	assert.True(t, ok)
+	assert.Equal(t, `{"metric":"cpu"}`, string(packet))
+
+	for _, invalid := range []string{"s3cr3t", "s3cr3t{}", "s3cr3x\n{}", "other\n{}", ""} {
+		_, ok := s.VerifySecret([]byte(invalid))
+		assert.False(t, ok, invalid)
+	}
+
+	s.udpSecret = nil
+	_, ok = s.VerifySecret([]byte("\n{}"))
+	assert.False(t, ok, "all packets are dropped without a configured secret")
+
+	var disabled *Service
+	packet, ok = disabled.VerifySecret([]byte("{}"))
+	assert.True(t, ok)
+	assert.Equal(t, "{}", string(packet))
+}
+
+func TestCreateTokenInvalid(t *testing.T) {
+
+	s := newTestService()
+
+	invalid := []tokenRequest{
+		{Keysets: []string{"ks"}, Operations: []Operation{OperationRead}},
+		{Name: "x", Operations: []Operation{OperationRead}},
+		{Name: "x", Keysets: []string{"ks"}},
+		{Name: "x", Keysets: []string{"ks"}, Operations: []Operation{OperationRead, "drop"}},
+	}
+
+	for _, req := range invalid {
+		_, _, gerr := s.CreateToken(req.Name, req.Keysets, req.Operations)
+		if assert.Error(t, gerr, "%+v", req) {
+			assert.Equal(t, http.StatusBadRequest, gerr.StatusCode())
+		}
+	}
+
+	gerr := s.DeleteToken("t3")
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusNotFound, gerr.StatusCode())
+	}
+
+	tokens := s.ListTokens()
+	if assert.Len(t, tokens, 2) {
+		assert.Equal(t, "reader", tokens[0].Name, "sorted by name")
+		assert.Equal(t, "writer", tokens[1].Name)
+	}
+}
+
+func TestHandleMiddlewares(t *testing.T) {
+
+	s := newTestService()
+
+	var received *Token
+	handler := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		received = FromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	router := httprouter.New()
+	router.GET("/keysets/:keyset/read", s.Handle(OperationRead, handler))
+	router.POST("/keysets/:keyset/write", s.Handle(OperationWrite, handler))
+	router.POST("/put", s.HandleAny(OperationWrite, handler))
+	router.POST("/admin", s.HandleGlobal(OperationAdmin, handler))
+
+	checks := []struct {
+		method, path, header string
+		status               int
+	}{
+		{http.MethodGet, "/keysets/ks/read", "Bearer t2.other", http.StatusNoContent},
+		{http.MethodGet, "/keysets/ks/read", "Bearer t1.secret", http.StatusForbidden},
+		{http.MethodGet, "/keysets/ks/read", "", http.StatusUnauthorized},
+		{http.MethodGet, "/keysets/ks/read", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{http.MethodGet, "/keysets/ks/read", "Bearer t2.wrong", http.StatusUnauthorized},
+		{http.MethodPost, "/keysets/ks/write", "Bearer t1.secret", http.StatusNoContent},
+		{http.MethodPost, "/keysets/other/write", "Bearer t1.secret", http.StatusForbidden},
+		{http.MethodPost, "/put", "Bearer t1.secret", http.StatusNoContent},
+		{http.MethodPost, "/put", "Bearer t2.other", http.StatusForbidden},
+		{http.MethodPost, "/put", "", http.StatusUnauthorized},
+		{http.MethodPost, "/admin", "Bearer t1.secret", http.StatusForbidden},
+		{http.MethodPost, "/admin", "Bearer root", http.StatusNoContent},
+	}
+
+	for _, check := range checks {
+
+		r := httptest.NewRequest(check.method, check.path, nil)
+		if check.header != "" {
+			r.Header.Set("Authorization", check.header)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, check.status, w.Code, "%s %s (%s)", check.method, check.path, check.header)
+	}
+
+	assert.Equal(t, "admin", received.ID, "the token is passed to the handler")
+
+	var disabled *Service
+	w := httptest.NewRecorder()
+	disabled.HandleGlobal(OperationAdmin, handler)(w, httptest.NewRequest(http.MethodPost, "/admin", nil), nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Nil(t, received, "no token without the authentication")
+}
+
+func TestTokenEndpoints(t *testing.T) {
+
+	s := newTestService()
+
+	router := httprouter.New()
+	router.GET("/admin/tokens/:id", s.GetTokenREST)
+	router.POST("/admin/tokens", s.CreateTokenREST)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/tokens/t1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	token := map[string]interface{}{}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &token)) {
+		assert.Equal(t, "writer", token["name"])
+		assert.NotContains(t, w.Body.String(), hashSecret("secret"), "the hash is never returned")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/tokens/t3", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/tokens", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var disabled *Service
+	w = httptest.NewRecorder()
+	disabled.ListTokensREST(w, httptest.NewRequest(http.MethodGet, "/admin/tokens", nil), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNodeToken(t *testing.T) {
+
+	s := newTestService()
+	assert.Empty(t, s.NodeToken())
+
+	s.conf.AdminToken = "root"
+	assert.Equal(t, "root", s.NodeToken(), "the other nodes are requested with the admin token")
+
+	var disabled *Service
+	assert.Empty(t, disabled.NodeToken())
+}
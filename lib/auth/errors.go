@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+const (
+	cPackage string = "auth"
+)
+
+func errBasic(function, message string, code int, e error) gobol.Error {
+	if e != nil {
+		return tserr.New(
+			e,
+			message,
+			cPackage,
+			function,
+			code,
+		)
+	}
+	return nil
+}
+
+func errBadRequest(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusBadRequest, errors.New(message))
+}
+
+func errUnauthorized(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusUnauthorized, errors.New(message))
+}
+
+func errForbidden(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusForbidden, errors.New(message))
+}
+
+func errNotFound(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusNotFound, errors.New(message))
+}
+
+func errInternalServer(function string, e error) gobol.Error {
+	return errBasic(function, e.Error(), http.StatusInternalServerError, e)
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// HTTP middlewares enforcing the tokens and the admin endpoints to manage them.
+//
+
+const (
+	cFuncHandle      string = "Handle"
+	cFuncCreateRest  string = "CreateTokenREST"
+	cFuncGetToken    string = "GetTokenREST"
+	cFuncDeleteRest  string = "DeleteTokenREST"
+	cMsgAuthDisabled string = "the authentication is disabled"
+	cBearerPrefix    string = "Bearer "
+)
+
+// authenticate - authenticates the request bearer token (a nil token is returned if it is missing)
+func (s *Service) authenticate(r *http.Request) (*Token, bool) {
+
+	header := r.Header.Get("Authorization")
+	if header == constants.StringsEmpty {
+		return nil, true
+	}
+
+	if !strings.HasPrefix(header, cBearerPrefix) {
+		return nil, false
+	}
+
+	token, gerr := s.Authenticate(strings.TrimSpace(header[len(cBearerPrefix):]))
+	if gerr != nil {
+		return nil, false
+	}
+
+	return token, true
+}
+
+// wrap - authenticates the request and checks the permission before calling the handler
+func (s *Service) wrap(op Operation, h httprouter.Handle, allowed func(token *Token, ps httprouter.Params) gobol.Error) httprouter.Handle {
+
+	if s == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+		token, ok := s.authenticate(r)
+		if !ok {
+			s.statsDenied(constants.StringsHTTP, op, constants.StringsEmpty)
+			rip.Fail(w, errUnauthorized(cFuncHandle, "invalid token"))
+			return
+		}
+
+		if gerr := allowed(token, ps); gerr != nil {
+			rip.Fail(w, gerr)
+			return
+		}
+
+		h(w, r.WithContext(WithToken(r.Context(), token)), ps)
+	}
+}
+
+// Handle - requires the operation on the route keyset (or on all keysets if the route has no keyset)
+func (s *Service) Handle(op Operation, h httprouter.Handle) httprouter.Handle {
+
+	return s.wrap(op, h, func(token *Token, ps httprouter.Params) gobol.Error {
+		return s.Authorize(token, ps.ByName(constants.StringsKeyset), op, constants.StringsHTTP)
+	})
+}
+
+// HandleGlobal - requires the operation on all keysets
+func (s *Service) HandleGlobal(op Operation, h httprouter.Handle) httprouter.Handle {
+
+	return s.wrap(op, h, func(token *Token, ps httprouter.Params) gobol.Error {
+		return s.Authorize(token, constants.StringsEmpty, op, constants.StringsHTTP)
+	})
+}
+
+// HandleAny - requires the operation on any keyset, the handler must authorize each keyset it touches
+func (s *Service) HandleAny(op Operation, h httprouter.Handle) httprouter.Handle {
+
+	return s.wrap(op, h, func(token *Token, ps httprouter.Params) gobol.Error {
+
+		if token == nil {
+			s.statsDenied(constants.StringsHTTP, op, constants.StringsEmpty)
+			return errUnauthorized(cFuncAuthorize, "no token informed")
+		}
+
+		if !token.AllowsOperation(op) {
+			s.statsDenied(constants.StringsHTTP, op, token.ID)
+			return errForbidden(cFuncAuthorize, "the token does not allow the operation \""+string(op)+"\"")
+		}
+
+		return nil
+	})
+}
+
+// tokenRequest - the body of the token creation
+type tokenRequest struct {
+	Name       string      `json:"name"`
+	Keysets    []string    `json:"keysets"`
+	Operations []Operation `json:"operations"`
+}
+
+// createdToken - the created token, the raw token is only returned here
+type createdToken struct {
+	*Token
+	Raw string `json:"token"`
+}
+
+// CreateTokenREST - creates a new token (POST /admin/tokens)
+func (s *Service) CreateTokenREST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if s == nil {
+		rip.Fail(w, errNotFound(cFuncCreateRest, cMsgAuthDisabled))
+		return
+	}
+
+	defer r.Body.Close()
+
+	req := tokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rip.Fail(w, errBadRequest(cFuncCreateRest, "invalid token request: "+err.Error()))
+		return
+	}
+
+	token, raw, gerr := s.CreateToken(req.Name, req.Keysets, req.Operations)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusCreated, createdToken{Token: token, Raw: raw})
+}
+
+// ListTokensREST - lists the tokens without their secrets (GET /admin/tokens)
+func (s *Service) ListTokensREST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if s == nil {
+		rip.Fail(w, errNotFound(cFuncGetToken, cMsgAuthDisabled))
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, s.ListTokens())
+}
+
+// GetTokenREST - returns a token without its secret (GET /admin/tokens/:id)
+func (s *Service) GetTokenREST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if s == nil {
+		rip.Fail(w, errNotFound(cFuncGetToken, cMsgAuthDisabled))
+		return
+	}
+
+	token, ok := s.GetToken(ps.ByName("id"))
+	if !ok {
+		rip.Fail(w, errNotFound(cFuncGetToken, "token not found: "+ps.ByName("id")))
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, token)
+}
+
+// DeleteTokenREST - revokes a token (DELETE /admin/tokens/:id)
+func (s *Service) DeleteTokenREST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if s == nil {
+		rip.Fail(w, errNotFound(cFuncDeleteRest, cMsgAuthDisabled))
+		return
+	}
+
+	if gerr := s.DeleteToken(ps.ByName("id")); gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.Success(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"github.com/uol/mycenae/lib/constants"
+)
+
+const (
+	// metricDenied - metric name for the requests and points denied by the authorization
+	metricDenied string = "auth.denied"
+
+	cNoToken string = "none"
+)
+
+// statsDenied - counts a denied operation
+func (s *Service) statsDenied(source string, op Operation, tokenID string) {
+
+	if tokenID == constants.StringsEmpty {
+		tokenID = cNoToken
+	}
+
+	s.timelineManager.FlattenCountIncN(
+		cFuncAuthorize,
+		metricDenied,
+		constants.StringsSource, source,
+		constants.StringsOperation, string(op),
+		"token", tokenID,
+	)
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+const (
+	cqlCreateTokenTable = `CREATE TABLE IF NOT EXISTS %s.ts_auth_token (
+		id text PRIMARY KEY, name text, token_hash text, keysets set<text>, operations set<text>, creation_date timestamp)`
+	cqlInsertToken  = `INSERT INTO %s.ts_auth_token (id, name, token_hash, keysets, operations, creation_date) VALUES (?, ?, ?, ?, ?, ?)`
+	cqlDeleteToken  = `DELETE FROM %s.ts_auth_token WHERE id = ?`
+	cqlSelectTokens = `SELECT id, name, token_hash, keysets, operations, creation_date FROM %s.ts_auth_token`
+
+	cIDBytes     int = 8
+	cSecretBytes int = 24
+)
+
+// cql - formats the statement with the keyspace
+func (s *Service) cql(statement string) string {
+	return fmt.Sprintf(statement, s.keyspace)
+}
+
+// cacheTokens - loads all tokens from scylla replacing the cached ones
+func (s *Service) cacheTokens() error {
+
+	iter := s.session.Query(s.cql(cqlSelectTokens)).Iter()
+
+	tokens := map[string]*Token{}
+
+	var id, name, hash string
+	var keysets, ops []string
+	var created time.Time
+
+	for iter.Scan(&id, &name, &hash, &keysets, &ops, &created) {
+
+		token := &Token{
+			ID:         id,
+			Name:       name,
+			Keysets:    keysets,
+			Operations: make([]Operation, len(ops)),
+			Created:    created,
+			hash:       hash,
+		}
+
+		for i, op := range ops {
+			token.Operations[i] = Operation(op)
+		}
+
+		tokens[id] = token
+		keysets, ops = nil, nil
+	}
+
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.tokens = tokens
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// randomHex - returns n random bytes hex encoded
+func randomHex(n int) (string, error) {
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return constants.StringsEmpty, err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+const cFuncCreateToken string = "CreateToken"
+
+// CreateToken - stores a new token returning it and its raw value (which is never stored)
+func (s *Service) CreateToken(name string, keysets []string, ops []Operation) (*Token, string, gobol.Error) {
+
+	if name == constants.StringsEmpty {
+		return nil, constants.StringsEmpty, errBadRequest(cFuncCreateToken, "the token name is required")
+	}
+
+	if len(keysets) == 0 {
+		return nil, constants.StringsEmpty, errBadRequest(cFuncCreateToken, "at least one keyset is required")
+	}
+
+	if len(ops) == 0 {
+		return nil, constants.StringsEmpty, errBadRequest(cFuncCreateToken, "at least one operation is required")
+	}
+
+	opStrs := make([]string, len(ops))
+	for i, op := range ops {
+		if !validOperation(op) {
+			return nil, constants.StringsEmpty, errBadRequest(cFuncCreateToken, fmt.Sprintf("unknown operation: %s", op))
+		}
+		opStrs[i] = string(op)
+	}
+
+	id, err := randomHex(cIDBytes)
+	if err != nil {
+		return nil, constants.StringsEmpty, errInternalServer(cFuncCreateToken, err)
+	}
+
+	secret, err := randomHex(cSecretBytes)
+	if err != nil {
+		return nil, constants.StringsEmpty, errInternalServer(cFuncCreateToken, err)
+	}
+
+	token := &Token{
+		ID:         id,
+		Name:       name,
+		Keysets:    keysets,
+		Operations: ops,
+		Created:    time.Now().UTC().Truncate(time.Millisecond),
+		hash:       hashSecret(secret),
+	}
+
+	if err := s.session.Query(s.cql(cqlInsertToken), token.ID, token.Name, token.hash, keysets, opStrs, token.Created).Consistency(gocql.Quorum).Exec(); err != nil {
+		return nil, constants.StringsEmpty, errInternalServer(cFuncCreateToken, err)
+	}
+
+	s.mutex.Lock()
+	s.tokens[token.ID] = token
+	s.mutex.Unlock()
+
+	return token, id + cTokenSeparator + secret, nil
+}
+
+// validOperation - checks if the operation is known
+func validOperation(op Operation) bool {
+
+	for _, o := range operations {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListTokens - returns all cached tokens sorted by name
+func (s *Service) ListTokens() []*Token {
+
+	s.mutex.RLock()
+	tokens := make([]*Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].Name == tokens[j].Name {
+			return tokens[i].ID < tokens[j].ID
+		}
+		return tokens[i].Name < tokens[j].Name
+	})
+
+	return tokens
+}
+
+// GetToken - returns a cached token
+func (s *Service) GetToken(id string) (*Token, bool) {
+
+	s.mutex.RLock()
+	token, ok := s.tokens[id]
+	s.mutex.RUnlock()
+
+	return token, ok
+}
+
+const cFuncDeleteToken string = "DeleteToken"
+
+// DeleteToken - revokes a token (the other nodes drop it on their next refresh)
+func (s *Service) DeleteToken(id string) gobol.Error {
+
+	if _, ok := s.GetToken(id); !ok {
+		return errNotFound(cFuncDeleteToken, fmt.Sprintf("token not found: %s", id))
+	}
+
+	if err := s.session.Query(s.cql(cqlDeleteToken), id).Consistency(gocql.Quorum).Exec(); err != nil {
+		return errInternalServer(cFuncDeleteToken, err)
+	}
+
+	s.mutex.Lock()
+	delete(s.tokens, id)
+	s.mutex.Unlock()
+
+	return nil
+}
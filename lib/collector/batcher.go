@@ -0,0 +1,329 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Groups the inserts by keyspace and partition in unlogged batches. Each batch has only one
+// partition, so the token aware policy sends it directly to one of the partition's replicas.
+//
+
+const (
+	cBatchDefaultMaxSize       int           = 100
+	cBatchDefaultMaxPending    int           = 100000
+	cBatchDefaultFlushInterval time.Duration = time.Second
+	cBatchDefaultWorkers       int           = 8
+	cBatchDefaultRetryInterval time.Duration = 100 * time.Millisecond
+	cFuncFlushBatch            string        = "flushBatch"
+)
+
+// batchKey - the partition of the batch
+type batchKey struct {
+	keyspace string
+	tsid     string
+	number   bool
+}
+
+// batchEntry - a point to be inserted, done is called when its batch is stored, fails or
+// is appended to the write ahead queue (errBatchSpilled)
+type batchEntry struct {
+	timestamp int64
+	value     interface{}
+	done      func(gerr gobol.Error)
+}
+
+// pendingBatch - the points of one partition
+type pendingBatch struct {
+	key     batchKey
+	entries []batchEntry
+}
+
+// writeBatcher - accumulates the points and flushes them in batches by size or time
+type writeBatcher struct {
+	cassandra     *gocql.Session
+	maxSize       int
+	maxPending    int
+	flushInterval time.Duration
+	maxRetries    int
+	retryInterval time.Duration
+	mutex         sync.Mutex
+	sending       sync.RWMutex
+	pending       map[batchKey][]batchEntry
+	numPending    int
+	closed        bool
+	batchChannel  chan pendingBatch
+	stopChannel   chan struct{}
+	waitGroup     sync.WaitGroup
+	logger        *logh.ContextualLogger
+	spill         func(pb pendingBatch) int
+}
+
+// newWriteBatcher - creates the batcher and starts the flush workers
+func newWriteBatcher(cass *gocql.Session, conf *structs.WriteBatchConfiguration) *writeBatcher {
+
+	wb := &writeBatcher{
+		cassandra:     cass,
+		maxSize:       conf.MaxBatchSize,
+		maxPending:    conf.MaxPendingPoints,
+		flushInterval: conf.FlushInterval.Duration,
+		maxRetries:    conf.MaxRetries,
+		retryInterval: conf.RetryInterval.Duration,
+		pending:       map[batchKey][]batchEntry{},
+		stopChannel:   make(chan struct{}),
+		logger:        logh.CreateContextualLogger(constants.StringsPKG, "collector/batcher"),
+	}
+
+	if wb.maxSize <= 0 {
+		wb.maxSize = cBatchDefaultMaxSize
+	}
+
+	if wb.maxPending <= 0 {
+		wb.maxPending = cBatchDefaultMaxPending
+	}
+
+	if wb.flushInterval <= 0 {
+		wb.flushInterval = cBatchDefaultFlushInterval
+	}
+
+	if wb.retryInterval <= 0 {
+		wb.retryInterval = cBatchDefaultRetryInterval
+	}
+
+	workers := conf.Workers
+	if workers <= 0 {
+		workers = cBatchDefaultWorkers
+	}
+
+	wb.batchChannel = make(chan pendingBatch, workers)
+
+	wb.waitGroup.Add(workers)
+	for i := 0; i < workers; i++ {
+		go wb.worker()
+	}
+
+	go wb.ticker()
+
+	return wb
+}
+
+// add - adds a point to the partition batch, returns false if the batcher is closed
+func (wb *writeBatcher) add(keyspace, tsid string, number bool, timestamp int64, value interface{}, done func(gerr gobol.Error)) bool {
+
+	key := batchKey{
+		keyspace: keyspace,
+		tsid:     tsid,
+		number:   number,
+	}
+
+	var ready []pendingBatch
+
+	wb.sending.RLock()
+	defer wb.sending.RUnlock()
+
+	wb.mutex.Lock()
+
+	if wb.closed {
+		wb.mutex.Unlock()
+		return false
+	}
+
+	wb.pending[key] = append(wb.pending[key], batchEntry{
+		timestamp: timestamp,
+		value:     value,
+		done:      done,
+	})
+
+	wb.numPending++
+
+	if wb.numPending >= wb.maxPending {
+		ready = wb.takeAll()
+	} else if len(wb.pending[key]) >= wb.maxSize {
+		ready = []pendingBatch{{key: key, entries: wb.pending[key]}}
+		wb.numPending -= len(wb.pending[key])
+		delete(wb.pending, key)
+	}
+
+	wb.mutex.Unlock()
+
+	for _, batch := range ready {
+		wb.batchChannel <- batch
+	}
+
+	return true
+}
+
+// takeAll - removes all pending batches (must be called with the lock)
+func (wb *writeBatcher) takeAll() []pendingBatch {
+
+	ready := make([]pendingBatch, 0, len(wb.pending))
+
+	for key, entries := range wb.pending {
+		ready = append(ready, pendingBatch{key: key, entries: entries})
+	}
+
+	wb.pending = map[batchKey][]batchEntry{}
+	wb.numPending = 0
+
+	return ready
+}
+
+// flush - sends all pending batches to the workers
+func (wb *writeBatcher) flush() {
+
+	wb.sending.RLock()
+	defer wb.sending.RUnlock()
+
+	wb.mutex.Lock()
+	if wb.closed {
+		wb.mutex.Unlock()
+		return
+	}
+	ready := wb.takeAll()
+	wb.mutex.Unlock()
+
+	for _, batch := range ready {
+		wb.batchChannel <- batch
+	}
+}
+
+// ticker - flushes the pending batches by time
+func (wb *writeBatcher) ticker() {
+
+	ticker := time.NewTicker(wb.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			wb.flush()
+		case <-wb.stopChannel:
+			return
+		}
+	}
+}
+
+// worker - executes the batches
+func (wb *writeBatcher) worker() {
+
+	defer wb.waitGroup.Done()
+
+	for batch := range wb.batchChannel {
+		wb.execute(batch)
+	}
+}
+
+// execute - executes the unlogged batch retrying if it fails
+func (wb *writeBatcher) execute(pb pendingBatch) {
+
+	query := fmtInsertTextQuery
+	if pb.key.number {
+		query = fmtInsertNumberQuery
+	}
+
+	query = fmt.Sprintf(query, pb.key.keyspace)
+
+	for offset := 0; offset < len(pb.entries); offset += wb.maxSize {
+
+		last := offset + wb.maxSize
+		if last > len(pb.entries) {
+			last = len(pb.entries)
+		}
+
+		batch := wb.cassandra.NewBatch(gocql.UnloggedBatch)
+		for _, entry := range pb.entries[offset:last] {
+			batch.Query(query, pb.key.tsid, entry.timestamp, entry.value)
+		}
+
+		var err error
+
+		for attempt := 0; attempt <= wb.maxRetries; attempt++ {
+
+			if attempt > 0 {
+				statsBatchRetry(pb.key.keyspace)
+				time.Sleep(time.Duration(attempt) * wb.retryInterval)
+			}
+
+			start := time.Now()
+
+			if err = wb.cassandra.ExecuteBatch(batch); err == nil {
+				statsInsertQuery(pb.key.keyspace, time.Since(start))
+				statsBatchSize(pb.key.keyspace, last-offset)
+				break
+			}
+		}
+
+		entries := pb.entries[offset:last]
+
+		if err == nil {
+			for _, entry := range entries {
+				entry.done(nil)
+			}
+			continue
+		}
+
+		statsInsertQueryError(pb.key.keyspace)
+
+		// the points appended to the write ahead queue are stored by its replay
+		spilled := 0
+		if wb.spill != nil {
+			spilled = wb.spill(pendingBatch{key: pb.key, entries: entries})
+			for _, entry := range entries[:spilled] {
+				entry.done(errBatchSpilled)
+			}
+		}
+
+		if spilled == len(entries) {
+			continue
+		}
+
+		statsBatchError(pb.key.keyspace, len(entries)-spilled)
+
+		if logh.ErrorEnabled {
+			wb.logger.Error().Err(err).Str(constants.StringsFunc, cFuncFlushBatch).Str("tsid", pb.key.tsid).Str("ksid", pb.key.keyspace).Int("points", len(entries)-spilled).Send()
+		}
+
+		statsInsertRollback(pb.key.keyspace)
+
+		gerr := errPersist(cFuncFlushBatch, err)
+		for _, entry := range entries[spilled:] {
+			entry.done(gerr)
+		}
+	}
+}
+
+// close - flushes the pending batches and waits the workers to finish
+func (wb *writeBatcher) close() {
+
+	wb.mutex.Lock()
+	if wb.closed {
+		wb.mutex.Unlock()
+		return
+	}
+	wb.closed = true
+	ready := wb.takeAll()
+	wb.mutex.Unlock()
+
+	close(wb.stopChannel)
+
+	wb.sending.Lock()
+
+	for _, batch := range ready {
+		wb.batchChannel <- batch
+	}
+
+	close(wb.batchChannel)
+
+	wb.sending.Unlock()
+
+	wb.waitGroup.Wait()
+}
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/funks"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// newTestBatcher - creates a batcher without workers, the batches stay in the channel
+func newTestBatcher(maxSize, maxPending int) *writeBatcher {
+
+	return &writeBatcher{
+		maxSize:      maxSize,
+		maxPending:   maxPending,
+		pending:      map[batchKey][]batchEntry{},
+		batchChannel: make(chan pendingBatch, 100),
+		stopChannel:  make(chan struct{}),
+	}
+}
+
+// sentBatches - drains the batches sent to the workers
+func sentBatches(wb *writeBatcher) map[batchKey][]batchEntry {
+
+	sent := map[batchKey][]batchEntry{}
+
+	for {
+		select {
+		case batch, ok := <-wb.batchChannel:
+			if !ok {
+				return sent
+			}
+			sent[batch.key] = append(sent[batch.key], batch.entries...)
+		default:
+			return sent
+		}
+	}
+}
+
+func TestWriteBatcherDefaults(t *testing.T) {
+
+	wb := newWriteBatcher(nil, &structs.WriteBatchConfiguration{})
+	defer wb.close()
+
+	assert.Equal(t, cBatchDefaultMaxSize, wb.maxSize)
+	assert.Equal(t, cBatchDefaultMaxPending, wb.maxPending)
+	assert.Equal(t, cBatchDefaultFlushInterval, wb.flushInterval)
+	assert.Equal(t, cBatchDefaultRetryInterval, wb.retryInterval)
+	assert.Equal(t, cBatchDefaultWorkers, cap(wb.batchChannel))
+
+	wb = newWriteBatcher(nil, &structs.WriteBatchConfiguration{
+		MaxBatchSize:  5,
+		FlushInterval: funks.Duration{Duration: time.Minute},
+		Workers:       2,
+	})
+	defer wb.close()
+
+	assert.Equal(t, 5, wb.maxSize)
+	assert.Equal(t, time.Minute, wb.flushInterval)
+	assert.Equal(t, 2, cap(wb.batchChannel))
+}
+
+func TestWriteBatcherFullPartition(t *testing.T) {
+
+	wb := newTestBatcher(3, 100)
+
+	number := batchKey{keyspace: "ks", tsid: "a", number: true}
+	text := batchKey{keyspace: "ks", tsid: "a", number: false}
+
+	for i := int64(1); i <= 3; i++ {
+		assert.True(t, wb.add("ks", "a", true, i, float64(i), nil))
+	}
+	assert.True(t, wb.add("ks", "a", false, 1, "up", nil))
+
+	sent := sentBatches(wb)
+
+	assert.Equal(t, map[batchKey][]batchEntry{
+		number: {{1, 1.0, nil}, {2, 2.0, nil}, {3, 3.0, nil}},
+	}, sent, "only the full partition is sent")
+
+	assert.Equal(t, []batchEntry{{1, "up", nil}}, wb.pending[text], "the text partition is not mixed with the number one")
+	assert.Equal(t, 1, wb.numPending)
+}
+
+func TestWriteBatcherMaxPending(t *testing.T) {
+
+	wb := newTestBatcher(100, 4)
+
+	for i, tsid := range []string{"a", "b", "c"} {
+		assert.True(t, wb.add("ks", tsid, true, int64(i), 1.0, nil))
+	}
+
+	assert.Empty(t, sentBatches(wb))
+	assert.Equal(t, 3, wb.numPending)
+
+	assert.True(t, wb.add("ks2", "a", true, 10, 2.0, nil))
+
+	sent := sentBatches(wb)
+	assert.Len(t, sent, 4, "all partitions are sent when the limit is reached")
+	assert.Equal(t, []batchEntry{{10, 2.0, nil}}, sent[batchKey{keyspace: "ks2", tsid: "a", number: true}])
+	assert.Empty(t, wb.pending)
+	assert.Zero(t, wb.numPending)
+}
+
+func TestWriteBatcherFlush(t *testing.T) {
+
+	wb := newTestBatcher(100, 100)
+
+	wb.flush()
+	assert.Empty(t, sentBatches(wb), "nothing to flush")
+
+	wb.add("ks", "a", true, 1, 1.0, nil)
+	wb.add("ks", "a", true, 2, 2.0, nil)
+	wb.add("ks", "b", false, 1, "x", nil)
+
+	wb.flush()
+
+	assert.Equal(t, map[batchKey][]batchEntry{
+		{keyspace: "ks", tsid: "a", number: true}:  {{1, 1.0, nil}, {2, 2.0, nil}},
+		{keyspace: "ks", tsid: "b", number: false}: {{1, "x", nil}},
+	}, sentBatches(wb))
+}
+
+func TestWriteBatcherClose(t *testing.T) {
+
+	wb := newTestBatcher(100, 100)
+
+	wb.add("ks", "a", true, 1, 1.0, nil)
+	wb.close()
+
+	sent := sentBatches(wb)
+	assert.Equal(t, []batchEntry{{1, 1.0, nil}}, sent[batchKey{keyspace: "ks", tsid: "a", number: true}], "the pending points are sent when closing")
+
+	_, open := <-wb.batchChannel
+	assert.False(t, open, "the workers are stopped")
+
+	assert.False(t, wb.add("ks", "a", true, 2, 2.0, nil), "the point must be inserted by the caller")
+	assert.Empty(t, wb.pending)
+
+	// closing twice does nothing
+	wb.close()
+	wb.flush()
+}
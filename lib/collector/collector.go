@@ -7,6 +7,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/uol/mycenae/lib/repair"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/validation"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/uol/hashing"
 	"github.com/uol/logh"
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/metadata"
 	tlmanager "github.com/uol/timelinemanager"
@@ -28,6 +30,7 @@ const (
 	cNumber              string = "number"
 	cText                string = "text"
 	cFuncHandleJSONBytes string = "HandleJSONBytes"
+	cFuncHandlePacket    string = "HandlePacket"
 )
 
 // New - creates a new Collector
@@ -38,6 +41,8 @@ func New(
 	set *structs.Settings,
 	keyspaceTTLMap map[int]string,
 	validation *validation.Service,
+	repair *repair.Service,
+	auth *auth.Service,
 ) (*Collector, error) {
 
 	timelineManager = tm
@@ -46,14 +51,41 @@ func New(
 		cassandra:      cass,
 		metaStorage:    metaStorage,
 		settings:       set,
-		jobChannel:     make(chan workerData, set.MaxConcurrentPoints),
+		queues:         newFairQueue(&set.FairQueue, set.MaxConcurrentPoints),
 		keyspaceTTLMap: keyspaceTTLMap,
 		logger:         logh.CreateContextualLogger(constants.StringsPKG, "collector"),
 		validation:     validation,
+		repair:         repair,
+		auth:           auth,
+		otlpDeltas:     newOTLPDeltaCache(set.OTLP.DeltaExpiration.Duration),
+	}
+
+	if set.LastSeen.Enabled {
+		collect.lastSeen = newLastSeenTracker(set.LastSeen.SampleInterval.Duration)
+	}
+
+	if set.WriteAheadQueue.Enabled {
+		wal, err := newWriteAheadQueue(&set.WriteAheadQueue, collect.replayRecord)
+		if err != nil {
+			return nil, err
+		}
+		collect.wal = wal
+	}
+
+	if set.WriteBatch.Enabled {
+		collect.batcher = newWriteBatcher(cass, &set.WriteBatch)
+		if collect.wal != nil {
+			collect.batcher.spill = collect.spillBatch
+		}
+
+		collect.completions = make(chan batchCompletion, set.MaxConcurrentPoints)
+		for i := 0; i < set.MaxConcurrentPoints; i++ {
+			go collect.completionWorker()
+		}
 	}
 
 	for i := 0; i < set.MaxConcurrentPoints; i++ {
-		go collect.worker(i, collect.jobChannel)
+		go collect.worker(i)
 	}
 
 	return collect, nil
@@ -67,11 +99,18 @@ type Collector struct {
 	settings    *structs.Settings
 
 	shutdown       bool
-	jobChannel     chan workerData
+	queues         *fairQueue
 	keyspaceTTLMap map[int]string
 
-	validation *validation.Service
-	logger     *logh.ContextualLogger
+	validation  *validation.Service
+	repair      *repair.Service
+	auth        *auth.Service
+	logger      *logh.ContextualLogger
+	otlpDeltas  *otlpDeltaCache
+	lastSeen    *lastSeenTracker
+	batcher     *writeBatcher
+	completions chan batchCompletion
+	wal         *writeAheadQueue
 }
 
 type workerData struct {
@@ -79,6 +118,12 @@ type workerData struct {
 	source         *constants.SourceType
 }
 
+// batchCompletion - the result of a batched point, completed by the completion workers
+type batchCompletion struct {
+	done func(gerr gobol.Error)
+	gerr gobol.Error
+}
+
 func (collect *Collector) getType(number bool) string {
 	if number {
 		return cNumber
@@ -86,19 +131,48 @@ func (collect *Collector) getType(number bool) string {
 	return cText
 }
 
-func (collect *Collector) worker(id int, jobChannel <-chan workerData) {
+func (collect *Collector) worker(id int) {
 
-	for j := range jobChannel {
+	for {
+		j, q := collect.queues.pop()
 
-		err := collect.processPacket(j.validatedPoint)
-		if err != nil {
-			statsPointsError(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, j.validatedPoint.Message.TTL)
-			if logh.ErrorEnabled {
-				collect.logger.Error().Str(constants.StringsFunc, "worker").Err(err).Send()
-			}
-		} else {
-			statsPoints(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, j.validatedPoint.Message.TTL)
-		}
+		// the keyset slot is only released when the point is stored (a batched point is still running)
+		collect.processPacket(j.validatedPoint, true, func(gerr gobol.Error) {
+			collect.packetDone(j, gerr)
+			collect.queues.done(q)
+		})
+	}
+}
+
+// completionWorker - runs the completion of the batched points, keeping the metadata
+// lookups out of the batch flush workers
+func (collect *Collector) completionWorker() {
+
+	for c := range collect.completions {
+		c.done(c.gerr)
+	}
+}
+
+// packetDone - counts the stored point, the failed ones are appended to the write ahead queue
+// (the spilled ones were already appended by the batcher and are counted by its replay)
+func (collect *Collector) packetDone(j workerData, gerr gobol.Error) {
+
+	if gerr == nil {
+		statsPoints(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, j.validatedPoint.Message.TTL)
+		return
+	}
+
+	if gerr == errBatchSpilled {
+		return
+	}
+
+	if collect.enqueuePacket(j.validatedPoint, j.source, cWALReasonError) {
+		return
+	}
+
+	statsPointsError(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, j.validatedPoint.Message.TTL)
+	if logh.ErrorEnabled {
+		collect.logger.Error().Str(constants.StringsFunc, "worker").Err(gerr).Send()
 	}
 }
 
@@ -107,7 +181,20 @@ func (collect *Collector) Stop() {
 	collect.shutdown = true
 }
 
-func (collect *Collector) processPacket(point *Point) gobol.Error {
+// Shutdown - flushes the pending batched writes (the next points are inserted one by one) and stops the write ahead queue replay
+func (collect *Collector) Shutdown() {
+	if collect.batcher != nil {
+		collect.batcher.close()
+	}
+
+	if collect.wal != nil {
+		collect.wal.close()
+	}
+}
+
+// processPacket - saves the point and then its metadata, done is called with the result when the point is stored
+// (the batched points are completed by the batcher and it is not used when replaying the write ahead queue)
+func (collect *Collector) processPacket(point *Point, batched bool, done func(gerr gobol.Error)) {
 
 	start := time.Now()
 
@@ -117,30 +204,38 @@ func (collect *Collector) processPacket(point *Point) gobol.Error {
 		statsDelayedMetrics(point.Message.Keyset, pastTime)
 	}
 
-	var gerr gobol.Error
+	stored := func(gerr gobol.Error) {
 
-	if point.Number {
-		gerr = collect.saveValue(point)
-	} else {
-		gerr = collect.saveText(point)
-	}
+		// the spilled point is stored later by the replay, its metadata is saved now
+		if gerr == nil || gerr == errBatchSpilled {
+			if metaErr := collect.saveMeta(point); metaErr != nil {
+				gerr = metaErr
+			}
+		}
 
-	if gerr != nil {
-		return gerr
-	}
+		if gerr == nil {
+			statsProcTime(point.Message.Keyset, time.Since(start))
+		}
 
-	gerr = collect.saveMeta(point)
-	if gerr != nil {
-		return gerr
+		done(gerr)
 	}
 
-	statsProcTime(point.Message.Keyset, time.Since(start))
+	if batched && collect.completions != nil {
+		direct := stored
+		stored = func(gerr gobol.Error) {
+			collect.completions <- batchCompletion{done: direct, gerr: gerr}
+		}
+	}
 
-	return nil
+	if point.Number {
+		collect.saveValue(point, batched, stored)
+	} else {
+		collect.saveText(point, batched, stored)
+	}
 }
 
 // HandleJSONBytes - handles a point in byte format
-func (collect *Collector) HandleJSONBytes(data []byte, sourceType *constants.SourceType, ip string, isNumber bool) (int, gobol.Error) {
+func (collect *Collector) HandleJSONBytes(data []byte, sourceType *constants.SourceType, ip string, isNumber bool, token *auth.Token) (int, gobol.Error) {
 
 	points := structs.TSDBpoints{}
 	gerrs := []gobol.Error{}
@@ -170,7 +265,15 @@ func (collect *Collector) HandleJSONBytes(data []byte, sourceType *constants.Sou
 			return 0, err
 		}
 
-		collect.HandlePacket(vp, sourceType)
+		if gerr := collect.ValidateLimits(vp); gerr != nil {
+			collect.validation.StatsValidationError(cFuncHandleJSONBytes, p.Keyset, ip, sourceType, gerr)
+			return 0, gerr
+		}
+
+		if gerr := collect.HandlePacket(vp, sourceType, token); gerr != nil {
+			collect.validation.StatsValidationError(cFuncHandleJSONBytes, p.Keyset, ip, sourceType, gerr)
+			return 0, gerr
+		}
 	}
 
 	return len(points), nil
@@ -199,13 +302,47 @@ func (collect *Collector) MakePacket(rcvMsg *structs.TSDBpoint, number bool) (*P
 	return packet, nil
 }
 
-// HandlePacket - handles a point in struct format
-func (collect *Collector) HandlePacket(vp *Point, source *constants.SourceType) {
+// ValidateLimits - checks the keyset limits of a packet, the protocols call it after making the packet (the tsid is needed)
+func (collect *Collector) ValidateLimits(vp *Point) gobol.Error {
 
-	collect.jobChannel <- workerData{
+	metaType := cMetaTypeText
+	if vp.Number {
+		metaType = cMetaTypeNumber
+	}
+
+	return collect.validation.ValidateLimits(vp.Message, metaType, vp.ID, vp.HashID)
+}
+
+// HandlePacket - handles a point in struct format (already validated by its protocol), when the keyset queue is saturated the point goes
+// to the write ahead queue, is rejected (sources rejecting when saturated) or waits for space (only the sources of this keyset wait)
+func (collect *Collector) HandlePacket(vp *Point, source *constants.SourceType, token *auth.Token) gobol.Error {
+
+	gerr := collect.auth.Authorize(token, vp.Message.Keyset, auth.OperationWrite, source.Name)
+	if gerr != nil {
+		return gerr
+	}
+
+	data := workerData{
 		validatedPoint: vp,
 		source:         source,
 	}
+
+	if collect.queues.push(data, false) {
+		return nil
+	}
+
+	if collect.enqueuePacket(vp, source, cWALReasonFull) {
+		return nil
+	}
+
+	if source.RejectWhenSaturated {
+		statsQueueRejected(vp.Message.Keyset, source)
+		return errQueueSaturated(cFuncHandlePacket, vp.Message.Keyset)
+	}
+
+	collect.queues.push(data, true)
+
+	return nil
 }
 
 // GenerateID - generates the unique ID from a point
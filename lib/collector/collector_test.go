@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"testing"
+
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+// keysetBackend - a metadata backend knowing only the keysets, the other calls are not expected
+type keysetBackend struct {
+	metadata.Backend
+	keysets []string
+}
+
+// CheckKeyset - checks the known keysets
+func (kb *keysetBackend) CheckKeyset(keyset string) bool {
+
+	for _, k := range kb.keysets {
+		if k == keyset {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newTestCollector - creates a collector validating as the default configuration and keeping the handled points in the job channel
+func newTestCollector(t *testing.T, keysets ...string) *Collector {
+
+	v, err := validation.New(
+		&structs.ValidationConfiguration{
+			MaxTextValueSize: 10000,
+			MaxNumTags:       20,
+			PropertyRegexp:   `(?i)^[0-9a-z-\._\%\&\#\;\/]+$`,
+			KeysetNameRegexp: `(?i)^[a-z_]{1}[a-z0-9_\-]+[a-z0-9]{1}$`,
+			DefaultTTL:       1,
+			MaxPropertySize:  256,
+		},
+		&metadata.Storage{Backend: &keysetBackend{keysets: keysets}},
+		map[int]string{1: "ts01", 7: "ts07"},
+		&tlmanager.Instance{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the queue stats are sent to a not started manager
+	timelineManager = &tlmanager.Instance{}
+
+	return &Collector{
+		settings:   &structs.Settings{TSIDKeySize: 16},
+		queues:     newFairQueue(&structs.FairQueueConfiguration{QueueSize: 100}, 1),
+		validation: v,
+	}
+}
+
+// handledPoints - returns the points sent to the workers
+func handledPoints(collect *Collector) []*Point {
+
+	points := []*Point{}
+
+	for {
+		collect.queues.mutex.Lock()
+		pending := len(collect.queues.ring)
+		collect.queues.mutex.Unlock()
+
+		if pending == 0 {
+			return points
+		}
+
+		data, q := collect.queues.pop()
+		collect.queues.done(q)
+		points = append(points, data.validatedPoint)
+	}
+}
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/uol/gobol"
@@ -12,6 +13,7 @@ import (
 const (
 	cMakePacket  = "makePacket"
 	cWrongFormat = "Wrong JSON format"
+	cWrongProto  = "Wrong protobuf format"
 	cPackage     = "collector"
 )
 
@@ -49,6 +51,31 @@ func errUnmarshal(function string, e error) gobol.Error {
 	return errBadRequest(function, cWrongFormat, e)
 }
 
+func errDecode(function string, e error) gobol.Error {
+	return errBadRequest(function, cWrongProto, e)
+}
+
+func errUnsupportedMediaType(function, message string) gobol.Error {
+	return tserr.New(
+		errors.New(message),
+		message,
+		cPackage,
+		function,
+		http.StatusUnsupportedMediaType,
+	)
+}
+
+func errQueueSaturated(function, keyset string) gobol.Error {
+	message := fmt.Sprintf("the keyset %s queue is saturated, try again later", keyset)
+	return tserr.New(
+		errors.New(message),
+		message,
+		cPackage,
+		function,
+		http.StatusTooManyRequests,
+	)
+}
+
 func errPersist(function string, e error) gobol.Error {
 	return errInternalServerError(function, e.Error(), e)
 }
@@ -56,3 +83,13 @@ func errPersist(function string, e error) gobol.Error {
 func errMultipleErrors(function string, gerrs []gobol.Error) gobol.Error {
 	return gerrs[0]
 }
+
+// errBatchSpilled - the batch failed but its points were appended to the write ahead queue,
+// they are stored by its replay (not an error to the point's sender)
+var errBatchSpilled = tserr.New(
+	errors.New("the batch points were appended to the write ahead queue"),
+	"the batch points were appended to the write ahead queue",
+	cPackage,
+	cFuncFlushBatch,
+	http.StatusAccepted,
+)
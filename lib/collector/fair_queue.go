@@ -0,0 +1,232 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Per keyset queues served by weighted round robin, so a noisy keyset can not starve the others.
+// Each keyset has its own queue size, weight (points taken in a row) and concurrency cap.
+//
+
+const (
+	cFairQueueDefaultWeight int = 1
+)
+
+// keysetQueue - the pending points of a keyset
+type keysetQueue struct {
+	keyset         string
+	items          []workerData
+	maxSize        int
+	weight         int
+	maxConcurrency int
+	running        int
+	served         int
+	active         bool
+}
+
+// fairQueue - schedules the points of all keysets to the collector workers
+type fairQueue struct {
+	mutex     sync.Mutex
+	workCond  *sync.Cond
+	spaceCond *sync.Cond
+	queues    map[string]*keysetQueue
+	ring      []*keysetQueue
+	next      int
+	conf      *structs.FairQueueConfiguration
+	workers   int
+}
+
+// newFairQueue - creates the scheduler (the defaults keep the old shared channel limits)
+func newFairQueue(conf *structs.FairQueueConfiguration, workers int) *fairQueue {
+
+	fq := &fairQueue{
+		queues:  map[string]*keysetQueue{},
+		conf:    conf,
+		workers: workers,
+	}
+
+	fq.workCond = sync.NewCond(&fq.mutex)
+	fq.spaceCond = sync.NewCond(&fq.mutex)
+
+	return fq
+}
+
+// queue - returns the keyset queue, creating it if it does not exist (must be called with the lock)
+func (fq *fairQueue) queue(keyset string) *keysetQueue {
+
+	if q, ok := fq.queues[keyset]; ok {
+		return q
+	}
+
+	q := &keysetQueue{
+		keyset:         keyset,
+		maxSize:        fq.conf.QueueSize,
+		weight:         fq.conf.Weight,
+		maxConcurrency: fq.conf.MaxConcurrency,
+	}
+
+	if ksConf, ok := fq.conf.Keysets[keyset]; ok {
+
+		if ksConf.QueueSize > 0 {
+			q.maxSize = ksConf.QueueSize
+		}
+
+		if ksConf.Weight > 0 {
+			q.weight = ksConf.Weight
+		}
+
+		if ksConf.MaxConcurrency > 0 {
+			q.maxConcurrency = ksConf.MaxConcurrency
+		}
+	}
+
+	if q.maxSize <= 0 {
+		q.maxSize = fq.workers
+	}
+
+	if q.weight <= 0 {
+		q.weight = cFairQueueDefaultWeight
+	}
+
+	if q.maxConcurrency <= 0 || q.maxConcurrency > fq.workers {
+		q.maxConcurrency = fq.workers
+	}
+
+	fq.queues[keyset] = q
+
+	return q
+}
+
+// push - adds the point to its keyset queue, waiting for space if required (returns false if the queue is full)
+func (fq *fairQueue) push(data workerData, wait bool) bool {
+
+	keyset := data.validatedPoint.Message.Keyset
+
+	fq.mutex.Lock()
+	defer fq.mutex.Unlock()
+
+	q := fq.queue(keyset)
+
+	for len(q.items) >= q.maxSize {
+
+		if !wait {
+			return false
+		}
+
+		fq.spaceCond.Wait()
+
+		// the queue may have been removed while waiting
+		q = fq.queue(keyset)
+	}
+
+	q.items = append(q.items, data)
+
+	if !q.active {
+		q.active = true
+		fq.ring = append(fq.ring, q)
+	}
+
+	statsQueueSize(keyset, len(q.items))
+
+	fq.workCond.Signal()
+
+	return true
+}
+
+// pick - returns the next queue to be served by weighted round robin (must be called with the lock)
+func (fq *fairQueue) pick() *keysetQueue {
+
+	for i := 0; i < len(fq.ring); i++ {
+
+		if fq.next >= len(fq.ring) {
+			fq.next = 0
+		}
+
+		q := fq.ring[fq.next]
+
+		if q.running < q.maxConcurrency {
+
+			q.served++
+			if q.served >= q.weight {
+				q.served = 0
+				fq.next++
+			}
+
+			return q
+		}
+
+		q.served = 0
+		fq.next++
+	}
+
+	return nil
+}
+
+// deactivate - removes the empty queue from the round robin (must be called with the lock)
+func (fq *fairQueue) deactivate(q *keysetQueue) {
+
+	for i, rq := range fq.ring {
+
+		if rq != q {
+			continue
+		}
+
+		fq.ring = append(fq.ring[:i], fq.ring[i+1:]...)
+
+		if i < fq.next {
+			fq.next--
+		}
+
+		break
+	}
+
+	q.active = false
+	q.served = 0
+}
+
+// pop - waits for the next point to be processed, done must be called after processing it
+func (fq *fairQueue) pop() (workerData, *keysetQueue) {
+
+	fq.mutex.Lock()
+	defer fq.mutex.Unlock()
+
+	for {
+		q := fq.pick()
+		if q == nil {
+			fq.workCond.Wait()
+			continue
+		}
+
+		data := q.items[0]
+		q.items[0] = workerData{}
+		q.items = q.items[1:]
+		q.running++
+
+		if len(q.items) == 0 {
+			fq.deactivate(q)
+		}
+
+		fq.spaceCond.Broadcast()
+
+		return data, q
+	}
+}
+
+// done - releases the keyset concurrency slot
+func (fq *fairQueue) done(q *keysetQueue) {
+
+	fq.mutex.Lock()
+	defer fq.mutex.Unlock()
+
+	q.running--
+
+	if !q.active && q.running == 0 {
+		delete(fq.queues, q.keyset)
+	}
+
+	// a worker may be waiting for the concurrency cap
+	fq.workCond.Signal()
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func newTestFairQueue(conf structs.FairQueueConfiguration, workers int) *fairQueue {
+
+	timelineManager = &tlmanager.Instance{}
+
+	return newFairQueue(&conf, workers)
+}
+
+// pushPoints - pushes one point for each "keyset/id" without waiting
+func pushPoints(t *testing.T, fq *fairQueue, points ...string) {
+
+	for _, p := range points {
+		if !fq.push(queueData(p), false) {
+			t.Fatalf("queue full pushing %s", p)
+		}
+	}
+}
+
+func queueData(point string) workerData {
+
+	parts := strings.SplitN(point, "/", 2)
+
+	return workerData{
+		validatedPoint: &Point{
+			ID:      parts[1],
+			Message: &structs.TSDBpoint{Keyset: parts[0]},
+		},
+	}
+}
+
+// popOrder - pops n points releasing each one before the next
+func popOrder(fq *fairQueue, n int) []string {
+
+	order := make([]string, n)
+
+	for i := range order {
+		data, q := fq.pop()
+		order[i] = data.validatedPoint.Message.Keyset + "/" + data.validatedPoint.ID
+		fq.done(q)
+	}
+
+	return order
+}
+
+func TestFairQueueDefaults(t *testing.T) {
+
+	fq := newTestFairQueue(structs.FairQueueConfiguration{
+		MaxConcurrency: 10,
+		Keysets: map[string]structs.KeysetQueueConfiguration{
+			"big": {QueueSize: 50, Weight: 3, MaxConcurrency: 2},
+		},
+	}, 4)
+
+	fq.mutex.Lock()
+	defer fq.mutex.Unlock()
+
+	q := fq.queue("small")
+	assert.Equal(t, 4, q.maxSize, "the old channel size is the default")
+	assert.Equal(t, cFairQueueDefaultWeight, q.weight)
+	assert.Equal(t, 4, q.maxConcurrency, "limited by the number of workers")
+
+	q = fq.queue("big")
+	assert.Equal(t, 50, q.maxSize)
+	assert.Equal(t, 3, q.weight)
+	assert.Equal(t, 2, q.maxConcurrency)
+
+	assert.True(t, q == fq.queue("big"), "the queue is created only once")
+}
+
+func TestFairQueueWeightedRoundRobin(t *testing.T) {
+
+	fq := newTestFairQueue(structs.FairQueueConfiguration{
+		QueueSize: 10,
+		Keysets: map[string]structs.KeysetQueueConfiguration{
+			"a": {Weight: 2},
+		},
+	}, 1)
+
+	pushPoints(t, fq, "a/1", "a/2", "a/3", "a/4", "a/5", "b/1", "b/2", "c/1")
+
+	expected := []string{"a/1", "a/2", "b/1", "c/1", "a/3", "a/4", "b/2", "a/5"}
+	assert.Equal(t, expected, popOrder(fq, len(expected)), "the noisy keyset does not starve the others")
+
+	assert.Empty(t, fq.ring)
+	assert.Empty(t, fq.queues, "the empty queues are removed")
+}
+
+func TestFairQueueMaxConcurrency(t *testing.T) {
+
+	fq := newTestFairQueue(structs.FairQueueConfiguration{QueueSize: 10, MaxConcurrency: 1}, 4)
+
+	pushPoints(t, fq, "a/1", "a/2", "b/1")
+
+	first, qa := fq.pop()
+	assert.Equal(t, "1", first.validatedPoint.ID)
+
+	// "a" is running its only slot, so "b" is served next
+	second, qb := fq.pop()
+	assert.Equal(t, "b", second.validatedPoint.Message.Keyset)
+	fq.done(qb)
+
+	popped := make(chan workerData)
+	go func() {
+		data, q := fq.pop()
+		fq.done(q)
+		popped <- data
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("the keyset concurrency cap was ignored")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fq.done(qa)
+
+	select {
+	case data := <-popped:
+		assert.Equal(t, "2", data.validatedPoint.ID)
+	case <-time.After(time.Second):
+		t.Fatal("the worker was not released")
+	}
+}
+
+func TestFairQueueSaturated(t *testing.T) {
+
+	fq := newTestFairQueue(structs.FairQueueConfiguration{QueueSize: 2}, 1)
+
+	pushPoints(t, fq, "a/1", "a/2")
+
+	assert.False(t, fq.push(queueData("a/3"), false), "the full queue rejects")
+	assert.True(t, fq.push(queueData("b/1"), false), "the other keysets are not affected")
+
+	pushed := make(chan bool)
+	go func() {
+		pushed <- fq.push(queueData("a/3"), true)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("the push must wait for space")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, []string{"a/1"}, popOrder(fq, 1))
+
+	select {
+	case ok := <-pushed:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("the waiting push was not released")
+	}
+
+	assert.ElementsMatch(t, []string{"b/1", "a/2", "a/3"}, popOrder(fq, 3))
+}
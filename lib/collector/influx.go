@@ -0,0 +1,351 @@
+package collector
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+//
+// Implements the influxdb line protocol parser used by the http, udp and telnet sources.
+//
+
+const (
+	cFuncParseInfluxLine string = "ParseInfluxLine"
+	cInfluxEscape        byte   = '\\'
+	cInfluxQuote         byte   = '"'
+	cInfluxSpace         byte   = ' '
+	cInfluxComma         byte   = ','
+	cInfluxEqual         byte   = '='
+	cInfluxMetricJoin    string = "."
+
+	// InfluxPrecisionNanoseconds - the default line protocol timestamp precision
+	InfluxPrecisionNanoseconds string = "ns"
+)
+
+// influxPrecisionToMillis - the divisor (or multiplier if negative) used to convert the timestamp to milliseconds
+var influxPrecisionToMillis = map[string]int64{
+	InfluxPrecisionNanoseconds: 1e6,
+	"n":                        1e6,
+	"u":                        1e3,
+	"us":                       1e3,
+	"ms":                       1,
+	"s":                        -1e3,
+	"m":                        -6e4,
+	"h":                        -36e5,
+}
+
+// ValidInfluxPrecision - checks if the precision is supported
+func ValidInfluxPrecision(precision string) bool {
+
+	_, ok := influxPrecisionToMillis[precision]
+
+	return ok
+}
+
+// splitInfluxUnescaped - splits the string by the separator ignoring escaped and quoted separators
+func splitInfluxUnescaped(value string, separator byte, quotes bool, limit int) []string {
+
+	parts := []string{}
+	escaped := false
+	quoted := false
+	start := 0
+
+	for i := 0; i < len(value); i++ {
+
+		c := value[i]
+
+		if escaped {
+			escaped = false
+			continue
+		}
+
+		switch {
+		case c == cInfluxEscape:
+			escaped = true
+		case quotes && c == cInfluxQuote:
+			quoted = !quoted
+		case !quoted && c == separator:
+			if limit > 0 && len(parts) == limit-1 {
+				continue
+			}
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
+
+// unescapeInflux - removes the escape character from the escaped special characters
+func unescapeInflux(value string) string {
+
+	if strings.IndexByte(value, cInfluxEscape) < 0 {
+		return value
+	}
+
+	b := strings.Builder{}
+	b.Grow(len(value))
+
+	for i := 0; i < len(value); i++ {
+		if value[i] == cInfluxEscape && i+1 < len(value) {
+			switch value[i+1] {
+			case cInfluxComma, cInfluxEqual, cInfluxSpace, cInfluxQuote, cInfluxEscape:
+				i++
+			}
+		}
+		b.WriteByte(value[i])
+	}
+
+	return b.String()
+}
+
+// parseInfluxFieldValue - parses a field value, returns the number or the text
+func parseInfluxFieldValue(value string) (*float64, string, bool) {
+
+	length := len(value)
+	if length == 0 {
+		return nil, constants.StringsEmpty, false
+	}
+
+	if value[0] == cInfluxQuote {
+		if length < 2 || value[length-1] != cInfluxQuote {
+			return nil, constants.StringsEmpty, false
+		}
+		return nil, unescapeInflux(value[1 : length-1]), true
+	}
+
+	var number float64
+
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		number = 1
+	case "f", "F", "false", "False", "FALSE":
+		number = 0
+	default:
+		var err error
+
+		switch value[length-1] {
+		case 'i':
+			var i int64
+			i, err = strconv.ParseInt(value[:length-1], 10, 64)
+			number = float64(i)
+		case 'u':
+			var u uint64
+			u, err = strconv.ParseUint(value[:length-1], 10, 64)
+			number = float64(u)
+		default:
+			number, err = strconv.ParseFloat(value, 64)
+		}
+
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, constants.StringsEmpty, false
+		}
+	}
+
+	return &number, constants.StringsEmpty, true
+}
+
+// ParseInfluxLine - parses a line protocol line creating one point for each field (the second return is the keyset)
+func (collect *Collector) ParseInfluxLine(line, precision, defaultKeyset string, defaultTTL int) (structs.TSDBpoints, string, gobol.Error) {
+
+	keyset := defaultKeyset
+	ttl := constants.StringsEmpty
+	if defaultTTL > 0 {
+		ttl = strconv.Itoa(defaultTTL)
+	}
+
+	sections := splitInfluxUnescaped(strings.TrimSpace(line), cInfluxSpace, true, 3)
+	if len(sections) < 2 {
+		return nil, keyset, validation.ErrMalformedLine
+	}
+
+	keyParts := splitInfluxUnescaped(sections[0], cInfluxComma, false, 0)
+
+	measurement := unescapeInflux(keyParts[0])
+	if measurement == constants.StringsEmpty {
+		return nil, keyset, validation.ErrMalformedLine
+	}
+
+	tags := make([]structs.TSDBTag, 0, len(keyParts)+1)
+
+	var gerr gobol.Error
+
+	for i := 1; i < len(keyParts); i++ {
+
+		kv := splitInfluxUnescaped(keyParts[i], cInfluxEqual, false, 2)
+		if len(kv) != 2 || kv[0] == constants.StringsEmpty || kv[1] == constants.StringsEmpty {
+			return nil, keyset, validation.ErrMalformedLine
+		}
+
+		tag := structs.TSDBTag{
+			Name:  unescapeInflux(kv[0]),
+			Value: unescapeInflux(kv[1]),
+		}
+
+		switch tag.Name {
+		case constants.StringsKSID:
+			keyset = tag.Value
+		case constants.StringsTTL:
+			ttl = tag.Value
+		default:
+			gerr = collect.validation.ValidateProperty(tag.Name, validation.TagKeyType)
+			if gerr != nil {
+				return nil, keyset, gerr
+			}
+
+			gerr = collect.validation.ValidateProperty(tag.Value, validation.TagValueType)
+			if gerr != nil {
+				return nil, keyset, gerr
+			}
+
+			tags = append(tags, tag)
+		}
+	}
+
+	gerr = collect.validation.ValidateKeyset(keyset)
+	if gerr != nil {
+		return nil, keyset, gerr
+	}
+
+	tags = append(tags, structs.TSDBTag{Name: constants.StringsKSID, Value: keyset})
+
+	ttlValue, ttlStr, gerr := collect.validation.ParseTTL(ttl)
+	if gerr != nil {
+		return nil, keyset, gerr
+	}
+
+	tags = append(tags, structs.TSDBTag{Name: constants.StringsTTL, Value: ttlStr})
+
+	var timestamp int64
+
+	if len(sections) == 3 && sections[2] != constants.StringsEmpty {
+
+		var err error
+		timestamp, err = strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, keyset, validation.ErrMalformedLine
+		}
+
+		factor, ok := influxPrecisionToMillis[precision]
+		if !ok {
+			return nil, keyset, validation.ErrMalformedLine
+		}
+
+		if factor > 0 {
+			timestamp /= factor
+		} else {
+			timestamp *= -factor
+		}
+	}
+
+	timestamp, gerr = collect.validation.ValidateTimestamp(timestamp)
+	if gerr != nil {
+		return nil, keyset, gerr
+	}
+
+	fields := splitInfluxUnescaped(sections[1], cInfluxComma, true, 0)
+	points := make(structs.TSDBpoints, 0, len(fields))
+
+	for _, field := range fields {
+
+		kv := splitInfluxUnescaped(field, cInfluxEqual, true, 2)
+		if len(kv) != 2 || kv[0] == constants.StringsEmpty {
+			return nil, keyset, validation.ErrMalformedLine
+		}
+
+		value, text, ok := parseInfluxFieldValue(kv[1])
+		if !ok {
+			return nil, keyset, validation.ErrParsingFieldValue
+		}
+
+		point := &structs.TSDBpoint{
+			Metric:    measurement + cInfluxMetricJoin + unescapeInflux(kv[0]),
+			Timestamp: timestamp,
+			Value:     value,
+			Text:      strings.TrimSpace(text),
+			Tags:      tags,
+			TTL:       ttlValue,
+			Keyset:    keyset,
+		}
+
+		gerr = collect.validation.ValidateTags(point)
+		if gerr != nil {
+			return nil, keyset, gerr
+		}
+
+		gerr = collect.validation.ValidateProperty(point.Metric, validation.MetricType)
+		if gerr != nil {
+			return nil, keyset, gerr
+		}
+
+		gerr = collect.validation.ValidateType(point, value != nil)
+		if gerr != nil {
+			return nil, keyset, gerr
+		}
+
+		gerr = collect.validation.ValidatePolicy(point)
+		if gerr != nil {
+			return nil, keyset, gerr
+		}
+
+		points = append(points, point)
+	}
+
+	return points, keyset, nil
+}
+
+// HandleInfluxLines - parses the line protocol lines and sends the points to be processed (returns the number of points)
+func (collect *Collector) HandleInfluxLines(data []byte, sourceType *constants.SourceType, ip, precision, defaultKeyset string, defaultTTL int, token *auth.Token) (int, gobol.Error) {
+
+	var firstErr gobol.Error
+	numPoints := 0
+
+	for _, line := range strings.Split(string(data), "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == constants.StringsEmpty || line[0] == '#' {
+			continue
+		}
+
+		points, keyset, gerr := collect.ParseInfluxLine(line, precision, defaultKeyset, defaultTTL)
+		if gerr != nil {
+			collect.validation.StatsValidationError(cFuncParseInfluxLine, keyset, ip, sourceType, gerr)
+			if firstErr == nil {
+				firstErr = gerr
+			}
+			continue
+		}
+
+		for _, p := range points {
+
+			vp, gerr := collect.MakePacket(p, p.Value != nil)
+			if gerr != nil {
+				return numPoints, gerr
+			}
+
+			gerr = collect.ValidateLimits(vp)
+			if gerr != nil {
+				collect.validation.StatsValidationError(cFuncParseInfluxLine, keyset, ip, sourceType, gerr)
+				return numPoints, gerr
+			}
+
+			gerr = collect.HandlePacket(vp, sourceType, token)
+			if gerr != nil {
+				collect.validation.StatsValidationError(cFuncParseInfluxLine, keyset, ip, sourceType, gerr)
+				return numPoints, gerr
+			}
+
+			numPoints++
+		}
+	}
+
+	return numPoints, firstErr
+}
@@ -0,0 +1,296 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+func TestSplitInfluxUnescaped(t *testing.T) {
+
+	cases := []struct {
+		value     string
+		separator byte
+		quotes    bool
+		limit     int
+		expected  []string
+	}{
+		{`cpu`, ',', false, 0, []string{`cpu`}},
+		{`cpu,host=a,region=b`, ',', false, 0, []string{`cpu`, `host=a`, `region=b`}},
+		{`cpu\,load,host=a`, ',', false, 0, []string{`cpu\,load`, `host=a`}},
+		{`cpu\ load value=1`, ' ', true, 0, []string{`cpu\ load`, `value=1`}},
+		{`text="a b" 1`, ' ', true, 0, []string{`text="a b"`, `1`}},
+		{`text="a b"`, ' ', false, 0, []string{`text="a`, `b"`}},
+		{`text="a\" b" 1`, ' ', true, 0, []string{`text="a\" b"`, `1`}},
+		{`a=b=c`, '=', false, 2, []string{`a`, `b=c`}},
+		{`cpu value=1 10 20`, ' ', true, 3, []string{`cpu`, `value=1`, `10 20`}},
+		{`,,`, ',', false, 0, []string{``, ``, ``}},
+		{`cpu,`, ',', false, 0, []string{`cpu`, ``}},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, splitInfluxUnescaped(c.value, c.separator, c.quotes, c.limit), c.value)
+	}
+}
+
+func TestUnescapeInflux(t *testing.T) {
+
+	cases := map[string]string{
+		`cpu.load`:   `cpu.load`,
+		`cpu\,load`:  `cpu,load`,
+		`a\=b`:       `a=b`,
+		`a\ b`:       `a b`,
+		`a\"b`:       `a"b`,
+		`a\\b`:       `a\b`,
+		`a\nb`:       `a\nb`,
+		`a\`:         `a\`,
+		`a\,b\ c\=d`: `a,b c=d`,
+	}
+
+	for escaped, expected := range cases {
+		assert.Equal(t, expected, unescapeInflux(escaped), escaped)
+	}
+}
+
+func TestParseInfluxFieldValue(t *testing.T) {
+
+	numbers := map[string]float64{
+		`1.5`:   1.5,
+		`-2e3`:  -2000,
+		`42i`:   42,
+		`-42i`:  -42,
+		`42u`:   42,
+		`t`:     1,
+		`TRUE`:  1,
+		`false`: 0,
+		`F`:     0,
+	}
+
+	for value, expected := range numbers {
+
+		number, text, ok := parseInfluxFieldValue(value)
+
+		if assert.True(t, ok, value) && assert.NotNil(t, number, value) {
+			assert.Equal(t, expected, *number, value)
+		}
+		assert.Empty(t, text, value)
+	}
+
+	texts := map[string]string{
+		`"a b"`:        `a b`,
+		`"a \"b\""`:    `a "b"`,
+		`""`:           ``,
+		`"1.5"`:        `1.5`,
+		`"a\\b"`:       `a\b`,
+		`"with,comma"`: `with,comma`,
+	}
+
+	for value, expected := range texts {
+
+		number, text, ok := parseInfluxFieldValue(value)
+
+		assert.True(t, ok, value)
+		assert.Nil(t, number, value)
+		assert.Equal(t, expected, text, value)
+	}
+
+	for _, value := range []string{`-1u`, `"a b`, `"`, ``, `NaN`, `+Inf`, `-Inf`, `1.5i`, `abc`, `1i2`} {
+
+		number, _, ok := parseInfluxFieldValue(value)
+
+		assert.False(t, ok, value)
+		assert.Nil(t, number, value)
+	}
+}
+
+func TestValidInfluxPrecision(t *testing.T) {
+
+	for _, precision := range []string{"ns", "n", "u", "us", "ms", "s", "m", "h"} {
+		assert.True(t, ValidInfluxPrecision(precision), precision)
+	}
+
+	for _, precision := range []string{"", "d", "NS", "µs"} {
+		assert.False(t, ValidInfluxPrecision(precision), precision)
+	}
+}
+
+func TestParseInfluxLine(t *testing.T) {
+
+	collect := newTestCollector(t, "stats", "other")
+
+	value := func(v float64) *float64 { return &v }
+
+	tags := func(pairs ...string) []structs.TSDBTag {
+		result := []structs.TSDBTag{}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			result = append(result, structs.TSDBTag{Name: pairs[i], Value: pairs[i+1]})
+		}
+		return result
+	}
+
+	cases := map[string]struct {
+		line      string
+		precision string
+		keyset    string
+		expected  structs.TSDBpoints
+	}{
+		"OnePointPerField": {
+			line:      `cpu,host=a load=1.5,state="ok" 1600000000000000000`,
+			precision: InfluxPrecisionNanoseconds,
+			keyset:    "stats",
+			expected: structs.TSDBpoints{
+				{Metric: "cpu.load", Timestamp: 1600000000000, Value: value(1.5), Tags: tags("host", "a", "ksid", "stats", "ttl", "1"), TTL: 1, Keyset: "stats"},
+				{Metric: "cpu.state", Timestamp: 1600000000000, Text: "ok", Tags: tags("host", "a", "ksid", "stats", "ttl", "1"), TTL: 1, Keyset: "stats"},
+			},
+		},
+		"KeysetAndTTLTags": {
+			line:      `cpu,ksid=other,host=a,ttl=7 load=2i 1600000000`,
+			precision: "s",
+			keyset:    "other",
+			expected: structs.TSDBpoints{
+				{Metric: "cpu.load", Timestamp: 1600000000000, Value: value(2), Tags: tags("host", "a", "ksid", "other", "ttl", "7"), TTL: 7, Keyset: "other"},
+			},
+		},
+		"UnknownTTLUsesTheDefault": {
+			line:      `cpu,host=a,ttl=3 load=1 1600000000000`,
+			precision: "ms",
+			keyset:    "stats",
+			expected: structs.TSDBpoints{
+				{Metric: "cpu.load", Timestamp: 1600000000000, Value: value(1), Tags: tags("host", "a", "ksid", "stats", "ttl", "1"), TTL: 1, Keyset: "stats"},
+			},
+		},
+		"MicrosecondsPrecision": {
+			line:      `cpu,host=a load=1 1600000000123456`,
+			precision: "u",
+			keyset:    "stats",
+			expected: structs.TSDBpoints{
+				{Metric: "cpu.load", Timestamp: 1600000000000, Value: value(1), Tags: tags("host", "a", "ksid", "stats", "ttl", "1"), TTL: 1, Keyset: "stats"},
+			},
+		},
+		"HoursPrecision": {
+			line:      `cpu,host=a load=1 444444`,
+			precision: "h",
+			keyset:    "stats",
+			expected: structs.TSDBpoints{
+				{Metric: "cpu.load", Timestamp: 1599998400000, Value: value(1), Tags: tags("host", "a", "ksid", "stats", "ttl", "1"), TTL: 1, Keyset: "stats"},
+			},
+		},
+		"EscapedCharacters": {
+			line:      `cpu,host=a value="x\"y, z=1" 1600000000`,
+			precision: "s",
+			keyset:    "stats",
+			expected: structs.TSDBpoints{
+				{Metric: "cpu.value", Timestamp: 1600000000000, Text: `x"y, z=1`, Tags: tags("host", "a", "ksid", "stats", "ttl", "1"), TTL: 1, Keyset: "stats"},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			points, keyset, gerr := collect.ParseInfluxLine(c.line, c.precision, "stats", 0)
+
+			assert.Nil(t, gerr)
+			assert.Equal(t, c.keyset, keyset)
+			assert.Equal(t, c.expected, points)
+		})
+	}
+}
+
+func TestParseInfluxLineWithoutTimestamp(t *testing.T) {
+
+	collect := newTestCollector(t, "stats")
+
+	before := time.Now().Unix() * 1000
+
+	points, _, gerr := collect.ParseInfluxLine(`cpu,host=a load=1`, InfluxPrecisionNanoseconds, "stats", 1)
+
+	if assert.Nil(t, gerr) && assert.Len(t, points, 1) {
+		assert.True(t, points[0].Timestamp >= before && points[0].Timestamp <= time.Now().Unix()*1000)
+	}
+}
+
+func TestParseInfluxLineInvalid(t *testing.T) {
+
+	collect := newTestCollector(t, "stats")
+
+	cases := []struct {
+		line      string
+		precision string
+		keyset    string
+		err       gobol.Error
+	}{
+		{``, "s", "stats", validation.ErrMalformedLine},
+		{`   `, "s", "stats", validation.ErrMalformedLine},
+		{`cpu`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,host=a`, "s", "stats", validation.ErrMalformedLine},
+		{`,host=a value=1`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,host value=1`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,host= value=1`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,=a value=1`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,host=a =1`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,host=a value`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,host=a value=1 abc`, "s", "stats", validation.ErrMalformedLine},
+		{`cpu,host=a value=1 1600000000`, "d", "stats", validation.ErrMalformedLine},
+		{`cpu,host=a value=abc`, "s", "stats", validation.ErrParsingFieldValue},
+		{`cpu,host=a value=1,other="open`, "s", "stats", validation.ErrParsingFieldValue},
+		{`cpu,host=a value=1 16000000000000000000`, "ms", "stats", validation.ErrMalformedLine},
+		{`cpu,host=a value=1 1600000000000000`, "ms", "stats", validation.ErrInvalidTimestamp},
+		{`cpu\ usage,host=a value=1`, "s", "stats", validation.ErrInvalidMetric},
+		{`cpu,host\=x=a value=1`, "s", "stats", validation.ErrInvalidTagKey},
+		{`cpu,ksid=unknown,host=a value=1`, "s", "unknown", validation.ErrInexistentKeyset},
+		{`cpu,ksid=1x,host=a value=1`, "s", "1x", validation.ErrInvalidKeysetFormat},
+		{`cpu,ttl=x,host=a value=1`, "s", "stats", validation.ErrInvalidTTLValue},
+		{`cpu,host|a=a value=1`, "s", "stats", validation.ErrInvalidTagKey},
+		{`cpu,host=a|b value=1`, "s", "stats", validation.ErrInvalidTagValue},
+		{`cpu,host=a,host=b value=1`, "s", "stats", validation.ErrDuplicatedTags},
+		{`cpu value=1`, "s", "stats", validation.ErrNoUserTags},
+		{`cpu|x,host=a value=1`, "s", "stats", validation.ErrInvalidMetric},
+	}
+
+	for _, c := range cases {
+
+		points, keyset, gerr := collect.ParseInfluxLine(c.line, c.precision, "stats", 1)
+
+		assert.Nil(t, points, c.line)
+		assert.Equal(t, c.keyset, keyset, c.line)
+		assert.Equal(t, c.err, gerr, c.line)
+	}
+}
+
+func TestParseInfluxLineNoDefaultKeyset(t *testing.T) {
+
+	collect := newTestCollector(t, "stats")
+
+	_, keyset, gerr := collect.ParseInfluxLine(`cpu,host=a value=1`, "s", constants.StringsEmpty, 1)
+
+	assert.Empty(t, keyset)
+	assert.Equal(t, validation.ErrNoKeysetTag, gerr)
+}
+
+func TestHandleInfluxLines(t *testing.T) {
+
+	collect := newTestCollector(t, "stats")
+
+	data := []byte("# comment\n\ncpu,host=a load=1,idle=2 1600000000\ncpu,host=a load=abc 1600000000\n  mem,host=b used=3 1600000000  \ndisk,ksid=unknown,host=a used=1 1600000000\n")
+
+	numPoints, gerr := collect.HandleInfluxLines(data, constants.SourceTypeInfluxHTTP, "127.0.0.1", "s", "stats", 1, nil)
+
+	assert.Equal(t, 3, numPoints)
+	assert.Equal(t, validation.ErrParsingFieldValue, gerr)
+
+	metrics := []string{}
+	for _, p := range handledPoints(collect) {
+		metrics = append(metrics, p.Message.Metric)
+		assert.True(t, p.Number)
+		assert.NotEmpty(t, p.ID)
+	}
+
+	assert.Equal(t, []string{"cpu.load", "cpu.idle", "mem.used"}, metrics)
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const cLastSeenDefaultInterval time.Duration = time.Hour
+
+// lastSeenTracker - samples the last write updates of the timeseries metadata,
+// each series is updated at most once per interval
+type lastSeenTracker struct {
+	sync.Mutex
+	series      map[string]time.Time
+	interval    time.Duration
+	lastCleanup time.Time
+}
+
+// newLastSeenTracker - creates a new tracker
+func newLastSeenTracker(interval time.Duration) *lastSeenTracker {
+
+	if interval <= 0 {
+		interval = cLastSeenDefaultInterval
+	}
+
+	return &lastSeenTracker{
+		series:      map[string]time.Time{},
+		interval:    interval,
+		lastCleanup: time.Now(),
+	}
+}
+
+// due - checks if the last write of the series must be updated, the first update of a series
+// not tracked yet is delayed by a random part of the interval to spread the writes after a restart
+func (t *lastSeenTracker) due(id string, now time.Time) bool {
+
+	t.Lock()
+	defer t.Unlock()
+
+	if now.Sub(t.lastCleanup) > t.interval {
+		for k, next := range t.series {
+			if now.Sub(next) > t.interval {
+				delete(t.series, k)
+			}
+		}
+		t.lastCleanup = now
+	}
+
+	next, ok := t.series[id]
+	if !ok {
+		t.series[id] = now.Add(time.Duration(rand.Int63n(int64(t.interval))))
+		return false
+	}
+
+	if now.Before(next) {
+		return false
+	}
+
+	t.series[id] = now.Add(t.interval)
+
+	return true
+}
+
+// track - registers a series whose last write was just stored
+func (t *lastSeenTracker) track(id string, now time.Time) {
+
+	t.Lock()
+	t.series[id] = now.Add(t.interval)
+	t.Unlock()
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastSeenTrackerDue(t *testing.T) {
+
+	tracker := newLastSeenTracker(time.Minute)
+	now := time.Now()
+
+	assert.False(t, tracker.due("a", now), "the first update is delayed")
+	assert.False(t, tracker.due("a", now.Add(time.Second)))
+	assert.True(t, tracker.due("a", now.Add(time.Minute)), "delayed by less than the interval")
+	assert.False(t, tracker.due("a", now.Add(time.Minute+time.Second)))
+	assert.True(t, tracker.due("a", now.Add(2*time.Minute)))
+
+	tracker.track("b", now)
+	assert.False(t, tracker.due("b", now.Add(59*time.Second)), "just stored")
+	assert.True(t, tracker.due("b", now.Add(time.Minute)))
+}
+
+func TestLastSeenTrackerCleanup(t *testing.T) {
+
+	tracker := newLastSeenTracker(time.Minute)
+	now := tracker.lastCleanup
+
+	tracker.track("old", now)
+	tracker.track("recent", now.Add(90*time.Second))
+
+	tracker.due("new", now.Add(3*time.Minute))
+
+	_, ok := tracker.series["old"]
+	assert.False(t, ok, "not written for longer than the interval")
+
+	_, ok = tracker.series["recent"]
+	assert.True(t, ok)
+
+	assert.Equal(t, cLastSeenDefaultInterval, newLastSeenTracker(0).interval)
+}
@@ -1,7 +1,10 @@
 package collector
 
 import (
+	"time"
+
 	"github.com/uol/gobol"
+	"github.com/uol/logh"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/metadata"
 )
@@ -35,22 +38,21 @@ func (collect *Collector) saveMeta(packet *Point) gobol.Error {
 		metaType = cMetaTypeText
 	}
 
+	now := time.Now()
+
 	if !found {
 
-		var tagKeys, tagValues []string
-		for _, tag := range packet.Message.Tags {
-			if tag.Name != constants.StringsKSID {
-				tagKeys = append(tagKeys, tag.Name)
-				tagValues = append(tagValues, tag.Value)
-			}
+		metadata := collect.toMetadata(packet, metaType)
+
+		if collect.lastSeen != nil {
+			metadata.LastSeen = now.UnixNano() / int64(time.Millisecond)
 		}
 
-		metadata := &metadata.Metadata{
-			ID:       packet.ID,
-			Metric:   packet.Message.Metric,
-			MetaType: metaType,
-			TagKey:   tagKeys,
-			TagValue: tagValues,
+		if err := collect.repair.SaveMapping(packet.Message.Keyset, metadata); err != nil {
+			statsTSIDMappingError(packet.Message.Keyset)
+			if logh.ErrorEnabled {
+				collect.logger.Error().Str(constants.StringsFunc, "saveMeta").Str("tsid", packet.ID).Err(err).Msg("error saving the tsid mapping")
+			}
 		}
 
 		gerr = collect.AddMetadata(packet.Message.Keyset, metadata)
@@ -58,11 +60,56 @@ func (collect *Collector) saveMeta(packet *Point) gobol.Error {
 			return gerr
 		}
 
+		if collect.lastSeen != nil {
+			collect.lastSeen.track(packet.ID, now)
+		}
+
+		collect.validation.RegisterNewSeries(packet.Message.Keyset)
+
 		statsCountNewTimeseries(packet.Message.Keyset, metaType, packet.Message.TTL)
 
 	} else {
+
+		if collect.lastSeen != nil && collect.lastSeen.due(packet.ID, now) {
+			collect.updateLastSeen(packet, metaType, now)
+		}
+
 		statsCountOldTimeseries(packet.Message.Keyset, metaType, packet.Message.TTL)
 	}
 
 	return nil
 }
+
+// toMetadata - builds the metadata document of the point's timeseries
+func (collect *Collector) toMetadata(packet *Point, metaType string) *metadata.Metadata {
+
+	var tagKeys, tagValues []string
+	for _, tag := range packet.Message.Tags {
+		if tag.Name != constants.StringsKSID {
+			tagKeys = append(tagKeys, tag.Name)
+			tagValues = append(tagValues, tag.Value)
+		}
+	}
+
+	return &metadata.Metadata{
+		ID:       packet.ID,
+		Metric:   packet.Message.Metric,
+		MetaType: metaType,
+		TagKey:   tagKeys,
+		TagValue: tagValues,
+	}
+}
+
+// updateLastSeen - updates the last write timestamp of an existing timeseries (errors do not reject the point)
+func (collect *Collector) updateLastSeen(packet *Point, metaType string, now time.Time) {
+
+	metadata := collect.toMetadata(packet, metaType)
+	metadata.LastSeen = now.UnixNano() / int64(time.Millisecond)
+
+	if gerr := collect.metaStorage.UpdateLastSeen(packet.Message.Keyset, metadata); gerr != nil {
+		statsLastSeenError(packet.Message.Keyset)
+		if logh.ErrorEnabled {
+			collect.logger.Error().Str(constants.StringsFunc, "updateLastSeen").Str("tsid", packet.ID).Err(gerr).Msg("error updating the timeseries last write")
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package collector
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/otlppb"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+//
+// Implements the opentelemetry OTLP/HTTP protobuf metrics receiver.
+//
+
+const (
+	cFuncHandleOTLPMetrics    string        = "HandleOTLPMetrics"
+	cOTLPBucketSuffix         string        = ".bucket"
+	cOTLPCountSuffix          string        = ".count"
+	cOTLPSumSuffix            string        = ".sum"
+	cOTLPBucketTag            string        = "le"
+	cOTLPInfBucket            string        = "inf"
+	cOTLPContentTypeProtobuf  string        = "application/x-protobuf"
+	cOTLPDefaultDeltaExpiry   time.Duration = 10 * time.Minute
+	cMsgfOTLPRejectedPoints   string        = "%d points rejected, first error: %s"
+	cMsgOTLPUnsupportedFormat string        = "only the protobuf encoding is supported"
+)
+
+// otlpCumulative - the last cumulative value stored for a series and its delta
+type otlpCumulative struct {
+	start     uint64
+	timestamp int64
+	value     float64
+	delta     float64
+	hasDelta  bool
+	lastSeen  time.Time
+}
+
+// otlpDeltaCache - stores the last cumulative values to convert them to deltas. The cache is kept
+// in the memory of each node, the exporters must send the points of a series to the same node
+// (or send deltas) when the nodes are behind a load balancer.
+type otlpDeltaCache struct {
+	sync.Mutex
+	series      map[string]*otlpCumulative
+	expiration  time.Duration
+	lastCleanup time.Time
+}
+
+// newOTLPDeltaCache - creates a new delta cache
+func newOTLPDeltaCache(expiration time.Duration) *otlpDeltaCache {
+
+	if expiration <= 0 {
+		expiration = cOTLPDefaultDeltaExpiry
+	}
+
+	return &otlpDeltaCache{
+		series:      map[string]*otlpCumulative{},
+		expiration:  expiration,
+		lastCleanup: time.Now(),
+	}
+}
+
+// delta - returns the delta from the last stored cumulative value, the first value of a series is
+// kept without a delta because there is no previous value to compare with. A repeated timestamp
+// (a retried export) returns the stored delta and an older one returns no delta.
+func (c *otlpDeltaCache) delta(key string, start uint64, timestamp int64, value float64) (float64, bool) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(c.lastCleanup) > c.expiration {
+		for k, v := range c.series {
+			if now.Sub(v.lastSeen) > c.expiration {
+				delete(c.series, k)
+			}
+		}
+		c.lastCleanup = now
+	}
+
+	last, ok := c.series[key]
+	if !ok {
+		c.series[key] = &otlpCumulative{start: start, timestamp: timestamp, value: value, lastSeen: now}
+		return 0, false
+	}
+
+	last.lastSeen = now
+
+	if timestamp == last.timestamp && start == last.start {
+		return last.delta, last.hasDelta
+	}
+
+	if timestamp < last.timestamp {
+		return 0, false
+	}
+
+	delta := value - last.value
+
+	// the series was restarted, the value is the accumulation since the new start
+	if start != last.start || delta < 0 {
+		delta = value
+	}
+
+	return delta, true
+}
+
+// store - stores the cumulative value and its delta, called after the point is accepted
+// (the value of a rejected point is used again by the next one)
+func (c *otlpDeltaCache) store(key string, start uint64, timestamp int64, value, delta float64) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.series[key] = &otlpCumulative{
+		start:     start,
+		timestamp: timestamp,
+		value:     value,
+		delta:     delta,
+		hasDelta:  true,
+		lastSeen:  time.Now(),
+	}
+}
+
+// otlpSeries - the validated tags of an otlp data point
+type otlpSeries struct {
+	keyset string
+	ttl    int
+	tags   []structs.TSDBTag
+}
+
+// HandleOTLPMetrics - handles the OTLP/HTTP protobuf metrics export request
+func (collect *Collector) HandleOTLPMetrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	ip := collect.sendIPStats(r)
+	token := auth.FromContext(r.Context())
+
+	defer r.Body.Close()
+
+	if contentType := r.Header.Get("Content-Type"); contentType != constants.StringsEmpty && !strings.HasPrefix(contentType, cOTLPContentTypeProtobuf) {
+		rip.Fail(w, errUnsupportedMediaType(cFuncHandleOTLPMetrics, cMsgOTLPUnsupportedFormat))
+		return
+	}
+
+	var data []byte
+	var err error
+
+	if r.Header.Get("Content-Encoding") == "gzip" {
+
+		var gzipReader *gzip.Reader
+
+		gzipReader, err = gzip.NewReader(r.Body)
+		if err != nil {
+			rip.Fail(w, errUnmarshal(cFuncHandleOTLPMetrics, err))
+			return
+		}
+
+		defer gzipReader.Close()
+
+		data, err = ioutil.ReadAll(gzipReader)
+
+	} else {
+
+		data, err = ioutil.ReadAll(r.Body)
+	}
+
+	if err != nil {
+		rip.Fail(w, errUnmarshal(cFuncHandleOTLPMetrics, err))
+		return
+	}
+
+	request := otlppb.ExportMetricsServiceRequest{}
+	if err = request.Unmarshal(data); err != nil {
+		rip.Fail(w, errDecode(cFuncHandleOTLPMetrics, err))
+		return
+	}
+
+	var firstErr gobol.Error
+	var rejected int64
+
+	for i := 0; i < len(request.ResourceMetrics); i++ {
+
+		rm := &request.ResourceMetrics[i]
+
+		for j := 0; j < len(rm.Metrics); j++ {
+
+			n, keyset, gerr := collect.handleOTLPMetric(rm.Attributes, &rm.Metrics[j], token)
+			if gerr != nil && !otlpRejected(gerr) {
+				rip.Fail(w, gerr)
+				return
+			}
+
+			if gerr != nil {
+				collect.validation.StatsValidationError(cFuncHandleOTLPMetrics, keyset, ip, constants.SourceTypeOTLP, gerr)
+				rejected += n
+				if firstErr == nil {
+					firstErr = gerr
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", cOTLPContentTypeProtobuf)
+
+	// the OTLP partial success response is used to report the rejected points
+	var response []byte
+	if firstErr != nil {
+		response = otlppb.MarshalExportResponse(rejected, fmt.Sprintf(cMsgfOTLPRejectedPoints, rejected, firstErr.Message()))
+	} else {
+		response = otlppb.MarshalExportResponse(0, constants.StringsEmpty)
+	}
+
+	rip.Success(w, http.StatusOK, response)
+}
+
+// otlpRejected - checks if the error only rejects the data point (reported as a partial success),
+// the saturation, authorization and server errors fail the request to be retried by the exporter
+func otlpRejected(gerr gobol.Error) bool {
+
+	switch gerr.StatusCode() {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+
+	return gerr.StatusCode() < http.StatusInternalServerError
+}
+
+// handleOTLPMetric - sends all data points of the metric, returns the number of rejected points
+// and the first error found (the sending stops at the first error not rejecting only the point)
+func (collect *Collector) handleOTLPMetric(resource []otlppb.KeyValue, metric *otlppb.Metric, token *auth.Token) (int64, string, gobol.Error) {
+
+	var firstErr gobol.Error
+	var rejected int64
+	var keyset string
+
+	reject := func(ks string, gerr gobol.Error) bool {
+		if !otlpRejected(gerr) {
+			firstErr = gerr
+			keyset = ks
+			return false
+		}
+
+		rejected++
+		if firstErr == nil {
+			firstErr = gerr
+			keyset = ks
+		}
+
+		return true
+	}
+
+	if metric.Type == otlppb.MetricTypeUnsupported {
+		return int64(len(metric.NumberDataPoints) + len(metric.HistogramDataPoints)), collect.settings.OTLP.DefaultKeyset, validation.ErrUnsupportedOTLPType
+	}
+
+	cumulative := collect.settings.OTLP.CumulativeToDelta && metric.AggregationTemporality == otlppb.TemporalityCumulative
+
+	for i := 0; i < len(metric.NumberDataPoints); i++ {
+
+		p := &metric.NumberDataPoints[i]
+
+		if p.Flags&otlppb.DataPointFlagNoRecordedValue != 0 {
+			continue
+		}
+
+		series, ks, gerr := collect.otlpSeries(resource, p.Attributes)
+		if gerr != nil {
+			if !reject(ks, gerr) {
+				return rejected, keyset, firstErr
+			}
+			continue
+		}
+
+		toDelta := cumulative && metric.Type == otlppb.MetricTypeSum && metric.IsMonotonic
+
+		gerr = collect.sendOTLPPoint(metric.Name, series, nil, p.StartTimeUnixNano, p.TimeUnixNano, p.Value, toDelta, token)
+		if gerr != nil && !reject(series.keyset, gerr) {
+			return rejected, keyset, firstErr
+		}
+	}
+
+	for i := 0; i < len(metric.HistogramDataPoints); i++ {
+
+		p := &metric.HistogramDataPoints[i]
+
+		if p.Flags&otlppb.DataPointFlagNoRecordedValue != 0 {
+			continue
+		}
+
+		series, ks, gerr := collect.otlpSeries(resource, p.Attributes)
+		if gerr != nil {
+			if !reject(ks, gerr) {
+				return rejected, keyset, firstErr
+			}
+			continue
+		}
+
+		gerr = collect.sendOTLPHistogram(metric.Name, series, p, cumulative, token)
+		if gerr != nil && !reject(series.keyset, gerr) {
+			return rejected, keyset, firstErr
+		}
+	}
+
+	return rejected, keyset, firstErr
+}
+
+// sendOTLPHistogram - explodes the histogram in the cumulative bucket, count and sum series
+func (collect *Collector) sendOTLPHistogram(name string, series *otlpSeries, p *otlppb.HistogramDataPoint, toDelta bool, token *auth.Token) gobol.Error {
+
+	gerr := collect.sendOTLPPoint(name+cOTLPCountSuffix, series, nil, p.StartTimeUnixNano, p.TimeUnixNano, float64(p.Count), toDelta, token)
+	if gerr != nil {
+		return gerr
+	}
+
+	if p.HasSum {
+		gerr = collect.sendOTLPPoint(name+cOTLPSumSuffix, series, nil, p.StartTimeUnixNano, p.TimeUnixNano, p.Sum, toDelta, token)
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	var accumulated uint64
+
+	for i, count := range p.BucketCounts {
+
+		accumulated += count
+
+		bound := cOTLPInfBucket
+		if i < len(p.ExplicitBounds) {
+			bound = strconv.FormatFloat(p.ExplicitBounds[i], 'f', -1, 64)
+		}
+
+		bucketTag := &structs.TSDBTag{Name: cOTLPBucketTag, Value: bound}
+
+		gerr = collect.sendOTLPPoint(name+cOTLPBucketSuffix, series, bucketTag, p.StartTimeUnixNano, p.TimeUnixNano, float64(accumulated), toDelta, token)
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// otlpSeries - maps the resource and data point attributes to validated tags (returns the keyset)
+func (collect *Collector) otlpSeries(resource, attributes []otlppb.KeyValue) (*otlpSeries, string, gobol.Error) {
+
+	conf := &collect.settings.OTLP
+
+	keysetAttribute := conf.KeysetAttribute
+	if keysetAttribute == constants.StringsEmpty {
+		keysetAttribute = constants.StringsKSID
+	}
+
+	ttlAttribute := conf.TTLAttribute
+	if ttlAttribute == constants.StringsEmpty {
+		ttlAttribute = constants.StringsTTL
+	}
+
+	keyset := conf.DefaultKeyset
+	ttl := constants.StringsEmpty
+	if conf.DefaultTTL > 0 {
+		ttl = strconv.Itoa(conf.DefaultTTL)
+	}
+
+	// the data point attributes overwrite the resource attributes
+	tagMap := make(map[string]string, len(resource)+len(attributes))
+
+	for _, attr := range resource {
+		if len(conf.ResourceAttributes) > 0 && attr.Key != keysetAttribute && attr.Key != ttlAttribute && !collect.otlpResourceAttributeAllowed(attr.Key) {
+			continue
+		}
+		tagMap[attr.Key] = attr.Value
+	}
+
+	for _, attr := range attributes {
+		tagMap[attr.Key] = attr.Value
+	}
+
+	series := &otlpSeries{
+		tags: make([]structs.TSDBTag, 0, len(tagMap)+2),
+	}
+
+	for k, v := range tagMap {
+
+		switch k {
+		case keysetAttribute:
+			keyset = v
+			continue
+		case ttlAttribute:
+			ttl = v
+			continue
+		}
+
+		// empty attributes can not be indexed
+		if v == constants.StringsEmpty {
+			continue
+		}
+
+		gerr := collect.validation.ValidateProperty(k, validation.TagKeyType)
+		if gerr != nil {
+			return nil, keyset, gerr
+		}
+
+		gerr = collect.validation.ValidateProperty(v, validation.TagValueType)
+		if gerr != nil {
+			return nil, keyset, gerr
+		}
+
+		series.tags = append(series.tags, structs.TSDBTag{Name: k, Value: v})
+	}
+
+	gerr := collect.validation.ValidateKeyset(keyset)
+	if gerr != nil {
+		return nil, keyset, gerr
+	}
+
+	ttlValue, ttlStr, gerr := collect.validation.ParseTTL(ttl)
+	if gerr != nil {
+		return nil, keyset, gerr
+	}
+
+	series.keyset = keyset
+	series.ttl = ttlValue
+	series.tags = append(series.tags, structs.TSDBTag{Name: constants.StringsKSID, Value: keyset}, structs.TSDBTag{Name: constants.StringsTTL, Value: ttlStr})
+
+	sort.Slice(series.tags, func(i, j int) bool { return series.tags[i].Name < series.tags[j].Name })
+
+	return series, keyset, nil
+}
+
+// otlpResourceAttributeAllowed - checks if the resource attribute is configured to be a tag
+func (collect *Collector) otlpResourceAttributeAllowed(key string) bool {
+
+	for _, allowed := range collect.settings.OTLP.ResourceAttributes {
+		if allowed == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sendOTLPPoint - validates and sends the point to the collector (converting to delta if required)
+func (collect *Collector) sendOTLPPoint(metric string, series *otlpSeries, extraTag *structs.TSDBTag, start, timeUnixNano uint64, value float64, toDelta bool, token *auth.Token) gobol.Error {
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return validation.ErrParsingValue
+	}
+
+	tags := series.tags
+	if extraTag != nil {
+		tags = append(make([]structs.TSDBTag, 0, len(tags)+1), tags...)
+		tags = append(tags, *extraTag)
+	}
+
+	point := structs.TSDBpoint{
+		Metric: metric,
+		Tags:   tags,
+		TTL:    series.ttl,
+		Keyset: series.keyset,
+	}
+
+	gerr := collect.validation.ValidateTags(&point)
+	if gerr != nil {
+		return gerr
+	}
+
+	gerr = collect.validation.ValidateProperty(point.Metric, validation.MetricType)
+	if gerr != nil {
+		return gerr
+	}
+
+	gerr = collect.validation.ValidatePolicy(&point)
+	if gerr != nil {
+		return gerr
+	}
+
+	point.Timestamp, gerr = collect.validation.ValidateTimestamp(int64(timeUnixNano / uint64(time.Millisecond)))
+	if gerr != nil {
+		return gerr
+	}
+
+	var key string
+	cumulative := value
+
+	if toDelta {
+		var ok bool
+		key = otlpSeriesKey(&point)
+		value, ok = collect.otlpDeltas.delta(key, start, point.Timestamp, cumulative)
+		if !ok {
+			return nil
+		}
+	}
+
+	point.Value = &value
+
+	validatedPoint, gerr := collect.MakePacket(&point, true)
+	if gerr != nil {
+		return gerr
+	}
+
+	gerr = collect.ValidateLimits(validatedPoint)
+	if gerr != nil {
+		return gerr
+	}
+
+	gerr = collect.HandlePacket(validatedPoint, constants.SourceTypeOTLP, token)
+	if gerr != nil {
+		return gerr
+	}
+
+	if toDelta {
+		collect.otlpDeltas.store(key, start, point.Timestamp, cumulative, value)
+	}
+
+	return nil
+}
+
+// otlpSeriesKey - builds the delta cache key (the tags are already sorted)
+func otlpSeriesKey(point *structs.TSDBpoint) string {
+
+	b := strings.Builder{}
+	b.WriteString(point.Metric)
+
+	for _, tag := range point.Tags {
+		b.WriteByte(0)
+		b.WriteString(tag.Name)
+		b.WriteByte(0)
+		b.WriteString(tag.Value)
+	}
+
+	return b.String()
+}
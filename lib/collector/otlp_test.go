@@ -0,0 +1,332 @@
+package collector
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/otlppb"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const testOTLPTime uint64 = 1600000000 * uint64(time.Second)
+
+func TestOTLPDeltaCache(t *testing.T) {
+
+	cache := newOTLPDeltaCache(0)
+	assert.Equal(t, cOTLPDefaultDeltaExpiry, cache.expiration)
+
+	steps := []struct {
+		name      string
+		start     uint64
+		timestamp int64
+		value     float64
+		expected  float64
+		ok        bool
+	}{
+		{"FirstValueIsDropped", 1, 10, 10, 0, false},
+		{"Delta", 1, 20, 15, 5, true},
+		{"NoChange", 1, 30, 15, 0, true},
+		{"CounterReset", 1, 40, 3, 3, true},
+		{"RetriedExport", 1, 40, 3, 3, true},
+		{"OlderPoint", 1, 35, 20, 0, false},
+		{"NewStartTime", 2, 50, 7, 7, true},
+		{"DeltaAfterRestart", 2, 60, 9.5, 2.5, true},
+	}
+
+	for _, s := range steps {
+		delta, ok := cache.delta("a", s.start, s.timestamp, s.value)
+		assert.Equal(t, s.ok, ok, s.name)
+		assert.Equal(t, s.expected, delta, s.name)
+		if ok {
+			cache.store("a", s.start, s.timestamp, s.value, delta)
+		}
+	}
+
+	_, ok := cache.delta("b", 1, 10, 100)
+	assert.False(t, ok, "each series has its own value")
+
+	_, ok = cache.delta("b", 1, 10, 100)
+	assert.False(t, ok, "the first value is retried without a delta")
+}
+
+func TestOTLPDeltaCacheNotStored(t *testing.T) {
+
+	cache := newOTLPDeltaCache(0)
+	cache.delta("a", 1, 10, 10)
+
+	delta, _ := cache.delta("a", 1, 20, 15)
+	assert.Equal(t, 5.0, delta)
+
+	// the point with the delta 5 was rejected, the next one includes its increment
+	delta, ok := cache.delta("a", 1, 30, 18)
+	assert.True(t, ok)
+	assert.Equal(t, 8.0, delta)
+}
+
+func TestOTLPDeltaCacheExpiration(t *testing.T) {
+
+	cache := newOTLPDeltaCache(time.Millisecond)
+
+	cache.delta("a", 1, 10, 10)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := cache.delta("a", 1, 20, 20)
+	assert.False(t, ok, "the expired series must start again")
+	assert.Len(t, cache.series, 1)
+}
+
+// newTestOTLPCollector - creates a test collector with the otlp receiver configuration
+func newTestOTLPCollector(t *testing.T, conf structs.OTLPConfiguration) *Collector {
+
+	collect := newTestCollector(t, "otlp_ks")
+	collect.settings.OTLP = conf
+	collect.otlpDeltas = newOTLPDeltaCache(0)
+
+	return collect
+}
+
+// otlpPoints - maps the handled points by metric and "le" tag
+func otlpPoints(collect *Collector) map[string]*structs.TSDBpoint {
+
+	points := map[string]*structs.TSDBpoint{}
+
+	for _, p := range handledPoints(collect) {
+
+		key := p.Message.Metric
+		for _, tag := range p.Message.Tags {
+			if tag.Name == cOTLPBucketTag {
+				key += " le=" + tag.Value
+			}
+		}
+
+		points[key] = p.Message
+	}
+
+	return points
+}
+
+func TestHandleOTLPGauge(t *testing.T) {
+
+	collect := newTestOTLPCollector(t, structs.OTLPConfiguration{
+		DefaultKeyset:      "otlp_ks",
+		ResourceAttributes: []string{"service.name"},
+	})
+
+	resource := []otlppb.KeyValue{
+		{Key: "service.name", Value: "api"},
+		{Key: "process.pid", Value: "10"},
+		{Key: "ttl", Value: "7"},
+	}
+
+	metric := &otlppb.Metric{
+		Name: "memory.used",
+		Type: otlppb.MetricTypeGauge,
+		NumberDataPoints: []otlppb.NumberDataPoint{
+			{Attributes: []otlppb.KeyValue{{Key: "host", Value: "a"}, {Key: "empty", Value: ""}}, TimeUnixNano: testOTLPTime, Value: 2.5},
+			{Attributes: []otlppb.KeyValue{{Key: "host", Value: "b"}}, TimeUnixNano: testOTLPTime, Flags: otlppb.DataPointFlagNoRecordedValue},
+		},
+	}
+
+	rejected, _, gerr := collect.handleOTLPMetric(resource, metric, nil)
+	assert.Nil(t, gerr)
+	assert.Zero(t, rejected)
+
+	points := handledPoints(collect)
+	if !assert.Len(t, points, 1, "points without a recorded value are skipped") {
+		return
+	}
+
+	p := points[0].Message
+	assert.Equal(t, "memory.used", p.Metric)
+	assert.Equal(t, "otlp_ks", p.Keyset)
+	assert.Equal(t, 7, p.TTL)
+	assert.Equal(t, int64(1600000000000), p.Timestamp)
+	assert.Equal(t, 2.5, *p.Value)
+	assert.Equal(t, []structs.TSDBTag{
+		{Name: "host", Value: "a"},
+		{Name: "ksid", Value: "otlp_ks"},
+		{Name: "service.name", Value: "api"},
+		{Name: "ttl", Value: "7"},
+	}, p.Tags, "only the configured resource attributes become tags")
+}
+
+func TestHandleOTLPCumulativeSum(t *testing.T) {
+
+	collect := newTestOTLPCollector(t, structs.OTLPConfiguration{
+		KeysetAttribute:   "tenant",
+		CumulativeToDelta: true,
+	})
+
+	resource := []otlppb.KeyValue{{Key: "tenant", Value: "otlp_ks"}, {Key: "host", Value: "a"}}
+
+	sum := func(monotonic bool, value float64) *otlppb.Metric {
+		return &otlppb.Metric{
+			Name:                   "requests",
+			Type:                   otlppb.MetricTypeSum,
+			AggregationTemporality: otlppb.TemporalityCumulative,
+			IsMonotonic:            monotonic,
+			NumberDataPoints:       []otlppb.NumberDataPoint{{StartTimeUnixNano: 1, TimeUnixNano: testOTLPTime + uint64(value)*uint64(time.Second), Value: value}},
+		}
+	}
+
+	collect.handleOTLPMetric(resource, sum(true, 100), nil)
+	assert.Empty(t, handledPoints(collect), "the first cumulative value has no delta")
+
+	collect.handleOTLPMetric(resource, sum(true, 130), nil)
+	points := handledPoints(collect)
+	if assert.Len(t, points, 1) {
+		assert.Equal(t, 30.0, *points[0].Message.Value)
+		assert.Equal(t, "otlp_ks", points[0].Message.Keyset)
+	}
+
+	collect.handleOTLPMetric(resource, sum(true, 130), nil)
+	points = handledPoints(collect)
+	if assert.Len(t, points, 1, "the retried point is sent again") {
+		assert.Equal(t, 30.0, *points[0].Message.Value, "with the same delta")
+	}
+
+	collect.handleOTLPMetric(resource, sum(false, 130), nil)
+	points = handledPoints(collect)
+	if assert.Len(t, points, 1, "non monotonic sums are stored as they are") {
+		assert.Equal(t, 130.0, *points[0].Message.Value)
+	}
+}
+
+func TestHandleOTLPHistogram(t *testing.T) {
+
+	collect := newTestOTLPCollector(t, structs.OTLPConfiguration{DefaultKeyset: "otlp_ks"})
+
+	metric := &otlppb.Metric{
+		Name:                   "latency",
+		Type:                   otlppb.MetricTypeHistogram,
+		AggregationTemporality: otlppb.TemporalityDelta,
+		HistogramDataPoints: []otlppb.HistogramDataPoint{
+			{
+				Attributes:     []otlppb.KeyValue{{Key: "route", Value: "/api"}},
+				TimeUnixNano:   testOTLPTime,
+				Count:          10,
+				Sum:            4.2,
+				HasSum:         true,
+				BucketCounts:   []uint64{2, 5, 3},
+				ExplicitBounds: []float64{0.1, 0.5},
+			},
+		},
+	}
+
+	rejected, _, gerr := collect.handleOTLPMetric(nil, metric, nil)
+	assert.Nil(t, gerr)
+	assert.Zero(t, rejected)
+
+	expected := map[string]float64{
+		"latency.count":         10,
+		"latency.sum":           4.2,
+		"latency.bucket le=0.1": 2,
+		"latency.bucket le=0.5": 7,
+		"latency.bucket le=inf": 10,
+	}
+
+	points := otlpPoints(collect)
+	assert.Len(t, points, len(expected))
+
+	for key, value := range expected {
+		if assert.Contains(t, points, key) {
+			assert.Equal(t, value, *points[key].Value, key)
+		}
+	}
+}
+
+func TestHandleOTLPMetricRejected(t *testing.T) {
+
+	cases := map[string]struct {
+		metric   otlppb.Metric
+		rejected int64
+		err      error
+	}{
+		"UnsupportedType": {
+			metric: otlppb.Metric{
+				Name:             "summary",
+				NumberDataPoints: []otlppb.NumberDataPoint{{}, {}},
+			},
+			rejected: 2,
+			err:      validation.ErrUnsupportedOTLPType,
+		},
+		"NaN": {
+			metric: otlppb.Metric{
+				Name: "gauge",
+				Type: otlppb.MetricTypeGauge,
+				NumberDataPoints: []otlppb.NumberDataPoint{
+					{Attributes: []otlppb.KeyValue{{Key: "host", Value: "a"}}, TimeUnixNano: testOTLPTime, Value: math.NaN()},
+					{Attributes: []otlppb.KeyValue{{Key: "host", Value: "a"}}, TimeUnixNano: testOTLPTime, Value: math.Inf(-1)},
+					{Attributes: []otlppb.KeyValue{{Key: "host", Value: "a"}}, TimeUnixNano: testOTLPTime, Value: 1},
+				},
+			},
+			rejected: 2,
+			err:      validation.ErrParsingValue,
+		},
+		"InvalidAttributeValue": {
+			metric: otlppb.Metric{
+				Name:             "gauge",
+				Type:             otlppb.MetricTypeGauge,
+				NumberDataPoints: []otlppb.NumberDataPoint{{Attributes: []otlppb.KeyValue{{Key: "host", Value: "a b"}}, TimeUnixNano: testOTLPTime}},
+			},
+			rejected: 1,
+			err:      validation.ErrInvalidTagValue,
+		},
+		"UnknownKeyset": {
+			metric: otlppb.Metric{
+				Name:             "gauge",
+				Type:             otlppb.MetricTypeGauge,
+				NumberDataPoints: []otlppb.NumberDataPoint{{Attributes: []otlppb.KeyValue{{Key: "host", Value: "a"}, {Key: "ksid", Value: "unknown"}}, TimeUnixNano: testOTLPTime}},
+			},
+			rejected: 1,
+			err:      validation.ErrInexistentKeyset,
+		},
+		"NoUserTags": {
+			metric: otlppb.Metric{
+				Name:             "gauge",
+				Type:             otlppb.MetricTypeGauge,
+				NumberDataPoints: []otlppb.NumberDataPoint{{TimeUnixNano: testOTLPTime}},
+			},
+			rejected: 1,
+			err:      validation.ErrNoUserTags,
+		},
+		"InvalidMetric": {
+			metric: otlppb.Metric{
+				Name:                "latency seconds",
+				Type:                otlppb.MetricTypeHistogram,
+				HistogramDataPoints: []otlppb.HistogramDataPoint{{Attributes: []otlppb.KeyValue{{Key: "host", Value: "a"}}, TimeUnixNano: testOTLPTime}},
+			},
+			rejected: 1,
+			err:      validation.ErrInvalidMetric,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			collect := newTestOTLPCollector(t, structs.OTLPConfiguration{DefaultKeyset: "otlp_ks"})
+
+			rejected, _, gerr := collect.handleOTLPMetric(nil, &c.metric, nil)
+			assert.Equal(t, c.rejected, rejected)
+			assert.Equal(t, c.err, gerr)
+		})
+	}
+}
+
+func TestOTLPRejected(t *testing.T) {
+
+	assert.True(t, otlpRejected(validation.ErrNoUserTags), "a validation error only rejects the point")
+	assert.True(t, otlpRejected(validation.ErrInexistentKeyset))
+
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		gerr := tserr.New(errors.New("error"), "error", "collector", "test", status)
+		assert.False(t, otlpRejected(gerr), "status %d fails the request", status)
+	}
+}
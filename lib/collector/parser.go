@@ -229,5 +229,10 @@ func (collect *Collector) ParsePoint(function string, isNumber bool, data []byte
 		return nil, p.Keyset, gerr
 	}
 
+	gerr = collect.validation.ValidatePolicy(&p)
+	if gerr != nil {
+		return nil, p.Keyset, gerr
+	}
+
 	return &p, p.Keyset, nil
 }
@@ -0,0 +1,208 @@
+package collector
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/prompb"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+//
+// Implements the prometheus remote write endpoint.
+//
+
+const (
+	cFuncHandlePrometheusWrite string = "HandlePrometheusWrite"
+	cPrometheusMetricLabel     string = "__name__"
+	cPrometheusReservedPrefix  string = "__"
+)
+
+// HandlePrometheusWrite - handles the snappy compressed protobuf prometheus remote write request
+func (collect *Collector) HandlePrometheusWrite(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	ip := collect.sendIPStats(r)
+	token := auth.FromContext(r.Context())
+
+	defer r.Body.Close()
+
+	compressed, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rip.Fail(w, errUnmarshal(cFuncHandlePrometheusWrite, err))
+		return
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		rip.Fail(w, errDecode(cFuncHandlePrometheusWrite, err))
+		return
+	}
+
+	request := prompb.WriteRequest{}
+	if err = request.Unmarshal(data); err != nil {
+		rip.Fail(w, errDecode(cFuncHandlePrometheusWrite, err))
+		return
+	}
+
+	var firstErr gobol.Error
+
+	for i := 0; i < len(request.Timeseries); i++ {
+
+		keyset, gerr := collect.handlePrometheusTimeseries(&request.Timeseries[i], token)
+		if gerr != nil {
+			collect.validation.StatsValidationError(cFuncHandlePrometheusWrite, keyset, ip, constants.SourceTypePrometheus, gerr)
+			if firstErr == nil {
+				firstErr = gerr
+			}
+		}
+	}
+
+	// the valid timeseries are always stored, prometheus does not retry on bad requests
+	if firstErr != nil {
+		rip.Fail(w, firstErr)
+		return
+	}
+
+	rip.Success(w, http.StatusNoContent, nil)
+}
+
+// handlePrometheusTimeseries - validates the timeseries labels and sends its samples to the collector (returns the keyset)
+func (collect *Collector) handlePrometheusTimeseries(ts *prompb.TimeSeries, token *auth.Token) (string, gobol.Error) {
+
+	keysetLabel := collect.settings.Prometheus.KeysetLabel
+	if keysetLabel == constants.StringsEmpty {
+		keysetLabel = constants.StringsKSID
+	}
+
+	ttlLabel := collect.settings.Prometheus.TTLLabel
+	if ttlLabel == constants.StringsEmpty {
+		ttlLabel = constants.StringsTTL
+	}
+
+	point := structs.TSDBpoint{
+		Tags: make([]structs.TSDBTag, 0, len(ts.Labels)+2),
+	}
+
+	keyset := collect.settings.Prometheus.DefaultKeyset
+	ttl := constants.StringsEmpty
+	if collect.settings.Prometheus.DefaultTTL > 0 {
+		ttl = strconv.Itoa(collect.settings.Prometheus.DefaultTTL)
+	}
+
+	var gerr gobol.Error
+
+	for _, label := range ts.Labels {
+
+		switch label.Name {
+		case cPrometheusMetricLabel:
+			point.Metric = label.Value
+		case keysetLabel:
+			keyset = label.Value
+		case ttlLabel:
+			ttl = label.Value
+		default:
+			if strings.HasPrefix(label.Name, cPrometheusReservedPrefix) {
+				continue
+			}
+
+			gerr = collect.validation.ValidateProperty(label.Name, validation.TagKeyType)
+			if gerr != nil {
+				return keyset, gerr
+			}
+
+			gerr = collect.validation.ValidateProperty(label.Value, validation.TagValueType)
+			if gerr != nil {
+				return keyset, gerr
+			}
+
+			point.Tags = append(point.Tags, structs.TSDBTag{Name: label.Name, Value: label.Value})
+		}
+	}
+
+	gerr = collect.validation.ValidateKeyset(keyset)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	point.Keyset = keyset
+	point.Tags = append(point.Tags, structs.TSDBTag{Name: constants.StringsKSID, Value: keyset})
+
+	ttlValue, ttlStr, gerr := collect.validation.ParseTTL(ttl)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	point.TTL = ttlValue
+	point.Tags = append(point.Tags, structs.TSDBTag{Name: constants.StringsTTL, Value: ttlStr})
+
+	gerr = collect.validation.ValidateTags(&point)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	if point.Metric == constants.StringsEmpty {
+		return keyset, validation.ErrNoMetricLabel
+	}
+
+	gerr = collect.validation.ValidateProperty(point.Metric, validation.MetricType)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	gerr = collect.validation.ValidatePolicy(&point)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	var packet *Point
+
+	for _, sample := range ts.Samples {
+
+		// NaN is used by prometheus as the staleness marker
+		if math.IsNaN(sample.Value) {
+			continue
+		}
+
+		samplePoint := point
+		value := sample.Value
+		samplePoint.Value = &value
+
+		samplePoint.Timestamp, gerr = collect.validation.ValidateTimestamp(sample.Timestamp)
+		if gerr != nil {
+			return keyset, gerr
+		}
+
+		if packet == nil {
+			packet, gerr = collect.MakePacket(&samplePoint, true)
+			if gerr != nil {
+				return keyset, gerr
+			}
+		}
+
+		samplePacket := *packet
+		samplePacket.Message = &samplePoint
+
+		gerr = collect.ValidateLimits(&samplePacket)
+		if gerr != nil {
+			return keyset, gerr
+		}
+
+		gerr = collect.HandlePacket(&samplePacket, constants.SourceTypePrometheus, token)
+		if gerr != nil {
+			return keyset, gerr
+		}
+	}
+
+	return keyset, nil
+}
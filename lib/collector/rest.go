@@ -8,7 +8,10 @@ import (
 	"strings"
 
 	"github.com/uol/logh"
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/tlsconfig"
+	"github.com/uol/mycenae/lib/validation"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol/rip"
@@ -40,7 +43,7 @@ func (collect *Collector) handle(w http.ResponseWriter, r *http.Request, ip stri
 		return
 	}
 
-	_, gerr := collect.HandleJSONBytes(bytes, constants.SourceTypeHTTP, ip, number)
+	_, gerr := collect.HandleJSONBytes(bytes, constants.SourceTypeHTTP, ip, number, auth.FromContext(r.Context()))
 	if gerr != nil {
 		rip.Fail(w, gerr)
 		return
@@ -71,12 +74,75 @@ func (collect *Collector) HandleText(w http.ResponseWriter, r *http.Request, _ h
 	collect.handle(w, r, ip, false)
 }
 
+const (
+	cFuncHandleInfluxWrite string = "HandleInfluxWrite"
+	cInfluxParamDB         string = "db"
+	cInfluxParamPrecision  string = "precision"
+)
+
+// HandleInfluxWrite - handles the influxdb line protocol points (the "db" parameter is used as the default keyset)
+func (collect *Collector) HandleInfluxWrite(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	ip := collect.sendIPStats(r)
+
+	defer r.Body.Close()
+
+	precision := r.URL.Query().Get(cInfluxParamPrecision)
+	if precision == constants.StringsEmpty {
+		precision = InfluxPrecisionNanoseconds
+	} else if !ValidInfluxPrecision(precision) {
+		rip.Fail(w, validation.ErrMalformedLine)
+		return
+	}
+
+	keyset := r.URL.Query().Get(cInfluxParamDB)
+	if keyset == constants.StringsEmpty {
+		keyset = collect.settings.Influx.DefaultKeyset
+	}
+
+	var bytes []byte
+	var err error
+
+	if r.Header.Get("Content-Encoding") == "gzip" {
+
+		var gzipReader *gzip.Reader
+
+		gzipReader, err = gzip.NewReader(r.Body)
+		if err != nil {
+			rip.Fail(w, errUnmarshal(cFuncHandleInfluxWrite, err))
+			return
+		}
+
+		defer gzipReader.Close()
+
+		bytes, err = ioutil.ReadAll(gzipReader)
+
+	} else {
+
+		bytes, err = ioutil.ReadAll(r.Body)
+	}
+
+	if err != nil {
+		rip.Fail(w, errUnmarshal(cFuncHandleInfluxWrite, err))
+		return
+	}
+
+	// the valid lines are always stored, only the first error is returned
+	_, gerr := collect.HandleInfluxLines(bytes, constants.SourceTypeInfluxHTTP, ip, precision, keyset, collect.settings.Influx.DefaultTTL, auth.FromContext(r.Context()))
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.Success(w, http.StatusNoContent, nil)
+}
+
 const (
 	cFuncIPStats   string = "sendIPStats"
 	cXForwardedFor string = "X-Forwarded-For"
 )
 
-// sendIPStats - send IP statistics and return the source IP
+// sendIPStats - send IP statistics and return the source IP (or the client certificate identity)
 func (collect *Collector) sendIPStats(r *http.Request) string {
 
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
@@ -96,5 +162,11 @@ func (collect *Collector) sendIPStats(r *http.Request) string {
 
 	statsNetworkIP(ip, constants.StringsHTTP)
 
+	// the client certificate identity replaces the ip in the statistics
+	if identity := tlsconfig.RequestIdentity(r); identity != constants.StringsEmpty {
+		statsNetworkIdentity(identity, constants.StringsHTTP)
+		return identity
+	}
+
 	return ip
 }
@@ -4,22 +4,30 @@ import (
 	"github.com/uol/gobol"
 )
 
-func (collector *Collector) saveValue(packet *Point) gobol.Error {
+// saveValue - inserts the number point, done is called when it is stored (later if it is batched)
+func (collector *Collector) saveValue(packet *Point, batched bool, done func(gerr gobol.Error)) {
 	ksid := collector.keyspaceTTLMap[packet.Message.TTL]
-	return collector.InsertPoint(
+	if batched && collector.batcher != nil && collector.batcher.add(ksid, packet.ID, true, packet.Message.Timestamp, *(packet.Message.Value), done) {
+		return
+	}
+	done(collector.InsertPoint(
 		ksid,
 		packet.ID,
 		packet.Message.Timestamp,
 		*(packet.Message.Value),
-	)
+	))
 }
 
-func (collector *Collector) saveText(packet *Point) gobol.Error {
+// saveText - inserts the text point, done is called when it is stored (later if it is batched)
+func (collector *Collector) saveText(packet *Point, batched bool, done func(gerr gobol.Error)) {
 	ksid := collector.keyspaceTTLMap[packet.Message.TTL]
-	return collector.InsertText(
+	if batched && collector.batcher != nil && collector.batcher.add(ksid, packet.ID, false, packet.Message.Timestamp, packet.Message.Text, done) {
+		return
+	}
+	done(collector.InsertText(
 		ksid,
 		packet.ID,
 		packet.Message.Timestamp,
 		packet.Message.Text,
-	)
+	))
 }
@@ -16,6 +16,18 @@ const (
 	metricTimeseriesCountOld  string = "timeseries.count.old"
 	metricScyllaRollbackError string = "scylla.rollback.error"
 	metricDelayedMetric       string = "delayed.metrics"
+	metricScyllaBatchSize     string = "scylla.batch.size"
+	metricScyllaBatchRetry    string = "scylla.batch.retry"
+	metricScyllaBatchError    string = "scylla.batch.error"
+	metricWALDepth            string = "wal.depth"
+	metricWALSize             string = "wal.size"
+	metricWALLag              string = "wal.replay.lag"
+	metricWALQueued           string = "wal.queued"
+	metricWALDropped          string = "wal.dropped"
+	metricQueueSize           string = "keyset.queue.size"
+	metricQueueRejected       string = "keyset.queue.rejected"
+	metricTSIDMappingError    string = "tsid.mapping.error"
+	metricLastSeenError       string = "timeseries.lastseen.error"
 )
 
 func statsProcTime(ksid string, d time.Duration) {
@@ -37,6 +49,24 @@ func statsLostMeta(ksid string) {
 	)
 }
 
+func statsTSIDMappingError(ksid string) {
+
+	timelineManager.FlattenCountIncN(
+		constants.StringsEmpty,
+		metricTSIDMappingError,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(ksid),
+	)
+}
+
+func statsLastSeenError(ksid string) {
+
+	timelineManager.FlattenCountIncN(
+		constants.StringsEmpty,
+		metricLastSeenError,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(ksid),
+	)
+}
+
 func statsInsertQuery(keyspace string, d time.Duration) {
 
 	timelineManager.FlattenMaxN(
@@ -130,6 +160,15 @@ func statsNetworkIP(ip, source string) {
 		constants.StringsSource, source)
 }
 
+func statsNetworkIdentity(identity, source string) {
+
+	timelineManager.FlattenCountIncN(
+		constants.StringsEmpty,
+		constants.StringsMetricNetworkIdentity,
+		constants.StringsIdentity, identity,
+		constants.StringsSource, source)
+}
+
 func statsDelayedMetrics(ksid string, pastTime int64) {
 
 	timelineManager.FlattenMaxN(
@@ -139,3 +178,98 @@ func statsDelayedMetrics(ksid string, pastTime int64) {
 		constants.StringsTargetKSID, utils.ValidateExpectedValue(ksid),
 	)
 }
+
+func statsBatchSize(keyspace string, size int) {
+
+	timelineManager.FlattenAvgN(
+		constants.StringsEmpty,
+		float64(size),
+		metricScyllaBatchSize,
+		constants.StringsKeyspace, keyspace,
+	)
+}
+
+func statsBatchRetry(keyspace string) {
+
+	timelineManager.FlattenCountIncN(
+		constants.StringsEmpty,
+		metricScyllaBatchRetry,
+		constants.StringsKeyspace, utils.ValidateExpectedValue(keyspace),
+	)
+}
+
+func statsBatchError(keyspace string, lostPoints int) {
+
+	timelineManager.FlattenCountN(
+		constants.StringsEmpty,
+		float64(lostPoints),
+		metricScyllaBatchError,
+		constants.StringsKeyspace, utils.ValidateExpectedValue(keyspace),
+	)
+}
+
+func statsWALDepth(records, size int64) {
+
+	timelineManager.FlattenMaxN(
+		constants.StringsEmpty,
+		float64(records),
+		metricWALDepth,
+	)
+
+	timelineManager.FlattenMaxN(
+		constants.StringsEmpty,
+		float64(size),
+		metricWALSize,
+	)
+}
+
+func statsWALLag(lag int64) {
+
+	timelineManager.FlattenMaxN(
+		constants.StringsEmpty,
+		float64(lag),
+		metricWALLag,
+	)
+}
+
+func statsWALQueued(ksid, reason string, points int) {
+
+	timelineManager.FlattenCountN(
+		constants.StringsEmpty,
+		float64(points),
+		metricWALQueued,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(ksid),
+		constants.StringsType, reason,
+	)
+}
+
+func statsWALDropped(ksid, reason string, points int) {
+
+	timelineManager.FlattenCountN(
+		constants.StringsEmpty,
+		float64(points),
+		metricWALDropped,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(ksid),
+		constants.StringsType, reason,
+	)
+}
+
+func statsQueueSize(ksid string, size int) {
+
+	timelineManager.FlattenMaxN(
+		constants.StringsEmpty,
+		float64(size),
+		metricQueueSize,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(ksid),
+	)
+}
+
+func statsQueueRejected(ksid string, sourceType *constants.SourceType) {
+
+	timelineManager.FlattenCountIncN(
+		constants.StringsEmpty,
+		metricQueueRejected,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(ksid),
+		constants.StringsProtocol, sourceType.Name,
+	)
+}
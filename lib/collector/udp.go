@@ -3,7 +3,9 @@ package collector
 import (
 	"github.com/uol/gobol"
 	"github.com/uol/logh"
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
 )
 
 // HandleUDPpacket - handles the UDP packet received from the collector
@@ -11,12 +13,45 @@ func (collector *Collector) HandleUDPpacket(buf []byte, addr string) {
 
 	statsNetworkIP(addr, constants.StringsUDP)
 
-	_, gerr := collector.HandleJSONBytes(buf, constants.SourceTypeUDP, addr, true)
+	_, gerr := collector.HandleJSONBytes(buf, constants.SourceTypeUDP, addr, true, auth.SharedSecret)
 	if gerr != nil {
 		collector.fail(gerr, addr)
 	}
 }
 
+// InfluxUDPHandler - handles the influxdb line protocol packets received by UDP
+type InfluxUDPHandler struct {
+	collector     *Collector
+	defaultKeyset string
+	defaultTTL    int
+}
+
+// NewInfluxUDPHandler - creates a new line protocol UDP handler using the server defaults
+func (collector *Collector) NewInfluxUDPHandler(conf *structs.SettingsUDP) *InfluxUDPHandler {
+
+	return &InfluxUDPHandler{
+		collector:     collector,
+		defaultKeyset: conf.DefaultKeyset,
+		defaultTTL:    conf.DefaultTTL,
+	}
+}
+
+// HandleUDPpacket - handles the line protocol UDP packet
+func (iuh *InfluxUDPHandler) HandleUDPpacket(buf []byte, addr string) {
+
+	statsNetworkIP(addr, constants.StringsUDP)
+
+	_, gerr := iuh.collector.HandleInfluxLines(buf, constants.SourceTypeInfluxUDP, addr, InfluxPrecisionNanoseconds, iuh.defaultKeyset, iuh.defaultTTL, auth.SharedSecret)
+	if gerr != nil {
+		iuh.collector.fail(gerr, addr)
+	}
+}
+
+// Stop - stops the collector
+func (iuh *InfluxUDPHandler) Stop() {
+	iuh.collector.Stop()
+}
+
 func (collector *Collector) fail(gerr gobol.Error, addr string) {
 
 	defer func() {
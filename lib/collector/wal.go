@@ -0,0 +1,887 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// An on disk write ahead queue used when scylla fails or the job channel is full.
+// The records are appended to segment files (one JSON per line) and replayed in order.
+// A replayed record may be written again after a restart (the inserts are idempotent).
+//
+
+const (
+	cWALSegmentExt             string        = ".wal"
+	cWALCheckpointFile         string        = "checkpoint"
+	cWALCheckpointEvery        int           = 1000
+	cWALDefaultSegmentSize     int64         = 64 * 1024 * 1024
+	cWALDefaultMaxSize         int64         = 1024 * 1024 * 1024
+	cWALDefaultRetryInterval   time.Duration = time.Second
+	cWALDefaultStatsInterval   time.Duration = 10 * time.Second
+	cFuncWALReplay             string        = "walReplay"
+	cFuncWALAppend             string        = "walAppend"
+	cFuncNewWriteAheadQueue    string        = "newWriteAheadQueue"
+	cFmtWALSegmentName         string        = "%020d" + cWALSegmentExt
+	cFmtWALCheckpoint          string        = "%d %d\n"
+	cWALErrQueueFull           string        = "write ahead queue is full"
+	cWALErrQueueClosed         string        = "write ahead queue is closed"
+	cWALErrInvalidCheckpoint   string        = "invalid write ahead queue checkpoint"
+	cWALDirectoryPermission    os.FileMode   = 0755
+	cWALFilePermission         os.FileMode   = 0644
+	cWALSegmentOpenFlags       int           = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	cWALCheckpointOpenFlags    int           = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	cWALReplayErrorLogInterval time.Duration = time.Minute
+	cWALSyncBatch              string        = "batch"
+	cWALSyncNone               string        = "none"
+	cWALPositiveInf            string        = "+Inf"
+	cWALNegativeInf            string        = "-Inf"
+	cWALNaN                    string        = "NaN"
+)
+
+var (
+	errWALQueueFull   = errors.New(cWALErrQueueFull)
+	errWALQueueClosed = errors.New(cWALErrQueueClosed)
+)
+
+// walFloat - a float encoded as a string when it is not a valid JSON number (infinite or NaN)
+type walFloat float64
+
+// MarshalJSON - encodes the float
+func (f walFloat) MarshalJSON() ([]byte, error) {
+
+	v := float64(f)
+
+	switch {
+	case math.IsInf(v, 1):
+		return json.Marshal(cWALPositiveInf)
+	case math.IsInf(v, -1):
+		return json.Marshal(cWALNegativeInf)
+	case math.IsNaN(v):
+		return json.Marshal(cWALNaN)
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON - decodes the float
+func (f *walFloat) UnmarshalJSON(data []byte) error {
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return json.Unmarshal(data, (*float64)(f))
+	}
+
+	switch s {
+	case cWALPositiveInf:
+		*f = walFloat(math.Inf(1))
+	case cWALNegativeInf:
+		*f = walFloat(math.Inf(-1))
+	case cWALNaN:
+		*f = walFloat(math.NaN())
+	default:
+		return fmt.Errorf("invalid write ahead queue value: %s", s)
+	}
+
+	return nil
+}
+
+// walInsert - a point insert (from a failed batch) without the metadata part
+type walInsert struct {
+	Keyspace  string   `json:"ksid"`
+	TSID      string   `json:"tsid"`
+	Number    bool     `json:"number"`
+	Timestamp int64    `json:"timestamp"`
+	Value     walFloat `json:"value,omitempty"`
+	Text      string   `json:"text,omitempty"`
+}
+
+// walRecord - a queued record, a full point or only an insert (the point value is kept apart
+// when it is not a valid JSON number)
+type walRecord struct {
+	Created int64                 `json:"created"`
+	Point   *Point                `json:"point,omitempty"`
+	Value   *walFloat             `json:"value,omitempty"`
+	Source  *constants.SourceType `json:"source,omitempty"`
+	Insert  *walInsert            `json:"insert,omitempty"`
+}
+
+// encode - the JSON line of the record
+func (record *walRecord) encode() ([]byte, error) {
+
+	if p := record.Point; p != nil && p.Message != nil && p.Message.Value != nil {
+
+		if v := *p.Message.Value; math.IsInf(v, 0) || math.IsNaN(v) {
+
+			message := *p.Message
+			message.Value = nil
+
+			point := *p
+			point.Message = &message
+
+			value := walFloat(v)
+
+			encoded := *record
+			encoded.Point = &point
+			encoded.Value = &value
+
+			record = &encoded
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// decode - reads the JSON line of the record
+func (record *walRecord) decode(line []byte) error {
+
+	if err := json.Unmarshal(line, record); err != nil {
+		return err
+	}
+
+	if record.Value != nil && record.Point != nil && record.Point.Message != nil {
+		value := float64(*record.Value)
+		record.Point.Message.Value = &value
+		record.Value = nil
+	}
+
+	return nil
+}
+
+// writeAheadQueue - the segmented on disk queue
+type writeAheadQueue struct {
+	directory     string
+	segmentSize   int64
+	maxSize       int64
+	retryInterval time.Duration
+	syncBatch     bool
+	replayFunc    func(record *walRecord) gobol.Error
+
+	mutex        sync.Mutex
+	segments     []uint64
+	writeSeq     uint64
+	writeFile    *os.File
+	writeOffset  int64
+	readSeq      uint64
+	readFile     *os.File
+	reader       *bufio.Reader
+	readOffset   int64
+	head         *walRecord
+	headSize     int64
+	depth        int64
+	size         int64
+	replayed     int
+	closed       bool
+	notifyChan   chan struct{}
+	stopChannel  chan struct{}
+	waitGroup    sync.WaitGroup
+	lastErrorLog time.Time
+	logger       *logh.ContextualLogger
+}
+
+// newWriteAheadQueue - opens the queue directory, loading the records not replayed yet, and starts the replay
+func newWriteAheadQueue(conf *structs.WriteAheadQueueConfiguration, replayFunc func(record *walRecord) gobol.Error) (*writeAheadQueue, error) {
+
+	q := &writeAheadQueue{
+		directory:     conf.Directory,
+		segmentSize:   conf.SegmentSize,
+		maxSize:       conf.MaxSize,
+		retryInterval: conf.RetryInterval.Duration,
+		replayFunc:    replayFunc,
+		notifyChan:    make(chan struct{}, 1),
+		stopChannel:   make(chan struct{}),
+		logger:        logh.CreateContextualLogger(constants.StringsPKG, "collector/wal"),
+	}
+
+	if q.segmentSize <= 0 {
+		q.segmentSize = cWALDefaultSegmentSize
+	}
+
+	if q.maxSize <= 0 {
+		q.maxSize = cWALDefaultMaxSize
+	}
+
+	if q.retryInterval <= 0 {
+		q.retryInterval = cWALDefaultRetryInterval
+	}
+
+	switch conf.SyncPolicy {
+	case constants.StringsEmpty, cWALSyncBatch:
+		q.syncBatch = true
+	case cWALSyncNone:
+	default:
+		return nil, fmt.Errorf("invalid write ahead queue sync policy: %s", conf.SyncPolicy)
+	}
+
+	if err := os.MkdirAll(q.directory, cWALDirectoryPermission); err != nil {
+		return nil, err
+	}
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	if logh.InfoEnabled && q.depth > 0 {
+		q.logger.Info().Str(constants.StringsFunc, cFuncNewWriteAheadQueue).Int64("records", q.depth).Int("segments", len(q.segments)).Msg("records to replay found")
+	}
+
+	q.waitGroup.Add(2)
+	go q.replay()
+	go q.stats()
+
+	return q, nil
+}
+
+// segmentPath - returns the segment file path
+func (q *writeAheadQueue) segmentPath(seq uint64) string {
+
+	return filepath.Join(q.directory, fmt.Sprintf(cFmtWALSegmentName, seq))
+}
+
+// load - lists the segments, removes the already replayed ones and counts the pending records
+func (q *writeAheadQueue) load() error {
+
+	files, err := ioutil.ReadDir(q.directory)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, cWALSegmentExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, cWALSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		q.segments = append(q.segments, seq)
+	}
+
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	checkpointSeq, checkpointOffset, err := q.readCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	for len(q.segments) > 0 && q.segments[0] < checkpointSeq {
+		if err := os.Remove(q.segmentPath(q.segments[0])); err != nil {
+			return err
+		}
+		q.segments = q.segments[1:]
+	}
+
+	// new records always go to a new segment, the last one may end with a partial line
+	q.writeSeq = checkpointSeq
+
+	if len(q.segments) == 0 {
+		q.readSeq = checkpointSeq
+		return nil
+	}
+
+	q.writeSeq = q.segments[len(q.segments)-1]
+
+	for i, seq := range q.segments {
+
+		var offset int64
+		if i == 0 && seq == checkpointSeq {
+			offset = checkpointOffset
+		}
+
+		records, size, err := q.countRecords(seq, offset)
+		if err != nil {
+			return err
+		}
+
+		q.depth += records
+		q.size += size
+	}
+
+	q.readSeq = q.segments[0]
+	if q.readSeq == checkpointSeq {
+		q.readOffset = checkpointOffset
+	}
+
+	return nil
+}
+
+// countRecords - counts the complete lines of the segment after the offset
+func (q *writeAheadQueue) countRecords(seq uint64, offset int64) (int64, int64, error) {
+
+	file, err := os.Open(q.segmentPath(seq))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	var records, size int64
+	reader := bufio.NewReader(file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return records, size, nil
+		}
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		records++
+		size += int64(len(line))
+	}
+}
+
+// readCheckpoint - returns the segment and offset of the next record to be replayed
+func (q *writeAheadQueue) readCheckpoint() (uint64, int64, error) {
+
+	data, err := ioutil.ReadFile(filepath.Join(q.directory, cWALCheckpointFile))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var seq uint64
+	var offset int64
+
+	if _, err := fmt.Sscanf(string(data), cFmtWALCheckpoint, &seq, &offset); err != nil {
+		return 0, 0, fmt.Errorf("%s: %s", cWALErrInvalidCheckpoint, err.Error())
+	}
+
+	return seq, offset, nil
+}
+
+// writeCheckpoint - stores the replay position (must be called with the lock)
+func (q *writeAheadQueue) writeCheckpoint() error {
+
+	file, err := os.OpenFile(filepath.Join(q.directory, cWALCheckpointFile), cWALCheckpointOpenFlags, cWALFilePermission)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(file, cFmtWALCheckpoint, q.readSeq, q.readOffset); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// append - adds a record to the end of the queue
+func (q *writeAheadQueue) append(record *walRecord) error {
+
+	_, err := q.appendBatch([]*walRecord{record})
+
+	return err
+}
+
+// appendBatch - adds the records to the end of the queue, stopping at the first error, and syncs the
+// segments once for all of them (sync policy "batch"), returns the number of appended records
+func (q *writeAheadQueue) appendBatch(records []*walRecord) (int, error) {
+
+	created := time.Now().UnixNano() / int64(time.Millisecond)
+
+	lines := make([][]byte, len(records))
+
+	for i, record := range records {
+
+		record.Created = created
+
+		data, err := record.encode()
+		if err != nil {
+			return 0, err
+		}
+
+		lines[i] = data
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return 0, errWALQueueClosed
+	}
+
+	var appended int
+	var err error
+
+	for _, data := range lines {
+
+		if err = q.write(data); err != nil {
+			break
+		}
+
+		appended++
+	}
+
+	if appended == 0 {
+		return 0, err
+	}
+
+	select {
+	case q.notifyChan <- struct{}{}:
+	default:
+	}
+
+	if q.syncBatch && q.writeFile != nil {
+		if syncErr := q.writeFile.Sync(); syncErr != nil {
+			// the records may still be replayed (the inserts are idempotent), but they are not acknowledged
+			return 0, syncErr
+		}
+	}
+
+	return appended, err
+}
+
+// write - writes the record line to the current segment (must be called with the lock)
+func (q *writeAheadQueue) write(data []byte) error {
+
+	size := int64(len(data))
+
+	if q.size+size > q.maxSize {
+		return errWALQueueFull
+	}
+
+	if q.writeFile != nil && q.writeOffset >= q.segmentSize {
+		if err := q.closeWriteSegment(); err != nil {
+			return err
+		}
+	}
+
+	if q.writeFile == nil {
+		if err := q.openWriteSegment(); err != nil {
+			return err
+		}
+	}
+
+	n, err := q.writeFile.Write(data)
+	if err != nil {
+		// the partial line is left behind and the segment is replaced
+		q.writeOffset += int64(n)
+		q.closeWriteSegment()
+		return err
+	}
+
+	q.writeOffset += size
+	q.size += size
+	q.depth++
+
+	return nil
+}
+
+// openWriteSegment - creates the next segment file (must be called with the lock)
+func (q *writeAheadQueue) openWriteSegment() error {
+
+	seq := q.writeSeq + 1
+
+	file, err := os.OpenFile(q.segmentPath(seq), cWALSegmentOpenFlags, cWALFilePermission)
+	if err != nil {
+		return err
+	}
+
+	if len(q.segments) == 0 {
+		q.readSeq = seq
+		q.readOffset = 0
+	}
+
+	q.writeSeq = seq
+	q.writeFile = file
+	q.writeOffset = 0
+	q.segments = append(q.segments, seq)
+
+	return nil
+}
+
+// closeWriteSegment - syncs and closes the current segment file (must be called with the lock)
+func (q *writeAheadQueue) closeWriteSegment() error {
+
+	if q.writeFile == nil {
+		return nil
+	}
+
+	syncErr := q.writeFile.Sync()
+	closeErr := q.writeFile.Close()
+	q.writeFile = nil
+
+	if syncErr != nil {
+		return syncErr
+	}
+
+	return closeErr
+}
+
+// peek - returns the first record of the queue without removing it (must be called with the lock)
+func (q *writeAheadQueue) peek() (*walRecord, bool, error) {
+
+	if q.head != nil {
+		return q.head, true, nil
+	}
+
+	for len(q.segments) > 0 {
+
+		if q.readFile == nil {
+
+			file, err := os.Open(q.segmentPath(q.readSeq))
+			if err != nil {
+				return nil, false, err
+			}
+
+			if _, err := file.Seek(q.readOffset, io.SeekStart); err != nil {
+				file.Close()
+				return nil, false, err
+			}
+
+			q.readFile = file
+			q.reader = bufio.NewReader(file)
+		}
+
+		line, err := q.reader.ReadBytes('\n')
+
+		if err == nil {
+
+			q.headSize = int64(len(line))
+			q.head = &walRecord{}
+
+			if err := q.head.decode(bytes.TrimSpace(line)); err != nil {
+				q.head = nil
+				q.discard()
+				return nil, false, err
+			}
+
+			return q.head, true, nil
+		}
+
+		if err != io.EOF {
+			return nil, false, err
+		}
+
+		// all records were replayed, the next ones go to a new segment
+		if q.readSeq == q.writeSeq && q.writeFile != nil {
+			if err := q.closeWriteSegment(); err != nil {
+				return nil, false, err
+			}
+		}
+
+		// a partial line (failed write or crash) is ignored, it was not acknowledged
+		if err := q.removeReadSegment(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// discard - removes the first record from the queue (must be called with the lock)
+func (q *writeAheadQueue) discard() {
+
+	q.readOffset += q.headSize
+	q.size -= q.headSize
+	q.depth--
+	q.head = nil
+	q.headSize = 0
+	q.replayed++
+
+	if q.replayed >= cWALCheckpointEvery {
+		q.checkpoint()
+	}
+}
+
+// checkpoint - stores the replay position logging the errors (must be called with the lock)
+func (q *writeAheadQueue) checkpoint() {
+
+	q.replayed = 0
+
+	if err := q.writeCheckpoint(); err != nil {
+		if logh.ErrorEnabled {
+			q.logger.Error().Str(constants.StringsFunc, cFuncWALReplay).Err(err).Msg("error writing the checkpoint")
+		}
+	}
+}
+
+// removeReadSegment - deletes the fully replayed segment and moves to the next one (must be called with the lock)
+func (q *writeAheadQueue) removeReadSegment() error {
+
+	if q.readFile != nil {
+		q.readFile.Close()
+		q.readFile = nil
+		q.reader = nil
+	}
+
+	if err := os.Remove(q.segmentPath(q.readSeq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	q.segments = q.segments[1:]
+	q.readOffset = 0
+
+	if len(q.segments) > 0 {
+		q.readSeq = q.segments[0]
+	} else {
+		q.readSeq = q.writeSeq + 1
+	}
+
+	q.checkpoint()
+
+	return nil
+}
+
+// replay - writes the queued records in order, retrying the first one until it succeeds
+func (q *writeAheadQueue) replay() {
+
+	defer q.waitGroup.Done()
+
+	for {
+		q.mutex.Lock()
+		record, ok, err := q.peek()
+		q.mutex.Unlock()
+
+		if err != nil {
+			q.logReplayError(err, "error reading the write ahead queue")
+			ok = false
+		}
+
+		if !ok {
+			select {
+			case <-q.notifyChan:
+			case <-time.After(q.retryInterval):
+			case <-q.stopChannel:
+				return
+			}
+			continue
+		}
+
+		if gerr := q.replayFunc(record); gerr != nil {
+			q.logReplayError(gerr, "error replaying the write ahead queue")
+			select {
+			case <-time.After(q.retryInterval):
+			case <-q.stopChannel:
+				return
+			}
+			continue
+		}
+
+		q.mutex.Lock()
+		q.discard()
+		q.mutex.Unlock()
+
+		select {
+		case <-q.stopChannel:
+			return
+		default:
+		}
+	}
+}
+
+// logReplayError - logs the replay errors at most once per interval (scylla may be down for a long time)
+func (q *writeAheadQueue) logReplayError(err error, msg string) {
+
+	if !logh.ErrorEnabled || time.Since(q.lastErrorLog) < cWALReplayErrorLogInterval {
+		return
+	}
+
+	q.lastErrorLog = time.Now()
+	q.logger.Error().Str(constants.StringsFunc, cFuncWALReplay).Err(err).Msg(msg)
+}
+
+// stats - sends the queue depth and the replay lag periodically
+func (q *writeAheadQueue) stats() {
+
+	defer q.waitGroup.Done()
+
+	ticker := time.NewTicker(cWALDefaultStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.stopChannel:
+			return
+		}
+
+		q.mutex.Lock()
+		depth := q.depth
+		size := q.size
+		var lag int64
+		if q.head != nil {
+			lag = time.Now().UnixNano()/int64(time.Millisecond) - q.head.Created
+		}
+		q.mutex.Unlock()
+
+		statsWALDepth(depth, size)
+		statsWALLag(lag)
+	}
+}
+
+// close - stops the replay and stores the current position
+func (q *writeAheadQueue) close() {
+
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return
+	}
+	q.closed = true
+	q.mutex.Unlock()
+
+	close(q.stopChannel)
+	q.waitGroup.Wait()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.checkpoint()
+
+	if err := q.closeWriteSegment(); err != nil {
+		if logh.ErrorEnabled {
+			q.logger.Error().Str(constants.StringsFunc, cFuncWALAppend).Err(err).Msg("error closing the segment")
+		}
+	}
+
+	if q.readFile != nil {
+		q.readFile.Close()
+		q.readFile = nil
+	}
+}
+
+const (
+	cWALReasonError string = "error"
+	cWALReasonFull  string = "full"
+	cWALReasonBatch string = "batch"
+)
+
+// enqueuePacket - appends the point to the write ahead queue, returns false if it was not queued
+func (collect *Collector) enqueuePacket(point *Point, source *constants.SourceType, reason string) bool {
+
+	if collect.wal == nil {
+		return false
+	}
+
+	err := collect.wal.append(&walRecord{
+		Point:  point,
+		Source: source,
+	})
+
+	return collect.checkEnqueue(point.Message.Keyset, reason, 1, err)
+}
+
+// spillBatch - appends the points of a failed batch to the write ahead queue, returns the number of appended points
+func (collect *Collector) spillBatch(pb pendingBatch) int {
+
+	records := make([]*walRecord, len(pb.entries))
+
+	for i, entry := range pb.entries {
+
+		insert := &walInsert{
+			Keyspace:  pb.key.keyspace,
+			TSID:      pb.key.tsid,
+			Number:    pb.key.number,
+			Timestamp: entry.timestamp,
+		}
+
+		if pb.key.number {
+			value, _ := entry.value.(float64)
+			insert.Value = walFloat(value)
+		} else {
+			insert.Text, _ = entry.value.(string)
+		}
+
+		records[i] = &walRecord{Insert: insert}
+	}
+
+	appended, err := collect.wal.appendBatch(records)
+
+	if appended > 0 {
+		collect.checkEnqueue(pb.key.keyspace, cWALReasonBatch, appended, nil)
+	}
+
+	if err != nil {
+		collect.checkEnqueue(pb.key.keyspace, cWALReasonBatch, len(records)-appended, err)
+	}
+
+	return appended
+}
+
+// checkEnqueue - sends the write ahead queue stats and logs the append error
+func (collect *Collector) checkEnqueue(keyset, reason string, points int, err error) bool {
+
+	if err != nil {
+		statsWALDropped(keyset, reason, points)
+		if logh.ErrorEnabled {
+			collect.logger.Error().Str(constants.StringsFunc, cFuncWALAppend).Str("reason", reason).Err(err).Send()
+		}
+		return false
+	}
+
+	statsWALQueued(keyset, reason, points)
+
+	return true
+}
+
+// replayRecord - writes a queued record to scylla (and the metadata storage when it is a full point)
+func (collect *Collector) replayRecord(record *walRecord) gobol.Error {
+
+	if record.Insert != nil {
+
+		if record.Insert.Number {
+			return collect.InsertPoint(record.Insert.Keyspace, record.Insert.TSID, record.Insert.Timestamp, float64(record.Insert.Value))
+		}
+
+		return collect.InsertText(record.Insert.Keyspace, record.Insert.TSID, record.Insert.Timestamp, record.Insert.Text)
+	}
+
+	if record.Point == nil || record.Point.Message == nil {
+		return nil
+	}
+
+	// not batched, done is called before returning
+	var gerr gobol.Error
+	collect.processPacket(record.Point, false, func(err gobol.Error) { gerr = err })
+	if gerr != nil {
+		return gerr
+	}
+
+	statsPoints(record.Point.Message.Keyset, collect.getType(record.Point.Number), record.Source, record.Point.Message.TTL)
+
+	return nil
+}
@@ -0,0 +1,439 @@
+package collector
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/funks"
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const testWALTimeout = 5 * time.Second
+
+func testWALConf(t *testing.T, directory string) *structs.WriteAheadQueueConfiguration {
+
+	if directory == "" {
+		directory = t.TempDir()
+	}
+
+	return &structs.WriteAheadQueueConfiguration{
+		Enabled:       true,
+		Directory:     directory,
+		RetryInterval: funks.Duration{Duration: 10 * time.Millisecond},
+	}
+}
+
+// collectReplayed - returns a replay function sending the insert timestamps to the channel
+func collectReplayed(accept func(ts int64) bool) (func(*walRecord) gobol.Error, chan int64) {
+
+	replayed := make(chan int64, 100)
+
+	return func(record *walRecord) gobol.Error {
+		if accept != nil && !accept(record.Insert.Timestamp) {
+			return errInternalServerError("replay", "scylla is down", errors.New("unavailable"))
+		}
+		replayed <- record.Insert.Timestamp
+		return nil
+	}, replayed
+}
+
+func appendInserts(t *testing.T, q *writeAheadQueue, timestamps ...int64) {
+
+	for _, ts := range timestamps {
+		err := q.append(&walRecord{Insert: &walInsert{Keyspace: "ks", TSID: "a", Number: true, Timestamp: ts, Value: walFloat(ts)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func receiveReplayed(t *testing.T, replayed chan int64, n int) []int64 {
+
+	received := []int64{}
+
+	for len(received) < n {
+		select {
+		case ts := <-replayed:
+			received = append(received, ts)
+		case <-time.After(testWALTimeout):
+			t.Fatalf("replayed %v, expected %d records", received, n)
+		}
+	}
+
+	return received
+}
+
+func walSegments(t *testing.T, directory string) []string {
+
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := []string{}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), cWALSegmentExt) {
+			segments = append(segments, f.Name())
+		}
+	}
+
+	return segments
+}
+
+func TestWriteAheadQueueReplayInOrder(t *testing.T) {
+
+	conf := testWALConf(t, "")
+
+	replayFunc, replayed := collectReplayed(nil)
+
+	q, err := newWriteAheadQueue(conf, replayFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	appendInserts(t, q, 1, 2, 3, 4, 5)
+
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, receiveReplayed(t, replayed, 5))
+
+	assert.Eventually(t, func() bool {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		return q.depth == 0 && q.size == 0 && len(q.segments) == 0
+	}, testWALTimeout, 10*time.Millisecond)
+
+	assert.Empty(t, walSegments(t, conf.Directory), "the replayed segment is removed")
+}
+
+func TestWriteAheadQueueRetriesTheFirstRecord(t *testing.T) {
+
+	var failures int32 = 3
+
+	replayFunc, replayed := collectReplayed(func(ts int64) bool {
+		return ts != 1 || atomic.AddInt32(&failures, -1) < 0
+	})
+
+	q, err := newWriteAheadQueue(testWALConf(t, ""), replayFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	appendInserts(t, q, 1, 2)
+
+	assert.Equal(t, []int64{1, 2}, receiveReplayed(t, replayed, 2), "the next record waits the first one")
+	assert.True(t, atomic.LoadInt32(&failures) < 0)
+}
+
+func TestWriteAheadQueueRestart(t *testing.T) {
+
+	conf := testWALConf(t, "")
+	conf.SegmentSize = 1
+
+	// only the first record is written before the restart
+	replayFunc, replayed := collectReplayed(func(ts int64) bool { return ts == 1 })
+
+	q, err := newWriteAheadQueue(conf, replayFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendInserts(t, q, 1, 2, 3)
+
+	// the first segment may already be replayed and removed
+	assert.Equal(t, []int64{1}, receiveReplayed(t, replayed, 1))
+	segments := walSegments(t, conf.Directory)
+	assert.Contains(t, segments, "00000000000000000002.wal", "one record for each segment")
+	assert.Contains(t, segments, "00000000000000000003.wal", "one record for each segment")
+
+	q.close()
+
+	assert.Error(t, q.append(&walRecord{}), "the closed queue does not accept records")
+
+	replayFunc, replayed = collectReplayed(nil)
+
+	q, err = newWriteAheadQueue(conf, replayFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	assert.Equal(t, []int64{2, 3}, receiveReplayed(t, replayed, 2), "the replayed record is not written again")
+
+	appendInserts(t, q, 4)
+	assert.Equal(t, []int64{4}, receiveReplayed(t, replayed, 1))
+}
+
+func TestWriteAheadQueueIgnoresThePartialLine(t *testing.T) {
+
+	directory := t.TempDir()
+
+	segment := `{"created":1,"insert":{"ksid":"ks","tsid":"a","number":true,"timestamp":7,"value":1}}` + "\n" + `{"created":1,"ins`
+	if err := ioutil.WriteFile(filepath.Join(directory, "00000000000000000001.wal"), []byte(segment), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	replayFunc, replayed := collectReplayed(nil)
+
+	q, err := newWriteAheadQueue(testWALConf(t, directory), replayFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	assert.Equal(t, []int64{7}, receiveReplayed(t, replayed, 1))
+
+	// the new records go to a new segment
+	appendInserts(t, q, 8)
+	assert.Equal(t, []int64{8}, receiveReplayed(t, replayed, 1))
+}
+
+func TestWriteAheadQueueFull(t *testing.T) {
+
+	conf := testWALConf(t, "")
+	conf.MaxSize = 200
+
+	replayFunc, _ := collectReplayed(func(int64) bool { return false })
+
+	q, err := newWriteAheadQueue(conf, replayFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	appendInserts(t, q, 1)
+
+	err = q.append(&walRecord{Insert: &walInsert{Keyspace: "ks", TSID: strings.Repeat("a", 200), Timestamp: 2}})
+	assert.Equal(t, errWALQueueFull, err)
+}
+
+func TestWriteAheadQueueInvalidCheckpoint(t *testing.T) {
+
+	directory := t.TempDir()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, cWALCheckpointFile), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := newWriteAheadQueue(testWALConf(t, directory), nil)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), cWALErrInvalidCheckpoint))
+	}
+
+	_, err = newWriteAheadQueue(testWALConf(t, filepath.Join(directory, cWALCheckpointFile, "sub")), nil)
+	assert.Error(t, err, "the directory can not be created")
+}
+
+// walRecords - decodes the records stored in the segments
+func walRecords(t *testing.T, directory string) []walRecord {
+
+	records := []walRecord{}
+
+	for _, name := range walSegments(t, directory) {
+
+		data, err := ioutil.ReadFile(filepath.Join(directory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			record := walRecord{}
+			if err := record.decode([]byte(line)); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func TestCollectorEnqueuesToTheWriteAheadQueue(t *testing.T) {
+
+	timelineManager = &tlmanager.Instance{}
+
+	conf := testWALConf(t, "")
+
+	q, err := newWriteAheadQueue(conf, func(*walRecord) gobol.Error {
+		return errInternalServerError("replay", "scylla is down", errors.New("unavailable"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := &Collector{
+		queues:     newFairQueue(&structs.FairQueueConfiguration{QueueSize: 1}, 1),
+		wal:        q,
+		validation: &validation.Service{},
+		logger:     logh.CreateContextualLogger("pkg", "collector"),
+	}
+
+	value := 1.5
+	point := func(id string) *Point {
+		return &Point{
+			ID:      id,
+			Number:  true,
+			Message: &structs.TSDBpoint{Metric: "cpu", Keyset: "ks", Timestamp: 10, Value: &value, TTL: 1},
+		}
+	}
+
+	assert.Nil(t, collect.HandlePacket(point("a"), constants.SourceTypeHTTP, nil))
+	assert.Nil(t, collect.HandlePacket(point("b"), constants.SourceTypeHTTP, nil))
+
+	if handled := handledPoints(collect); assert.Len(t, handled, 1) {
+		assert.Equal(t, "a", handled[0].ID, "the keyset queue is used first")
+	}
+
+	spilled := collect.spillBatch(pendingBatch{
+		key:     batchKey{keyspace: "ts01", tsid: "c", number: false},
+		entries: []batchEntry{{1, "up", nil}, {2, "down", nil}},
+	})
+	assert.Equal(t, 2, spilled, "all points are appended")
+
+	q.close()
+
+	records := walRecords(t, conf.Directory)
+	if !assert.Len(t, records, 3) {
+		return
+	}
+
+	if assert.NotNil(t, records[0].Point, "the full point is queued when the channel is full") {
+		assert.Equal(t, "b", records[0].Point.ID)
+		assert.Equal(t, value, *records[0].Point.Message.Value)
+	}
+
+	assert.Equal(t, &walInsert{Keyspace: "ts01", TSID: "c", Timestamp: 1, Text: "up"}, records[1].Insert)
+	assert.Equal(t, &walInsert{Keyspace: "ts01", TSID: "c", Timestamp: 2, Text: "down"}, records[2].Insert)
+
+	// the closed queue does not accept points, the saturated keyset rejects them
+	assert.Nil(t, collect.HandlePacket(point("d"), constants.SourceTypeHTTP, nil))
+
+	gerr := collect.HandlePacket(point("e"), constants.SourceTypeHTTP, nil)
+	if assert.NotNil(t, gerr) {
+		assert.Equal(t, http.StatusTooManyRequests, gerr.StatusCode())
+	}
+
+	assert.Len(t, handledPoints(collect), 1)
+}
+
+func TestCollectorDoesNotEnqueueTheSpilledPoints(t *testing.T) {
+
+	timelineManager = &tlmanager.Instance{}
+
+	conf := testWALConf(t, "")
+
+	q, err := newWriteAheadQueue(conf, func(*walRecord) gobol.Error {
+		return errInternalServerError("replay", "scylla is down", errors.New("unavailable"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := &Collector{
+		wal:    q,
+		logger: logh.CreateContextualLogger("pkg", "collector"),
+	}
+
+	value := 1.5
+	point := func(id string) workerData {
+		return workerData{
+			validatedPoint: &Point{
+				ID:      id,
+				Number:  true,
+				Message: &structs.TSDBpoint{Metric: "cpu", Keyset: "ks", Timestamp: 10, Value: &value, TTL: 1},
+			},
+			source: constants.SourceTypeHTTP,
+		}
+	}
+
+	collect.packetDone(point("spilled"), errBatchSpilled)
+	collect.packetDone(point("failed"), errInternalServerError("insert", "scylla is down", errors.New("unavailable")))
+
+	q.close()
+
+	records := walRecords(t, conf.Directory)
+	if assert.Len(t, records, 1, "the spilled point is already in the queue") && assert.NotNil(t, records[0].Point) {
+		assert.Equal(t, "failed", records[0].Point.ID)
+	}
+}
+
+func TestWriteAheadQueueNonFiniteValues(t *testing.T) {
+
+	conf := testWALConf(t, "")
+
+	// nothing is replayed, the records are read back from the segment
+	q, err := newWriteAheadQueue(conf, func(*walRecord) gobol.Error {
+		return errInternalServerError("replay", "scylla is down", errors.New("unavailable"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := []float64{math.Inf(1), math.Inf(-1), 1.5}
+
+	for _, value := range values {
+
+		v := value
+		err := q.append(&walRecord{Point: &Point{ID: "a", Number: true, Message: &structs.TSDBpoint{Metric: "cpu", Keyset: "ks", Value: &v}}})
+		assert.NoError(t, err)
+	}
+
+	appended, err := q.appendBatch([]*walRecord{
+		{Insert: &walInsert{Keyspace: "ks", TSID: "a", Number: true, Timestamp: 1, Value: walFloat(math.Inf(1))}},
+		{Insert: &walInsert{Keyspace: "ks", TSID: "a", Number: true, Timestamp: 2, Value: walFloat(math.NaN())}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, appended)
+
+	q.close()
+
+	records := walRecords(t, conf.Directory)
+	if !assert.Len(t, records, 5) {
+		return
+	}
+
+	for i, value := range values {
+		if assert.NotNil(t, records[i].Point.Message.Value) {
+			assert.Equal(t, value, *records[i].Point.Message.Value)
+		}
+		assert.Nil(t, records[i].Value)
+	}
+
+	assert.True(t, math.IsInf(float64(records[3].Insert.Value), 1))
+	assert.True(t, math.IsNaN(float64(records[4].Insert.Value)))
+}
+
+func TestWriteAheadQueueSyncPolicy(t *testing.T) {
+
+	conf := testWALConf(t, "")
+
+	for policy, syncBatch := range map[string]bool{"": true, "batch": true, "none": false} {
+
+		conf.SyncPolicy = policy
+
+		q, err := newWriteAheadQueue(conf, nil)
+		if assert.NoError(t, err, policy) {
+			assert.Equal(t, syncBatch, q.syncBatch, policy)
+			q.close()
+		}
+	}
+
+	conf.SyncPolicy = "always"
+
+	_, err := newWriteAheadQueue(conf, nil)
+	assert.EqualError(t, err, "invalid write ahead queue sync policy: always")
+}
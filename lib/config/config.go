@@ -12,6 +12,15 @@ func GetAggregators() []string {
 		"min",
 		"max",
 		"sum",
+		"median",
+		"dev",
+		"stddev",
+		"p50",
+		"p75",
+		"p90",
+		"p95",
+		"p99",
+		"p999",
 	}
 }
 
@@ -52,6 +61,15 @@ func GetDownsamplers() []string {
 		"min",
 		"max",
 		"sum",
+		"median",
+		"dev",
+		"stddev",
+		"p50",
+		"p75",
+		"p90",
+		"p95",
+		"p99",
+		"p999",
 	}
 }
 
@@ -61,5 +79,7 @@ func GetFillers() []string {
 		"nan",
 		"null",
 		"zero",
+		"previous",
+		"linear",
 	}
 }
@@ -10,4 +10,11 @@ const (
 	errorCodeTelnetNetdata  string = "VETN"
 	errorCodeTelnetOpenTSDB string = "VEOT"
 	errorCodeUDP            string = "VEUDP"
+	errorCodePrometheus     string = "VEPROM"
+	errorCodeInfluxHTTP     string = "VEIH"
+	errorCodeInfluxUDP      string = "VEIU"
+	errorCodeTelnetInflux   string = "VEIT"
+	errorCodeTelnetGraphite string = "VEGT"
+	errorCodeGraphitePickle string = "VEGP"
+	errorCodeOTLP           string = "VEOTLP"
 )
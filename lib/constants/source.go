@@ -13,19 +13,24 @@ type SourceType struct {
 
 	// ErrorCodePrefix - the error code prefix for this error
 	ErrorCodePrefix string
+
+	// RejectWhenSaturated - the point is rejected instead of waiting when the keyset queue is saturated
+	RejectWhenSaturated bool
 }
 
 var (
 	// SourceTypeHTTP - defines the source's data
 	SourceTypeHTTP *SourceType = &SourceType{
-		Name:            "http",
-		ErrorCodePrefix: errorCodeHTTP,
+		Name:                "http",
+		ErrorCodePrefix:     errorCodeHTTP,
+		RejectWhenSaturated: true,
 	}
 
 	// SourceTypeUDP - defines the source's data
 	SourceTypeUDP *SourceType = &SourceType{
-		Name:            "udp",
-		ErrorCodePrefix: errorCodeUDP,
+		Name:                "udp",
+		ErrorCodePrefix:     errorCodeUDP,
+		RejectWhenSaturated: true,
 	}
 
 	// SourceTypeTelnetNetdata - defines the source's data
@@ -39,4 +44,50 @@ var (
 		Name:            "telnet-opentsdb",
 		ErrorCodePrefix: errorCodeTelnetOpenTSDB,
 	}
+
+	// SourceTypePrometheus - defines the source's data
+	SourceTypePrometheus *SourceType = &SourceType{
+		Name:                "prometheus",
+		ErrorCodePrefix:     errorCodePrometheus,
+		RejectWhenSaturated: true,
+	}
+
+	// SourceTypeInfluxHTTP - defines the source's data
+	SourceTypeInfluxHTTP *SourceType = &SourceType{
+		Name:                "http-influx",
+		ErrorCodePrefix:     errorCodeInfluxHTTP,
+		RejectWhenSaturated: true,
+	}
+
+	// SourceTypeInfluxUDP - defines the source's data
+	SourceTypeInfluxUDP *SourceType = &SourceType{
+		Name:                "udp-influx",
+		ErrorCodePrefix:     errorCodeInfluxUDP,
+		RejectWhenSaturated: true,
+	}
+
+	// SourceTypeTelnetInflux - defines the source's data
+	SourceTypeTelnetInflux *SourceType = &SourceType{
+		Name:            "telnet-influx",
+		ErrorCodePrefix: errorCodeTelnetInflux,
+	}
+
+	// SourceTypeTelnetGraphite - defines the source's data
+	SourceTypeTelnetGraphite *SourceType = &SourceType{
+		Name:            "telnet-graphite",
+		ErrorCodePrefix: errorCodeTelnetGraphite,
+	}
+
+	// SourceTypeGraphitePickle - defines the source's data
+	SourceTypeGraphitePickle *SourceType = &SourceType{
+		Name:            "graphite-pickle",
+		ErrorCodePrefix: errorCodeGraphitePickle,
+	}
+
+	// SourceTypeOTLP - defines the source's data
+	SourceTypeOTLP *SourceType = &SourceType{
+		Name:                "otlp",
+		ErrorCodePrefix:     errorCodeOTLP,
+		RejectWhenSaturated: true,
+	}
 )
@@ -85,6 +85,12 @@ const (
 	// StringsMetricNetworkIP - metric name for network ip
 	StringsMetricNetworkIP string = "network.ip"
 
+	// StringsMetricNetworkIdentity - metric name for the client certificate identity
+	StringsMetricNetworkIdentity string = "network.identity"
+
+	// StringsIdentity - identity tag
+	StringsIdentity string = "identity"
+
 	// StringsAll - "all" word
 	StringsAll string = "all"
 
@@ -131,3 +137,6 @@ const (
 	// ClusteringOrderDESC - clustering order
 	ClusteringOrderDESC ClusteringOrder = "DESC"
 )
+
+// Version - the mycenae version (may be replaced at build time using -ldflags "-X")
+var Version = "2.36.0"
@@ -0,0 +1,170 @@
+package graphite
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+//
+// Converts graphite paths to mycenae points, shared by the plaintext and pickle sources.
+//
+
+// Converter - converts and sends graphite points to the collector
+type Converter struct {
+	templates         *Templates
+	collector         *collector.Collector
+	validationService *validation.Service
+	defaultKeyset     string
+	defaultTTL        string
+}
+
+// NewConverter - creates a new converter using the server templates and defaults
+func NewConverter(collector *collector.Collector, configuration *structs.TelnetServerConfiguration, validationService *validation.Service) (*Converter, error) {
+
+	templates, err := ParseTemplates(configuration.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultTTL := constants.StringsEmpty
+	if configuration.DefaultTTL > 0 {
+		defaultTTL = strconv.Itoa(configuration.DefaultTTL)
+	}
+
+	return &Converter{
+		templates:         templates,
+		collector:         collector,
+		validationService: validationService,
+		defaultKeyset:     configuration.DefaultKeyset,
+		defaultTTL:        defaultTTL,
+	}, nil
+}
+
+// ParseLine - parses a plaintext line in the format "path value [timestamp]"
+func ParseLine(line string) (string, float64, int64, gobol.Error) {
+
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return constants.StringsEmpty, 0, 0, validation.ErrMalformedGraphite
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return constants.StringsEmpty, 0, 0, validation.ErrParsingValue
+	}
+
+	var timestamp int64
+
+	if len(fields) == 3 {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return constants.StringsEmpty, 0, 0, validation.ErrInvalidTimestamp
+		}
+
+		// a negative timestamp means "now" for graphite
+		if ts > 0 {
+			timestamp = int64(ts)
+		}
+	}
+
+	return fields[0], value, timestamp, nil
+}
+
+// Handle - converts the path using the templates, validates and sends the point (returns the keyset)
+func (c *Converter) Handle(path string, value float64, timestamp int64, sourceType *constants.SourceType, token *auth.Token) (string, gobol.Error) {
+
+	result := c.templates.Apply(path)
+
+	keyset := result.Keyset
+	if keyset == constants.StringsEmpty {
+		keyset = c.defaultKeyset
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return keyset, validation.ErrParsingValue
+	}
+
+	var gerr gobol.Error
+
+	for _, tag := range result.Tags {
+
+		gerr = c.validationService.ValidateProperty(tag.Name, validation.TagKeyType)
+		if gerr != nil {
+			return keyset, gerr
+		}
+
+		gerr = c.validationService.ValidateProperty(tag.Value, validation.TagValueType)
+		if gerr != nil {
+			return keyset, gerr
+		}
+	}
+
+	gerr = c.validationService.ValidateKeyset(keyset)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	ttl := result.TTL
+	if ttl == constants.StringsEmpty {
+		ttl = c.defaultTTL
+	}
+
+	ttlValue, ttlStr, gerr := c.validationService.ParseTTL(ttl)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	point := structs.TSDBpoint{
+		Metric: result.Metric,
+		Value:  &value,
+		Tags:   append(result.Tags, structs.TSDBTag{Name: constants.StringsKSID, Value: keyset}, structs.TSDBTag{Name: constants.StringsTTL, Value: ttlStr}),
+		TTL:    ttlValue,
+		Keyset: keyset,
+	}
+
+	gerr = c.validationService.ValidateTags(&point)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	gerr = c.validationService.ValidateProperty(point.Metric, validation.MetricType)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	gerr = c.validationService.ValidatePolicy(&point)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	point.Timestamp, gerr = c.validationService.ValidateTimestamp(timestamp)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	validatedPoint, gerr := c.collector.MakePacket(&point, true)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	gerr = c.collector.ValidateLimits(validatedPoint)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	gerr = c.collector.HandlePacket(validatedPoint, sourceType, token)
+	if gerr != nil {
+		return keyset, gerr
+	}
+
+	return keyset, nil
+}
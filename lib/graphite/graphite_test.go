@@ -0,0 +1,67 @@
+package graphite
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/validation"
+)
+
+func TestParseLine(t *testing.T) {
+
+	valid := []struct {
+		line      string
+		path      string
+		value     float64
+		timestamp int64
+	}{
+		{"servers.a.cpu 2.5 1600000000", "servers.a.cpu", 2.5, 1600000000},
+		{"servers.a.cpu 2.5", "servers.a.cpu", 2.5, 0},
+		{"servers.a.cpu 1 1600000000.75", "servers.a.cpu", 1, 1600000000},
+		{"servers.a.cpu 1 -1", "servers.a.cpu", 1, 0},
+		{"servers.a.cpu 1 0", "servers.a.cpu", 1, 0},
+		{"  servers.a.cpu   -3e2   1600000000 ", "servers.a.cpu", -300, 1600000000},
+		{"servers.a.cpu\t4\t1600000000", "servers.a.cpu", 4, 1600000000},
+	}
+
+	for _, c := range valid {
+
+		path, value, timestamp, gerr := ParseLine(c.line)
+
+		assert.Nil(t, gerr, c.line)
+		assert.Equal(t, c.path, path, c.line)
+		assert.Equal(t, c.value, value, c.line)
+		assert.Equal(t, c.timestamp, timestamp, c.line)
+	}
+
+	invalid := map[string]gobol.Error{
+		"servers.a.cpu":                validation.ErrMalformedGraphite,
+		"servers.a.cpu 1 1600000000 x": validation.ErrMalformedGraphite,
+		"":                             validation.ErrMalformedGraphite,
+		"servers.a.cpu abc 1600000000": validation.ErrParsingValue,
+		"servers.a.cpu 1 abc":          validation.ErrInvalidTimestamp,
+	}
+
+	for line, expected := range invalid {
+
+		path, _, _, gerr := ParseLine(line)
+
+		assert.Equal(t, expected, gerr, line)
+		assert.Empty(t, path, line)
+	}
+}
+
+func TestParseLineSpecialValues(t *testing.T) {
+
+	// the special values are parsed, the converter rejects them
+	_, value, _, gerr := ParseLine("servers.a.cpu NaN")
+	assert.Nil(t, gerr)
+	assert.True(t, math.IsNaN(value))
+
+	_, value, _, gerr = ParseLine("servers.a.cpu -Inf")
+	assert.Nil(t, gerr)
+	assert.True(t, math.IsInf(value, -1))
+}
@@ -0,0 +1,529 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//
+// Implements a minimal python pickle decoder for the graphite pickle protocol, the payload
+// is a list of tuples in the format: [(path, (timestamp, value)), ...]
+//
+
+// PicklePoint - a point decoded from the pickle payload
+type PicklePoint struct {
+	Path      string
+	Timestamp int64
+	Value     float64
+}
+
+var (
+	// ErrPickleTruncated - the payload ended before the stop opcode
+	ErrPickleTruncated = errors.New("pickle: truncated payload")
+
+	// ErrPickleStack - the opcode found an unexpected stack
+	ErrPickleStack = errors.New("pickle: invalid stack")
+
+	// ErrPickleFormat - the decoded object is not a graphite point list
+	ErrPickleFormat = errors.New("pickle: unexpected object format")
+)
+
+const (
+	opMark           byte = '('
+	opStop           byte = '.'
+	opPop            byte = '0'
+	opPopMark        byte = '1'
+	opDup            byte = '2'
+	opFloat          byte = 'F'
+	opInt            byte = 'I'
+	opBinInt         byte = 'J'
+	opBinInt1        byte = 'K'
+	opLong           byte = 'L'
+	opBinInt2        byte = 'M'
+	opNone           byte = 'N'
+	opString         byte = 'S'
+	opBinString      byte = 'T'
+	opShortBinString byte = 'U'
+	opUnicode        byte = 'V'
+	opBinUnicode     byte = 'X'
+	opAppend         byte = 'a'
+	opGet            byte = 'g'
+	opBinGet         byte = 'h'
+	opLongBinGet     byte = 'j'
+	opList           byte = 'l'
+	opEmptyList      byte = ']'
+	opAppends        byte = 'e'
+	opPut            byte = 'p'
+	opBinPut         byte = 'q'
+	opLongBinPut     byte = 'r'
+	opTuple          byte = 't'
+	opEmptyTuple     byte = ')'
+	opBinFloat       byte = 'G'
+	opBinBytes       byte = 'B'
+	opShortBinBytes  byte = 'C'
+	opProto          byte = 0x80
+	opTuple1         byte = 0x85
+	opTuple2         byte = 0x86
+	opTuple3         byte = 0x87
+	opNewTrue        byte = 0x88
+	opNewFalse       byte = 0x89
+	opLong1          byte = 0x8a
+	opShortBinUni    byte = 0x8c
+	opMemoize        byte = 0x94
+	opFrame          byte = 0x95
+)
+
+// pickleMark - the mark object pushed to the stack
+type pickleMark struct{}
+
+// unpickler - the decoder state
+type unpickler struct {
+	data  []byte
+	pos   int
+	stack []interface{}
+	memo  map[int]interface{}
+}
+
+func (u *unpickler) read(n int) ([]byte, error) {
+
+	if n < 0 || u.pos+n > len(u.data) {
+		return nil, ErrPickleTruncated
+	}
+
+	b := u.data[u.pos : u.pos+n]
+	u.pos += n
+
+	return b, nil
+}
+
+func (u *unpickler) readLine() (string, error) {
+
+	i := bytes.IndexByte(u.data[u.pos:], '\n')
+	if i < 0 {
+		return "", ErrPickleTruncated
+	}
+
+	line := string(u.data[u.pos : u.pos+i])
+	u.pos += i + 1
+
+	return line, nil
+}
+
+func (u *unpickler) push(v interface{}) {
+
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) pop() (interface{}, error) {
+
+	if len(u.stack) == 0 {
+		return nil, ErrPickleStack
+	}
+
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+
+	return v, nil
+}
+
+func (u *unpickler) top() (interface{}, error) {
+
+	if len(u.stack) == 0 {
+		return nil, ErrPickleStack
+	}
+
+	return u.stack[len(u.stack)-1], nil
+}
+
+// popMark - pops all items until the last mark
+func (u *unpickler) popMark() ([]interface{}, error) {
+
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		if _, ok := u.stack[i].(pickleMark); ok {
+			items := append([]interface{}{}, u.stack[i+1:]...)
+			u.stack = u.stack[:i]
+			return items, nil
+		}
+	}
+
+	return nil, ErrPickleStack
+}
+
+// appendToList - appends the items to the list on top of the stack
+func (u *unpickler) appendToList(items ...interface{}) error {
+
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+
+	list, ok := v.(*[]interface{})
+	if !ok {
+		return ErrPickleStack
+	}
+
+	*list = append(*list, items...)
+
+	return nil
+}
+
+func (u *unpickler) pushTuple(n int) error {
+
+	if len(u.stack) < n {
+		return ErrPickleStack
+	}
+
+	tuple := append([]interface{}{}, u.stack[len(u.stack)-n:]...)
+	u.stack = u.stack[:len(u.stack)-n]
+	u.push(tuple)
+
+	return nil
+}
+
+func (u *unpickler) readUint(n int) (uint64, error) {
+
+	b, err := u.read(n)
+	if err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+
+	return v, nil
+}
+
+func (u *unpickler) memoize(key int) error {
+
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+
+	u.memo[key] = v
+
+	return nil
+}
+
+func (u *unpickler) memoGet(key int) error {
+
+	v, ok := u.memo[key]
+	if !ok {
+		return ErrPickleStack
+	}
+
+	u.push(v)
+
+	return nil
+}
+
+// decodeLong1 - decodes a little endian two's complement integer
+func decodeLong1(b []byte) interface{} {
+
+	if len(b) == 0 {
+		return int64(0)
+	}
+
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+
+	v := new(big.Int).SetBytes(be)
+	if b[len(b)-1]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+
+	if v.IsInt64() {
+		return v.Int64()
+	}
+
+	f, _ := new(big.Float).SetInt(v).Float64()
+
+	return f
+}
+
+// unpickle - decodes the pickle payload
+func unpickle(data []byte) (interface{}, error) {
+
+	u := &unpickler{
+		data: data,
+		memo: map[int]interface{}{},
+	}
+
+	for {
+
+		b, err := u.read(1)
+		if err != nil {
+			return nil, err
+		}
+
+		op := b[0]
+
+		switch op {
+		case opStop:
+			return u.pop()
+		case opProto:
+			_, err = u.read(1)
+		case opFrame:
+			_, err = u.read(8)
+		case opMark:
+			u.push(pickleMark{})
+		case opPop:
+			_, err = u.pop()
+		case opPopMark:
+			_, err = u.popMark()
+		case opDup:
+			var v interface{}
+			if v, err = u.top(); err == nil {
+				u.push(v)
+			}
+		case opNone:
+			u.push(nil)
+		case opNewTrue:
+			u.push(true)
+		case opNewFalse:
+			u.push(false)
+		case opInt, opLong:
+			var line string
+			if line, err = u.readLine(); err == nil {
+				line = strings.TrimSuffix(line, "L")
+				switch line {
+				case "00":
+					u.push(false)
+				case "01":
+					u.push(true)
+				default:
+					var i int64
+					if i, err = strconv.ParseInt(line, 10, 64); err == nil {
+						u.push(i)
+					} else if bi, ok := new(big.Int).SetString(line, 10); ok {
+						f, _ := new(big.Float).SetInt(bi).Float64()
+						u.push(f)
+						err = nil
+					}
+				}
+			}
+		case opBinInt:
+			var v uint64
+			if v, err = u.readUint(4); err == nil {
+				u.push(int64(int32(uint32(v))))
+			}
+		case opBinInt1:
+			var v uint64
+			if v, err = u.readUint(1); err == nil {
+				u.push(int64(v))
+			}
+		case opBinInt2:
+			var v uint64
+			if v, err = u.readUint(2); err == nil {
+				u.push(int64(v))
+			}
+		case opLong1:
+			var n uint64
+			if n, err = u.readUint(1); err == nil {
+				var lb []byte
+				if lb, err = u.read(int(n)); err == nil {
+					u.push(decodeLong1(lb))
+				}
+			}
+		case opFloat:
+			var line string
+			if line, err = u.readLine(); err == nil {
+				var f float64
+				if f, err = strconv.ParseFloat(line, 64); err == nil {
+					u.push(f)
+				}
+			}
+		case opBinFloat:
+			var fb []byte
+			if fb, err = u.read(8); err == nil {
+				u.push(math.Float64frombits(binary.BigEndian.Uint64(fb)))
+			}
+		case opString, opUnicode:
+			var line string
+			if line, err = u.readLine(); err == nil {
+				if op == opString {
+					var unquoted string
+					if unquoted, err = strconv.Unquote(line); err != nil {
+						// python uses single quotes by default
+						unquoted, err = strconv.Unquote(`"` + strings.Trim(line, `'`) + `"`)
+					}
+					line = unquoted
+				}
+				u.push(line)
+			}
+		case opShortBinString, opShortBinBytes, opShortBinUni:
+			var n uint64
+			if n, err = u.readUint(1); err == nil {
+				var sb []byte
+				if sb, err = u.read(int(n)); err == nil {
+					u.push(string(sb))
+				}
+			}
+		case opBinString, opBinUnicode, opBinBytes:
+			var n uint64
+			if n, err = u.readUint(4); err == nil {
+				var sb []byte
+				if sb, err = u.read(int(n)); err == nil {
+					u.push(string(sb))
+				}
+			}
+		case opEmptyList:
+			u.push(&[]interface{}{})
+		case opList:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				u.push(&items)
+			}
+		case opAppend:
+			var v interface{}
+			if v, err = u.pop(); err == nil {
+				err = u.appendToList(v)
+			}
+		case opAppends:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				err = u.appendToList(items...)
+			}
+		case opEmptyTuple:
+			u.push([]interface{}{})
+		case opTuple:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				u.push(items)
+			}
+		case opTuple1:
+			err = u.pushTuple(1)
+		case opTuple2:
+			err = u.pushTuple(2)
+		case opTuple3:
+			err = u.pushTuple(3)
+		case opPut:
+			var line string
+			if line, err = u.readLine(); err == nil {
+				var key int
+				if key, err = strconv.Atoi(line); err == nil {
+					err = u.memoize(key)
+				}
+			}
+		case opBinPut:
+			var key uint64
+			if key, err = u.readUint(1); err == nil {
+				err = u.memoize(int(key))
+			}
+		case opLongBinPut:
+			var key uint64
+			if key, err = u.readUint(4); err == nil {
+				err = u.memoize(int(key))
+			}
+		case opMemoize:
+			err = u.memoize(len(u.memo))
+		case opGet:
+			var line string
+			if line, err = u.readLine(); err == nil {
+				var key int
+				if key, err = strconv.Atoi(line); err == nil {
+					err = u.memoGet(key)
+				}
+			}
+		case opBinGet:
+			var key uint64
+			if key, err = u.readUint(1); err == nil {
+				err = u.memoGet(int(key))
+			}
+		case opLongBinGet:
+			var key uint64
+			if key, err = u.readUint(4); err == nil {
+				err = u.memoGet(int(key))
+			}
+		default:
+			err = fmt.Errorf("pickle: unsupported opcode 0x%x", op)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// toFloat - converts the decoded number to float
+func toFloat(v interface{}) (float64, bool) {
+
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// DecodePickle - decodes the graphite pickle payload
+func DecodePickle(data []byte) ([]PicklePoint, error) {
+
+	obj, err := unpickle(data)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.(*[]interface{})
+	if !ok {
+		return nil, ErrPickleFormat
+	}
+
+	points := make([]PicklePoint, 0, len(*list))
+
+	for _, item := range *list {
+
+		tuple, ok := item.([]interface{})
+		if !ok || len(tuple) != 2 {
+			return nil, ErrPickleFormat
+		}
+
+		path, ok := tuple[0].(string)
+		if !ok {
+			return nil, ErrPickleFormat
+		}
+
+		datapoint, ok := tuple[1].([]interface{})
+		if !ok || len(datapoint) != 2 {
+			return nil, ErrPickleFormat
+		}
+
+		timestamp, ok := toFloat(datapoint[0])
+		if !ok {
+			return nil, ErrPickleFormat
+		}
+
+		value, ok := toFloat(datapoint[1])
+		if !ok {
+			return nil, ErrPickleFormat
+		}
+
+		points = append(points, PicklePoint{
+			Path:      path,
+			Timestamp: int64(timestamp),
+			Value:     value,
+		})
+	}
+
+	return points, nil
+}
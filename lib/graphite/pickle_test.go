@@ -0,0 +1,102 @@
+package graphite
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePickle(t *testing.T) {
+
+	twoPoints := []PicklePoint{
+		{Path: "servers.a.cpu", Timestamp: 1600000000, Value: 2.5},
+		{Path: "servers.b.cpu", Timestamp: 1600000060, Value: -1},
+	}
+
+	cases := map[string]struct {
+		data     string
+		expected []PicklePoint
+	}{
+		"Protocol0": {
+			data:     "(lp0\n(Vservers.a.cpu\np1\n(I1600000000\nF2.5\ntp2\ntp3\na(Vservers.b.cpu\np4\n(I1600000060\nI-1\ntp5\ntp6\na.",
+			expected: twoPoints,
+		},
+		"Protocol0Python2": {
+			data:     "(lp0\n(S'servers.a.cpu'\np1\n(I1600000000\nF2.5\ntp2\ntp3\na.",
+			expected: twoPoints[:1],
+		},
+		"Protocol1": {
+			data:     "]q\x00((X\x0d\x00\x00\x00servers.a.cpuq\x01(J\x00\x10^_G@\x04\x00\x00\x00\x00\x00\x00tq\x02tq\x03(X\x0d\x00\x00\x00servers.b.cpuq\x04(J<\x10^_J\xff\xff\xff\xfftq\x05tq\x06e.",
+			expected: twoPoints,
+		},
+		"Protocol2": {
+			data:     "\x80\x02]q\x00(X\x0d\x00\x00\x00servers.a.cpuq\x01J\x00\x10^_G@\x04\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03X\x0d\x00\x00\x00servers.b.cpuq\x04J<\x10^_J\xff\xff\xff\xff\x86q\x05\x86q\x06e.",
+			expected: twoPoints,
+		},
+		"Protocol4": {
+			data:     "\x80\x04\x95E\x00\x00\x00\x00\x00\x00\x00]\x94(\x8c\x0dservers.a.cpu\x94J\x00\x10^_G@\x04\x00\x00\x00\x00\x00\x00\x86\x94\x86\x94\x8c\x0dservers.b.cpu\x94J<\x10^_J\xff\xff\xff\xff\x86\x94\x86\x94e.",
+			expected: twoPoints,
+		},
+		"Long1": {
+			data:     "\x80\x02]q\x00X\x01\x00\x00\x00aq\x01\x8a\x06\x00\x00\x00\x00\x00\x01\x8a\x09\x00\x00\x00\x00\x00\x00\x00\x00@\x86q\x02\x86q\x03a.",
+			expected: []PicklePoint{{Path: "a", Timestamp: 1 << 40, Value: math.Pow(2, 70)}},
+		},
+		"Bool": {
+			data:     "\x80\x02]q\x00X\x01\x00\x00\x00aq\x01J\x00\x10^_\x88\x86q\x02\x86q\x03a.",
+			expected: []PicklePoint{{Path: "a", Timestamp: 1600000000, Value: 1}},
+		},
+		"Strings": {
+			data:     "\x80\x02]q\x00X\x01\x00\x00\x00aq\x01X\x0a\x00\x00\x001600000000q\x02X\x03\x00\x00\x003.5q\x03\x86q\x04\x86q\x05a.",
+			expected: []PicklePoint{{Path: "a", Timestamp: 1600000000, Value: 3.5}},
+		},
+		"EmptyList": {
+			data:     "\x80\x02]q\x00.",
+			expected: []PicklePoint{},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			points, err := DecodePickle([]byte(c.data))
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, points)
+		})
+	}
+}
+
+func TestDecodePickleInvalid(t *testing.T) {
+
+	cases := map[string]struct {
+		data     string
+		expected error
+	}{
+		"Empty":             {data: "", expected: ErrPickleTruncated},
+		"NoStop":            {data: "\x80\x02]q\x00", expected: ErrPickleTruncated},
+		"TruncatedLine":     {data: "(lp0\n(Vservers.a.cpu", expected: ErrPickleTruncated},
+		"TruncatedString":   {data: "\x80\x02]q\x00X\x0d\x00\x00\x00serv", expected: ErrPickleTruncated},
+		"Dict":              {data: "\x80\x02}q\x00X\x01\x00\x00\x00aq\x01K\x01s."},
+		"NotAList":          {data: "\x80\x02K\x01.", expected: ErrPickleFormat},
+		"ThreeItemsTuple":   {data: "\x80\x02]q\x00X\x01\x00\x00\x00aq\x01K\x01K\x02K\x03\x87q\x02\x86q\x03a.", expected: ErrPickleFormat},
+		"PathNotString":     {data: "\x80\x02]q\x00K\x01K\x01K\x02\x86q\x02\x86q\x03a.", expected: ErrPickleFormat},
+		"ValueNotNumber":    {data: "\x80\x02]q\x00X\x01\x00\x00\x00aq\x01K\x01N\x86q\x02\x86q\x03a.", expected: ErrPickleFormat},
+		"AppendWithoutList": {data: "\x80\x02K\x01K\x02a.", expected: ErrPickleStack},
+		"PopEmptyStack":     {data: "\x80\x020.", expected: ErrPickleStack},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			points, err := DecodePickle([]byte(c.data))
+
+			assert.Nil(t, points)
+			if c.expected != nil {
+				assert.Equal(t, c.expected, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
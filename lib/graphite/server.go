@@ -0,0 +1,270 @@
+package graphite
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tlsconfig"
+	"github.com/uol/mycenae/lib/utils"
+	"github.com/uol/mycenae/lib/validation"
+	tlmanager "github.com/uol/timelinemanager"
+)
+
+//
+// Implements the graphite pickle protocol listener, each message is a 4 bytes big endian
+// length header followed by the pickled list of points (when the authentication is enabled
+// the first message must be the "auth <token>" line instead of a pickle).
+//
+
+const (
+	cFuncListen          string = "Listen"
+	cFuncHandleConn      string = "handleConnection"
+	cFuncHandlePayload   string = "HandlePickle"
+	cPickleHeaderSize    int    = 4
+	cDefaultMaxPayload   int64  = 1024 * 1024
+	cMsgfPayloadTooLarge string = "pickle payload of %d bytes exceeds the maximum of %d bytes, closing connection from %s"
+)
+
+// PickleServer - the graphite pickle protocol server
+type PickleServer struct {
+	listenAddress   string
+	listener        net.Listener
+	tlsConfig       *tls.Config
+	maxPayloadSize  int64
+	configuration   *structs.TelnetServerConfiguration
+	converter       *Converter
+	validation      *validation.Service
+	auth            *auth.Service
+	timelineManager *tlmanager.Instance
+	logger          *logh.ContextualLogger
+	connections     sync.Map
+}
+
+// NewPickleServer - creates a new pickle server (the max buffer size is used as the max payload size)
+func NewPickleServer(configuration *structs.TelnetServerConfiguration, collector *collector.Collector, validationService *validation.Service, authService *auth.Service, timelineManager *tlmanager.Instance) (*PickleServer, error) {
+
+	converter, err := NewConverter(collector, configuration, validationService)
+	if err != nil {
+		return nil, err
+	}
+
+	maxPayloadSize := configuration.MaxBufferSize
+	if maxPayloadSize <= 0 {
+		maxPayloadSize = cDefaultMaxPayload
+	}
+
+	tlsConfig, err := tlsconfig.New(&configuration.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PickleServer{
+		listenAddress:   fmt.Sprintf("%s:%d", configuration.Host, configuration.Port),
+		tlsConfig:       tlsConfig,
+		maxPayloadSize:  maxPayloadSize,
+		configuration:   configuration,
+		converter:       converter,
+		validation:      validationService,
+		auth:            authService,
+		timelineManager: timelineManager,
+		logger:          logh.CreateContextualLogger(constants.StringsPKG, "graphite", "source", constants.SourceTypeGraphitePickle.Name),
+	}, nil
+}
+
+// Listen - starts to listen and to handle the incoming connections
+func (ps *PickleServer) Listen() error {
+
+	var err error
+	ps.listener, err = tlsconfig.Listen(ps.listenAddress, ps.tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	if logh.InfoEnabled {
+		ps.logger.Info().Str(constants.StringsFunc, cFuncListen).Msgf("listening pickle connections at %q (tls: %t)...", ps.listener.Addr(), ps.tlsConfig != nil)
+	}
+
+	go func() {
+
+		for {
+
+			conn, err := ps.listener.Accept()
+			if err != nil {
+				if utils.IsConnectionClosedError(err) {
+					return
+				}
+
+				if logh.ErrorEnabled {
+					ps.logger.Error().Str(constants.StringsFunc, cFuncListen).Err(err).Send()
+				}
+
+				continue
+			}
+
+			go ps.handleConnection(conn)
+		}
+	}()
+
+	return nil
+}
+
+// handleConnection - reads the pickle messages until the connection is closed
+func (ps *PickleServer) handleConnection(conn net.Conn) {
+
+	ps.connections.Store(conn, struct{}{})
+
+	defer func() {
+		if r := recover(); r != nil {
+			if logh.ErrorEnabled {
+				ps.logger.Error().Str(constants.StringsFunc, cFuncHandleConn).Msgf("panic recovery: %v", r)
+			}
+		}
+
+		ps.connections.Delete(conn)
+		conn.Close()
+	}()
+
+	ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	ps.statsNetworkIP(cFuncHandleConn, ip)
+
+	if err := conn.SetDeadline(time.Now().Add(ps.configuration.MaxIdleConnectionTimeout.Duration)); err != nil {
+		return
+	}
+
+	// the client certificate identity replaces the ip in the statistics
+	identity, err := tlsconfig.Handshake(conn)
+	if err != nil {
+		if !ps.configuration.SilenceLogs && logh.WarnEnabled {
+			ps.logger.Warn().Str(constants.StringsFunc, cFuncHandleConn).Err(err).Msgf("tls handshake failed, closing connection from %s", ip)
+		}
+		return
+	}
+
+	if identity != constants.StringsEmpty {
+		ps.statsNetworkIdentity(cFuncHandleConn, identity)
+		ip = identity
+	}
+
+	header := make([]byte, cPickleHeaderSize)
+
+	var token *auth.Token
+
+	for {
+
+		err := conn.SetReadDeadline(time.Now().Add(ps.configuration.MaxIdleConnectionTimeout.Duration))
+		if err != nil {
+			return
+		}
+
+		if _, err = io.ReadFull(conn, header); err != nil {
+			if err != io.EOF && !utils.IsConnectionClosedError(err) && !ps.configuration.SilenceLogs && logh.WarnEnabled {
+				ps.logger.Warn().Str(constants.StringsFunc, cFuncHandleConn).Err(err).Msg("error reading pickle header")
+			}
+			return
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		if size > ps.maxPayloadSize {
+			if logh.WarnEnabled {
+				ps.logger.Warn().Str(constants.StringsFunc, cFuncHandleConn).Msgf(cMsgfPayloadTooLarge, size, ps.maxPayloadSize, ip)
+			}
+			return
+		}
+
+		payload := make([]byte, size)
+		if _, err = io.ReadFull(conn, payload); err != nil {
+			if !ps.configuration.SilenceLogs && logh.WarnEnabled {
+				ps.logger.Warn().Str(constants.StringsFunc, cFuncHandleConn).Err(err).Msg("error reading pickle payload")
+			}
+			return
+		}
+
+		if ps.auth.Enabled() && token == nil {
+			var gerr gobol.Error
+			token, gerr = ps.auth.Handshake(string(payload))
+			if gerr != nil {
+				ps.validation.StatsValidationError(cFuncHandleConn, constants.StringsEmpty, ip, constants.SourceTypeGraphitePickle, gerr)
+				if logh.WarnEnabled {
+					ps.logger.Warn().Str(constants.StringsFunc, cFuncHandleConn).Err(gerr).Msgf("authentication failed, closing connection from %s", ip)
+				}
+				return
+			}
+			continue
+		}
+
+		ps.HandlePickle(payload, ip, token)
+	}
+}
+
+// HandlePickle - decodes the pickle payload and sends all points to the collector
+func (ps *PickleServer) HandlePickle(payload []byte, ip string, token *auth.Token) {
+
+	points, err := DecodePickle(payload)
+	if err != nil {
+		ps.validation.StatsValidationError(cFuncHandlePayload, constants.StringsEmpty, ip, constants.SourceTypeGraphitePickle, validation.ErrMalformedPickle)
+		if !ps.configuration.SilenceLogs && logh.ErrorEnabled {
+			ps.logger.Error().Str(constants.StringsFunc, cFuncHandlePayload).Err(err).Msg("error decoding pickle payload")
+		}
+		return
+	}
+
+	for _, p := range points {
+
+		keyset, gerr := ps.converter.Handle(p.Path, p.Value, p.Timestamp, constants.SourceTypeGraphitePickle, token)
+		if gerr != nil {
+			ps.validation.StatsValidationError(cFuncHandlePayload, keyset, ip, constants.SourceTypeGraphitePickle, gerr)
+			if !ps.configuration.SilenceLogs && logh.ErrorEnabled {
+				ps.logger.Error().Str(constants.StringsFunc, cFuncHandlePayload).Err(gerr).Msgf("invalid pickle point: %s", p.Path)
+			}
+		}
+	}
+}
+
+// Shutdown - stops listening and closes all connections
+func (ps *PickleServer) Shutdown() {
+
+	if ps.listener != nil {
+		err := ps.listener.Close()
+		if err != nil && logh.ErrorEnabled {
+			ps.logger.Error().Str(constants.StringsFunc, "Shutdown").Err(err).Send()
+		}
+	}
+
+	ps.connections.Range(func(k, _ interface{}) bool {
+		k.(net.Conn).Close()
+		return true
+	})
+}
+
+func (ps *PickleServer) statsNetworkIdentity(function, identity string) {
+
+	ps.timelineManager.FlattenCountIncN(
+		function,
+		constants.StringsMetricNetworkIdentity,
+		constants.StringsIdentity, identity,
+		constants.StringsSource, constants.SourceTypeGraphitePickle.Name,
+	)
+}
+
+func (ps *PickleServer) statsNetworkIP(function, ip string) {
+
+	ps.timelineManager.FlattenCountIncN(
+		function,
+		constants.StringsMetricNetworkIP,
+		constants.StringsIP, ip,
+		constants.StringsSource, constants.SourceTypeGraphitePickle.Name,
+	)
+}
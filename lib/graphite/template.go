@@ -0,0 +1,249 @@
+package graphite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Implements the carbon like templates used to convert a graphite path to a metric and tags.
+//
+// A template has the format "[filter] template [default tags]", examples:
+//
+//	"servers.* .host.measurement* env=prod,ksid=stats"
+//	"stats.*.*.*  ..host.measurement.field"
+//	"measurement.host region=us,ksid=stats,ttl=7"
+//
+// The template nodes are matched against the path nodes: "measurement" nodes are joined to
+// build the metric, "measurement*" consumes all the remaining nodes, "field" nodes are appended
+// to the metric, empty nodes are ignored and any other name becomes a tag ("ksid" and "ttl"
+// select the keyset and ttl). A path matching no template, or converted without tags, is
+// tagged with "source=graphite" since the points require at least one tag.
+//
+
+const (
+	cNodeSeparator     string = "."
+	cWildcard          string = "*"
+	cNodeMeasurement   string = "measurement"
+	cNodeMeasurementGr string = "measurement*"
+	cNodeField         string = "field"
+	cTagSeparator      string = ","
+	cTagAssignment     string = "="
+	cDefaultTagName    string = "source"
+	cDefaultTagValue   string = "graphite"
+)
+
+// Template - a parsed carbon like template
+type Template struct {
+	filter      []string
+	nodes       []string
+	defaultTags []structs.TSDBTag
+}
+
+// Templates - the configured templates ordered by precedence
+type Templates struct {
+	templates []*Template
+}
+
+// Result - the result of a path conversion
+type Result struct {
+	Metric string
+	Tags   []structs.TSDBTag
+	Keyset string
+	TTL    string
+}
+
+// ParseTemplates - parses the template configuration lines
+func ParseTemplates(lines []string) (*Templates, error) {
+
+	templates := &Templates{
+		templates: make([]*Template, 0, len(lines)),
+	}
+
+	for _, line := range lines {
+
+		t, err := parseTemplate(line)
+		if err != nil {
+			return nil, err
+		}
+
+		templates.templates = append(templates.templates, t)
+	}
+
+	return templates, nil
+}
+
+// parseTemplate - parses a single template line
+func parseTemplate(line string) (*Template, error) {
+
+	parts := strings.Fields(line)
+
+	var filter, template, tags string
+
+	switch len(parts) {
+	case 1:
+		template = parts[0]
+	case 2:
+		if strings.Contains(parts[1], cTagAssignment) {
+			template, tags = parts[0], parts[1]
+		} else {
+			filter, template = parts[0], parts[1]
+		}
+	case 3:
+		filter, template, tags = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid graphite template: %q", line)
+	}
+
+	t := &Template{
+		nodes: strings.Split(template, cNodeSeparator),
+	}
+
+	hasMeasurement := false
+	for i, node := range t.nodes {
+		if node == cNodeMeasurement || node == cNodeMeasurementGr {
+			hasMeasurement = true
+		}
+		if node == cNodeMeasurementGr && i != len(t.nodes)-1 {
+			return nil, fmt.Errorf("graphite template %q: %q must be the last node", line, cNodeMeasurementGr)
+		}
+	}
+
+	if !hasMeasurement {
+		return nil, fmt.Errorf("graphite template %q: no measurement node found", line)
+	}
+
+	if filter != constants.StringsEmpty {
+		t.filter = strings.Split(filter, cNodeSeparator)
+	}
+
+	if tags != constants.StringsEmpty {
+		for _, tag := range strings.Split(tags, cTagSeparator) {
+			kv := strings.SplitN(tag, cTagAssignment, 2)
+			if len(kv) != 2 || kv[0] == constants.StringsEmpty || kv[1] == constants.StringsEmpty {
+				return nil, fmt.Errorf("graphite template %q: invalid tag %q", line, tag)
+			}
+			t.defaultTags = append(t.defaultTags, structs.TSDBTag{Name: kv[0], Value: kv[1]})
+		}
+	}
+
+	return t, nil
+}
+
+// matches - checks if the filter matches the path nodes
+func (t *Template) matches(nodes []string) bool {
+
+	if len(t.filter) > len(nodes) {
+		return false
+	}
+
+	for i, f := range t.filter {
+		if f != cWildcard && f != nodes[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match - returns the template with the most specific matching filter (nil if there is none)
+func (ts *Templates) Match(path string) *Template {
+
+	nodes := strings.Split(path, cNodeSeparator)
+
+	var best *Template
+
+	for _, t := range ts.templates {
+		if t.matches(nodes) && (best == nil || len(t.filter) > len(best.filter)) {
+			best = t
+		}
+	}
+
+	return best
+}
+
+// Apply - converts the path to metric and tags using the most specific template, if no template
+// matches the path is used as metric
+func (ts *Templates) Apply(path string) *Result {
+
+	var result *Result
+
+	if t := ts.Match(path); t != nil {
+		result = t.Apply(path)
+	} else {
+		result = &Result{
+			Metric: path,
+		}
+	}
+
+	if len(result.Tags) == 0 {
+		result.Tags = append(result.Tags, structs.TSDBTag{Name: cDefaultTagName, Value: cDefaultTagValue})
+	}
+
+	return result
+}
+
+// Apply - converts the path to metric and tags
+func (t *Template) Apply(path string) *Result {
+
+	nodes := strings.Split(path, cNodeSeparator)
+
+	result := &Result{
+		Tags: make([]structs.TSDBTag, 0, len(t.nodes)+len(t.defaultTags)),
+	}
+
+	measurement := []string{}
+	fields := []string{}
+	tagValues := map[string][]string{}
+	tagOrder := []string{}
+
+	for i := 0; i < len(t.nodes) && i < len(nodes); i++ {
+
+		switch t.nodes[i] {
+		case constants.StringsEmpty:
+		case cNodeMeasurement:
+			measurement = append(measurement, nodes[i])
+		case cNodeMeasurementGr:
+			measurement = append(measurement, nodes[i:]...)
+		case cNodeField:
+			fields = append(fields, nodes[i])
+		default:
+			if _, ok := tagValues[t.nodes[i]]; !ok {
+				tagOrder = append(tagOrder, t.nodes[i])
+			}
+			tagValues[t.nodes[i]] = append(tagValues[t.nodes[i]], nodes[i])
+		}
+	}
+
+	if len(measurement) == 0 {
+		result.Metric = path
+	} else {
+		result.Metric = strings.Join(append(measurement, fields...), cNodeSeparator)
+	}
+
+	for _, tag := range t.defaultTags {
+		if _, ok := tagValues[tag.Name]; !ok {
+			tagOrder = append(tagOrder, tag.Name)
+			tagValues[tag.Name] = []string{tag.Value}
+		}
+	}
+
+	for _, name := range tagOrder {
+
+		value := strings.Join(tagValues[name], cNodeSeparator)
+
+		switch name {
+		case constants.StringsKSID:
+			result.Keyset = value
+		case constants.StringsTTL:
+			result.TTL = value
+		default:
+			result.Tags = append(result.Tags, structs.TSDBTag{Name: name, Value: value})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,166 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func TestParseTemplatesInvalid(t *testing.T) {
+
+	lines := []string{
+		"servers.* .host.field",
+		"measurement*.host",
+		"servers.* .host.measurement env=prod extra",
+		"servers.* .host.measurement env",
+		"servers.* .host.measurement env=",
+		"servers.* .host.measurement =prod",
+		"",
+	}
+
+	for _, line := range lines {
+
+		templates, err := ParseTemplates([]string{"servers.* .host.measurement*", line})
+
+		assert.Error(t, err, line)
+		assert.Nil(t, templates, line)
+	}
+}
+
+// sourceTag - the tag of the paths converted without tags
+var sourceTag = structs.TSDBTag{Name: "source", Value: "graphite"}
+
+func TestTemplatesApply(t *testing.T) {
+
+	templates, err := ParseTemplates([]string{
+		"servers.* .host.measurement*",
+		"servers.web.* ..role.host.measurement.field env=prod,ksid=web",
+		"stats.* .host.measurement* source=statsd,ttl=7",
+		"apps.* .ksid.measurement.measurement.app.app",
+		"*.*.queue .host.measurement.field",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cases := map[string]struct {
+		path     string
+		expected *Result
+	}{
+		"GreedyMeasurement": {
+			path: "servers.host1.cpu.load.avg",
+			expected: &Result{
+				Metric: "cpu.load.avg",
+				Tags:   []structs.TSDBTag{{Name: "host", Value: "host1"}},
+			},
+		},
+		"MostSpecificFilter": {
+			path: "servers.web.frontend.host2.http.requests",
+			expected: &Result{
+				Metric: "http.requests",
+				Tags: []structs.TSDBTag{
+					{Name: "role", Value: "frontend"},
+					{Name: "host", Value: "host2"},
+					{Name: "env", Value: "prod"},
+				},
+				Keyset: "web",
+			},
+		},
+		"DefaultTagsAndTTL": {
+			path: "stats.host3.gauges.memory",
+			expected: &Result{
+				Metric: "gauges.memory",
+				Tags: []structs.TSDBTag{
+					{Name: "host", Value: "host3"},
+					{Name: "source", Value: "statsd"},
+				},
+				TTL: "7",
+			},
+		},
+		"KeysetNodeAndJoinedTags": {
+			path: "apps.stats.jvm.heap.billing.api",
+			expected: &Result{
+				Metric: "jvm.heap",
+				Tags:   []structs.TSDBTag{{Name: "app", Value: "billing.api"}},
+				Keyset: "stats",
+			},
+		},
+		"WildcardFilter": {
+			path: "mq.host5.queue.size",
+			expected: &Result{
+				Metric: "queue.size",
+				Tags:   []structs.TSDBTag{{Name: "host", Value: "host5"}},
+			},
+		},
+		"ShorterPath": {
+			path: "servers.host4",
+			expected: &Result{
+				Metric: "servers.host4",
+				Tags:   []structs.TSDBTag{{Name: "host", Value: "host4"}},
+			},
+		},
+		"NoUserTags": {
+			path: "apps.stats.jvm.heap",
+			expected: &Result{
+				Metric: "jvm.heap",
+				Tags:   []structs.TSDBTag{sourceTag},
+				Keyset: "stats",
+			},
+		},
+		"NoMatchingTemplate": {
+			path:     "other.cpu.load",
+			expected: &Result{Metric: "other.cpu.load", Tags: []structs.TSDBTag{sourceTag}},
+		},
+		"FilterLongerThanPath": {
+			path:     "mq.host5",
+			expected: &Result{Metric: "mq.host5", Tags: []structs.TSDBTag{sourceTag}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.expected, templates.Apply(c.path))
+		})
+	}
+}
+
+func TestTemplateWithoutFilter(t *testing.T) {
+
+	templates, err := ParseTemplates([]string{
+		"measurement.host region=us",
+		"servers.* .host.measurement*",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the template without filter matches any path, a filtered template is more specific
+	assert.Equal(t, &Result{
+		Metric: "cpu",
+		Tags:   []structs.TSDBTag{{Name: "host", Value: "host1"}, {Name: "region", Value: "us"}},
+	}, templates.Apply("cpu.host1.ignored"))
+
+	assert.Equal(t, &Result{
+		Metric: "cpu",
+		Tags:   []structs.TSDBTag{{Name: "host", Value: "host1"}},
+	}, templates.Apply("servers.host1.cpu"))
+
+	// a node value takes precedence over the default tag
+	regional, err := parseTemplate("measurement.region region=us")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []structs.TSDBTag{{Name: "region", Value: "eu"}}, regional.Apply("cpu.eu").Tags)
+	}
+}
+
+func TestTemplatesApplyWithoutTemplates(t *testing.T) {
+
+	templates, err := ParseTemplates(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Nil(t, templates.Match("servers.host1.cpu"))
+	assert.Equal(t, &Result{Metric: "servers.host1.cpu", Tags: []structs.TSDBTag{sourceTag}}, templates.Apply("servers.host1.cpu"))
+}
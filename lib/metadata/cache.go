@@ -22,9 +22,10 @@ const (
 )
 
 var (
-	idNamespace     []byte = []byte("tsid")
-	facetsNamespace []byte = []byte("fac")
-	tsidOK          []byte = []byte("1")
+	idNamespace          []byte = []byte("tsid")
+	facetsNamespace      []byte = []byte("fac")
+	cardinalityNamespace []byte = []byte("card")
+	tsidOK               []byte = []byte("1")
 )
 
 // isIDCached - checks if a document id is cached
@@ -178,3 +179,48 @@ func (sb *SolrBackend) cacheFacets(facets []string, collection, query string) er
 
 	return nil
 }
+
+// getCachedCardinality - returns the cached cardinality of the query
+func (sb *SolrBackend) getCachedCardinality(collection, query string) (*Cardinality, error) {
+
+	hash, gerr := sb.hash(collection, query)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	data, exists, err := sb.memcached.Get(hash, cardinalityNamespace, collection, hex.EncodeToString(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	cardinality := &Cardinality{}
+	if err = json.Unmarshal(data, cardinality); err != nil {
+		return nil, err
+	}
+
+	return cardinality, nil
+}
+
+// cacheCardinality - caches the cardinality of the query
+func (sb *SolrBackend) cacheCardinality(cardinality *Cardinality, collection, query string) error {
+
+	if sb.noQueryCache {
+		return nil
+	}
+
+	hash, gerr := sb.hash(collection, query)
+	if gerr != nil {
+		return gerr
+	}
+
+	data, err := json.Marshal(cardinality)
+	if err != nil {
+		return err
+	}
+
+	return sb.memcached.Put(hash, data, sb.queryCacheTTL, cardinalityNamespace, collection, hex.EncodeToString(hash))
+}
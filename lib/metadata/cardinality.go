@@ -0,0 +1,174 @@
+package metadata
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uol/go-solr/solr"
+	"github.com/uol/gobol"
+	"github.com/uol/restrictedhttpclient"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// Counts the timeseries of a keyset by metric, tag key and tag value using facet queries.
+// The number of timeseries of a tag key is the number of parent documents having it (block join facet),
+// the number of timeseries of a tag value is the number of child documents having it.
+//
+
+const (
+	funcFilterCardinality    string = "FilterCardinality"
+	queryCardinalityParents  string = "{!parent which=\"parent_doc:true\"}tag_key:*"
+	queryCardinalityChildren string = "{!child of=\"parent_doc:true\"}"
+	cCardinalityAllParents   string = "+parent_doc:true"
+	cCreationDateField       string = "creation_date"
+)
+
+// FacetCount - a facet value and its number of timeseries
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Cardinality - the number of timeseries of a keyset by metric, tag key and tag value
+type Cardinality struct {
+	Total     int          `json:"total"`
+	Metrics   []FacetCount `json:"metrics"`
+	TagKeys   []FacetCount `json:"tagKeys"`
+	TagValues []FacetCount `json:"tagValues"`
+}
+
+// extractFacetCounts - extracts the facet values and counts from the solr.SolrResult, sorted by the greatest counts
+func (sb *SolrBackend) extractFacetCounts(r *solr.SolrResult, field string, topN int) []FacetCount {
+
+	counts := []FacetCount{}
+
+	wrapper := r.FacetCounts["facet_fields"]
+	if wrapper == nil {
+		return counts
+	}
+
+	wrapper = wrapper.(map[string]interface{})[field]
+	if wrapper == nil {
+		return counts
+	}
+
+	data := wrapper.([]interface{})
+	for i := 0; i+1 < len(data); i += 2 {
+		count := int(data[i+1].(float64))
+		if count > 0 {
+			counts = append(counts, FacetCount{
+				Value: data[i].(string),
+				Count: count,
+			})
+		}
+	}
+
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Count > counts[j].Count
+	})
+
+	if len(counts) > topN {
+		counts = counts[:topN]
+	}
+
+	return counts
+}
+
+// buildCardinalityFilters - builds the parent document filters (metric and creation date)
+func (sb *SolrBackend) buildCardinalityFilters(metric string, since time.Time) []string {
+
+	filterQueries := []string{}
+
+	if !sb.leaveEmpty(metric) {
+		filterQueries = append(filterQueries, "metric:"+sb.escapeSolrSpecialChars(metric))
+	}
+
+	if !since.IsZero() {
+		filterQueries = append(filterQueries, cCreationDateField+":["+since.UTC().Format(time.RFC3339)+" TO *]")
+	}
+
+	return filterQueries
+}
+
+// FilterCardinality - counts the timeseries of a collection by metric, tag key and the top tag values
+// (of all tag keys or only the specified one), only the timeseries created after "since" are counted if it is not zero
+func (sb *SolrBackend) FilterCardinality(collection, metric, tagKey string, since time.Time, topN int) (*Cardinality, gobol.Error) {
+
+	// relative times would never hit the cache
+	since = since.Truncate(time.Minute)
+
+	cacheKey := strings.Join([]string{metric, tagKey, strconv.FormatInt(since.Unix(), 10), strconv.Itoa(topN)}, constants.StringsBar)
+
+	cardinality, err := sb.getCachedCardinality(collection, cacheKey)
+	if err != nil {
+		sb.statsError(funcFilterCardinality, collection, constants.StringsAll, solrFacetQuery)
+		if err == restrictedhttpclient.ErrMaxRequestsReached {
+			return nil, errServiceUnavailable(funcFilterCardinality, err)
+		}
+		return nil, errInternalServer(funcFilterCardinality, err)
+	}
+
+	if cardinality != nil {
+		return cardinality, nil
+	}
+
+	start := time.Now()
+
+	filterQueries := sb.buildCardinalityFilters(metric, since)
+
+	r, err := sb.solrService.Facets(collection, queryCardinalityParents, constants.StringsEmpty, 0, 0, filterQueries, []string{"metric"}, []string{"tag_key"}, true, topN, 1)
+	if err != nil {
+		sb.statsError(funcFilterCardinality, collection, constants.StringsAll, solrFacetQuery)
+		if err == restrictedhttpclient.ErrMaxRequestsReached {
+			return nil, errServiceUnavailable(funcFilterCardinality, err)
+		}
+		return nil, errInternalServer(funcFilterCardinality, err)
+	}
+
+	cardinality = &Cardinality{
+		Metrics: sb.extractFacetCounts(r, "metric", topN),
+		TagKeys: sb.extractFacetCounts(r, "tag_key", topN),
+	}
+
+	if r.Results != nil {
+		cardinality.Total = r.Results.NumFound
+	}
+
+	childrenQuery := "tag_key:*"
+	if tagKey != constants.StringsEmpty {
+		childrenQuery = "tag_key:" + sb.escapeSolrSpecialChars(tagKey)
+	}
+
+	parentsFilter := queryCardinalityChildren + cCardinalityAllParents
+	for _, fq := range filterQueries {
+		parentsFilter += " +" + fq
+	}
+
+	r, err = sb.solrService.Facets(collection, childrenQuery, constants.StringsEmpty, 0, 0, []string{parentsFilter}, []string{"tag_value"}, nil, false, topN, 1)
+	if err != nil {
+		sb.statsError(funcFilterCardinality, collection, constants.StringsAll, solrFacetQuery)
+		if err == restrictedhttpclient.ErrMaxRequestsReached {
+			return nil, errServiceUnavailable(funcFilterCardinality, err)
+		}
+		return nil, errInternalServer(funcFilterCardinality, err)
+	}
+
+	cardinality.TagValues = sb.extractFacetCounts(r, "tag_value", topN)
+
+	err = sb.cacheCardinality(cardinality, collection, cacheKey)
+	if err != nil {
+		sb.statsError(funcFilterCardinality, collection, constants.StringsAll, solrFacetQuery)
+		if err == restrictedhttpclient.ErrMaxRequestsReached {
+			return nil, errServiceUnavailable(funcFilterCardinality, err)
+		}
+		return nil, errInternalServer(funcFilterCardinality, err)
+	}
+
+	sb.statsRequest(funcFilterCardinality, collection, constants.StringsAll, solrFacetQuery, time.Since(start))
+
+	return cardinality, nil
+}
@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/go-solr/solr"
+)
+
+func newTestSolrBackend() *SolrBackend {
+
+	return &SolrBackend{
+		solrSpecialCharRegexp: regexp.MustCompile(`(\+|\-|\&|\||\!|\(|\)|\{|\}|\[|\]|\^|"|\~|\*|\?|\:|\/|\\)`),
+	}
+}
+
+func TestExtractFacetCounts(t *testing.T) {
+
+	sb := newTestSolrBackend()
+
+	result := &solr.SolrResult{
+		FacetCounts: map[string]interface{}{
+			"facet_fields": map[string]interface{}{
+				"metric":  []interface{}{"mem", 2.0, "cpu", 7.0, "disk", 0.0, "net", 2.0},
+				"tag_key": []interface{}{"host", 3.0, "dangling"},
+			},
+		},
+	}
+
+	assert.Equal(t,
+		[]FacetCount{{"cpu", 7}, {"mem", 2}, {"net", 2}},
+		sb.extractFacetCounts(result, "metric", 10),
+		"sorted by count keeping the solr order and without the empty values",
+	)
+
+	assert.Equal(t, []FacetCount{{"cpu", 7}}, sb.extractFacetCounts(result, "metric", 1))
+	assert.Equal(t, []FacetCount{{"host", 3}}, sb.extractFacetCounts(result, "tag_key", 10), "the value without count is ignored")
+	assert.Empty(t, sb.extractFacetCounts(result, "tag_value", 10))
+	assert.Empty(t, sb.extractFacetCounts(&solr.SolrResult{}, "metric", 10))
+}
+
+func TestBuildCardinalityFilters(t *testing.T) {
+
+	sb := newTestSolrBackend()
+
+	since := time.Date(2020, time.May, 10, 12, 30, 0, 0, time.FixedZone("BRT", -3*3600))
+
+	assert.Empty(t, sb.buildCardinalityFilters("", time.Time{}))
+	assert.Empty(t, sb.buildCardinalityFilters("*", time.Time{}), "all metrics")
+
+	assert.Equal(t,
+		[]string{`metric:os.cpu\-time`, "creation_date:[2020-05-10T15:30:00Z TO *]"},
+		sb.buildCardinalityFilters("os.cpu-time", since),
+	)
+
+	assert.Equal(t, []string{"creation_date:[2020-05-10T15:30:00Z TO *]"}, sb.buildCardinalityFilters(".*", since))
+}
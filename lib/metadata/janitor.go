@@ -0,0 +1,302 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/utils"
+)
+
+//
+// Deletes the metadata of the timeseries whose last write is older than their keyspace TTL, the
+// documents without a last write timestamp (created before it was tracked) are deleted when their
+// keyspace table has no point newer than the TTL.
+//
+
+const (
+	funcJanitorRun          string = "run"
+	cJanitorDefaultInterval        = time.Hour
+	cJanitorDefaultBatch    int    = 1000
+	cJanitorMetaTypeText    string = "metatext"
+	cJanitorNumberTable     string = "ts_number_stamp"
+	cJanitorTextTable       string = "ts_text_stamp"
+
+	// the reversed order works with both table clustering orders
+	cqlSelectLastWrite string = `SELECT date FROM %s.%s WHERE id = ? ORDER BY date DESC LIMIT 1`
+
+	// metricJanitorDeleted - metric name for the stale timeseries metadata deleted by the janitor
+	metricJanitorDeleted string = "metadata.janitor.deleted"
+
+	// metricJanitorError - metric name for the janitor errors
+	metricJanitorError string = "metadata.janitor.error"
+)
+
+// Janitor - periodically deletes the stale timeseries metadata
+type Janitor struct {
+	storage         *Storage
+	session         *gocql.Session
+	lastWrite       func(keyspace string, m *Metadata) (int64, bool, error)
+	timelineManager *tlmanager.Instance
+	keyspaceTTLMap  map[int]string
+	interval        time.Duration
+	batchSize       int
+	logger          *logh.ContextualLogger
+	terminate       chan struct{}
+}
+
+// NewJanitor - creates a new janitor (Start must be called)
+func NewJanitor(storage *Storage, session *gocql.Session, timelineManager *tlmanager.Instance, keyspaceTTLMap map[int]string, interval time.Duration, batchSize int) *Janitor {
+
+	if interval <= 0 {
+		interval = cJanitorDefaultInterval
+	}
+
+	if batchSize <= 0 {
+		batchSize = cJanitorDefaultBatch
+	}
+
+	j := &Janitor{
+		storage:         storage,
+		session:         session,
+		timelineManager: timelineManager,
+		keyspaceTTLMap:  keyspaceTTLMap,
+		interval:        interval,
+		batchSize:       batchSize,
+		logger:          logh.CreateContextualLogger(constants.StringsPKG, "metadata/janitor"),
+		terminate:       make(chan struct{}),
+	}
+
+	j.lastWrite = j.scanLastWrite
+
+	return j
+}
+
+// Start - runs the janitor on each interval
+func (j *Janitor) Start() {
+
+	go func() {
+
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.run()
+			case <-j.terminate:
+				return
+			}
+		}
+	}()
+}
+
+// Stop - stops the janitor
+func (j *Janitor) Stop() {
+
+	close(j.terminate)
+}
+
+// run - cleans all keysets for each keyspace TTL
+func (j *Janitor) run() {
+
+	ttls := make([]int, 0, len(j.keyspaceTTLMap))
+	for ttl := range j.keyspaceTTLMap {
+		ttls = append(ttls, ttl)
+	}
+
+	sort.Ints(ttls)
+
+	for _, keyset := range j.storage.ListKeysets() {
+
+		for _, ttl := range ttls {
+
+			select {
+			case <-j.terminate:
+				return
+			default:
+			}
+
+			deleted, gerr := j.clean(keyset, ttl)
+
+			if deleted > 0 {
+				j.statsDeleted(keyset, ttl, deleted)
+			}
+
+			if gerr != nil {
+				j.statsError(keyset, ttl)
+				if logh.ErrorEnabled {
+					j.logger.Error().Str(constants.StringsFunc, funcJanitorRun).Str(constants.StringsKeyset, keyset).Int(constants.StringsTTL, ttl).Err(gerr).Msg("error deleting the stale timeseries metadata")
+				}
+				continue
+			}
+
+			if deleted > 0 && logh.InfoEnabled {
+				j.logger.Info().Str(constants.StringsFunc, funcJanitorRun).Str(constants.StringsKeyset, keyset).Int(constants.StringsTTL, ttl).Msgf("%d stale timeseries metadata deleted", deleted)
+			}
+		}
+	}
+}
+
+// clean - deletes the metadata of the keyset timeseries with the ttl not written for longer than it, the
+// documents without a last write timestamp are checked against the newest point of the keyspace table
+func (j *Janitor) clean(keyset string, ttl int) (int, gobol.Error) {
+
+	staleBefore := time.Now().Add(-time.Duration(ttl)*24*time.Hour).UnixNano() / int64(time.Millisecond)
+
+	deleted, gerr := j.cleanPages(keyset, ttl, &Query{StaleBefore: staleBefore}, nil)
+	if gerr != nil {
+		return deleted, gerr
+	}
+
+	neverSeen, gerr := j.cleanPages(keyset, ttl, &Query{NeverSeen: true}, func(m *Metadata) (bool, gobol.Error) {
+
+		lastWrite, found, err := j.lastWrite(j.keyspaceTTLMap[ttl], m)
+		if err != nil {
+			return false, errInternalServer(funcJanitorRun, err)
+		}
+
+		return !found || lastWrite < staleBefore, nil
+	})
+
+	return deleted + neverSeen, gerr
+}
+
+// cleanPages - deletes the documents of the query with the ttl accepted by isStale (all if it is nil),
+// one deletion for each page
+func (j *Janitor) cleanPages(keyset string, ttl int, query *Query, isStale func(m *Metadata) (bool, gobol.Error)) (int, gobol.Error) {
+
+	ttlStr := strconv.Itoa(ttl)
+
+	query.Tags = []QueryTag{
+		{
+			Key:    constants.StringsTTL,
+			Values: []string{ttlStr},
+		},
+	}
+
+	// the documents already checked, a page without new ones ends the cleaning
+	// (a deletion may not be visible in the index yet)
+	checked := map[string]struct{}{}
+	deleted, skipped := 0, 0
+
+	for {
+		metadatas, _, gerr := j.storage.FilterMetadata(keyset, query, skipped, j.batchSize)
+		if gerr != nil {
+			return deleted, gerr
+		}
+
+		pending := false
+		stale := make([]Metadata, 0, len(metadatas))
+
+		for i := range metadatas {
+
+			m := &metadatas[i]
+
+			if _, ok := checked[m.ID]; ok {
+				continue
+			}
+
+			checked[m.ID] = struct{}{}
+			pending = true
+
+			// the tag query matches the key and the value separately
+			if tagValue(m, constants.StringsTTL) != ttlStr {
+				skipped++
+				continue
+			}
+
+			if isStale != nil {
+
+				ok, gerr := isStale(m)
+				if gerr != nil {
+					return deleted, gerr
+				}
+
+				if !ok {
+					skipped++
+					continue
+				}
+			}
+
+			stale = append(stale, *m)
+		}
+
+		if len(stale) > 0 {
+
+			if gerr := j.storage.DeleteDocuments(keyset, stale); gerr != nil {
+				return deleted, gerr
+			}
+
+			deleted += len(stale)
+		}
+
+		if !pending {
+			return deleted, nil
+		}
+	}
+}
+
+// scanLastWrite - returns the timestamp (in milliseconds) of the newest point of the timeseries in the keyspace
+func (j *Janitor) scanLastWrite(keyspace string, m *Metadata) (int64, bool, error) {
+
+	table := cJanitorNumberTable
+	if m.MetaType == cJanitorMetaTypeText {
+		table = cJanitorTextTable
+	}
+
+	var date time.Time
+
+	err := j.session.Query(fmt.Sprintf(cqlSelectLastWrite, keyspace, table), m.ID).Scan(&date)
+	if err == gocql.ErrNotFound {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	return date.UnixNano() / int64(time.Millisecond), true, nil
+}
+
+// tagValue - returns the value of a tag of the metadata
+func tagValue(m *Metadata, key string) string {
+
+	for i := 0; i < len(m.TagKey) && i < len(m.TagValue); i++ {
+		if m.TagKey[i] == key {
+			return m.TagValue[i]
+		}
+	}
+
+	return constants.StringsEmpty
+}
+
+// statsDeleted - stores the number of deleted documents
+func (j *Janitor) statsDeleted(keyset string, ttl, deleted int) {
+
+	j.timelineManager.FlattenCountN(
+		funcJanitorRun,
+		float64(deleted),
+		metricJanitorDeleted,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(keyset),
+		constants.StringsTargetTTL, ttl,
+	)
+}
+
+// statsError - stores a janitor error
+func (j *Janitor) statsError(keyset string, ttl int) {
+
+	j.timelineManager.FlattenCountIncN(
+		funcJanitorRun,
+		metricJanitorError,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(keyset),
+		constants.StringsTargetTTL, ttl,
+	)
+}
@@ -0,0 +1,222 @@
+package metadata
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+	tlmanager "github.com/uol/timelinemanager"
+)
+
+// staleBackend - a backend filtering its documents only by the last write timestamp
+type staleBackend struct {
+	Backend
+	docs       []Metadata
+	queries    int
+	deletions  int
+	failDelete bool
+}
+
+// FilterMetadata - returns a page of the documents written before the query timestamp
+func (sb *staleBackend) FilterMetadata(collection string, query *Query, from, maxResults int) ([]Metadata, int, gobol.Error) {
+
+	sb.queries++
+
+	var matched []Metadata
+	for _, doc := range sb.docs {
+		if matchLastSeen(query, doc.LastSeen) {
+			matched = append(matched, doc)
+		}
+	}
+
+	total := len(matched)
+	from, to := pageBounds(total, from, maxResults)
+
+	return matched[from:to], total, nil
+}
+
+// DeleteDocumentByID - removes the document
+func (sb *staleBackend) DeleteDocumentByID(collection, tsType, id string) gobol.Error {
+
+	if sb.failDelete {
+		return errInternalServer("DeleteDocumentByID", errors.New("solr is down"))
+	}
+
+	for i, doc := range sb.docs {
+		if doc.ID == id {
+			sb.docs = append(sb.docs[:i], sb.docs[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// DeleteDocuments - removes the documents in a single deletion
+func (sb *staleBackend) DeleteDocuments(collection string, metadatas []Metadata) gobol.Error {
+
+	sb.deletions++
+
+	for _, m := range metadatas {
+		if gerr := sb.DeleteDocumentByID(collection, m.MetaType, m.ID); gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// ListKeysets - a single keyset
+func (sb *staleBackend) ListKeysets() []string {
+	return []string{"ks"}
+}
+
+func staleDoc(id, ttl string, lastSeen time.Time) Metadata {
+
+	doc := Metadata{ID: id, MetaType: "meta", TagKey: []string{"host", "ttl"}, TagValue: []string{"a", ttl}}
+	if !lastSeen.IsZero() {
+		doc.LastSeen = lastSeen.UnixNano() / int64(time.Millisecond)
+	}
+
+	return doc
+}
+
+// newTestJanitor - a janitor finding the newest points in the map (by id)
+func newTestJanitor(backend Backend, lastWrites map[string]time.Time, interval time.Duration, batchSize int) *Janitor {
+
+	j := NewJanitor(&Storage{Backend: backend}, nil, &tlmanager.Instance{}, map[int]string{1: "ts01", 7: "ts07"}, interval, batchSize)
+
+	j.lastWrite = func(keyspace string, m *Metadata) (int64, bool, error) {
+
+		if keyspace != "ts01" && keyspace != "ts07" {
+			return 0, false, errors.New("unknown keyspace " + keyspace)
+		}
+
+		if m.ID == "fail" {
+			return 0, false, errors.New("scylla is down")
+		}
+
+		date, ok := lastWrites[m.ID]
+
+		return date.UnixNano() / int64(time.Millisecond), ok, nil
+	}
+
+	return j
+}
+
+func TestJanitorClean(t *testing.T) {
+
+	old := time.Now().Add(-48 * time.Hour)
+
+	backend := &staleBackend{
+		docs: []Metadata{
+			staleDoc("a", "1", old),
+			staleDoc("b", "7", old),
+			staleDoc("c", "1", time.Time{}),
+			staleDoc("d", "1", time.Now()),
+			staleDoc("e", "1", old),
+			staleDoc("f", "1", time.Time{}),
+			staleDoc("g", "1", time.Time{}),
+		},
+	}
+
+	// c has no points left, f was written before the ttl and g is still written
+	j := newTestJanitor(backend, map[string]time.Time{"f": old, "g": time.Now()}, 0, 2)
+
+	deleted, gerr := j.clean("ks", 1)
+	assert.NoError(t, gerr)
+	assert.Equal(t, 4, deleted)
+	assert.Equal(t, 3, backend.deletions, "one deletion for each page with stale documents")
+
+	var left []string
+	for _, doc := range backend.docs {
+		left = append(left, doc.ID)
+	}
+
+	assert.Equal(t, []string{"b", "d", "g"}, left, "the other ttl and the recently written ones are kept")
+
+	deleted, gerr = j.clean("ks", 7)
+	assert.NoError(t, gerr)
+	assert.Zero(t, deleted, "written in the last seven days")
+}
+
+func TestJanitorCleanLastWriteError(t *testing.T) {
+
+	backend := &staleBackend{
+		docs: []Metadata{staleDoc("fail", "1", time.Time{})},
+	}
+
+	j := newTestJanitor(backend, nil, time.Minute, 10)
+
+	deleted, gerr := j.clean("ks", 1)
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusInternalServerError, gerr.StatusCode())
+	}
+	assert.Zero(t, deleted)
+	assert.Len(t, backend.docs, 1)
+}
+
+func TestJanitorCleanError(t *testing.T) {
+
+	backend := &staleBackend{
+		docs:       []Metadata{staleDoc("a", "1", time.Now().Add(-48*time.Hour))},
+		failDelete: true,
+	}
+
+	j := newTestJanitor(backend, nil, time.Minute, 10)
+
+	deleted, gerr := j.clean("ks", 1)
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusInternalServerError, gerr.StatusCode())
+	}
+	assert.Zero(t, deleted)
+
+	j.run()
+	assert.Len(t, backend.docs, 1, "the errors are only counted")
+}
+
+func TestMatchLastSeen(t *testing.T) {
+
+	assert.True(t, matchLastSeen(&Query{}, 0))
+	assert.True(t, matchLastSeen(&Query{ActiveSince: 100}, 100))
+	assert.False(t, matchLastSeen(&Query{ActiveSince: 100}, 99))
+	assert.False(t, matchLastSeen(&Query{ActiveSince: 100}, 0))
+
+	assert.True(t, matchLastSeen(&Query{StaleBefore: 100}, 99))
+	assert.False(t, matchLastSeen(&Query{StaleBefore: 100}, 100))
+	assert.False(t, matchLastSeen(&Query{StaleBefore: 100}, 0), "a document without the last write is never stale")
+
+	assert.True(t, matchLastSeen(&Query{NeverSeen: true}, 0))
+	assert.False(t, matchLastSeen(&Query{NeverSeen: true}, 99))
+}
+
+func TestPageBounds(t *testing.T) {
+
+	from, to := pageBounds(10, 0, 3)
+	assert.Equal(t, []int{0, 3}, []int{from, to})
+
+	from, to = pageBounds(10, 8, 3)
+	assert.Equal(t, []int{8, 10}, []int{from, to})
+
+	from, to = pageBounds(10, 20, 3)
+	assert.Equal(t, []int{10, 10}, []int{from, to})
+}
+
+func TestBuildLastSeenFilters(t *testing.T) {
+
+	sb := newTestSolrBackend()
+	millis := time.Date(2020, time.May, 10, 12, 30, 0, 5e6, time.UTC).UnixNano() / int64(time.Millisecond)
+
+	assert.Empty(t, sb.buildLastSeenFilters(&Query{}))
+	assert.Equal(t,
+		[]string{"last_seen:[2020-05-10T12:30:00.005Z TO *]", "last_seen:[* TO 2020-05-10T12:30:00.005Z}"},
+		sb.buildLastSeenFilters(&Query{ActiveSince: millis, StaleBefore: millis}),
+	)
+	assert.Equal(t, []string{"-last_seen:[* TO *]"}, sb.buildLastSeenFilters(&Query{NeverSeen: true}))
+
+	assert.Equal(t, "1", tagValue(&Metadata{TagKey: []string{"host", "ttl"}, TagValue: []string{"a", "1"}}, "ttl"))
+	assert.Empty(t, tagValue(&Metadata{TagKey: []string{"host", "ttl"}, TagValue: []string{"a"}}, "ttl"))
+}
@@ -1,6 +1,10 @@
 package metadata
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/solar"
 	"github.com/uol/logh"
@@ -23,14 +27,14 @@ type Backend interface {
 	// CheckKeyset - verifies if a keyset exists
 	CheckKeyset(keyset string) bool
 
-	// FilterTagValues - filter tag values from a collection
-	FilterTagValues(collection, prefix string, maxResults int) ([]string, int, gobol.Error)
+	// FilterTagValues - filter tag values from a collection (only of the timeseries written after activeSince if it is not zero)
+	FilterTagValues(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error)
 
-	// FilterTagKeys - filter tag keys from a collection
-	FilterTagKeys(collection, prefix string, maxResults int) ([]string, int, gobol.Error)
+	// FilterTagKeys - filter tag keys from a collection (only of the timeseries written after activeSince if it is not zero)
+	FilterTagKeys(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error)
 
-	// FilterMetrics - filter metrics from a collection
-	FilterMetrics(collection, prefix string, maxResults int) ([]string, int, gobol.Error)
+	// FilterMetrics - filter metrics from a collection (only of the timeseries written after activeSince if it is not zero)
+	FilterMetrics(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error)
 
 	// FilterMetadata - list all metas from a collection
 	// Returns: results, total and gobol.Error
@@ -39,9 +43,15 @@ type Backend interface {
 	// AddDocument - add/update a document
 	AddDocument(collection string, metadata *Metadata) gobol.Error
 
+	// UpdateLastSeen - updates the last write timestamp of an existing document
+	UpdateLastSeen(collection string, metadata *Metadata) gobol.Error
+
 	// CheckMetadata - verifies if a metadata exists
 	CheckMetadata(collection, tsType, tsid string, tsidBytes []byte) (bool, gobol.Error)
 
+	// CheckStoredMetadata - verifies if a metadata exists in the storage, bypassing the id cache
+	CheckStoredMetadata(collection, tsType, tsid string) (bool, gobol.Error)
+
 	// SetRegexValue - add slashes to the value
 	SetRegexValue(value string) string
 
@@ -51,11 +61,17 @@ type Backend interface {
 	// DeleteDocumentByID - delete a document by ID and its child documents
 	DeleteDocumentByID(collection, tsType, id string) gobol.Error
 
+	// DeleteDocuments - delete the documents and their child documents, committing once for all of them
+	DeleteDocuments(collection string, metadatas []Metadata) gobol.Error
+
 	// FilterTagKeysByMetric - filter tag values from a collection given its metric
 	FilterTagKeysByMetric(collection, tsType, metric, prefix string, maxResults int) ([]string, int, gobol.Error)
 
 	// FilterTagValuesByMetricAndTag - filter tag values from a collection given its metric and tag
 	FilterTagValuesByMetricAndTag(collection, tsType, metric, tag, prefix string, maxResults int) ([]string, int, gobol.Error)
+
+	// FilterCardinality - counts the timeseries by metric, tag key and the top tag values
+	FilterCardinality(collection, metric, tagKey string, since time.Time, topN int) (*Cardinality, gobol.Error)
 }
 
 // Storage is a storage for metadata
@@ -66,8 +82,18 @@ type Storage struct {
 	Backend
 }
 
+const (
+	// BackendSolr - the metadata is indexed in SolrCloud (default)
+	BackendSolr string = "solr"
+
+	// BackendScylla - the metadata is indexed in scylla tables (no SolrCloud and Zookeeper required)
+	BackendScylla string = "scylla"
+)
+
 // Settings for the metadata package
 type Settings struct {
+	Backend                       string
+	ScyllaKeyspace                string
 	NumShards                     int
 	ReplicationFactor             int
 	IDCacheTTL                    int
@@ -88,6 +114,7 @@ type Metadata struct {
 	TagValue []string `json:"tagValue"`
 	MetaType string   `json:"type"`
 	Keyset   string   `json:"keyset"`
+	LastSeen int64    `json:"lastSeen,omitempty"`
 }
 
 // Query - query
@@ -96,6 +123,15 @@ type Query struct {
 	MetaType string     `json:"type"`
 	Regexp   bool       `json:regexp`
 	Tags     []QueryTag `json:"tags"`
+
+	// ActiveSince - only the timeseries written after this timestamp (in milliseconds)
+	ActiveSince int64 `json:"activeSince,omitempty"`
+
+	// StaleBefore - only the timeseries not written since this timestamp (in milliseconds)
+	StaleBefore int64 `json:"staleBefore,omitempty"`
+
+	// NeverSeen - only the timeseries without a last write timestamp (created before it was tracked)
+	NeverSeen bool `json:"neverSeen,omitempty"`
 }
 
 // QueryTag - tags for query
@@ -107,9 +143,20 @@ type QueryTag struct {
 }
 
 // Create creates a metadata handler
-func Create(settings *Settings, mc *tlmanager.Instance, memcached *memcached.Memcached) (*Storage, error) {
+func Create(settings *Settings, mc *tlmanager.Instance, memcached *memcached.Memcached, scyllaConn *gocql.Session) (*Storage, error) {
+
+	var backend Backend
+	var err error
+
+	switch settings.Backend {
+	case BackendSolr, constants.StringsEmpty:
+		backend, err = NewSolrBackend(settings, mc, memcached)
+	case BackendScylla:
+		backend, err = NewScyllaBackend(settings, mc, memcached, scyllaConn)
+	default:
+		err = fmt.Errorf("unknown metadata backend: %s", settings.Backend)
+	}
 
-	backend, err := NewSolrBackend(settings, mc, memcached)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,556 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/memcached"
+)
+
+//
+// Metadata backend keeping an inverted index (metric, tag key and tag value to tsids) in scylla tables,
+// meant for small deployments and tests where running SolrCloud and Zookeeper is not desired.
+//
+
+const (
+	cqlCreateKeysetTable = `CREATE TABLE IF NOT EXISTS %s.ts_meta_keyset (keyset text PRIMARY KEY, creation_date timestamp)`
+
+	cqlCreateDocumentTable = `CREATE TABLE IF NOT EXISTS %s.ts_meta_document (
+		keyset text, type text, id text, metric text, tag_key list<text>, tag_value list<text>, creation_date timestamp,
+		last_seen timestamp, PRIMARY KEY (keyset, type, id))`
+
+	cqlCreateIndexTable = `CREATE TABLE IF NOT EXISTS %s.ts_meta_index (
+		keyset text, field text, value text, type text, id text,
+		PRIMARY KEY ((keyset, field, value), type, id))`
+
+	cqlCreateValueTable = `CREATE TABLE IF NOT EXISTS %s.ts_meta_value (
+		keyset text, field text, value text,
+		PRIMARY KEY ((keyset, field), value))`
+
+	cqlInsertKeyset      = `INSERT INTO %s.ts_meta_keyset (keyset, creation_date) VALUES (?, toTimestamp(now()))`
+	cqlDeleteKeyset      = `DELETE FROM %s.ts_meta_keyset WHERE keyset = ?`
+	cqlListKeysets       = `SELECT keyset FROM %s.ts_meta_keyset`
+	cqlInsertDocument    = `INSERT INTO %s.ts_meta_document (keyset, type, id, metric, tag_key, tag_value, creation_date, last_seen) VALUES (?, ?, ?, ?, ?, ?, toTimestamp(now()), ?)`
+	cqlUpdateLastSeen    = `UPDATE %s.ts_meta_document SET last_seen = ? WHERE keyset = ? AND type = ? AND id = ? IF EXISTS`
+	cqlSelectDocument    = `SELECT metric, tag_key, tag_value, creation_date, last_seen FROM %s.ts_meta_document WHERE keyset = ? AND type = ? AND id = ?`
+	cqlScanDocuments     = `SELECT type, id, metric, tag_key, tag_value, creation_date, last_seen FROM %s.ts_meta_document WHERE keyset = ?`
+	cqlDeleteDocument    = `DELETE FROM %s.ts_meta_document WHERE keyset = ? AND type = ? AND id = ?`
+	cqlDeleteDocuments   = `DELETE FROM %s.ts_meta_document WHERE keyset = ?`
+	cqlInsertIndex       = `INSERT INTO %s.ts_meta_index (keyset, field, value, type, id) VALUES (?, ?, ?, ?, ?)`
+	cqlSelectIndex       = `SELECT type, id FROM %s.ts_meta_index WHERE keyset = ? AND field = ? AND value = ?`
+	cqlSelectIndexByType = `SELECT type, id FROM %s.ts_meta_index WHERE keyset = ? AND field = ? AND value = ? AND type = ?`
+	cqlDeleteIndex       = `DELETE FROM %s.ts_meta_index WHERE keyset = ? AND field = ? AND value = ? AND type = ? AND id = ?`
+	cqlDeleteIndexValue  = `DELETE FROM %s.ts_meta_index WHERE keyset = ? AND field = ? AND value = ?`
+	cqlInsertValue       = `INSERT INTO %s.ts_meta_value (keyset, field, value) VALUES (?, ?, ?)`
+	cqlSelectValues      = `SELECT value FROM %s.ts_meta_value WHERE keyset = ? AND field = ?`
+	cqlDeleteValues      = `DELETE FROM %s.ts_meta_value WHERE keyset = ? AND field = ?`
+
+	indexFieldMetric   string = "metric"
+	indexFieldTagKey   string = "tag_key"
+	indexFieldTagValue string = "tag_value"
+	indexFieldTagPref  string = "tag:"
+)
+
+// ScyllaBackend - metadata backend using scylla tables
+type ScyllaBackend struct {
+	session                       *gocql.Session
+	keyspace                      string
+	regexPattern                  *regexp.Regexp
+	timelineManager               *tlmanager.Instance
+	logger                        *logh.ContextualLogger
+	memcached                     *memcached.Memcached
+	idCacheTTL                    []byte
+	noIDCache                     bool
+	blacklistedKeysetMap          map[string]bool
+	keysetCacheAutoUpdateInterval time.Duration
+	cachedKeysets                 []string
+	keysetsMutex                  sync.RWMutex
+}
+
+// scyllaDocument - a document stored in the ts_meta_document table
+type scyllaDocument struct {
+	Metadata
+	created  time.Time
+	lastSeen time.Time
+}
+
+// fillLastSeen - copies the scanned last write timestamp to the metadata (in milliseconds)
+func (doc *scyllaDocument) fillLastSeen() {
+
+	if doc.lastSeen.IsZero() {
+		doc.LastSeen = 0
+		return
+	}
+
+	doc.LastSeen = doc.lastSeen.UnixNano() / int64(time.Millisecond)
+}
+
+// lastSeenValue - returns the last write timestamp column value (null if it was never set)
+func lastSeenValue(m *Metadata) interface{} {
+
+	if m.LastSeen <= 0 {
+		return nil
+	}
+
+	return time.Unix(0, m.LastSeen*int64(time.Millisecond))
+}
+
+// NewScyllaBackend - creates a new instance (the tables are created if they do not exist)
+func NewScyllaBackend(settings *Settings, mc *tlmanager.Instance, memcached *memcached.Memcached, session *gocql.Session) (*ScyllaBackend, error) {
+
+	if session == nil {
+		return nil, fmt.Errorf("no scylla session to create the metadata backend")
+	}
+
+	if settings.ScyllaKeyspace == constants.StringsEmpty {
+		return nil, fmt.Errorf("no scylla keyspace configured for the metadata backend")
+	}
+
+	keysetCacheAutoUpdateIntervalDuration, err := time.ParseDuration(settings.KeysetCacheAutoUpdateInterval)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing keysetCacheAutoUpdateInterval")
+	}
+
+	blacklistedKeysetMap := map[string]bool{}
+	for _, value := range settings.BlacklistedKeysets {
+		blacklistedKeysetMap[value] = true
+	}
+
+	sb := &ScyllaBackend{
+		session:                       session,
+		keyspace:                      settings.ScyllaKeyspace,
+		regexPattern:                  newRegexPattern(),
+		timelineManager:               mc,
+		logger:                        logh.CreateContextualLogger(constants.StringsPKG, "metadata"),
+		memcached:                     memcached,
+		idCacheTTL:                    []byte(strconv.Itoa(settings.IDCacheTTL)),
+		noIDCache:                     settings.IDCacheTTL < 0,
+		blacklistedKeysetMap:          blacklistedKeysetMap,
+		keysetCacheAutoUpdateInterval: keysetCacheAutoUpdateIntervalDuration,
+	}
+
+	for _, table := range []string{cqlCreateKeysetTable, cqlCreateDocumentTable, cqlCreateIndexTable, cqlCreateValueTable} {
+		if err := session.Query(fmt.Sprintf(table, sb.keyspace)).Exec(); err != nil {
+			return nil, fmt.Errorf("error creating the metadata tables: %s", err.Error())
+		}
+	}
+
+	sb.cacheKeysets()
+
+	go func() {
+		for {
+			<-time.After(sb.keysetCacheAutoUpdateInterval)
+			sb.cacheKeysets()
+		}
+	}()
+
+	if logh.InfoEnabled {
+		sb.logger.Info().Msgf("using the scylla metadata backend on keyspace: %s", sb.keyspace)
+	}
+
+	return sb, nil
+}
+
+// cql - formats the statement with the keyspace
+func (sb *ScyllaBackend) cql(statement string) string {
+	return fmt.Sprintf(statement, sb.keyspace)
+}
+
+const funcScyllaCacheKeysets string = "cacheKeysets"
+
+// cacheKeysets - caches the keyset list
+func (sb *ScyllaBackend) cacheKeysets() {
+
+	iter := sb.session.Query(sb.cql(cqlListKeysets)).Iter()
+
+	keysets := []string{}
+	var keyset string
+
+	for iter.Scan(&keyset) {
+		if _, ok := sb.blacklistedKeysetMap[keyset]; !ok {
+			keysets = append(keysets, keyset)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		if logh.ErrorEnabled {
+			sb.logger.Error().Str(constants.StringsFunc, funcScyllaCacheKeysets).Err(err).Msg("error on updating cached keysets")
+		}
+		sb.statsError(funcScyllaCacheKeysets, constants.StringsEmpty, constants.StringsAll, scyllaQuery)
+		return
+	}
+
+	sort.Strings(keysets)
+
+	sb.keysetsMutex.Lock()
+	sb.cachedKeysets = keysets
+	sb.keysetsMutex.Unlock()
+}
+
+const funcScyllaCreateKeyset string = "CreateKeyset"
+
+// CreateKeyset - creates a new keyset
+func (sb *ScyllaBackend) CreateKeyset(name string) gobol.Error {
+
+	if err := sb.session.Query(sb.cql(cqlInsertKeyset), name).Exec(); err != nil {
+		sb.statsError(funcScyllaCreateKeyset, name, constants.StringsAll, scyllaInsert)
+		return errInternalServer(funcScyllaCreateKeyset, err)
+	}
+
+	sb.cacheKeysets()
+
+	return nil
+}
+
+const funcScyllaDeleteKeyset string = "DeleteKeyset"
+
+// DeleteKeyset - deletes a keyset and all of its documents
+func (sb *ScyllaBackend) DeleteKeyset(name string) gobol.Error {
+
+	tagKeys, err := sb.values(name, indexFieldTagKey)
+	if err != nil {
+		return errInternalServer(funcScyllaDeleteKeyset, err)
+	}
+
+	fields := []string{indexFieldMetric, indexFieldTagKey, indexFieldTagValue}
+	for _, tagKey := range tagKeys {
+		fields = append(fields, indexFieldTagPref+tagKey)
+	}
+
+	for _, field := range fields {
+
+		values, err := sb.values(name, field)
+		if err != nil {
+			return errInternalServer(funcScyllaDeleteKeyset, err)
+		}
+
+		for _, value := range values {
+			if err := sb.session.Query(sb.cql(cqlDeleteIndexValue), name, field, value).Exec(); err != nil {
+				sb.statsError(funcScyllaDeleteKeyset, name, constants.StringsAll, scyllaDelete)
+				return errInternalServer(funcScyllaDeleteKeyset, err)
+			}
+		}
+
+		if err := sb.session.Query(sb.cql(cqlDeleteValues), name, field).Exec(); err != nil {
+			sb.statsError(funcScyllaDeleteKeyset, name, constants.StringsAll, scyllaDelete)
+			return errInternalServer(funcScyllaDeleteKeyset, err)
+		}
+	}
+
+	for _, statement := range []string{cqlDeleteDocuments, cqlDeleteKeyset} {
+		if err := sb.session.Query(sb.cql(statement), name).Exec(); err != nil {
+			sb.statsError(funcScyllaDeleteKeyset, name, constants.StringsAll, scyllaDelete)
+			return errInternalServer(funcScyllaDeleteKeyset, err)
+		}
+	}
+
+	sb.cacheKeysets()
+
+	return nil
+}
+
+// ListKeysets - list all keysets
+func (sb *ScyllaBackend) ListKeysets() []string {
+
+	sb.keysetsMutex.RLock()
+	defer sb.keysetsMutex.RUnlock()
+
+	return sb.cachedKeysets
+}
+
+const funcScyllaCheckKeyset string = "CheckKeyset"
+
+// CheckKeyset - verifies if a keyset exists
+func (sb *ScyllaBackend) CheckKeyset(keyset string) bool {
+
+	for _, k := range sb.ListKeysets() {
+		if k == keyset {
+			return true
+		}
+	}
+
+	sb.statsMissedKeyset(funcScyllaCheckKeyset, keyset)
+
+	return false
+}
+
+// indexEntries - returns the index fields and values of a document
+func indexEntries(m *Metadata) [][2]string {
+
+	entries := make([][2]string, 0, 1+len(m.TagKey)*3)
+	entries = append(entries, [2]string{indexFieldMetric, m.Metric})
+
+	for i := 0; i < len(m.TagKey) && i < len(m.TagValue); i++ {
+		entries = append(entries,
+			[2]string{indexFieldTagKey, m.TagKey[i]},
+			[2]string{indexFieldTagValue, m.TagValue[i]},
+			[2]string{indexFieldTagPref + m.TagKey[i], m.TagValue[i]},
+		)
+	}
+
+	return entries
+}
+
+const funcScyllaAddDocument string = "AddDocument"
+
+// AddDocument - add/update a document (the old index entries are removed when updating)
+func (sb *ScyllaBackend) AddDocument(collection string, m *Metadata) gobol.Error {
+
+	start := time.Now()
+
+	old, found, err := sb.document(collection, m.MetaType, m.ID)
+	if err != nil {
+		sb.statsError(funcScyllaAddDocument, collection, m.MetaType, scyllaInsert)
+		return errInternalServer(funcScyllaAddDocument, err)
+	}
+
+	if found {
+		if err := sb.deleteIndexEntries(collection, &old.Metadata); err != nil {
+			sb.statsError(funcScyllaAddDocument, collection, m.MetaType, scyllaInsert)
+			return errInternalServer(funcScyllaAddDocument, err)
+		}
+	}
+
+	batch := sb.session.NewBatch(gocql.UnloggedBatch)
+
+	for _, entry := range indexEntries(m) {
+		batch.Query(sb.cql(cqlInsertIndex), collection, entry[0], entry[1], m.MetaType, m.ID)
+		batch.Query(sb.cql(cqlInsertValue), collection, entry[0], entry[1])
+	}
+
+	batch.Query(sb.cql(cqlInsertDocument), collection, m.MetaType, m.ID, m.Metric, m.TagKey, m.TagValue, lastSeenValue(m))
+
+	if err := sb.session.ExecuteBatch(batch); err != nil {
+		sb.statsError(funcScyllaAddDocument, collection, m.MetaType, scyllaInsert)
+		return errInternalServer(funcScyllaAddDocument, err)
+	}
+
+	m.Keyset = collection
+
+	go sb.cacheID(collection, m.MetaType, m.ID, []byte(m.ID))
+
+	if logh.InfoEnabled {
+		sb.logger.Info().Str(constants.StringsFunc, funcScyllaAddDocument).Str(constants.StringsKeyset, collection).Msgf("new document: %s added", m.ID)
+	}
+
+	sb.statsRequest(funcScyllaAddDocument, collection, m.MetaType, scyllaInsert, time.Since(start))
+
+	return nil
+}
+
+const funcScyllaUpdateLastSeen string = "UpdateLastSeen"
+
+// UpdateLastSeen - updates the last write timestamp of an existing document (the index is not changed)
+func (sb *ScyllaBackend) UpdateLastSeen(collection string, m *Metadata) gobol.Error {
+
+	start := time.Now()
+
+	if err := sb.session.Query(sb.cql(cqlUpdateLastSeen), lastSeenValue(m), collection, m.MetaType, m.ID).Exec(); err != nil {
+		sb.statsError(funcScyllaUpdateLastSeen, collection, m.MetaType, scyllaUpdate)
+		return errInternalServer(funcScyllaUpdateLastSeen, err)
+	}
+
+	sb.statsRequest(funcScyllaUpdateLastSeen, collection, m.MetaType, scyllaUpdate, time.Since(start))
+
+	return nil
+}
+
+// deleteIndexEntries - removes the index entries of a document (the distinct values are kept)
+func (sb *ScyllaBackend) deleteIndexEntries(collection string, m *Metadata) error {
+
+	batch := sb.session.NewBatch(gocql.UnloggedBatch)
+
+	for _, entry := range indexEntries(m) {
+		batch.Query(sb.cql(cqlDeleteIndex), collection, entry[0], entry[1], m.MetaType, m.ID)
+	}
+
+	return sb.session.ExecuteBatch(batch)
+}
+
+// document - returns a document by its type and id
+func (sb *ScyllaBackend) document(collection, tsType, id string) (*scyllaDocument, bool, error) {
+
+	doc := &scyllaDocument{
+		Metadata: Metadata{
+			ID:       id,
+			MetaType: tsType,
+			Keyset:   collection,
+		},
+	}
+
+	err := sb.session.Query(sb.cql(cqlSelectDocument), collection, tsType, id).Scan(&doc.Metric, &doc.TagKey, &doc.TagValue, &doc.created, &doc.lastSeen)
+	if err == gocql.ErrNotFound {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	doc.fillLastSeen()
+
+	return doc, true, nil
+}
+
+// scanDocuments - calls the function for each document of the keyset
+func (sb *ScyllaBackend) scanDocuments(collection string, f func(doc *scyllaDocument)) error {
+
+	iter := sb.session.Query(sb.cql(cqlScanDocuments), collection).Iter()
+
+	doc := scyllaDocument{}
+	doc.Keyset = collection
+
+	for iter.Scan(&doc.MetaType, &doc.ID, &doc.Metric, &doc.TagKey, &doc.TagValue, &doc.created, &doc.lastSeen) {
+		doc.fillLastSeen()
+		f(&doc)
+		doc.TagKey = nil
+		doc.TagValue = nil
+		doc.lastSeen = time.Time{}
+	}
+
+	return iter.Close()
+}
+
+// values - returns the distinct values of an index field
+func (sb *ScyllaBackend) values(collection, field string) ([]string, error) {
+
+	iter := sb.session.Query(sb.cql(cqlSelectValues), collection, field).Iter()
+
+	values := []string{}
+	var value string
+
+	for iter.Scan(&value) {
+		values = append(values, value)
+	}
+
+	return values, iter.Close()
+}
+
+const funcScyllaCheckMetadata string = "CheckMetadata"
+
+// CheckMetadata - verifies if a metadata exists
+func (sb *ScyllaBackend) CheckMetadata(collection, tsType, tsid string, tsidBytes []byte) (bool, gobol.Error) {
+
+	_, cached, err := sb.memcached.Get(tsidBytes, idNamespace, collection, tsType, tsid)
+	if err != nil {
+		return false, errInternalServer(funcScyllaCheckMetadata, err)
+	}
+
+	if cached {
+		return true, nil
+	}
+
+	found, gerr := sb.CheckStoredMetadata(collection, tsType, tsid)
+	if gerr != nil {
+		return false, gerr
+	}
+
+	if found {
+		go sb.cacheID(collection, tsType, tsid, tsidBytes)
+	}
+
+	return found, nil
+}
+
+// CheckStoredMetadata - verifies if a metadata exists in the storage, bypassing the id cache
+func (sb *ScyllaBackend) CheckStoredMetadata(collection, tsType, tsid string) (bool, gobol.Error) {
+
+	start := time.Now()
+
+	_, found, err := sb.document(collection, tsType, tsid)
+	if err != nil {
+		sb.statsError(funcScyllaCheckMetadata, collection, tsType, scyllaDocID)
+		return false, errInternalServer(funcScyllaCheckMetadata, err)
+	}
+
+	sb.statsRequest(funcScyllaCheckMetadata, collection, tsType, scyllaDocID, time.Since(start))
+
+	return found, nil
+}
+
+// cacheID - caches an ID
+func (sb *ScyllaBackend) cacheID(collection, tsType, tsid string, tsidBytes []byte) error {
+
+	if sb.noIDCache {
+		return nil
+	}
+
+	return sb.memcached.Put(tsidBytes, tsidOK, sb.idCacheTTL, idNamespace, collection, tsType, tsid)
+}
+
+const funcScyllaDeleteDocumentByID string = "DeleteDocumentByID"
+
+// DeleteDocumentByID - delete a document by ID and its index entries
+func (sb *ScyllaBackend) DeleteDocumentByID(collection, tsType, id string) gobol.Error {
+
+	start := time.Now()
+
+	doc, found, err := sb.document(collection, tsType, id)
+	if err != nil {
+		sb.statsError(funcScyllaDeleteDocumentByID, collection, tsType, scyllaDelete)
+		return errInternalServer(funcScyllaDeleteDocumentByID, err)
+	}
+
+	if !found {
+		return nil
+	}
+
+	if err := sb.deleteIndexEntries(collection, &doc.Metadata); err != nil {
+		sb.statsError(funcScyllaDeleteDocumentByID, collection, tsType, scyllaDelete)
+		return errInternalServer(funcScyllaDeleteDocumentByID, err)
+	}
+
+	if err := sb.session.Query(sb.cql(cqlDeleteDocument), collection, tsType, id).Exec(); err != nil {
+		sb.statsError(funcScyllaDeleteDocumentByID, collection, tsType, scyllaDelete)
+		return errInternalServer(funcScyllaDeleteDocumentByID, err)
+	}
+
+	if _, cached, err := sb.memcached.Get([]byte(id), idNamespace, collection, tsType, id); err == nil && cached {
+		err = sb.memcached.Delete([]byte(id), idNamespace, collection, tsType, id)
+		if err != nil && logh.ErrorEnabled {
+			sb.logger.Error().Str(constants.StringsFunc, funcScyllaDeleteDocumentByID).Str(constants.StringsKeyset, collection).Err(err).Msg("error deleting tsid from cache")
+		}
+	}
+
+	sb.statsRequest(funcScyllaDeleteDocumentByID, collection, tsType, scyllaDelete, time.Since(start))
+
+	return nil
+}
+
+// DeleteDocuments - delete the documents and their index entries (there is no commit to share)
+func (sb *ScyllaBackend) DeleteDocuments(collection string, metadatas []Metadata) gobol.Error {
+
+	for i := range metadatas {
+		if gerr := sb.DeleteDocumentByID(collection, metadatas[i].MetaType, metadatas[i].ID); gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// SetRegexValue - add slashes to the value
+func (sb *ScyllaBackend) SetRegexValue(value string) string {
+
+	if value == constants.StringsEmpty || value == "*" {
+		return value
+	}
+
+	return "/" + value + "/"
+}
+
+// HasRegexPattern - check if the value has a regular expression
+func (sb *ScyllaBackend) HasRegexPattern(value string) bool {
+
+	return sb.regexPattern.MatchString(value)
+}
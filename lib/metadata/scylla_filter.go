@@ -0,0 +1,521 @@
+package metadata
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// Queries of the scylla metadata backend: the query conditions are resolved to tsid sets using the inverted index.
+//
+
+// idSet - tsids mapped to their types
+type idSet map[string]string
+
+// leaveEmpty - checks if the value is '*' or empty
+func leaveEmpty(value string) bool {
+	return value == constants.StringsEmpty || value == "*" || value == ".*"
+}
+
+// compileExpression - compiles the query regular expression (the whole value must match, like in Solr)
+func compileExpression(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + removeRegexpSlashes(expr) + ")$")
+}
+
+// ids - returns the tsids indexed by the field value
+func (sb *ScyllaBackend) ids(collection, field, value, tsType string, ids idSet) error {
+
+	var args []interface{}
+	statement := cqlSelectIndex
+
+	if tsType == constants.StringsEmpty {
+		args = []interface{}{collection, field, value}
+	} else {
+		statement = cqlSelectIndexByType
+		args = []interface{}{collection, field, value, tsType}
+	}
+
+	iter := sb.session.Query(sb.cql(statement), args...).Iter()
+
+	var idType, id string
+	for iter.Scan(&idType, &id) {
+		ids[id] = idType
+	}
+
+	return iter.Close()
+}
+
+// idsByExpression - returns the tsids of all field values matching the expression
+func (sb *ScyllaBackend) idsByExpression(collection, field, expr string, isRegexp bool, tsType string, ids idSet) error {
+
+	if !isRegexp {
+		return sb.ids(collection, field, expr, tsType, ids)
+	}
+
+	r, err := compileExpression(expr)
+	if err != nil {
+		return err
+	}
+
+	values, err := sb.values(collection, field)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		if !r.MatchString(value) {
+			continue
+		}
+
+		if err := sb.ids(collection, field, value, tsType, ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// intersect - keeps only the tsids found in both sets (a nil set means all tsids)
+func intersect(current, other idSet) idSet {
+
+	if current == nil {
+		return other
+	}
+
+	for id := range current {
+		if _, ok := other[id]; !ok {
+			delete(current, id)
+		}
+	}
+
+	return current
+}
+
+// filterIDs - resolves the query to a set of tsids
+func (sb *ScyllaBackend) filterIDs(collection string, query *Query) (idSet, error) {
+
+	var result idSet
+	excluded := idSet{}
+
+	if !leaveEmpty(query.Metric) {
+		ids := idSet{}
+		if err := sb.idsByExpression(collection, indexFieldMetric, query.Metric, query.Regexp, query.MetaType, ids); err != nil {
+			return nil, err
+		}
+		result = intersect(result, ids)
+	}
+
+	for _, tag := range query.Tags {
+
+		valueField := indexFieldTagValue
+
+		if !leaveEmpty(tag.Key) {
+
+			ids := idSet{}
+			if err := sb.idsByExpression(collection, indexFieldTagKey, tag.Key, tag.Regexp, query.MetaType, ids); err != nil {
+				return nil, err
+			}
+			result = intersect(result, ids)
+
+			// a literal key restricts the values to the ones of this tag
+			if regexp.QuoteMeta(tag.Key) == tag.Key {
+				valueField = indexFieldTagPref + tag.Key
+			}
+		}
+
+		ids := idSet{}
+		hasValues := false
+
+		for _, value := range tag.Values {
+
+			if leaveEmpty(value) {
+				continue
+			}
+
+			hasValues = true
+
+			target := ids
+			if tag.Negate {
+				target = excluded
+			}
+
+			if err := sb.idsByExpression(collection, valueField, value, tag.Regexp, query.MetaType, target); err != nil {
+				return nil, err
+			}
+		}
+
+		if hasValues && !tag.Negate {
+			result = intersect(result, ids)
+		}
+	}
+
+	if result == nil {
+
+		result = idSet{}
+
+		err := sb.scanDocuments(collection, func(doc *scyllaDocument) {
+			if query.MetaType == constants.StringsEmpty || query.MetaType == doc.MetaType {
+				result[doc.ID] = doc.MetaType
+			}
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for id := range excluded {
+		delete(result, id)
+	}
+
+	return result, nil
+}
+
+const funcScyllaFilterMetadata string = "FilterMetadata"
+
+// FilterMetadata - list all metas from a collection
+func (sb *ScyllaBackend) FilterMetadata(collection string, query *Query, from, maxResults int) ([]Metadata, int, gobol.Error) {
+
+	start := time.Now()
+
+	ids, err := sb.filterIDs(collection, query)
+	if err != nil {
+		sb.statsError(funcScyllaFilterMetadata, collection, query.MetaType, scyllaQuery)
+		return nil, 0, errInternalServer(funcScyllaFilterMetadata, err)
+	}
+
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+
+	sort.Strings(sorted)
+
+	// the last write filters need all documents loaded before the pagination
+	filterLastSeen := query.ActiveSince > 0 || query.StaleBefore > 0 || query.NeverSeen
+
+	total := len(sorted)
+	if !filterLastSeen {
+		from, to := pageBounds(total, from, maxResults)
+		sorted = sorted[from:to]
+	}
+
+	var metadatas []Metadata
+
+	for _, id := range sorted {
+
+		doc, found, err := sb.document(collection, ids[id], id)
+		if err != nil {
+			sb.statsError(funcScyllaFilterMetadata, collection, query.MetaType, scyllaQuery)
+			return nil, 0, errInternalServer(funcScyllaFilterMetadata, err)
+		}
+
+		if found && (!filterLastSeen || matchLastSeen(query, doc.LastSeen)) {
+			metadatas = append(metadatas, doc.Metadata)
+		}
+	}
+
+	if filterLastSeen {
+		total = len(metadatas)
+		from, to := pageBounds(total, from, maxResults)
+		metadatas = metadatas[from:to]
+	}
+
+	sb.statsRequest(funcScyllaFilterMetadata, collection, query.MetaType, scyllaQuery, time.Since(start))
+
+	return metadatas, total, nil
+}
+
+// pageBounds - returns the slice bounds of the requested page
+func pageBounds(total, from, maxResults int) (int, int) {
+
+	if from > total {
+		from = total
+	}
+
+	to := from + maxResults
+	if to > total {
+		to = total
+	}
+
+	return from, to
+}
+
+// matchLastSeen - checks the last write timestamp against the query filters (a document without it is never stale)
+func matchLastSeen(query *Query, lastSeen int64) bool {
+
+	if query.ActiveSince > 0 && lastSeen < query.ActiveSince {
+		return false
+	}
+
+	if query.StaleBefore > 0 && (lastSeen <= 0 || lastSeen >= query.StaleBefore) {
+		return false
+	}
+
+	if query.NeverSeen && lastSeen > 0 {
+		return false
+	}
+
+	return true
+}
+
+// matchValues - filters the values like the Solr facets: "*" returns all, a regular expression or the exact value
+func (sb *ScyllaBackend) matchValues(values []string, expr string) ([]string, error) {
+
+	if expr == "*" {
+		return values, nil
+	}
+
+	matched := []string{}
+
+	if !sb.regexPattern.MatchString(expr) {
+		for _, value := range values {
+			if value == expr {
+				matched = append(matched, value)
+			}
+		}
+		return matched, nil
+	}
+
+	r, err := regexp.Compile(removeRegexpSlashes(expr))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		if r.MatchString(value) {
+			matched = append(matched, value)
+		}
+	}
+
+	return matched, nil
+}
+
+// activeValues - returns the distinct sorted values of an index field from the timeseries written after activeSince
+func (sb *ScyllaBackend) activeValues(collection, field string, activeSince int64) ([]string, error) {
+
+	distinct := map[string]struct{}{}
+
+	err := sb.scanDocuments(collection, func(doc *scyllaDocument) {
+
+		if doc.LastSeen < activeSince {
+			return
+		}
+
+		switch field {
+		case indexFieldMetric:
+			distinct[doc.Metric] = struct{}{}
+		case indexFieldTagKey:
+			for _, key := range doc.TagKey {
+				distinct[key] = struct{}{}
+			}
+		case indexFieldTagValue:
+			for _, value := range doc.TagValue {
+				distinct[value] = struct{}{}
+			}
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(distinct))
+	for value := range distinct {
+		values = append(values, value)
+	}
+
+	sort.Strings(values)
+
+	return values, nil
+}
+
+// filterFieldValues - filters the distinct values of an index field
+func (sb *ScyllaBackend) filterFieldValues(function, collection, field, expr string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
+
+	start := time.Now()
+
+	var values []string
+	var err error
+
+	if activeSince > 0 {
+		values, err = sb.activeValues(collection, field, activeSince)
+	} else {
+		values, err = sb.values(collection, field)
+	}
+
+	if err == nil {
+		values, err = sb.matchValues(values, expr)
+	}
+
+	if err != nil {
+		sb.statsError(function, collection, constants.StringsAll, scyllaQuery)
+		return nil, 0, errInternalServer(function, err)
+	}
+
+	sb.statsRequest(function, collection, constants.StringsAll, scyllaQuery, time.Since(start))
+
+	total := len(values)
+	if total > maxResults {
+		values = values[:maxResults]
+	}
+
+	return values, total, nil
+}
+
+// FilterTagValues - filter tag values from a collection
+func (sb *ScyllaBackend) FilterTagValues(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
+	return sb.filterFieldValues(funcFilterTagValues, collection, indexFieldTagValue, prefix, activeSince, maxResults)
+}
+
+// FilterTagKeys - filter tag keys from a collection
+func (sb *ScyllaBackend) FilterTagKeys(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
+	return sb.filterFieldValues(funcFilterTagKeys, collection, indexFieldTagKey, prefix, activeSince, maxResults)
+}
+
+// FilterMetrics - filter metrics from a collection
+func (sb *ScyllaBackend) FilterMetrics(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
+	return sb.filterFieldValues(funcFilterMetrics, collection, indexFieldMetric, prefix, activeSince, maxResults)
+}
+
+// filterTagsByMetric - returns the tag keys (or the values of a tag key) of the metric timeseries
+func (sb *ScyllaBackend) filterTagsByMetric(function, collection, tsType, metric, tagKey, expr string, maxResults int) ([]string, int, gobol.Error) {
+
+	start := time.Now()
+
+	ids := idSet{}
+	if err := sb.ids(collection, indexFieldMetric, metric, tsType, ids); err != nil {
+		sb.statsError(function, collection, tsType, scyllaQuery)
+		return nil, 0, errInternalServer(function, err)
+	}
+
+	distinct := map[string]struct{}{}
+
+	for id := range ids {
+
+		doc, found, err := sb.document(collection, tsType, id)
+		if err != nil {
+			sb.statsError(function, collection, tsType, scyllaQuery)
+			return nil, 0, errInternalServer(function, err)
+		}
+
+		if !found {
+			continue
+		}
+
+		for i, key := range doc.TagKey {
+			if tagKey == constants.StringsEmpty {
+				distinct[key] = struct{}{}
+			} else if key == tagKey && i < len(doc.TagValue) {
+				distinct[doc.TagValue[i]] = struct{}{}
+			}
+		}
+	}
+
+	values := make([]string, 0, len(distinct))
+	for value := range distinct {
+		values = append(values, value)
+	}
+
+	sort.Strings(values)
+
+	values, err := sb.matchValues(values, expr)
+	if err != nil {
+		sb.statsError(function, collection, tsType, scyllaQuery)
+		return nil, 0, errInternalServer(function, err)
+	}
+
+	sb.statsRequest(function, collection, tsType, scyllaQuery, time.Since(start))
+
+	total := len(values)
+	if total > maxResults {
+		values = values[:maxResults]
+	}
+
+	return values, total, nil
+}
+
+// FilterTagKeysByMetric - filter tag keys from a collection given its metric
+func (sb *ScyllaBackend) FilterTagKeysByMetric(collection, tsType, metric, prefix string, maxResults int) ([]string, int, gobol.Error) {
+	return sb.filterTagsByMetric(funcFilterTagKeysByMetric, collection, tsType, metric, constants.StringsEmpty, prefix, maxResults)
+}
+
+// FilterTagValuesByMetricAndTag - filter tag values from a collection given its metric and tag
+func (sb *ScyllaBackend) FilterTagValuesByMetricAndTag(collection, tsType, metric, tag, prefix string, maxResults int) ([]string, int, gobol.Error) {
+	return sb.filterTagsByMetric(funcFilterTagValuesByMetricAndTag, collection, tsType, metric, tag, prefix, maxResults)
+}
+
+// sortCounts - converts the counters to facet counts sorted by the greatest counts
+func sortCounts(counters map[string]int, topN int) []FacetCount {
+
+	counts := make([]FacetCount, 0, len(counters))
+	for value, count := range counters {
+		counts = append(counts, FacetCount{Value: value, Count: count})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Value < counts[j].Value
+		}
+		return counts[i].Count > counts[j].Count
+	})
+
+	if len(counts) > topN {
+		counts = counts[:topN]
+	}
+
+	return counts
+}
+
+// FilterCardinality - counts the timeseries by metric, tag key and the top tag values
+func (sb *ScyllaBackend) FilterCardinality(collection, metric, tagKey string, since time.Time, topN int) (*Cardinality, gobol.Error) {
+
+	start := time.Now()
+
+	metrics := map[string]int{}
+	tagKeys := map[string]int{}
+	tagValues := map[string]int{}
+	total := 0
+
+	err := sb.scanDocuments(collection, func(doc *scyllaDocument) {
+
+		if !leaveEmpty(metric) && doc.Metric != metric {
+			return
+		}
+
+		if !since.IsZero() && doc.created.Before(since) {
+			return
+		}
+
+		total++
+		metrics[doc.Metric]++
+
+		for i, key := range doc.TagKey {
+
+			tagKeys[key]++
+
+			if i < len(doc.TagValue) && (tagKey == constants.StringsEmpty || tagKey == key) {
+				tagValues[doc.TagValue[i]]++
+			}
+		}
+	})
+
+	if err != nil {
+		sb.statsError(funcFilterCardinality, collection, constants.StringsAll, scyllaQuery)
+		return nil, errInternalServer(funcFilterCardinality, err)
+	}
+
+	sb.statsRequest(funcFilterCardinality, collection, constants.StringsAll, scyllaQuery, time.Since(start))
+
+	return &Cardinality{
+		Total:     total,
+		Metrics:   sortCounts(metrics, topN),
+		TagKeys:   sortCounts(tagKeys, topN),
+		TagValues: sortCounts(tagValues, topN),
+	}, nil
+}
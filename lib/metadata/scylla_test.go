@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileExpression(t *testing.T) {
+
+	r, err := compileExpression("os.cpu.*")
+	if assert.NoError(t, err) {
+		assert.True(t, r.MatchString("os.cpu.user"))
+		assert.False(t, r.MatchString("sys.os.cpu"), "the whole value must match")
+	}
+
+	r, err = compileExpression("/host[0-9]|db/")
+	if assert.NoError(t, err) {
+		assert.True(t, r.MatchString("host1"))
+		assert.True(t, r.MatchString("db"))
+		assert.False(t, r.MatchString("host1db"), "the alternation is grouped")
+	}
+
+	_, err = compileExpression("host(")
+	assert.Error(t, err)
+}
+
+func TestIntersect(t *testing.T) {
+
+	other := idSet{"a": "meta", "b": "meta"}
+	assert.Equal(t, other, intersect(nil, other), "nil is the set of all tsids")
+
+	current := idSet{"a": "meta", "c": "metatext"}
+	assert.Equal(t, idSet{"a": "meta"}, intersect(current, other))
+	assert.Empty(t, intersect(idSet{"c": "meta"}, idSet{}))
+}
+
+func TestIndexEntries(t *testing.T) {
+
+	entries := indexEntries(&Metadata{
+		Metric:   "cpu",
+		TagKey:   []string{"host", "dc", "orphan"},
+		TagValue: []string{"a", "x"},
+	})
+
+	expected := [][2]string{
+		{indexFieldMetric, "cpu"},
+		{indexFieldTagKey, "host"}, {indexFieldTagValue, "a"}, {"tag:host", "a"},
+		{indexFieldTagKey, "dc"}, {indexFieldTagValue, "x"}, {"tag:dc", "x"},
+	}
+
+	assert.Equal(t, expected, entries, "the key without value is not indexed")
+}
+
+func TestScyllaMatchValues(t *testing.T) {
+
+	sb := &ScyllaBackend{regexPattern: newRegexPattern()}
+	values := []string{"host1", "host2", "db1"}
+
+	matched, err := sb.matchValues(values, "*")
+	assert.NoError(t, err)
+	assert.Equal(t, values, matched)
+
+	matched, err = sb.matchValues(values, "host.*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host1", "host2"}, matched)
+
+	matched, err = sb.matchValues(values, "db1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db1"}, matched, "not a regular expression, the exact value")
+
+	matched, err = sb.matchValues(values, "db")
+	assert.NoError(t, err)
+	assert.Empty(t, matched)
+
+	assert.Equal(t, "/host.*/", sb.SetRegexValue("host.*"))
+	assert.Equal(t, "*", sb.SetRegexValue("*"))
+	assert.True(t, sb.HasRegexPattern("host.*"))
+	assert.False(t, sb.HasRegexPattern("host"))
+}
+
+func TestSortCounts(t *testing.T) {
+
+	counters := map[string]int{"mem": 2, "cpu": 5, "disk": 2, "net": 1}
+
+	assert.Equal(t, []FacetCount{{"cpu", 5}, {"disk", 2}, {"mem", 2}, {"net", 1}}, sortCounts(counters, 10), "the same counts are sorted by value")
+	assert.Equal(t, []FacetCount{{"cpu", 5}, {"disk", 2}}, sortCounts(counters, 2))
+	assert.Empty(t, sortCounts(map[string]int{}, 10))
+}
+
+func TestNewScyllaBackendInvalid(t *testing.T) {
+
+	_, err := NewScyllaBackend(&Settings{ScyllaKeyspace: "mycenae"}, nil, nil, nil)
+	assert.EqualError(t, err, "no scylla session to create the metadata backend")
+
+	_, err = NewScyllaBackend(&Settings{}, nil, nil, &gocql.Session{})
+	assert.EqualError(t, err, "no scylla keyspace configured for the metadata backend")
+
+	_, err = NewScyllaBackend(&Settings{ScyllaKeyspace: "mycenae", KeysetCacheAutoUpdateInterval: "x"}, nil, nil, &gocql.Session{})
+	assert.Error(t, err)
+
+	_, err = Create(&Settings{Backend: "elastic"}, nil, nil, nil)
+	assert.EqualError(t, err, "unknown metadata backend: elastic")
+}
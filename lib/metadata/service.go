@@ -53,9 +53,6 @@ func NewSolrBackend(settings *Settings, mc *tlmanager.Instance, memcached *memca
 		return nil, err
 	}
 
-	baseWordRegexp := "[0-9A-Za-z\\-\\.\\_\\%\\&\\#\\;\\/\\?]+(\\{[0-9]+\\})?"
-	rp := regexp.MustCompile("^\\.?\\*" + baseWordRegexp + "|" + baseWordRegexp + "\\.?\\*$|\\[" + baseWordRegexp + "\\][\\+\\*]{1}|\\(" + baseWordRegexp + "\\)|" + baseWordRegexp + "\\{[0-9]+\\}")
-
 	blacklistedKeysetMap := map[string]bool{}
 	for _, value := range settings.BlacklistedKeysets {
 		blacklistedKeysetMap[value] = true
@@ -78,7 +75,7 @@ func NewSolrBackend(settings *Settings, mc *tlmanager.Instance, memcached *memca
 		logger:                        logger,
 		replicationFactor:             settings.ReplicationFactor,
 		numShards:                     settings.NumShards,
-		regexPattern:                  rp,
+		regexPattern:                  newRegexPattern(),
 		memcached:                     memcached,
 		idCacheTTL:                    []byte(strconv.Itoa(settings.IDCacheTTL)),
 		noIDCache:                     settings.IDCacheTTL < 0,
@@ -100,8 +97,20 @@ func NewSolrBackend(settings *Settings, mc *tlmanager.Instance, memcached *memca
 	return sb, nil
 }
 
+// newRegexPattern - creates the pattern used to check if a value has a regular expression
+func newRegexPattern() *regexp.Regexp {
+
+	baseWordRegexp := "[0-9A-Za-z\\-\\.\\_\\%\\&\\#\\;\\/\\?]+(\\{[0-9]+\\})?"
+	return regexp.MustCompile("^\\.?\\*" + baseWordRegexp + "|" + baseWordRegexp + "\\.?\\*$|\\[" + baseWordRegexp + "\\][\\+\\*]{1}|\\(" + baseWordRegexp + "\\)|" + baseWordRegexp + "\\{[0-9]+\\}")
+}
+
 // removeRegexpSlashes - removes all regular expression slashes
 func (sb *SolrBackend) removeRegexpSlashes(value string) string {
+	return removeRegexpSlashes(value)
+}
+
+// removeRegexpSlashes - removes all regular expression slashes
+func removeRegexpSlashes(value string) string {
 	length := len(value)
 	if length >= 3 && string(value[0]) == "/" && string(value[length-1]) == "/" {
 		runes := []rune(value)
@@ -189,7 +198,7 @@ func (sb *SolrBackend) cropFacets(facets []string, size int) []string {
 }
 
 // filterFieldValues - filter by field value using wildcard
-func (sb *SolrBackend) filterFieldValues(function, collection, field, value string, maxResults int) ([]string, int, gobol.Error) {
+func (sb *SolrBackend) filterFieldValues(function, collection, field, value string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
 
 	start := time.Now()
 
@@ -217,8 +226,17 @@ func (sb *SolrBackend) filterFieldValues(function, collection, field, value stri
 	}
 
 	q, _ := sb.buildMetadataQuery(&query, true)
+	cacheKey := q
 
-	facets, err := sb.getCachedFacets(collection, q)
+	var filterQueries []string
+	if activeSince > 0 {
+		// relative times would never hit the cache
+		query.ActiveSince = activeSince - activeSince%int64(time.Minute/time.Millisecond)
+		filterQueries = sb.buildLastSeenFilters(&query)
+		cacheKey += " " + strings.Join(filterQueries, " ")
+	}
+
+	facets, err := sb.getCachedFacets(collection, cacheKey)
 	if err != nil {
 		sb.statsError(function, collection, constants.StringsAll, solrFacetQuery)
 		if err == restrictedhttpclient.ErrMaxRequestsReached {
@@ -232,7 +250,7 @@ func (sb *SolrBackend) filterFieldValues(function, collection, field, value stri
 		return cropped, len(facets), nil
 	}
 
-	r, e := sb.solrService.Facets(collection, q, constants.StringsEmpty, 0, 0, nil, facetFields, childFacetFields, true, sb.maxReturnedMetadata, 1)
+	r, e := sb.solrService.Facets(collection, q, constants.StringsEmpty, 0, 0, filterQueries, facetFields, childFacetFields, true, sb.maxReturnedMetadata, 1)
 	if e != nil {
 		sb.statsError(function, collection, constants.StringsAll, solrFacetQuery)
 		if err == restrictedhttpclient.ErrMaxRequestsReached {
@@ -243,7 +261,7 @@ func (sb *SolrBackend) filterFieldValues(function, collection, field, value stri
 
 	facets = sb.extractFacets(r, field, value, collection)
 
-	err = sb.cacheFacets(facets, collection, q)
+	err = sb.cacheFacets(facets, collection, cacheKey)
 	if err != nil {
 		sb.statsError(function, collection, constants.StringsAll, solrFacetQuery)
 		if err == restrictedhttpclient.ErrMaxRequestsReached {
@@ -262,9 +280,9 @@ func (sb *SolrBackend) filterFieldValues(function, collection, field, value stri
 const funcFilterTagValues string = "FilterTagValues"
 
 // FilterTagValues - list all tag values from a collection
-func (sb *SolrBackend) FilterTagValues(collection, prefix string, maxResults int) ([]string, int, gobol.Error) {
+func (sb *SolrBackend) FilterTagValues(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
 
-	tags, total, err := sb.filterFieldValues(funcFilterTagValues, collection, "tag_value", prefix, maxResults)
+	tags, total, err := sb.filterFieldValues(funcFilterTagValues, collection, "tag_value", prefix, activeSince, maxResults)
 	if err != nil {
 		if err == restrictedhttpclient.ErrMaxRequestsReached {
 			return nil, 0, errServiceUnavailable(funcFilterTagValues, err)
@@ -278,9 +296,9 @@ func (sb *SolrBackend) FilterTagValues(collection, prefix string, maxResults int
 const funcFilterTagKeys string = "FilterTagKeys"
 
 // FilterTagKeys - list all tag keys from a collection
-func (sb *SolrBackend) FilterTagKeys(collection, prefix string, maxResults int) ([]string, int, gobol.Error) {
+func (sb *SolrBackend) FilterTagKeys(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
 
-	tags, total, err := sb.filterFieldValues(funcFilterTagKeys, collection, "tag_key", prefix, maxResults)
+	tags, total, err := sb.filterFieldValues(funcFilterTagKeys, collection, "tag_key", prefix, activeSince, maxResults)
 	if err != nil {
 		if err == restrictedhttpclient.ErrMaxRequestsReached {
 			return nil, 0, errServiceUnavailable(funcFilterTagKeys, err)
@@ -294,9 +312,9 @@ func (sb *SolrBackend) FilterTagKeys(collection, prefix string, maxResults int)
 const funcFilterMetrics string = "FilterMetrics"
 
 // FilterMetrics - list all metrics from a collection
-func (sb *SolrBackend) FilterMetrics(collection, prefix string, maxResults int) ([]string, int, gobol.Error) {
+func (sb *SolrBackend) FilterMetrics(collection, prefix string, activeSince int64, maxResults int) ([]string, int, gobol.Error) {
 
-	metrics, total, err := sb.filterFieldValues(funcFilterMetrics, collection, "metric", prefix, maxResults)
+	metrics, total, err := sb.filterFieldValues(funcFilterMetrics, collection, "metric", prefix, activeSince, maxResults)
 	if err != nil {
 		if err == restrictedhttpclient.ErrMaxRequestsReached {
 			return nil, 0, errServiceUnavailable(funcFilterMetrics, err)
@@ -490,7 +508,11 @@ func (sb *SolrBackend) buildMetadataQuery(query *Query, parentQueryOnly bool) (s
 	return parentQuery, filterQueries
 }
 
-const funcFilterMetadata string = "FilterMetadata"
+const (
+	funcFilterMetadata string = "FilterMetadata"
+	cLastSeenField     string = "last_seen"
+	cSolrDateFormat    string = "2006-01-02T15:04:05.000Z"
+)
 
 // FilterMetadata - list all metas from a collection
 func (sb *SolrBackend) FilterMetadata(collection string, query *Query, from, maxResults int) ([]Metadata, int, gobol.Error) {
@@ -498,6 +520,7 @@ func (sb *SolrBackend) FilterMetadata(collection string, query *Query, from, max
 	start := time.Now()
 
 	q, qfs := sb.buildMetadataQuery(query, false)
+	qfs = append(qfs, sb.buildLastSeenFilters(query)...)
 
 	r, err := sb.solrService.FilteredQuery(collection, q, sb.fieldListQuery, from, maxResults, qfs)
 	if err != nil {
@@ -513,6 +536,31 @@ func (sb *SolrBackend) FilterMetadata(collection string, query *Query, from, max
 	return sb.fromDocuments(r.Results, collection), r.Results.NumFound, nil
 }
 
+// buildLastSeenFilters - builds the parent document filters of the last write timestamp
+func (sb *SolrBackend) buildLastSeenFilters(query *Query) []string {
+
+	filterQueries := []string{}
+
+	if query.ActiveSince > 0 {
+		filterQueries = append(filterQueries, fmt.Sprintf("%s:[%s TO *]", cLastSeenField, formatSolrDate(query.ActiveSince)))
+	}
+
+	if query.StaleBefore > 0 {
+		filterQueries = append(filterQueries, fmt.Sprintf("%s:[* TO %s}", cLastSeenField, formatSolrDate(query.StaleBefore)))
+	}
+
+	if query.NeverSeen {
+		filterQueries = append(filterQueries, fmt.Sprintf("-%s:[* TO *]", cLastSeenField))
+	}
+
+	return filterQueries
+}
+
+// formatSolrDate - formats the timestamp in milliseconds as a solr date
+func formatSolrDate(millis int64) string {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format(cSolrDateFormat)
+}
+
 // toDocument - changes the metadata to the document format
 func (sb *SolrBackend) toDocument(metadata *Metadata, collection string) (docs *solr.Document, id string) {
 
@@ -540,6 +588,10 @@ func (sb *SolrBackend) toDocument(metadata *Metadata, collection string) (docs *
 		"_childDocuments_": tagDocs,
 	}
 
+	if metadata.LastSeen > 0 {
+		(*doc)[cLastSeenField] = formatSolrDate(metadata.LastSeen)
+	}
+
 	return doc, metadata.ID
 }
 
@@ -602,6 +654,12 @@ func (sb *SolrBackend) fromDocuments(results *solr.Collection, collection string
 			TagKey:   keys,
 			TagValue: values,
 		}
+
+		if lastSeen, ok := doc.Get(cLastSeenField).(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, lastSeen); err == nil {
+				metadatas[i].LastSeen = t.UnixNano() / int64(time.Millisecond)
+			}
+		}
 	}
 
 	return metadatas
@@ -641,6 +699,49 @@ func (sb *SolrBackend) AddDocument(collection string, m *Metadata) gobol.Error {
 	return nil
 }
 
+const funcUpdateLastSeen string = "UpdateLastSeen"
+
+// UpdateLastSeen - updates the last write timestamp of an existing document, the whole
+// block is replaced keeping its creation date (solr does not support partial updates of
+// nested documents) and it is left to the auto commit
+func (sb *SolrBackend) UpdateLastSeen(collection string, m *Metadata) gobol.Error {
+
+	start := time.Now()
+
+	r, err := sb.solrService.SimpleQuery(collection, fmt.Sprintf(queryCheckMetadata, m.ID, m.MetaType), cCreationDateField, 0, 1)
+	if err != nil {
+		sb.statsError(funcUpdateLastSeen, collection, m.MetaType, solrDocID)
+		if err == restrictedhttpclient.ErrMaxRequestsReached {
+			return errServiceUnavailable(funcUpdateLastSeen, err)
+		}
+		return errInternalServer(funcUpdateLastSeen, err)
+	}
+
+	// the document was deleted and must not be recreated
+	if r.Results == nil || len(r.Results.Docs) == 0 {
+		return nil
+	}
+
+	doc, _ := sb.toDocument(m, collection)
+
+	if created := r.Results.Docs[0].Get(cCreationDateField); created != nil {
+		(*doc)[cCreationDateField] = created
+	}
+
+	err = sb.solrService.AddDocument(collection, false, doc)
+	if err != nil {
+		sb.statsError(funcUpdateLastSeen, collection, m.MetaType, solrUpdate)
+		if err == restrictedhttpclient.ErrMaxRequestsReached {
+			return errServiceUnavailable(funcUpdateLastSeen, err)
+		}
+		return errInternalServer(funcUpdateLastSeen, err)
+	}
+
+	sb.statsRequest(funcUpdateLastSeen, collection, m.MetaType, solrUpdate, time.Since(start))
+
+	return nil
+}
+
 const (
 	funcCheckMetadata  string = "CheckMetadata"
 	queryCheckMetadata string = "parent_doc:true AND id:%s AND type:%s"
@@ -661,6 +762,21 @@ func (sb *SolrBackend) CheckMetadata(collection, tsType, tsid string, tsidBytes
 		return true, nil
 	}
 
+	found, gerr := sb.CheckStoredMetadata(collection, tsType, tsid)
+	if gerr != nil {
+		return false, gerr
+	}
+
+	if found {
+		go sb.cacheID(collection, tsType, tsid, tsidBytes)
+	}
+
+	return found, nil
+}
+
+// CheckStoredMetadata - verifies if a metadata exists in solr, bypassing the id cache
+func (sb *SolrBackend) CheckStoredMetadata(collection, tsType, tsid string) (bool, gobol.Error) {
+
 	start := time.Now()
 
 	q := fmt.Sprintf(queryCheckMetadata, tsid, tsType)
@@ -668,20 +784,15 @@ func (sb *SolrBackend) CheckMetadata(collection, tsType, tsid string, tsidBytes
 
 	if e != nil {
 		sb.statsError(funcCheckMetadata, collection, tsType, solrDocID)
-		if err == restrictedhttpclient.ErrMaxRequestsReached {
-			return false, errServiceUnavailable(funcCheckMetadata, err)
+		if e == restrictedhttpclient.ErrMaxRequestsReached {
+			return false, errServiceUnavailable(funcCheckMetadata, e)
 		}
-		return false, errInternalServer(funcCheckMetadata, err)
+		return false, errInternalServer(funcCheckMetadata, e)
 	}
 
 	sb.statsRequest(funcCheckMetadata, collection, tsType, solrDocID, time.Since(start))
 
-	if r.Results.NumFound > 0 {
-		go sb.cacheID(collection, tsType, tsid, tsidBytes)
-		return true, nil
-	}
-
-	return false, nil
+	return r.Results.NumFound > 0, nil
 }
 
 const (
@@ -712,6 +823,42 @@ func (sb *SolrBackend) DeleteDocumentByID(collection, tsType, id string) gobol.E
 	return nil
 }
 
+const funcDeleteDocuments string = "DeleteDocuments"
+
+// DeleteDocuments - delete the documents and their child documents with a single commit
+func (sb *SolrBackend) DeleteDocuments(collection string, metadatas []Metadata) gobol.Error {
+
+	if len(metadatas) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+
+	ids := make([]string, len(metadatas))
+	for i := range metadatas {
+		ids[i] = "id:" + fmt.Sprintf(queryDeleteDocumentByID, metadatas[i].ID)
+	}
+
+	err := sb.solrService.DeleteDocumentByQuery(collection, true, strings.Join(ids, " OR "))
+	if err != nil {
+		sb.statsError(funcDeleteDocuments, collection, constants.StringsAll, solrDelete)
+		if err == restrictedhttpclient.ErrMaxRequestsReached {
+			return errServiceUnavailable(funcDeleteDocuments, err)
+		}
+		return errInternalServer(funcDeleteDocuments, err)
+	}
+
+	go func() {
+		for i := range metadatas {
+			sb.DeleteCachedIDifExist(collection, metadatas[i].MetaType, metadatas[i].ID)
+		}
+	}()
+
+	sb.statsRequest(funcDeleteDocuments, collection, constants.StringsAll, solrDelete, time.Since(start))
+
+	return nil
+}
+
 const funcDeleteCachedIDifExist string = "DeleteCachedIDifExist"
 
 // DeleteCachedIDifExist - check if ID is cached and delete it
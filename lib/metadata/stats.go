@@ -41,6 +41,7 @@ const (
 	solrQuery      solrOperation = "query"
 	solrFacetQuery solrOperation = "facet_query"
 	solrDelete     solrOperation = "delete"
+	solrUpdate     solrOperation = "update"
 )
 
 // statsError - stores an error statistics
@@ -103,3 +104,68 @@ func (sb *SolrBackend) statsListCollectionsError(function string) {
 		metricListCollectionsError,
 	)
 }
+
+const (
+	// metricScyllaRequest - metric name for the scylla metadata backend request
+	metricScyllaRequest string = "scylla.meta.request"
+
+	// metricScyllaRequestDuration - metric name for the scylla metadata backend request duration
+	metricScyllaRequestDuration string = "scylla.meta.request.duration"
+
+	// metricScyllaRequestError - metric name for the scylla metadata backend request errors
+	metricScyllaRequestError string = "scylla.meta.request.error"
+)
+
+// scyllaOperation - identifies some scylla metadata backend operation
+type scyllaOperation string
+
+const (
+	scyllaDocID  scyllaOperation = "doc_by_id"
+	scyllaInsert scyllaOperation = "new_doc"
+	scyllaQuery  scyllaOperation = "query"
+	scyllaDelete scyllaOperation = "delete"
+	scyllaUpdate scyllaOperation = "update"
+)
+
+// statsError - stores an error statistics
+func (sb *ScyllaBackend) statsError(function, collection, metaType string, operation scyllaOperation) {
+
+	sb.timelineManager.FlattenCountIncN(
+		function,
+		metricScyllaRequestError,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(collection),
+		constants.StringsType, metaType,
+		constants.StringsOperation, operation,
+	)
+}
+
+// statsRequest - stores a sucessful request statistics
+func (sb *ScyllaBackend) statsRequest(function, collection, metaType string, operation scyllaOperation, d time.Duration) {
+
+	sb.timelineManager.FlattenMaxN(
+		function,
+		float64(d.Nanoseconds())/float64(time.Millisecond),
+		metricScyllaRequestDuration,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(collection),
+		constants.StringsType, metaType,
+		constants.StringsOperation, operation,
+	)
+
+	sb.timelineManager.FlattenCountIncN(
+		function,
+		metricScyllaRequest,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(collection),
+		constants.StringsType, metaType,
+		constants.StringsOperation, operation,
+	)
+}
+
+// statsMissedKeyset - stores a missed keyset statistics
+func (sb *ScyllaBackend) statsMissedKeyset(function, collection string) {
+
+	sb.timelineManager.FlattenCountIncN(
+		function,
+		metricMissedKeyset,
+		constants.StringsTargetKSID, utils.ValidateExpectedValue(collection),
+	)
+}
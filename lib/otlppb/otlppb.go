@@ -0,0 +1,452 @@
+package otlppb
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/uol/mycenae/lib/protowire"
+)
+
+//
+// OpenTelemetry metrics protobuf messages (only the fields used by mycenae).
+//
+
+// AggregationTemporality - the sum and histogram temporality
+type AggregationTemporality uint8
+
+const (
+	// TemporalityUnspecified - the temporality was not set
+	TemporalityUnspecified AggregationTemporality = 0
+	// TemporalityDelta - each point contains the change since the last report
+	TemporalityDelta AggregationTemporality = 1
+	// TemporalityCumulative - each point contains the accumulated value since the start time
+	TemporalityCumulative AggregationTemporality = 2
+)
+
+// DataPointFlagNoRecordedValue - the point has no value and must be ignored
+const DataPointFlagNoRecordedValue uint64 = 1
+
+// MetricType - the metric data type
+type MetricType uint8
+
+const (
+	// MetricTypeUnsupported - exponential histograms, summaries or empty metrics
+	MetricTypeUnsupported MetricType = 0
+	// MetricTypeGauge - a gauge
+	MetricTypeGauge MetricType = 1
+	// MetricTypeSum - a sum
+	MetricTypeSum MetricType = 2
+	// MetricTypeHistogram - an explicit buckets histogram
+	MetricTypeHistogram MetricType = 3
+)
+
+// KeyValue - an attribute, the value is converted to string
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// NumberDataPoint - a gauge or sum point
+type NumberDataPoint struct {
+	Attributes        []KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	Value             float64
+	Flags             uint64
+}
+
+// HistogramDataPoint - an explicit buckets histogram point
+type HistogramDataPoint struct {
+	Attributes        []KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	Count             uint64
+	Sum               float64
+	HasSum            bool
+	BucketCounts      []uint64
+	ExplicitBounds    []float64
+	Flags             uint64
+}
+
+// Metric - a metric with its points
+type Metric struct {
+	Name                   string
+	Type                   MetricType
+	AggregationTemporality AggregationTemporality
+	IsMonotonic            bool
+	NumberDataPoints       []NumberDataPoint
+	HistogramDataPoints    []HistogramDataPoint
+}
+
+// ResourceMetrics - the metrics of one resource
+type ResourceMetrics struct {
+	Attributes []KeyValue
+	Metrics    []Metric
+}
+
+// ExportMetricsServiceRequest - the export request
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []ResourceMetrics
+}
+
+// readMessages - calls the function for each length delimited field with the specified number
+func readMessages(data []byte, fn func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error)) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		handled, err := fn(field, r, wt)
+		if err != nil {
+			return err
+		}
+
+		if !handled {
+			if err = r.Skip(wt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Unmarshal - decodes the export request
+func (req *ExportMetricsServiceRequest) Unmarshal(data []byte) error {
+
+	return readMessages(data, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		if field != 1 || wt != protowire.WireBytes {
+			return false, nil
+		}
+
+		b, err := r.Bytes()
+		if err != nil {
+			return true, err
+		}
+
+		rm := ResourceMetrics{}
+		if err = rm.Unmarshal(b); err != nil {
+			return true, err
+		}
+
+		req.ResourceMetrics = append(req.ResourceMetrics, rm)
+
+		return true, nil
+	})
+}
+
+// Unmarshal - decodes the resource metrics (scopes are flattened)
+func (rm *ResourceMetrics) Unmarshal(data []byte) error {
+
+	return readMessages(data, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		if wt != protowire.WireBytes {
+			return false, nil
+		}
+
+		switch field {
+		case 1: // resource
+			b, err := r.Bytes()
+			if err != nil {
+				return true, err
+			}
+			return true, readMessages(b, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+				if field != 1 || wt != protowire.WireBytes {
+					return false, nil
+				}
+				return true, readKeyValue(r, &rm.Attributes)
+			})
+		case 2, 1000: // scope metrics and the deprecated instrumentation library metrics
+			b, err := r.Bytes()
+			if err != nil {
+				return true, err
+			}
+			return true, readMessages(b, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+				if field != 2 || wt != protowire.WireBytes {
+					return false, nil
+				}
+				mb, err := r.Bytes()
+				if err != nil {
+					return true, err
+				}
+				m := Metric{}
+				if err = m.Unmarshal(mb); err != nil {
+					return true, err
+				}
+				rm.Metrics = append(rm.Metrics, m)
+				return true, nil
+			})
+		}
+
+		return false, nil
+	})
+}
+
+// Unmarshal - decodes a metric
+func (m *Metric) Unmarshal(data []byte) error {
+
+	return readMessages(data, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		if wt != protowire.WireBytes {
+			return false, nil
+		}
+
+		var err error
+
+		switch field {
+		case 1:
+			m.Name, err = r.String()
+			return true, err
+		case 5:
+			m.Type = MetricTypeGauge
+		case 7:
+			m.Type = MetricTypeSum
+		case 9:
+			m.Type = MetricTypeHistogram
+		default:
+			return false, nil
+		}
+
+		b, err := r.Bytes()
+		if err != nil {
+			return true, err
+		}
+
+		return true, m.unmarshalData(b)
+	})
+}
+
+// unmarshalData - decodes the gauge, sum or histogram message
+func (m *Metric) unmarshalData(data []byte) error {
+
+	return readMessages(data, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		switch {
+		case field == 1 && wt == protowire.WireBytes:
+			b, err := r.Bytes()
+			if err != nil {
+				return true, err
+			}
+
+			if m.Type == MetricTypeHistogram {
+				p := HistogramDataPoint{}
+				if err = p.Unmarshal(b); err != nil {
+					return true, err
+				}
+				m.HistogramDataPoints = append(m.HistogramDataPoints, p)
+			} else {
+				p := NumberDataPoint{}
+				if err = p.Unmarshal(b); err != nil {
+					return true, err
+				}
+				m.NumberDataPoints = append(m.NumberDataPoints, p)
+			}
+
+			return true, nil
+		case field == 2 && wt == protowire.WireVarint:
+			v, err := r.Varint()
+			m.AggregationTemporality = AggregationTemporality(v)
+			return true, err
+		case field == 3 && wt == protowire.WireVarint && m.Type == MetricTypeSum:
+			v, err := r.Varint()
+			m.IsMonotonic = v != 0
+			return true, err
+		}
+
+		return false, nil
+	})
+}
+
+// Unmarshal - decodes a number data point
+func (p *NumberDataPoint) Unmarshal(data []byte) error {
+
+	return readMessages(data, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		var err error
+
+		switch {
+		case field == 7 && wt == protowire.WireBytes:
+			err = readKeyValue(r, &p.Attributes)
+		case field == 2 && wt == protowire.WireFixed64:
+			p.StartTimeUnixNano, err = r.Fixed64()
+		case field == 3 && wt == protowire.WireFixed64:
+			p.TimeUnixNano, err = r.Fixed64()
+		case field == 4 && wt == protowire.WireFixed64:
+			p.Value, err = r.Double()
+		case field == 6 && wt == protowire.WireFixed64:
+			var v uint64
+			v, err = r.Fixed64()
+			p.Value = float64(int64(v))
+		case field == 8 && wt == protowire.WireVarint:
+			p.Flags, err = r.Varint()
+		default:
+			return false, nil
+		}
+
+		return true, err
+	})
+}
+
+// Unmarshal - decodes a histogram data point
+func (p *HistogramDataPoint) Unmarshal(data []byte) error {
+
+	return readMessages(data, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		var err error
+
+		switch {
+		case field == 9 && wt == protowire.WireBytes:
+			err = readKeyValue(r, &p.Attributes)
+		case field == 2 && wt == protowire.WireFixed64:
+			p.StartTimeUnixNano, err = r.Fixed64()
+		case field == 3 && wt == protowire.WireFixed64:
+			p.TimeUnixNano, err = r.Fixed64()
+		case field == 4 && wt == protowire.WireFixed64:
+			p.Count, err = r.Fixed64()
+		case field == 5 && wt == protowire.WireFixed64:
+			p.Sum, err = r.Double()
+			p.HasSum = true
+		case field == 6 && wt == protowire.WireFixed64:
+			var v uint64
+			v, err = r.Fixed64()
+			p.BucketCounts = append(p.BucketCounts, v)
+		case field == 6 && wt == protowire.WireBytes:
+			err = readPackedFixed64(r, func(v uint64) { p.BucketCounts = append(p.BucketCounts, v) })
+		case field == 7 && wt == protowire.WireFixed64:
+			var v float64
+			v, err = r.Double()
+			p.ExplicitBounds = append(p.ExplicitBounds, v)
+		case field == 7 && wt == protowire.WireBytes:
+			err = readPackedFixed64(r, func(v uint64) { p.ExplicitBounds = append(p.ExplicitBounds, math.Float64frombits(v)) })
+		case field == 10 && wt == protowire.WireVarint:
+			p.Flags, err = r.Varint()
+		default:
+			return false, nil
+		}
+
+		return true, err
+	})
+}
+
+// readPackedFixed64 - reads a packed repeated fixed 64 bits field
+func readPackedFixed64(r *protowire.Reader, fn func(uint64)) error {
+
+	b, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+
+	pr := protowire.NewReader(b)
+
+	for !pr.Done() {
+
+		v, err := pr.Fixed64()
+		if err != nil {
+			return err
+		}
+
+		fn(v)
+	}
+
+	return nil
+}
+
+// readKeyValue - reads a key value message and appends it to the list
+func readKeyValue(r *protowire.Reader, list *[]KeyValue) error {
+
+	b, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+
+	kv := KeyValue{}
+
+	err = readMessages(b, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		if wt != protowire.WireBytes {
+			return false, nil
+		}
+
+		var err error
+
+		switch field {
+		case 1:
+			kv.Key, err = r.String()
+		case 2:
+			var vb []byte
+			if vb, err = r.Bytes(); err == nil {
+				kv.Value, err = anyValueToString(vb)
+			}
+		default:
+			return false, nil
+		}
+
+		return true, err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	*list = append(*list, kv)
+
+	return nil
+}
+
+// anyValueToString - converts the scalar any value to string (arrays, lists and bytes are ignored)
+func anyValueToString(data []byte) (string, error) {
+
+	var value string
+
+	err := readMessages(data, func(field int, r *protowire.Reader, wt protowire.WireType) (bool, error) {
+
+		var err error
+
+		switch {
+		case field == 1 && wt == protowire.WireBytes:
+			value, err = r.String()
+		case field == 2 && wt == protowire.WireVarint:
+			var v uint64
+			v, err = r.Varint()
+			value = strconv.FormatBool(v != 0)
+		case field == 3 && wt == protowire.WireVarint:
+			var v int64
+			v, err = r.Int64()
+			value = strconv.FormatInt(v, 10)
+		case field == 4 && wt == protowire.WireFixed64:
+			var v float64
+			v, err = r.Double()
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return false, nil
+		}
+
+		return true, err
+	})
+
+	return value, err
+}
+
+// MarshalExportResponse - encodes the export response with the partial success information (if any point was rejected)
+func MarshalExportResponse(rejectedDataPoints int64, errorMessage string) []byte {
+
+	w := protowire.NewWriter(64)
+
+	if rejectedDataPoints == 0 {
+		return w.Bytes()
+	}
+
+	partial := protowire.NewWriter(64)
+	partial.Int64(1, rejectedDataPoints)
+	partial.String(2, errorMessage)
+
+	w.BytesField(1, partial.Bytes())
+
+	return w.Bytes()
+}
@@ -0,0 +1,251 @@
+package otlppb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/protowire"
+)
+
+// the writer has no fixed64 integer method, the bits are written as a double
+func fixed64(w *protowire.Writer, field int, v uint64) {
+	w.Double(field, math.Float64frombits(v))
+}
+
+func message(fn func(w *protowire.Writer)) []byte {
+
+	w := protowire.NewWriter(64)
+	fn(w)
+
+	return w.Bytes()
+}
+
+func stringAttribute(key, value string) []byte {
+
+	return message(func(w *protowire.Writer) {
+		w.String(1, key)
+		w.BytesField(2, message(func(v *protowire.Writer) { v.String(1, value) }))
+	})
+}
+
+func TestExportMetricsServiceRequestUnmarshal(t *testing.T) {
+
+	gaugePoint := message(func(w *protowire.Writer) {
+		w.BytesField(7, stringAttribute("host", "a"))
+		fixed64(w, 3, 1600000000000000000)
+		w.Double(4, 0.5)
+	})
+
+	negative := int64(-42)
+
+	sumPoint := message(func(w *protowire.Writer) {
+		fixed64(w, 2, 1500000000000000000)
+		fixed64(w, 3, 1600000000000000000)
+		// as_int (sfixed64)
+		fixed64(w, 6, uint64(negative))
+		w.Varint(8, DataPointFlagNoRecordedValue)
+	})
+
+	histogramPoint := message(func(w *protowire.Writer) {
+		w.BytesField(9, stringAttribute("route", "/"))
+		fixed64(w, 3, 1600000000000000000)
+		fixed64(w, 4, 6)
+		w.Double(5, 12.5)
+		// packed bucket counts and bounds
+		w.BytesField(6, message(func(p *protowire.Writer) {
+			p.Double(1, math.Float64frombits(1))
+		})[1:])
+		w.BytesField(7, message(func(p *protowire.Writer) {
+			p.Double(1, 0.1)
+		})[1:])
+	})
+
+	metrics := message(func(scope *protowire.Writer) {
+		// the scope (1) is ignored
+		scope.BytesField(1, message(func(w *protowire.Writer) { w.String(1, "lib") }))
+		scope.BytesField(2, message(func(m *protowire.Writer) {
+			m.String(1, "gauge")
+			m.BytesField(5, message(func(g *protowire.Writer) { g.BytesField(1, gaugePoint) }))
+		}))
+		scope.BytesField(2, message(func(m *protowire.Writer) {
+			m.String(1, "sum")
+			m.BytesField(7, message(func(s *protowire.Writer) {
+				s.BytesField(1, sumPoint)
+				s.Varint(2, uint64(TemporalityCumulative))
+				s.Varint(3, 1)
+			}))
+		}))
+		scope.BytesField(2, message(func(m *protowire.Writer) {
+			m.String(1, "histogram")
+			m.BytesField(9, message(func(h *protowire.Writer) {
+				h.BytesField(1, histogramPoint)
+				h.Varint(2, uint64(TemporalityDelta))
+			}))
+		}))
+		scope.BytesField(2, message(func(m *protowire.Writer) {
+			m.String(1, "summary")
+			m.BytesField(11, message(func(s *protowire.Writer) { s.BytesField(1, []byte{}) }))
+		}))
+	})
+
+	resource := message(func(r *protowire.Writer) {
+		r.BytesField(1, stringAttribute("service.name", "api"))
+		r.BytesField(1, message(func(w *protowire.Writer) {
+			w.String(1, "pid")
+			w.BytesField(2, message(func(v *protowire.Writer) { v.Int64(3, -7) }))
+		}))
+		r.BytesField(1, message(func(w *protowire.Writer) {
+			w.String(1, "debug")
+			w.BytesField(2, message(func(v *protowire.Writer) { v.Varint(2, 1) }))
+		}))
+		r.BytesField(1, message(func(w *protowire.Writer) {
+			w.String(1, "ratio")
+			w.BytesField(2, message(func(v *protowire.Writer) { v.Double(4, 0.25) }))
+		}))
+		r.BytesField(1, message(func(w *protowire.Writer) {
+			w.String(1, "list")
+			w.BytesField(2, message(func(v *protowire.Writer) { v.BytesField(5, []byte{}) }))
+		}))
+	})
+
+	request := message(func(w *protowire.Writer) {
+		w.BytesField(1, message(func(rm *protowire.Writer) {
+			rm.BytesField(1, resource)
+			rm.BytesField(2, metrics)
+		}))
+		// the deprecated instrumentation library metrics field
+		w.BytesField(1, message(func(rm *protowire.Writer) {
+			rm.BytesField(1000, metrics)
+		}))
+	})
+
+	req := ExportMetricsServiceRequest{}
+	if !assert.NoError(t, req.Unmarshal(request)) || !assert.Len(t, req.ResourceMetrics, 2) {
+		return
+	}
+
+	expected := []Metric{
+		{
+			Name: "gauge",
+			Type: MetricTypeGauge,
+			NumberDataPoints: []NumberDataPoint{
+				{Attributes: []KeyValue{{Key: "host", Value: "a"}}, TimeUnixNano: 1600000000000000000, Value: 0.5},
+			},
+		},
+		{
+			Name:                   "sum",
+			Type:                   MetricTypeSum,
+			AggregationTemporality: TemporalityCumulative,
+			IsMonotonic:            true,
+			NumberDataPoints: []NumberDataPoint{
+				{StartTimeUnixNano: 1500000000000000000, TimeUnixNano: 1600000000000000000, Value: -42, Flags: DataPointFlagNoRecordedValue},
+			},
+		},
+		{
+			Name:                   "histogram",
+			Type:                   MetricTypeHistogram,
+			AggregationTemporality: TemporalityDelta,
+			HistogramDataPoints: []HistogramDataPoint{
+				{
+					Attributes:     []KeyValue{{Key: "route", Value: "/"}},
+					TimeUnixNano:   1600000000000000000,
+					Count:          6,
+					Sum:            12.5,
+					HasSum:         true,
+					BucketCounts:   []uint64{1},
+					ExplicitBounds: []float64{0.1},
+				},
+			},
+		},
+		{
+			Name: "summary",
+		},
+	}
+
+	assert.Equal(t, []KeyValue{
+		{Key: "service.name", Value: "api"},
+		{Key: "pid", Value: "-7"},
+		{Key: "debug", Value: "true"},
+		{Key: "ratio", Value: "0.25"},
+		{Key: "list", Value: ""},
+	}, req.ResourceMetrics[0].Attributes)
+
+	assert.Equal(t, expected, req.ResourceMetrics[0].Metrics)
+	assert.Nil(t, req.ResourceMetrics[1].Attributes)
+	assert.Equal(t, expected, req.ResourceMetrics[1].Metrics)
+}
+
+func TestHistogramDataPointUnpackedFields(t *testing.T) {
+
+	data := message(func(w *protowire.Writer) {
+		fixed64(w, 6, 2)
+		fixed64(w, 6, 3)
+		w.Double(7, 1.5)
+		w.Varint(10, 1)
+	})
+
+	p := HistogramDataPoint{}
+	if assert.NoError(t, p.Unmarshal(data)) {
+		assert.Equal(t, HistogramDataPoint{BucketCounts: []uint64{2, 3}, ExplicitBounds: []float64{1.5}, Flags: 1}, p)
+	}
+}
+
+func TestExportMetricsServiceRequestUnmarshalInvalid(t *testing.T) {
+
+	cases := map[string][]byte{
+		"TruncatedResource":      {0x0a, 0x05, 0x0a},
+		"TruncatedMetric":        {0x0a, 0x04, 0x12, 0x02, 0x12, 0x05},
+		"TruncatedPackedBuckets": message(func(w *protowire.Writer) { w.BytesField(6, []byte{0x01, 0x02}) }),
+		"InvalidWireType":        {0x0f},
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			var err error
+			if name == "TruncatedPackedBuckets" {
+				err = (&HistogramDataPoint{}).Unmarshal(data)
+			} else {
+				err = (&ExportMetricsServiceRequest{}).Unmarshal(data)
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMarshalExportResponse(t *testing.T) {
+
+	assert.Empty(t, MarshalExportResponse(0, "ignored"))
+
+	r := protowire.NewReader(MarshalExportResponse(3, "3 points rejected"))
+
+	field, wt, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, field)
+	assert.Equal(t, protowire.WireBytes, wt)
+
+	partial, err := r.Bytes()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pr := protowire.NewReader(partial)
+
+	_, _, err = pr.Next()
+	assert.NoError(t, err)
+	rejected, err := pr.Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), rejected)
+
+	_, _, err = pr.Next()
+	assert.NoError(t, err)
+	message, err := pr.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "3 points rejected", message)
+
+	assert.True(t, r.Done())
+	assert.True(t, pr.Done())
+}
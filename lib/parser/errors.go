@@ -72,3 +72,8 @@ func errRateResetValue(e error) gobol.Error {
 func errUnkFunc(msg string) gobol.Error {
 	return errBasic("parseExpression", msg, errors.New(msg))
 }
+
+func errUnkOper(function, funcName, oper string) gobol.Error {
+	s := fmt.Sprintf("unknown %s operation %s", funcName, oper)
+	return errBasic(function, s, errors.New(s))
+}
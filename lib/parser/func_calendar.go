@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseCalendar(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[8:]))
+
+	if len(params) != 1 && len(params) != 2 {
+		return constants.StringsEmpty, errParams(
+			"parseCalendar",
+			"calendar needs 1 or 2 parameters: an optional timezone and a function",
+			fmt.Errorf("calendar expects 1 or 2 parameters but found %d: %v", len(params), params),
+		)
+	}
+
+	if tsdb.UseCalendar {
+		return constants.StringsEmpty, errDoubleFunc("parseCalendar", "calendar")
+	}
+
+	tsdb.UseCalendar = true
+
+	if len(params) == 2 {
+		tsdb.Timezone = params[0]
+	}
+
+	return params[len(params)-1], nil
+}
+
+func writeCalendar(exp string, useCalendar bool, timezone string) string {
+	if !useCalendar {
+		return exp
+	}
+	if timezone != constants.StringsEmpty {
+		return fmt.Sprintf("calendar(%s,%s)", timezone, exp)
+	}
+	return fmt.Sprintf("calendar(%s)", exp)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/uol/gobol"
 
+	"github.com/uol/mycenae/lib/config"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/structs"
 )
@@ -14,15 +15,19 @@ func parseDownsample(exp string, tsdb *structs.TSDBquery) (string, gobol.Error)
 
 	params := parseParams(string(exp[10:]))
 
-	if len(params) != 4 {
+	if len(params) != 4 && len(params) != 5 {
 		return constants.StringsEmpty, errParams(
 			"parseDownsample",
-			"downsample needs 4 parameters: downsample operation, downsample period, fill option and a function",
-			fmt.Errorf("downsample expects 4 parameters but found %d: %v", len(params), params),
+			"downsample needs 4 parameters: downsample operation, downsample period, fill option and a function (the max gap of the previous and linear fill options can be set before the function)",
+			fmt.Errorf("downsample expects 4 or 5 parameters but found %d: %v", len(params), params),
 		)
 	}
 
-	tsdb.Downsample = fmt.Sprintf("%s-%s-%s", params[0], params[1], params[2])
+	if !validOperation(params[1], config.GetDownsamplers()) {
+		return constants.StringsEmpty, errUnkOper("parseDownsample", "downsample", params[1])
+	}
+
+	tsdb.Downsample = strings.Join(params[:len(params)-1], "-")
 
 	for _, oper := range tsdb.Order {
 		if oper == "downsample" {
@@ -32,7 +37,7 @@ func parseDownsample(exp string, tsdb *structs.TSDBquery) (string, gobol.Error)
 
 	tsdb.Order = append([]string{"downsample"}, tsdb.Order...)
 
-	return params[3], nil
+	return params[len(params)-1], nil
 }
 
 func writeDownsample(exp, dsInfo string) string {
@@ -41,7 +46,7 @@ func writeDownsample(exp, dsInfo string) string {
 		if len(info) == 2 {
 			info = append(info, "none")
 		}
-		exp = fmt.Sprintf("downsample(%s,%s,%s,%s)", info[0], info[1], info[2], exp)
+		exp = fmt.Sprintf("downsample(%s,%s)", strings.Join(info, ","), exp)
 	}
 	return exp
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/uol/gobol"
 
+	"github.com/uol/mycenae/lib/config"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/structs"
 )
@@ -21,6 +22,10 @@ func parseMerge(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
 		)
 	}
 
+	if !validOperation(params[0], config.GetAggregators()) {
+		return constants.StringsEmpty, errUnkOper("parseMerge", "merge", params[0])
+	}
+
 	tsdb.Aggregator = params[0]
 
 	for _, oper := range tsdb.Order {
@@ -37,3 +42,13 @@ func parseMerge(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
 func writeMerge(exp, operator string) string {
 	return fmt.Sprintf("merge(%s,%s)", operator, exp)
 }
+
+// validOperation - checks if the operation is in the list of the valid ones
+func validOperation(oper string, valid []string) bool {
+	for _, v := range valid {
+		if v == oper {
+			return true
+		}
+	}
+	return false
+}
@@ -53,6 +53,8 @@ func parseExpression(exp string, tsdb *structs.TSDBquery) (relative string, err
 		exp, err = parseRate(exp, tsdb)
 	case "filter":
 		exp, err = parseFilter(exp, tsdb)
+	case "calendar":
+		exp, err = parseCalendar(exp, tsdb)
 	default:
 		return constants.StringsEmpty, errUnkFunc(fmt.Sprintf("unkown function %s", string(name)))
 	}
@@ -89,6 +91,14 @@ func CompileExpression(tsQueries []structs.TSDBqueryPayload) (exps []string) {
 
 			}
 
+			useCalendar := query.UseCalendar || tsQuery.UseCalendar
+			timezone := query.Timezone
+			if timezone == constants.StringsEmpty {
+				timezone = tsQuery.Timezone
+			}
+
+			exp = writeCalendar(exp, useCalendar, timezone)
+
 			exp = writeGroup(exp, query.Filters)
 
 			exps = append(exps, exp)
@@ -2,6 +2,7 @@ package plot
 
 import (
 	"math"
+	"sort"
 	"time"
 
 	"github.com/uol/mycenae/lib/structs"
@@ -15,6 +16,71 @@ const (
 	msWeek = 6.048e+8
 )
 
+// percentiles - the percentile of each percentile aggregation
+var percentiles = map[string]float64{
+	"median": 50,
+	"p50":    50,
+	"p75":    75,
+	"p90":    90,
+	"p95":    95,
+	"p99":    99,
+	"p999":   99.9,
+}
+
+// isHolistic - checks if the aggregation needs all values of the group to be calculated
+func isHolistic(aggregation string) bool {
+
+	if _, ok := percentiles[aggregation]; ok {
+		return true
+	}
+
+	return aggregation == "dev" || aggregation == "stddev"
+}
+
+// aggregateValues - calculates the holistic aggregation (the values are sorted in place)
+func aggregateValues(aggregation string, values []float64) float64 {
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	if p, ok := percentiles[aggregation]; ok {
+		return percentile(p, values)
+	}
+
+	return stddev(values)
+}
+
+// percentile - calculates the percentile using linear interpolation between the closest ranks
+func percentile(p float64, values []float64) float64 {
+
+	sort.Float64s(values)
+
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	if lower == upper {
+		return values[lower]
+	}
+
+	return values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+}
+
+// stddev - calculates the population standard deviation
+func stddev(values []float64) float64 {
+
+	var mean, m2 float64
+
+	for i, v := range values {
+		delta := v - mean
+		mean += delta / float64(i+1)
+		m2 += delta * (v - mean)
+	}
+
+	return math.Sqrt(m2 / float64(len(values)))
+}
+
 func basic(totalPoints int, serie []Pnt) (groupSerie []Pnt) {
 
 	total := len(serie)
@@ -129,9 +195,10 @@ func rate(options structs.TSDBrateOptions, serie Pnts) Pnts {
 	return rateSerie
 }
 
-func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, serie Pnts) Pnts {
+// alignStart - aligns the start date to the beginning of the downsample unit
+func alignStart(start int64, options structs.DSoptions, loc *time.Location) int64 {
 
-	startDate := time.Unix(0, start*1e+6)
+	startDate := time.Unix(0, start*1e+6).In(loc)
 
 	switch options.Unit {
 	case "sec":
@@ -143,7 +210,7 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 			startDate.Minute(),
 			startDate.Second(),
 			0,
-			time.Local,
+			loc,
 		)
 		start = base.Unix() * 1e+3
 	case "min":
@@ -155,7 +222,7 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 			startDate.Minute(),
 			0,
 			0,
-			time.Local,
+			loc,
 		)
 		start = base.Unix() * 1e+3
 	case "hour":
@@ -167,33 +234,38 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 			0,
 			0,
 			0,
-			time.Local,
+			loc,
 		)
 		start = base.Unix() * 1e+3
 	case "day":
-		base := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.Local)
+		base := calendarDay(startDate.Year(), startDate.Month(), startDate.Day(), loc)
 		start = base.Unix() * 1e+3
 	case "week":
-		base := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.Local)
+		base := calendarDay(startDate.Year(), startDate.Month(), startDate.Day(), loc)
 		for base.Weekday() != time.Monday {
-			base = base.AddDate(0, 0, -1)
+			base = calendarDay(base.Year(), base.Month(), base.Day()-1, loc)
 		}
 		start = base.Unix() * 1e+3
 	case "month":
-		base := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.Local)
-		for base.Month() == startDate.Month() {
-			base = base.AddDate(0, 0, -1)
-		}
-		base = base.AddDate(0, 0, 1)
+		base := calendarDay(startDate.Year(), startDate.Month(), 1, loc)
 		start = base.Unix() * 1e+3
 	case "year":
-		base := time.Date(startDate.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+		base := calendarDay(startDate.Year(), time.January, 1, loc)
 		start = base.Unix() * 1e+3
 	}
 
+	return start
+}
+
+func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, serie Pnts) Pnts {
+
+	loc := downsampleLocation(options)
+
+	start = alignStart(start, options, loc)
+
 	groupDate := start
 
-	endInterval := getEndInterval(start, options.Unit, options.Value)
+	endInterval := getEndInterval(start, options, loc)
 
 	var groupedCount float64
 
@@ -201,6 +273,10 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 
 	groupedSerie := Pnts{}
 
+	holistic := isHolistic(options.Downsample)
+
+	var groupedValues []float64
+
 	for i := 0; i < len(serie); i++ {
 
 		point := serie[i]
@@ -223,7 +299,7 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 
 			groupDate = endInterval
 
-			endInterval = getEndInterval(endInterval, options.Unit, options.Value)
+			endInterval = getEndInterval(endInterval, options, loc)
 		}
 
 		groupedCount++
@@ -249,6 +325,10 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 			}
 		case "pnt":
 			groupedPoint.Value = groupedCount
+		default:
+			if holistic {
+				groupedValues = append(groupedValues, point.Value)
+			}
 		}
 
 		if i+1 == len(serie) || serie[i+1].Date >= endInterval {
@@ -259,6 +339,9 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 
 			if options.Downsample == "avg" {
 				groupedPoint.Value = groupedPoint.Value / groupedCount
+			} else if holistic {
+				groupedPoint.Value = aggregateValues(options.Downsample, groupedValues)
+				groupedValues = groupedValues[:0]
 			}
 
 			groupedSerie = append(groupedSerie, groupedPoint)
@@ -268,7 +351,7 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 			groupedPoint = Pnt{}
 
 			if i+1 != len(serie) {
-				endInterval = getEndInterval(endInterval, options.Unit, options.Value)
+				endInterval = getEndInterval(endInterval, options, loc)
 			}
 		}
 
@@ -289,18 +372,176 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 
 			groupedSerie = append(groupedSerie, groupedPoint)
 
-			endInterval = getEndInterval(i, options.Unit, options.Value)
+			endInterval = getEndInterval(i, options, loc)
+		}
+
+		if structs.IsInterpolatingFill(options.Fill) {
+			fillGaps(options, groupedSerie)
 		}
 	}
 
 	return groupedSerie
 }
 
-func getEndInterval(start int64, unit string, value int) int64 {
+// fillValue - calculates the value of a missing point using the previous and next real points
+func fillValue(options structs.DSoptions, prev, next *Pnt, date int64) (float64, bool) {
+
+	switch options.Fill {
+	case "previous":
+		if prev == nil || (options.MaxGap > 0 && date-prev.Date > options.MaxGap) {
+			return 0, false
+		}
+
+		return prev.Value, true
+	case "linear":
+		if prev == nil || next == nil || (options.MaxGap > 0 && next.Date-prev.Date > options.MaxGap) {
+			return 0, false
+		}
+
+		return prev.Value + (next.Value-prev.Value)*float64(date-prev.Date)/float64(next.Date-prev.Date), true
+	}
+
+	return 0, false
+}
+
+// fillGaps - fills the empty points of a sorted serie (the ones beyond the max gap stay empty)
+func fillGaps(options structs.DSoptions, serie Pnts) {
+
+	var prev *Pnt
+
+	next := 0
+
+	for i := range serie {
+
+		if !serie[i].Empty {
+			prev = &serie[i]
+			continue
+		}
+
+		for next < len(serie) && (next <= i || serie[next].Empty) {
+			next++
+		}
+
+		var nextPoint *Pnt
+
+		if next < len(serie) {
+			nextPoint = &serie[next]
+		}
+
+		if value, ok := fillValue(options, prev, nextPoint, serie[i].Date); ok {
+			serie[i].Value = value
+			serie[i].Empty = false
+		}
+	}
+}
+
+// interpolateSeries - adds to each serie the dates found only in the other ones before merging them
+func interpolateSeries(options structs.DSoptions, series []Pnts) Pnts {
+
+	dateSet := map[int64]struct{}{}
+
+	for _, serie := range series {
+		for _, point := range serie {
+			if !point.Empty {
+				dateSet[point.Date] = struct{}{}
+			}
+		}
+	}
+
+	dates := make([]int64, 0, len(dateSet))
+
+	for date := range dateSet {
+		dates = append(dates, date)
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+
+	interpolated := Pnts{}
+
+	for _, serie := range series {
+
+		interpolated = append(interpolated, serie...)
+
+		present := make(map[int64]struct{}, len(serie))
+		realPoints := make(Pnts, 0, len(serie))
+
+		for _, point := range serie {
+			present[point.Date] = struct{}{}
+			if !point.Empty {
+				realPoints = append(realPoints, point)
+			}
+		}
+
+		sort.Sort(realPoints)
+
+		next := 0
+
+		for _, date := range dates {
+
+			for next < len(realPoints) && realPoints[next].Date <= date {
+				next++
+			}
+
+			if _, ok := present[date]; ok {
+				continue
+			}
+
+			var prev, nextPoint *Pnt
+
+			if next > 0 {
+				prev = &realPoints[next-1]
+			}
+
+			if next < len(realPoints) {
+				nextPoint = &realPoints[next]
+			}
+
+			if value, ok := fillValue(options, prev, nextPoint, date); ok {
+				interpolated = append(interpolated, Pnt{Date: date, Value: value})
+			}
+		}
+	}
+
+	return interpolated
+}
+
+// downsampleLocation - returns the location used to align the intervals (the query validation only
+// accepts a timezone with the calendar option)
+func downsampleLocation(options structs.DSoptions) *time.Location {
+
+	if !options.UseCalendar || options.Timezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(options.Timezone)
+	if err != nil {
+		return time.Local
+	}
+
+	return loc
+}
+
+// calendarDay - returns the first instant of the day (midnight does not exist in some daylight saving transitions)
+func calendarDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+
+	year, month, day = time.Date(year, month, day, 12, 0, 0, 0, loc).Date()
+
+	base := time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	for base.Day() != day {
+		base = base.Add(time.Hour)
+	}
+
+	return base
+}
+
+func getEndInterval(start int64, options structs.DSoptions, loc *time.Location) int64 {
 
 	var end int64
 
-	switch unit {
+	value := options.Value
+
+	switch options.Unit {
 	case "ms":
 		end = start + int64(value)
 	case "sec":
@@ -310,23 +551,29 @@ func getEndInterval(start int64, unit string, value int) int64 {
 	case "hour":
 		end = start + msHour*int64(value)
 	case "day":
-		end = start + msDay*int64(value)
+		if options.UseCalendar {
+			year, month, day := msToTime(start).In(loc).Date()
+			end = timeToMs(calendarDay(year, month, day+value, loc))
+		} else {
+			end = start + msDay*int64(value)
+		}
 	case "week":
-		end = start + msWeek*int64(value)
+		if options.UseCalendar {
+			year, month, day := msToTime(start).In(loc).Date()
+			end = timeToMs(calendarDay(year, month, day+7*value, loc))
+		} else {
+			end = start + msWeek*int64(value)
+		}
 	case "month":
-		startDate := time.Unix(0, start*1e+6)
+		startDate := time.Unix(0, start*1e+6).In(loc)
 
-		base := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.Local)
-
-		base = base.AddDate(0, value, 0)
+		base := calendarDay(startDate.Year(), startDate.Month()+time.Month(value), 1, loc)
 
 		end = base.Unix() * 1e+3
 	case "year":
-		startDate := time.Unix(0, start*1e+6)
-
-		base := time.Date(startDate.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+		startDate := time.Unix(0, start*1e+6).In(loc)
 
-		base = base.AddDate(value, 0, 0)
+		base := calendarDay(startDate.Year()+value, time.January, 1, loc)
 
 		end = base.Unix() * 1e+3
 	default:
@@ -375,6 +622,10 @@ func merge(mergeType string, keepEmpties bool, serie Pnts) Pnts {
 
 	mergedSerie := Pnts{}
 
+	holistic := isHolistic(mergeType)
+
+	var mergedValues []float64
+
 	for i := 0; i < len(serie); i++ {
 
 		point := serie[i]
@@ -393,8 +644,12 @@ func merge(mergeType string, keepEmpties bool, serie Pnts) Pnts {
 
 			mergedCount++
 
+			mergedValues = mergedValues[:0]
+
 			if point.Empty {
 				nullCount++
+			} else if holistic {
+				mergedValues = append(mergedValues, point.Value)
 			}
 
 			for point.Date == nextPoint.Date {
@@ -422,6 +677,10 @@ func merge(mergeType string, keepEmpties bool, serie Pnts) Pnts {
 						}
 					case "pnt":
 						mergedPoint.Value = mergedCount
+					default:
+						if holistic {
+							mergedValues = append(mergedValues, nextPoint.Value)
+						}
 					}
 				} else {
 					nullCount++
@@ -446,8 +705,16 @@ func merge(mergeType string, keepEmpties bool, serie Pnts) Pnts {
 				mergedPoint.Empty = true
 			}
 
+			if holistic && len(mergedValues) > 0 {
+				mergedPoint.Value = aggregateValues(mergeType, mergedValues)
+			}
+
 		} else {
 			mergedPoint = point
+
+			if holistic && !point.Empty {
+				mergedPoint.Value = aggregateValues(mergeType, []float64{point.Value})
+			}
 		}
 
 		mergedSerie = append(mergedSerie, mergedPoint)
@@ -0,0 +1,427 @@
+package plot
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func TestPercentile(t *testing.T) {
+
+	values := []float64{15, 20, 35, 40, 50}
+
+	cases := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 15},
+		{25, 20},
+		{50, 35},
+		{60, 37},
+		{90, 46},
+		{99.9, 49.96},
+		{100, 50},
+	}
+
+	for _, c := range cases {
+		assert.InDelta(t, c.expected, percentile(c.p, append([]float64{}, values...)), 1e-9, "p%v", c.p)
+	}
+
+	assert.Equal(t, 7.0, percentile(99, []float64{7}), "a single value is every percentile")
+}
+
+func TestAggregateValues(t *testing.T) {
+
+	cases := map[string]struct {
+		aggregation string
+		values      []float64
+		expected    float64
+	}{
+		"MedianUnsorted":       {"median", []float64{9, 1, 5}, 5},
+		"MedianEvenCount":      {"median", []float64{4, 1, 3, 2}, 2.5},
+		"P50":                  {"p50", []float64{4, 1, 3, 2}, 2.5},
+		"P75":                  {"p75", []float64{4, 1, 3, 2}, 3.25},
+		"StandardDeviation":    {"stddev", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+		"Dev":                  {"dev", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+		"StandardDeviationOf1": {"stddev", []float64{42}, 0},
+		"NoValues":             {"p99", nil, 0},
+	}
+
+	for name, c := range cases {
+		assert.InDelta(t, c.expected, aggregateValues(c.aggregation, c.values), 1e-9, name)
+	}
+}
+
+func TestIsHolistic(t *testing.T) {
+
+	for _, aggregation := range []string{"median", "dev", "stddev", "p50", "p75", "p90", "p95", "p99", "p999"} {
+		assert.True(t, isHolistic(aggregation), aggregation)
+	}
+
+	for _, aggregation := range []string{"avg", "count", "min", "max", "sum", "pnt", "p42", ""} {
+		assert.False(t, isHolistic(aggregation), aggregation)
+	}
+}
+
+func TestDownsampleHolistic(t *testing.T) {
+
+	start := time.Date(2020, time.January, 1, 10, 0, 0, 0, time.Local).Unix() * 1e3
+
+	serie := Pnts{
+		{Date: start, Value: 4},
+		{Date: start + 10e3, Value: 1},
+		{Date: start + 20e3, Value: 3},
+		{Date: start + 30e3, Value: 2},
+		{Date: start + 60e3, Value: 10},
+		{Date: start + 90e3, Value: 20},
+	}
+
+	cases := map[string][]float64{
+		"median": {2.5, 15},
+		"p75":    {3.25, 17.5},
+		"stddev": {math.Sqrt(1.25), 5},
+	}
+
+	for aggregation, expected := range cases {
+
+		options := structs.DSoptions{Downsample: aggregation, Unit: "min", Value: 1, Fill: "none"}
+
+		result := downsample(options, false, start, start+120e3, append(Pnts{}, serie...))
+
+		if assert.Len(t, result, len(expected), aggregation) {
+			for i, value := range expected {
+				assert.Equal(t, start+int64(i)*60e3, result[i].Date, aggregation)
+				assert.InDelta(t, value, result[i].Value, 1e-9, aggregation)
+			}
+		}
+	}
+}
+
+func TestDownsampleHolisticKeepsTheEmptyGroups(t *testing.T) {
+
+	start := time.Date(2020, time.January, 1, 10, 0, 0, 0, time.Local).Unix() * 1e3
+
+	serie := Pnts{
+		{Date: start, Value: 1},
+		{Date: start + 30e3, Value: 3},
+		{Date: start + 120e3, Value: 8},
+	}
+
+	options := structs.DSoptions{Downsample: "p90", Unit: "min", Value: 1, Fill: "null"}
+
+	result := downsample(options, true, start, start+180e3, serie)
+
+	if assert.Len(t, result, 3) {
+		assert.InDelta(t, 2.8, result[0].Value, 1e-9)
+		assert.True(t, result[1].Empty, "the values of a group must not leak to the next one")
+		assert.Equal(t, 8.0, result[2].Value)
+	}
+}
+
+func TestMergeHolistic(t *testing.T) {
+
+	serie := Pnts{
+		{Date: 1000, Value: 3},
+		{Date: 1000, Value: 1},
+		{Date: 1000, Empty: true},
+		{Date: 1000, Value: 2},
+		{Date: 2000, Value: 5},
+		{Date: 3000, Empty: true},
+	}
+
+	cases := map[string]Pnts{
+		"median": {
+			{Date: 1000, Value: 2},
+			{Date: 2000, Value: 5},
+			{Date: 3000, Empty: true},
+		},
+		"p999": {
+			{Date: 1000, Value: 2.998},
+			{Date: 2000, Value: 5},
+			{Date: 3000, Empty: true},
+		},
+		"stddev": {
+			{Date: 1000, Value: math.Sqrt(2.0 / 3.0)},
+			{Date: 2000, Value: 0},
+			{Date: 3000, Empty: true},
+		},
+	}
+
+	for aggregation, expected := range cases {
+
+		result := merge(aggregation, true, append(Pnts{}, serie...))
+
+		if assert.Len(t, result, len(expected), aggregation) {
+			for i := range expected {
+				assert.Equal(t, expected[i].Date, result[i].Date, aggregation)
+				assert.Equal(t, expected[i].Empty, result[i].Empty, aggregation)
+				assert.InDelta(t, expected[i].Value, result[i].Value, 1e-9, aggregation)
+			}
+		}
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+
+	empty := Pnt{Empty: true}
+
+	serie := func(points ...Pnt) Pnts {
+		for i := range points {
+			points[i].Date = int64(i) * 60e3
+		}
+		return points
+	}
+
+	value := func(v float64) Pnt { return Pnt{Value: v} }
+
+	cases := map[string]struct {
+		options  structs.DSoptions
+		serie    Pnts
+		expected Pnts
+	}{
+		"Previous": {
+			structs.DSoptions{Fill: "previous"},
+			serie(value(1), empty, empty, value(4), empty),
+			serie(value(1), value(1), value(1), value(4), value(4)),
+		},
+		"PreviousMaxGap": {
+			structs.DSoptions{Fill: "previous", MaxGap: 60e3},
+			serie(value(1), empty, empty, value(4), empty),
+			serie(value(1), value(1), empty, value(4), value(4)),
+		},
+		"PreviousWithoutFirstPoint": {
+			structs.DSoptions{Fill: "previous"},
+			serie(empty, value(2)),
+			serie(empty, value(2)),
+		},
+		"Linear": {
+			structs.DSoptions{Fill: "linear"},
+			serie(value(1), empty, empty, value(4), empty),
+			serie(value(1), value(2), value(3), value(4), empty),
+		},
+		"LinearMaxGap": {
+			structs.DSoptions{Fill: "linear", MaxGap: 120e3},
+			serie(value(1), empty, empty, value(4), empty, value(0)),
+			serie(value(1), empty, empty, value(4), value(2), value(0)),
+		},
+		"LinearWithoutFirstPoint": {
+			structs.DSoptions{Fill: "linear"},
+			serie(empty, empty, value(2)),
+			serie(empty, empty, value(2)),
+		},
+	}
+
+	for name, c := range cases {
+		fillGaps(c.options, c.serie)
+		assert.Equal(t, c.expected, c.serie, name)
+	}
+}
+
+func TestDownsampleLinearFill(t *testing.T) {
+
+	start := time.Date(2020, time.January, 1, 10, 0, 0, 0, time.Local).Unix() * 1e3
+
+	serie := Pnts{
+		{Date: start, Value: 2},
+		{Date: start + 180e3, Value: 8},
+	}
+
+	options := structs.DSoptions{Downsample: "max", Unit: "min", Value: 1, Fill: "linear"}
+
+	result := downsample(options, true, start, start+300e3, serie)
+
+	expected := []float64{2, 4, 6, 8}
+
+	if assert.True(t, len(result) >= len(expected)) {
+		for i, value := range expected {
+			assert.False(t, result[i].Empty, "minute %d", i)
+			assert.Equal(t, value, result[i].Value, "minute %d", i)
+		}
+		for _, point := range result[len(expected):] {
+			assert.True(t, point.Empty, "there is no point after the last one to interpolate")
+		}
+	}
+}
+
+func TestInterpolateSeries(t *testing.T) {
+
+	series := []Pnts{
+		{{Date: 0, Value: 1}, {Date: 120e3, Value: 3}, {Date: 180e3, Empty: true}},
+		{{Date: 60e3, Value: 10}, {Date: 120e3, Value: 20}, {Date: 240e3, Value: 40}},
+	}
+
+	// the empty dates are not interpolated in the other series and there is nothing to
+	// interpolate before the first point
+	cases := map[string]struct {
+		options structs.DSoptions
+		added   Pnts
+	}{
+		"Linear": {
+			structs.DSoptions{Fill: "linear"},
+			Pnts{{Date: 60e3, Value: 2}},
+		},
+		"Previous": {
+			structs.DSoptions{Fill: "previous"},
+			Pnts{{Date: 60e3, Value: 1}, {Date: 240e3, Value: 3}},
+		},
+		"PreviousMaxGap": {
+			structs.DSoptions{Fill: "previous", MaxGap: 60e3},
+			Pnts{{Date: 60e3, Value: 1}},
+		},
+		"LinearMaxGap": {
+			structs.DSoptions{Fill: "linear", MaxGap: 60e3},
+			Pnts{},
+		},
+	}
+
+	for name, c := range cases {
+
+		expected := append(append(Pnts{}, series[0]...), series[1]...)
+		expected = append(expected, c.added...)
+
+		assert.ElementsMatch(t, expected, interpolateSeries(c.options, series), name)
+	}
+}
+
+func loadLocation(t *testing.T, name string) *time.Location {
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc
+}
+
+func TestCalendarDay(t *testing.T) {
+
+	saoPaulo := loadLocation(t, "America/Sao_Paulo")
+
+	// the daylight saving time started at midnight, the day starts at 01:00
+	assert.Equal(t, time.Date(2018, time.November, 4, 1, 0, 0, 0, saoPaulo), calendarDay(2018, time.November, 4, saoPaulo))
+
+	assert.Equal(t, time.Date(2018, time.November, 5, 0, 0, 0, 0, saoPaulo), calendarDay(2018, time.November, 5, saoPaulo))
+
+	// the day overflow is normalized
+	assert.Equal(t, time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC), calendarDay(2020, time.January, 32, time.UTC))
+	assert.Equal(t, time.Date(2019, time.December, 31, 0, 0, 0, 0, time.UTC), calendarDay(2020, time.January, 0, time.UTC))
+}
+
+func TestGetEndIntervalCalendar(t *testing.T) {
+
+	newYork := loadLocation(t, "America/New_York")
+	saoPaulo := loadLocation(t, "America/Sao_Paulo")
+
+	ms := func(year int, month time.Month, day, hour int, loc *time.Location) int64 {
+		return timeToMs(time.Date(year, month, day, hour, 0, 0, 0, loc))
+	}
+
+	cases := map[string]struct {
+		options  structs.DSoptions
+		loc      *time.Location
+		start    int64
+		expected int64
+	}{
+		"ShortDay": {
+			structs.DSoptions{Unit: "day", Value: 1, UseCalendar: true},
+			newYork, ms(2020, time.March, 8, 0, newYork), ms(2020, time.March, 9, 0, newYork),
+		},
+		"LongDay": {
+			structs.DSoptions{Unit: "day", Value: 1, UseCalendar: true},
+			newYork, ms(2020, time.November, 1, 0, newYork), ms(2020, time.November, 2, 0, newYork),
+		},
+		"FixedDay": {
+			structs.DSoptions{Unit: "day", Value: 1},
+			newYork, ms(2020, time.March, 8, 0, newYork), ms(2020, time.March, 8, 0, newYork) + 24*3600e3,
+		},
+		"Week": {
+			structs.DSoptions{Unit: "week", Value: 1, UseCalendar: true},
+			newYork, ms(2020, time.March, 2, 0, newYork), ms(2020, time.March, 9, 0, newYork),
+		},
+		"FixedWeek": {
+			structs.DSoptions{Unit: "week", Value: 1},
+			newYork, ms(2020, time.March, 2, 0, newYork), ms(2020, time.March, 2, 0, newYork) + 7*24*3600e3,
+		},
+		"MonthWithoutMidnight": {
+			structs.DSoptions{Unit: "month", Value: 1, UseCalendar: true},
+			saoPaulo, ms(2018, time.October, 1, 0, saoPaulo), ms(2018, time.November, 1, 0, saoPaulo),
+		},
+		"TwoMonthsCrossingTheYear": {
+			structs.DSoptions{Unit: "month", Value: 2, UseCalendar: true},
+			saoPaulo, ms(2019, time.December, 1, 0, saoPaulo), ms(2020, time.February, 1, 0, saoPaulo),
+		},
+		"Year": {
+			structs.DSoptions{Unit: "year", Value: 1, UseCalendar: true},
+			saoPaulo, ms(2019, time.January, 1, 0, saoPaulo), ms(2020, time.January, 1, 0, saoPaulo),
+		},
+	}
+
+	for name, c := range cases {
+		assert.Equal(t, c.expected, getEndInterval(c.start, c.options, c.loc), name)
+	}
+}
+
+func TestDownsampleLocation(t *testing.T) {
+
+	assert.Equal(t, time.Local, downsampleLocation(structs.DSoptions{Timezone: "Asia/Tokyo"}), "the timezone requires the calendar option")
+	assert.Equal(t, time.Local, downsampleLocation(structs.DSoptions{UseCalendar: true}))
+	assert.Equal(t, time.Local, downsampleLocation(structs.DSoptions{UseCalendar: true, Timezone: "Mars/Olympus"}))
+	assert.Equal(t, "Asia/Tokyo", downsampleLocation(structs.DSoptions{UseCalendar: true, Timezone: "Asia/Tokyo"}).String())
+}
+
+func TestDownsampleCalendarTimezone(t *testing.T) {
+
+	tokyo := loadLocation(t, "Asia/Tokyo")
+
+	serie := Pnts{
+		{Date: timeToMs(time.Date(2020, time.January, 1, 23, 0, 0, 0, time.UTC)), Value: 1},
+		{Date: timeToMs(time.Date(2020, time.January, 2, 10, 0, 0, 0, time.UTC)), Value: 2},
+		{Date: timeToMs(time.Date(2020, time.January, 2, 16, 0, 0, 0, time.UTC)), Value: 4},
+	}
+
+	options := structs.DSoptions{Downsample: "sum", Unit: "day", Value: 1, Fill: "none", UseCalendar: true, Timezone: "Asia/Tokyo"}
+
+	result := downsample(options, false, serie[0].Date, serie[2].Date, serie)
+
+	expected := Pnts{
+		{Date: timeToMs(time.Date(2020, time.January, 2, 0, 0, 0, 0, tokyo)), Value: 3},
+		{Date: timeToMs(time.Date(2020, time.January, 3, 0, 0, 0, 0, tokyo)), Value: 4},
+	}
+
+	assert.Equal(t, expected, result)
+}
+
+func TestDownsampleCalendarMonths(t *testing.T) {
+
+	saoPaulo := loadLocation(t, "America/Sao_Paulo")
+
+	date := func(month time.Month, day int) int64 {
+		return timeToMs(time.Date(2019, month, day, 12, 0, 0, 0, saoPaulo))
+	}
+
+	serie := Pnts{
+		{Date: date(time.January, 31), Value: 1},
+		{Date: date(time.February, 1), Value: 10},
+		{Date: date(time.February, 28), Value: 20},
+		{Date: date(time.April, 2), Value: 5},
+	}
+
+	options := structs.DSoptions{Downsample: "max", Unit: "month", Value: 1, Fill: "zero", UseCalendar: true, Timezone: "America/Sao_Paulo"}
+
+	result := downsample(options, true, serie[0].Date, date(time.April, 30), serie)
+
+	month := func(m time.Month) int64 { return timeToMs(time.Date(2019, m, 1, 0, 0, 0, 0, saoPaulo)) }
+
+	expected := Pnts{
+		{Date: month(time.January), Value: 1},
+		{Date: month(time.February), Value: 20},
+		{Date: month(time.March), Value: 0},
+		{Date: month(time.April), Value: 5},
+	}
+
+	assert.Equal(t, expected, result)
+}
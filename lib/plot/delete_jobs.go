@@ -0,0 +1,674 @@
+package plot
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Runs the committed deletions in background jobs: the job state is kept in the static columns of a scylla
+// partition holding the selected series, which are removed in the same batch storing the job progress, so an
+// interrupted job is resumed by its node after a restart (or taken over by another node when it is not updated
+// for too long). The finished and cancelled jobs expire after the configured retention. A job is only resumed
+// after all its series are stored, the partition of an interrupted creation is removed.
+//
+
+const (
+	cqlCreateDeleteJobTable = `CREATE TABLE IF NOT EXISTS %s.ts_delete_job (
+		id text, tsid text, metric text, ttl int, keyset text static, type text static, kind text static,
+		start_date bigint static, end_date bigint static, status text static, total int static, deleted int static,
+		failed int static, failures map<text, text> static, node text static, creation_date timestamp static,
+		update_date timestamp static, PRIMARY KEY (id, tsid))`
+
+	cqlInsertDeleteJob = `INSERT INTO %s.ts_delete_job (id, keyset, type, kind, start_date, end_date, status, total, deleted, failed, node, creation_date, update_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, 0, ?, ?, ?)`
+
+	// the closed jobs rewrite all static columns to expire the whole partition
+	cqlCloseDeleteJob = `UPDATE %s.ts_delete_job USING TTL ? SET keyset = ?, type = ?, kind = ?, start_date = ?, end_date = ?, total = ?,
+		creation_date = ?, node = ?, status = ?, deleted = ?, failed = ?, failures = ?, update_date = ? WHERE id = ?`
+
+	cqlInsertDeleteJobKey   = `INSERT INTO %s.ts_delete_job (id, tsid, metric, ttl) VALUES (?, ?, ?, ?)`
+	cqlSelectDeleteJob      = `SELECT keyset, type, kind, start_date, end_date, status, total, deleted, failed, failures, node, creation_date, update_date FROM %s.ts_delete_job WHERE id = ? LIMIT 1`
+	cqlSelectDeleteJobs     = `SELECT DISTINCT id, status, node, update_date FROM %s.ts_delete_job`
+	cqlSelectDeleteJobKeys  = `SELECT tsid, metric, ttl FROM %s.ts_delete_job WHERE id = ?`
+	cqlDeleteDeleteJobKey   = `DELETE FROM %s.ts_delete_job WHERE id = ? AND tsid = ?`
+	cqlDeleteDeleteJobKeys  = `DELETE FROM %s.ts_delete_job WHERE id = ? AND tsid >= ''`
+	cqlDeleteDeleteJob      = `DELETE FROM %s.ts_delete_job WHERE id = ?`
+	cqlStartDeleteJob       = `UPDATE %s.ts_delete_job SET status = ?, update_date = ? WHERE id = ?`
+	cqlUpdateDeleteJob      = `UPDATE %s.ts_delete_job SET deleted = ?, failed = ?, failures = ?, update_date = ? WHERE id = ? IF node = ? AND status = ?`
+	cqlFinishDeleteJob      = cqlCloseDeleteJob + ` IF node = ? AND status = ?`
+	cqlCancelDeleteJob      = cqlCloseDeleteJob + ` IF status = ?`
+	cqlClaimDeleteJob       = `UPDATE %s.ts_delete_job SET node = ?, update_date = ? WHERE id = ? IF node = ? AND status = ?`
+	cqlSelectDeleteJobState = `SELECT status, node FROM %s.ts_delete_job WHERE id = ? LIMIT 1`
+
+	// DeleteJobSeries - the job deletes the series, their metadata and all their points
+	DeleteJobSeries string = "series"
+
+	// DeleteJobPoints - the job deletes the points of a time range keeping the series
+	DeleteJobPoints string = "points"
+
+	deleteJobStatusCreating  string = "creating"
+	deleteJobStatusRunning   string = "running"
+	deleteJobStatusFinished  string = "finished"
+	deleteJobStatusCancelled string = "cancelled"
+
+	cFuncCreateDeleteJob          string        = "createDeleteJob"
+	cFuncRunDeleteJob             string        = "runDeleteJob"
+	cFuncResumeDeleteJobs         string        = "resumeDeleteJobs"
+	cFuncGetDeleteJob             string        = "GetDeleteJob"
+	cFuncCancelDeleteJob          string        = "CancelDeleteJob"
+	cMaxDeleteJobFailures         int           = 100
+	cDeleteJobDefaultPageSize     int           = 1000
+	cDeleteJobDefaultProgress     int           = 100
+	cDeleteJobDefaultCheck        time.Duration = time.Minute
+	cDeleteJobDefaultStaleTimeout time.Duration = 10 * time.Minute
+	cDeleteJobDefaultRetention    time.Duration = 7 * 24 * time.Hour
+	cDeleteJobKeysBatchSize       int           = 100
+)
+
+// DeleteJob - the progress of a deletion job (the failures are sampled by tsid)
+type DeleteJob struct {
+	ID        string            `json:"id"`
+	Keyset    string            `json:"keyset"`
+	Type      string            `json:"type"`
+	Kind      string            `json:"kind"`
+	Start     int64             `json:"start,omitempty"`
+	End       int64             `json:"end,omitempty"`
+	Status    string            `json:"status"`
+	Total     int               `json:"total"`
+	Deleted   int               `json:"deleted"`
+	Failed    int               `json:"failed"`
+	Remaining int               `json:"remaining"`
+	Failures  map[string]string `json:"failures,omitempty"`
+	Node      string            `json:"node"`
+	Created   time.Time         `json:"creationDate"`
+	Updated   time.Time         `json:"updateDate"`
+	cancelled bool
+}
+
+// deleteJobKey - a series to be processed by a job
+type deleteJobKey struct {
+	tsid   string
+	metric string
+	ttl    int
+}
+
+// deleteJobs - the deletion jobs running on this node
+type deleteJobs struct {
+	keyspace string
+	node     string
+	conf     *structs.DeleteJobsConfiguration
+	mutex    sync.Mutex
+	running  map[string]*DeleteJob
+}
+
+// newDeleteJobs - creates the job table and starts resuming the unfinished jobs
+func (plot *Plot) newDeleteJobs(keyspace string, conf *structs.DeleteJobsConfiguration) error {
+
+	if conf.PageSize <= 0 {
+		conf.PageSize = cDeleteJobDefaultPageSize
+	}
+
+	if conf.ProgressInterval <= 0 {
+		conf.ProgressInterval = cDeleteJobDefaultProgress
+	}
+
+	if conf.CheckInterval.Duration <= 0 {
+		conf.CheckInterval.Duration = cDeleteJobDefaultCheck
+	}
+
+	if conf.StaleTimeout.Duration <= 0 {
+		conf.StaleTimeout.Duration = cDeleteJobDefaultStaleTimeout
+	}
+
+	if conf.Retention.Duration <= 0 {
+		conf.Retention.Duration = cDeleteJobDefaultRetention
+	}
+
+	node, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	if err := plot.persist.cassandra.Query(fmt.Sprintf(cqlCreateDeleteJobTable, keyspace)).Exec(); err != nil {
+		return err
+	}
+
+	plot.deleteJobs = &deleteJobs{
+		keyspace: keyspace,
+		node:     node,
+		conf:     conf,
+		running:  map[string]*DeleteJob{},
+	}
+
+	go func() {
+		for {
+			plot.resumeDeleteJobs()
+			<-time.After(conf.CheckInterval.Duration)
+		}
+	}()
+
+	return nil
+}
+
+// cql - formats the query with the jobs keyspace
+func (dj *deleteJobs) cql(query string) string {
+	return fmt.Sprintf(query, dj.keyspace)
+}
+
+// deletionKeys - returns the job keys of the selected series (the series without the ttl tag use the informed or the default one)
+func (plot *Plot) deletionKeys(keys []TsMetaInfo, defaultTTL int) ([]deleteJobKey, gobol.Error) {
+
+	if defaultTTL == 0 {
+		defaultTTL = plot.defaultTTL
+	}
+
+	jobKeys := make([]deleteJobKey, len(keys))
+
+	for i, key := range keys {
+
+		ttl := defaultTTL
+		if ttlStr, ok := key.Tags[constants.StringsTTL]; ok {
+			var gerr gobol.Error
+			if ttl, gerr = plot.parseDeletionTTL(ttlStr); gerr != nil {
+				return nil, gerr
+			}
+		}
+
+		jobKeys[i] = deleteJobKey{tsid: key.TsId, metric: key.Metric, ttl: ttl}
+	}
+
+	return jobKeys, nil
+}
+
+// createDeleteJob - stores the job and its series and starts running it
+func (plot *Plot) createDeleteJob(keyset, tsType, kind string, start, end int64, keys []deleteJobKey) (*DeleteJob, gobol.Error) {
+
+	dj := plot.deleteJobs
+	now := time.Now()
+
+	job := &DeleteJob{
+		ID:        gocql.TimeUUID().String(),
+		Keyset:    keyset,
+		Type:      tsType,
+		Kind:      kind,
+		Start:     start,
+		End:       end,
+		Status:    deleteJobStatusRunning,
+		Total:     len(keys),
+		Remaining: len(keys),
+		Node:      dj.node,
+		Created:   now,
+		Updated:   now,
+	}
+
+	// the job is only resumed when all its series are stored
+	if err := plot.persist.cassandra.Query(
+		dj.cql(cqlInsertDeleteJob),
+		job.ID, keyset, tsType, kind, start, end, deleteJobStatusCreating, job.Total, job.Node, now, now,
+	).Exec(); err != nil {
+		return nil, errPersist(cFuncCreateDeleteJob, err)
+	}
+
+	if err := plot.storeDeleteJobKeys(job.ID, keys); err != nil {
+		plot.dropDeleteJob(job.ID)
+		return nil, errPersist(cFuncCreateDeleteJob, err)
+	}
+
+	if err := plot.persist.cassandra.Query(dj.cql(cqlStartDeleteJob), job.Status, now, job.ID).Exec(); err != nil {
+		plot.dropDeleteJob(job.ID)
+		return nil, errPersist(cFuncCreateDeleteJob, err)
+	}
+
+	if logh.InfoEnabled {
+		plot.logger.Info().Str(constants.StringsFunc, cFuncCreateDeleteJob).Msgf("%s deletion job %s created for %d series of keyset %s", kind, job.ID, job.Total, keyset)
+	}
+
+	plot.startDeleteJob(job)
+
+	out := *job
+
+	return &out, nil
+}
+
+// storeDeleteJobKeys - writes the series of the job in unlogged batches (all in the job partition)
+func (plot *Plot) storeDeleteJobKeys(id string, keys []deleteJobKey) error {
+
+	session := plot.persist.cassandra
+
+	for from := 0; from < len(keys); from += cDeleteJobKeysBatchSize {
+
+		to := from + cDeleteJobKeysBatchSize
+		if to > len(keys) {
+			to = len(keys)
+		}
+
+		batch := session.NewBatch(gocql.UnloggedBatch)
+
+		for _, key := range keys[from:to] {
+			batch.Query(plot.deleteJobs.cql(cqlInsertDeleteJobKey), id, key.tsid, key.metric, key.ttl)
+		}
+
+		if err := session.ExecuteBatch(batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropDeleteJob - removes the partition of a job not created (the resume removes it later if this fails)
+func (plot *Plot) dropDeleteJob(id string) {
+
+	if err := plot.persist.cassandra.Query(plot.deleteJobs.cql(cqlDeleteDeleteJob), id).Exec(); err != nil && logh.ErrorEnabled {
+		plot.logger.Error().Str(constants.StringsFunc, cFuncCreateDeleteJob).Str("job", id).Err(err).Msg("error removing the deletion job not created")
+	}
+}
+
+// startDeleteJob - runs the job if it is not running on this node yet
+func (plot *Plot) startDeleteJob(job *DeleteJob) bool {
+
+	dj := plot.deleteJobs
+
+	dj.mutex.Lock()
+	defer dj.mutex.Unlock()
+
+	if _, ok := dj.running[job.ID]; ok {
+		return false
+	}
+
+	dj.running[job.ID] = job
+
+	go plot.runDeleteJob(job)
+
+	return true
+}
+
+// updateDeleteJob - changes the job under the lock
+func (plot *Plot) updateDeleteJob(job *DeleteJob, f func(job *DeleteJob)) {
+
+	plot.deleteJobs.mutex.Lock()
+	f(job)
+	plot.deleteJobs.mutex.Unlock()
+}
+
+// runDeleteJob - processes the remaining series of the job, the failed ones are recorded and skipped
+func (plot *Plot) runDeleteJob(job *DeleteJob) {
+
+	dj := plot.deleteJobs
+
+	defer func() {
+		dj.mutex.Lock()
+		delete(dj.running, job.ID)
+		dj.mutex.Unlock()
+	}()
+
+	if logh.InfoEnabled {
+		plot.logger.Info().Str(constants.StringsFunc, cFuncRunDeleteJob).Msgf("%s deletion job %s running (%d deleted, %d failed of %d)", job.Kind, job.ID, job.Deleted, job.Failed, job.Total)
+	}
+
+	table := cTableNumberStamp
+	if job.Type == "metatext" {
+		table = cTableTextStamp
+	}
+
+	invalidated := map[string]bool{}
+
+	// the processed series not stored yet, removed with the next progress
+	processed := make([]string, 0, dj.conf.ProgressInterval)
+
+	iter := plot.persist.cassandra.Query(dj.cql(cqlSelectDeleteJobKeys), job.ID).PageSize(dj.conf.PageSize).Iter()
+
+	var key deleteJobKey
+	for iter.Scan(&key.tsid, &key.metric, &key.ttl) {
+
+		// the job partition without series returns only its static columns
+		if key.tsid == constants.StringsEmpty {
+			continue
+		}
+
+		dj.mutex.Lock()
+		cancelled := job.cancelled
+		dj.mutex.Unlock()
+
+		// the cancellation stores the job and removes its series
+		if cancelled {
+			iter.Close()
+			return
+		}
+
+		var gerr gobol.Error
+		if job.Kind == DeleteJobPoints {
+			gerr = plot.DeletePointRange(key.tsid, key.ttl, job.Keyset, key.metric, table, job.Start, job.End)
+		} else {
+			gerr = plot.persist.metaStorage.DeleteDocumentByID(job.Keyset, job.Type, key.tsid)
+			if gerr == nil {
+				gerr = plot.DeletePoint(key.tsid, strconv.Itoa(key.ttl), job.Keyset, key.metric)
+			}
+		}
+
+		plot.updateDeleteJob(job, func(job *DeleteJob) {
+			job.Remaining--
+			if gerr == nil {
+				job.Deleted++
+				return
+			}
+			job.Failed++
+			if len(job.Failures) < cMaxDeleteJobFailures {
+				if job.Failures == nil {
+					job.Failures = map[string]string{}
+				}
+				job.Failures[key.tsid] = gerr.Error()
+			}
+		})
+
+		if gerr != nil && logh.ErrorEnabled {
+			plot.logger.Error().Str(constants.StringsFunc, cFuncRunDeleteJob).Str("job", job.ID).Str("tsid", key.tsid).Err(gerr).Msg("error deleting the series")
+		}
+
+		// the metric is unknown when the series are selected by their ids, invalidating all cached results of the keyset
+		if !invalidated[key.metric] {
+			plot.invalidateQueryCache(job.Keyset, key.metric)
+			invalidated[key.metric] = true
+		}
+
+		processed = append(processed, key.tsid)
+		if len(processed) < dj.conf.ProgressInterval {
+			continue
+		}
+
+		if !plot.saveDeleteJob(job, processed, deleteJobStatusRunning) {
+			iter.Close()
+			return
+		}
+
+		processed = processed[:0]
+	}
+
+	// the job keeps running and is resumed by the next check
+	if err := iter.Close(); err != nil {
+		plot.saveDeleteJob(job, processed, deleteJobStatusRunning)
+		if logh.ErrorEnabled {
+			plot.logger.Error().Str(constants.StringsFunc, cFuncRunDeleteJob).Str("job", job.ID).Err(err).Msg("error reading the job series, the job will be resumed")
+		}
+		return
+	}
+
+	if plot.saveDeleteJob(job, processed, deleteJobStatusFinished) && logh.InfoEnabled {
+		final := plot.copyDeleteJob(job)
+		plot.logger.Info().Str(constants.StringsFunc, cFuncRunDeleteJob).Msgf("%s deletion job %s finished: %d deleted, %d failed of %d", final.Kind, final.ID, final.Deleted, final.Failed, final.Total)
+	}
+}
+
+// copyDeleteJob - returns a copy of the job counters under the lock
+func (plot *Plot) copyDeleteJob(job *DeleteJob) DeleteJob {
+
+	plot.deleteJobs.mutex.Lock()
+	defer plot.deleteJobs.mutex.Unlock()
+
+	out := *job
+	out.Failures = make(map[string]string, len(job.Failures))
+	for k, v := range job.Failures {
+		out.Failures[k] = v
+	}
+
+	return out
+}
+
+// saveDeleteJob - stores the job counters (and the final status when it is finished) removing the processed
+// series in the same batch, returns false if the job must stop (not running anymore or taken over by another node)
+func (plot *Plot) saveDeleteJob(job *DeleteJob, processed []string, status string) bool {
+
+	dj := plot.deleteJobs
+
+	if status != deleteJobStatusRunning {
+		plot.updateDeleteJob(job, func(job *DeleteJob) { job.Status = status })
+	}
+
+	current := plot.copyDeleteJob(job)
+	now := time.Now()
+
+	batch := plot.persist.cassandra.NewBatch(gocql.LoggedBatch)
+
+	if status == deleteJobStatusRunning {
+		batch.Query(
+			dj.cql(cqlUpdateDeleteJob),
+			current.Deleted, current.Failed, current.Failures, now, job.ID, dj.node, deleteJobStatusRunning,
+		)
+	} else {
+		batch.Query(
+			dj.cql(cqlFinishDeleteJob),
+			append(plot.closeDeleteJobValues(&current, now), dj.node, deleteJobStatusRunning)...,
+		)
+	}
+
+	for _, tsid := range processed {
+		batch.Query(dj.cql(cqlDeleteDeleteJobKey), job.ID, tsid)
+	}
+
+	applied, iter, err := plot.persist.cassandra.MapExecuteBatchCAS(batch, map[string]interface{}{})
+	if iter != nil {
+		iter.Close()
+	}
+
+	// the job is resumed from the last stored progress
+	if err != nil {
+		if logh.ErrorEnabled {
+			plot.logger.Error().Str(constants.StringsFunc, cFuncRunDeleteJob).Str("job", job.ID).Err(err).Msg("error storing the job progress")
+		}
+		return status == deleteJobStatusRunning
+	}
+
+	if applied {
+		return true
+	}
+
+	var storedStatus, node string
+	if err := plot.persist.cassandra.Query(dj.cql(cqlSelectDeleteJobState), job.ID).Scan(&storedStatus, &node); err != nil {
+		if logh.ErrorEnabled {
+			plot.logger.Error().Str(constants.StringsFunc, cFuncRunDeleteJob).Str("job", job.ID).Err(err).Msg("error reading the job status")
+		}
+		return false
+	}
+
+	if logh.WarnEnabled {
+		if node != dj.node {
+			plot.logger.Warn().Str(constants.StringsFunc, cFuncRunDeleteJob).Msgf("deletion job %s was taken over by another node", job.ID)
+		} else {
+			plot.logger.Warn().Str(constants.StringsFunc, cFuncRunDeleteJob).Msgf("deletion job %s is %s, stopping it", job.ID, storedStatus)
+		}
+	}
+
+	return false
+}
+
+// closeDeleteJobValues - the values of the query closing the job, the partition expires after the retention
+func (plot *Plot) closeDeleteJobValues(job *DeleteJob, now time.Time) []interface{} {
+
+	return []interface{}{
+		int(plot.deleteJobs.conf.Retention.Duration.Seconds()), job.Keyset, job.Type, job.Kind, job.Start, job.End, job.Total,
+		job.Created, job.Node, job.Status, job.Deleted, job.Failed, job.Failures, now, job.ID,
+	}
+}
+
+// cas - runs the lightweight transaction, returning if it was applied
+func (plot *Plot) cas(query string, values ...interface{}) (bool, error) {
+	return plot.persist.cassandra.Query(plot.deleteJobs.cql(query), values...).MapScanCAS(map[string]interface{}{})
+}
+
+// loadDeleteJob - reads the stored job
+func (plot *Plot) loadDeleteJob(id string) (*DeleteJob, bool, error) {
+
+	job := &DeleteJob{ID: id}
+
+	err := plot.persist.cassandra.Query(plot.deleteJobs.cql(cqlSelectDeleteJob), id).Scan(
+		&job.Keyset, &job.Type, &job.Kind, &job.Start, &job.End, &job.Status, &job.Total, &job.Deleted,
+		&job.Failed, &job.Failures, &job.Node, &job.Created, &job.Updated,
+	)
+	if err == gocql.ErrNotFound {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	job.Remaining = job.Total - job.Deleted - job.Failed
+
+	return job, true, nil
+}
+
+// resumeDeleteJobs - resumes the running jobs of this node and takes over the stale jobs of the other nodes
+func (plot *Plot) resumeDeleteJobs() {
+
+	dj := plot.deleteJobs
+
+	iter := plot.persist.cassandra.Query(dj.cql(cqlSelectDeleteJobs)).Iter()
+
+	var id, status, node string
+	var updated time.Time
+	var ids []string
+
+	var dropped []string
+
+	for iter.Scan(&id, &status, &node, &updated) {
+
+		// the creation was interrupted (the job was never returned)
+		if status == deleteJobStatusCreating && time.Since(updated) >= dj.conf.StaleTimeout.Duration {
+			dropped = append(dropped, id)
+			continue
+		}
+
+		if status != deleteJobStatusRunning {
+			continue
+		}
+
+		dj.mutex.Lock()
+		_, running := dj.running[id]
+		dj.mutex.Unlock()
+
+		if running || (node != dj.node && time.Since(updated) < dj.conf.StaleTimeout.Duration) {
+			continue
+		}
+
+		if node != dj.node {
+			applied, err := plot.cas(cqlClaimDeleteJob, dj.node, time.Now(), id, node, deleteJobStatusRunning)
+			if err != nil || !applied {
+				continue
+			}
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := iter.Close(); err != nil {
+		if logh.ErrorEnabled {
+			plot.logger.Error().Str(constants.StringsFunc, cFuncResumeDeleteJobs).Err(err).Msg("error reading the deletion jobs")
+		}
+		return
+	}
+
+	for _, id := range dropped {
+		plot.dropDeleteJob(id)
+	}
+
+	for _, id := range ids {
+
+		job, found, err := plot.loadDeleteJob(id)
+		if err != nil || !found {
+			if err != nil && logh.ErrorEnabled {
+				plot.logger.Error().Str(constants.StringsFunc, cFuncResumeDeleteJobs).Str("job", id).Err(err).Msg("error loading the deletion job")
+			}
+			continue
+		}
+
+		if plot.startDeleteJob(job) && logh.InfoEnabled {
+			plot.logger.Info().Str(constants.StringsFunc, cFuncResumeDeleteJobs).Msgf("resuming %s deletion job %s", job.Kind, job.ID)
+		}
+	}
+}
+
+// GetDeleteJob - returns the job progress (the live counters if it runs on this node)
+func (plot *Plot) GetDeleteJob(id string) (*DeleteJob, gobol.Error) {
+
+	dj := plot.deleteJobs
+
+	dj.mutex.Lock()
+	job, running := dj.running[id]
+	dj.mutex.Unlock()
+
+	if running {
+		out := plot.copyDeleteJob(job)
+		return &out, nil
+	}
+
+	stored, found, err := plot.loadDeleteJob(id)
+	if err != nil {
+		return nil, errPersist(cFuncGetDeleteJob, err)
+	}
+
+	if !found {
+		return nil, errNotFound(cFuncGetDeleteJob)
+	}
+
+	return stored, nil
+}
+
+// CancelDeleteJob - stops a running job, the series already deleted are not restored
+func (plot *Plot) CancelDeleteJob(id string) (*DeleteJob, gobol.Error) {
+
+	dj := plot.deleteJobs
+
+	job, gerr := plot.GetDeleteJob(id)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	if job.Status != deleteJobStatusRunning {
+		return nil, errJobNotRunning(cFuncCancelDeleteJob)
+	}
+
+	// stops the job if it runs on this node, keeping its last counters
+	dj.mutex.Lock()
+	running, ok := dj.running[id]
+	if ok {
+		running.cancelled = true
+	}
+	dj.mutex.Unlock()
+
+	if ok {
+		*job = plot.copyDeleteJob(running)
+	}
+
+	job.Status = deleteJobStatusCancelled
+	job.Remaining = job.Total - job.Deleted - job.Failed
+
+	applied, err := plot.cas(cqlCancelDeleteJob, append(plot.closeDeleteJobValues(job, time.Now()), deleteJobStatusRunning)...)
+	if err != nil {
+		return nil, errPersist(cFuncCancelDeleteJob, err)
+	}
+
+	if !applied {
+		return nil, errJobNotRunning(cFuncCancelDeleteJob)
+	}
+
+	if err := plot.persist.cassandra.Query(dj.cql(cqlDeleteDeleteJobKeys), id).Exec(); err != nil && logh.ErrorEnabled {
+		plot.logger.Error().Str(constants.StringsFunc, cFuncCancelDeleteJob).Str("job", id).Err(err).Msg("error removing the job series")
+	}
+
+	if logh.InfoEnabled {
+		plot.logger.Info().Str(constants.StringsFunc, cFuncCancelDeleteJob).Msgf("%s deletion job %s cancelled", job.Kind, job.ID)
+	}
+
+	return job, nil
+}
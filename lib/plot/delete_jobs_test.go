@@ -0,0 +1,70 @@
+package plot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/funks"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func TestDeletionKeys(t *testing.T) {
+
+	plot := &Plot{defaultTTL: 1}
+
+	keys := []TsMetaInfo{
+		{TsId: "a", Metric: "cpu", Tags: map[string]string{"ttl": "7", "host": "a"}},
+		{TsId: "b", Metric: "mem"},
+	}
+
+	jobKeys, gerr := plot.deletionKeys(keys, 0)
+	assert.NoError(t, gerr)
+	assert.Equal(t, []deleteJobKey{{tsid: "a", metric: "cpu", ttl: 7}, {tsid: "b", metric: "mem", ttl: 1}}, jobKeys)
+
+	jobKeys, gerr = plot.deletionKeys(keys, 30)
+	assert.NoError(t, gerr)
+	assert.Equal(t, 30, jobKeys[1].ttl, "the informed ttl of the series without the tag")
+	assert.Equal(t, 7, jobKeys[0].ttl)
+
+	_, gerr = plot.deletionKeys([]TsMetaInfo{{TsId: "a", Tags: map[string]string{"ttl": "x"}}}, 0)
+	assert.Error(t, gerr)
+}
+
+func TestGetRunningDeleteJob(t *testing.T) {
+
+	running := &DeleteJob{ID: "job", Status: deleteJobStatusRunning, Total: 3, Remaining: 1, Failures: map[string]string{"a": "timeout"}}
+
+	plot := &Plot{deleteJobs: &deleteJobs{running: map[string]*DeleteJob{"job": running}}}
+
+	job, gerr := plot.GetDeleteJob("job")
+	assert.NoError(t, gerr)
+	assert.Equal(t, 1, job.Remaining, "the live counters")
+
+	job.Failures["b"] = "timeout"
+	assert.Len(t, running.Failures, 1, "a copy is returned")
+
+	plot.updateDeleteJob(running, func(job *DeleteJob) { job.Remaining-- })
+	assert.Zero(t, plot.copyDeleteJob(running).Remaining)
+
+	assert.False(t, plot.startDeleteJob(running), "already running on this node")
+}
+
+func TestCloseDeleteJobValues(t *testing.T) {
+
+	conf := &structs.DeleteJobsConfiguration{Retention: funks.Duration{Duration: 2 * time.Hour}}
+	plot := &Plot{deleteJobs: &deleteJobs{conf: conf}}
+
+	now := time.Now()
+	job := &DeleteJob{ID: "job", Keyset: "tenant", Status: deleteJobStatusFinished, Deleted: 3}
+
+	values := plot.closeDeleteJobValues(job, now)
+	if assert.Len(t, values, 15) {
+		assert.Equal(t, 7200, values[0], "the partition expires after the retention")
+		assert.Equal(t, "tenant", values[1])
+		assert.Equal(t, deleteJobStatusFinished, values[9])
+		assert.Equal(t, now, values[13])
+		assert.Equal(t, "job", values[14], "the job id is the partition key")
+	}
+}
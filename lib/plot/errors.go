@@ -46,6 +46,10 @@ func errNotFound(f string) gobol.Error {
 	return errBasic(f, "status not found", http.StatusNotFound, errors.New("status not found"))
 }
 
+func errNotFoundS(f, s string) gobol.Error {
+	return errBasic(f, s, http.StatusNotFound, errors.New(s))
+}
+
 func errKeysetNotFound(f string) gobol.Error {
 	return errBasic(f, "keyset not found", http.StatusNotFound, errors.New("keyset not found"))
 }
@@ -62,6 +66,10 @@ func errParamSize(f string, e error) gobol.Error {
 	return errBasic(f, `query param "size" should be an integer number greater than zero`, http.StatusBadRequest, e)
 }
 
+func errParamTime(f, param string, e error) gobol.Error {
+	return errBasic(f, fmt.Sprintf(`query param "%s" should be a timestamp or a relative time like "1h" or "7d"`, param), http.StatusBadRequest, e)
+}
+
 func errParamFrom(f string, e error) gobol.Error {
 	return errBasic(f, `query param "from" should be an integer number greater or equals zero`, http.StatusBadRequest, e)
 }
@@ -97,3 +105,7 @@ func errUnmarshal(function string, err error) gobol.Error {
 func errInternalServer(function string, err error) gobol.Error {
 	return errBasic(function, "internal server error", http.StatusInternalServerError, err)
 }
+
+func errJobNotRunning(function string) gobol.Error {
+	return errBasic(function, "the job is not running", http.StatusConflict, errors.New("the job is not running"))
+}
@@ -17,6 +17,8 @@ const (
 	funcGetLastTS             string = "GetLastTS"
 	queryGetLastTSNoTimestamp string = `SELECT id, date, value FROM %s.ts_number_stamp WHERE id = ? limit 1`              // given that clustering order MUST be date desc
 	queryGetLastTS            string = `SELECT id, date, value FROM %s.ts_number_stamp WHERE id = ? AND date < ? limit 1` // given that clustering order MUST be date desc
+	funcStreamTS              string = "StreamTS"
+	queryStreamTS             string = `SELECT date, value FROM %s.ts_number_stamp WHERE id = ? AND date > ? AND date < ? ORDER BY date ASC`
 )
 
 func (persist *persistence) GetTS(keyspace string, keys []string, start, end int64, ms, allowFullFetch bool, maxBytesLimit uint32, keyset string) (map[string][]Pnt, uint32, gobol.Error) {
@@ -190,3 +192,78 @@ mainLoop:
 
 	return tsMap, numBytes, nil
 }
+
+// StreamTS - pages through the points of each serie calling the function for each page (the page slice is reused)
+func (persist *persistence) StreamTS(keyspace string, keys []string, start, end int64, pageSize int, maxBytesLimit uint32, keyset string, fn func(tsid string, page []Pnt) error) (uint32, gobol.Error) {
+
+	start--
+	end++
+
+	var date int64
+	var value float64
+	var numBytes uint32
+	_, allowFullFetch := persist.unlimitedBytesKeysetWhiteList[keyset]
+	page := make([]Pnt, 0, pageSize)
+
+	for _, tsid := range keys {
+
+		track := time.Now()
+		countRows := 0
+		numBytes += uint32(persist.getStringSize(tsid))
+
+		var pageState []byte
+
+		for {
+			iter := persist.cassandra.Query(
+				fmt.Sprintf(queryStreamTS, keyspace),
+				tsid,
+				start,
+				end,
+			).PageSize(pageSize).PageState(pageState).Iter()
+
+			pageState = iter.PageState()
+			page = page[:0]
+
+			for iter.Scan(&date, &value) {
+				page = append(page, Pnt{
+					Date:  date,
+					Value: value,
+				})
+			}
+
+			if err := iter.Close(); err != nil && err != gocql.ErrNotFound {
+				if logh.ErrorEnabled {
+					logh.Error().Str(constants.StringsFunc, funcStreamTS).Err(err).Send()
+				}
+
+				persist.statsQueryError(funcStreamTS, keyset, keyspace, typeNumber)
+				return numBytes, errPersist(funcStreamTS, err)
+			}
+
+			countRows += len(page)
+			numBytes += uint32(len(page)) * uint32(persist.constPartBytesFromNumberPoint)
+
+			// the page exceeding the limit is not sent
+			if !allowFullFetch && numBytes >= maxBytesLimit {
+				persist.statsQueryBytes(funcStreamTS, keyset, keyspace, typeNumber, float64(numBytes))
+				return numBytes, errMaxBytesLimitWrapper(funcStreamTS, persist.maxBytesErr)
+			}
+
+			if len(page) > 0 {
+				if err := fn(tsid, page); err != nil {
+					return numBytes, errPersist(funcStreamTS, err)
+				}
+			}
+
+			if len(pageState) == 0 {
+				break
+			}
+		}
+
+		persist.statsSelect(funcStreamTS, keyset, keyspace, typeNumber, time.Since(track), countRows)
+	}
+
+	persist.statsQueryBytes(funcStreamTS, keyset, keyspace, typeNumber, float64(numBytes))
+
+	return numBytes, nil
+}
@@ -17,6 +17,8 @@ const (
 	funcGetLastTST             string = "GetLastTST"
 	queryGetLastTSTNoTimestamp string = `SELECT id, date, value FROM %s.ts_text_stamp WHERE id = ? limit 1`              // given that clustering order MUST be date desc
 	queryGetLastTST            string = `SELECT id, date, value FROM %s.ts_text_stamp WHERE id = ? AND date < ? limit 1` // given that clustering order MUST be date desc
+	funcStreamTST              string = "StreamTST"
+	queryStreamTST             string = `SELECT date, value FROM %s.ts_text_stamp WHERE id = ? AND date > ? AND date < ? ORDER BY date ASC`
 )
 
 func (persist *persistence) GetTST(keyspace string, keys []string, start, end int64, search *regexp.Regexp, allowFullFetch bool, maxBytesLimit uint32, keyset string) (map[string][]TextPnt, uint32, gobol.Error) {
@@ -198,3 +200,78 @@ mainLoop:
 
 	return tsMap, numBytes, nil
 }
+
+// StreamTST - pages through the texts of each serie calling the function for each page (the page slice is reused)
+func (persist *persistence) StreamTST(keyspace string, keys []string, start, end int64, pageSize int, maxBytesLimit uint32, keyset string, fn func(tsid string, page []TextPnt) error) (uint32, gobol.Error) {
+
+	start--
+	end++
+
+	var date int64
+	var value string
+	var numBytes uint32
+	_, allowFullFetch := persist.unlimitedBytesKeysetWhiteList[keyset]
+	page := make([]TextPnt, 0, pageSize)
+
+	for _, tsid := range keys {
+
+		track := time.Now()
+		countRows := 0
+		numBytes += uint32(persist.getStringSize(tsid))
+
+		var pageState []byte
+
+		for {
+			iter := persist.cassandra.Query(
+				fmt.Sprintf(queryStreamTST, keyspace),
+				tsid,
+				start,
+				end,
+			).PageSize(pageSize).PageState(pageState).Iter()
+
+			pageState = iter.PageState()
+			page = page[:0]
+
+			for iter.Scan(&date, &value) {
+				page = append(page, TextPnt{
+					Date:  date,
+					Value: value,
+				})
+				numBytes += uint32(persist.constPartBytesFromTextPoint + persist.getStringSize(value))
+			}
+
+			if err := iter.Close(); err != nil && err != gocql.ErrNotFound {
+				if logh.ErrorEnabled {
+					logh.Error().Str(constants.StringsFunc, funcStreamTST).Err(err).Send()
+				}
+
+				persist.statsQueryError(funcStreamTST, keyset, keyspace, typeText)
+				return numBytes, errPersist(funcStreamTST, err)
+			}
+
+			countRows += len(page)
+
+			// the page exceeding the limit is not sent
+			if !allowFullFetch && numBytes >= maxBytesLimit {
+				persist.statsQueryBytes(funcStreamTST, keyset, keyspace, typeText, float64(numBytes))
+				return numBytes, errMaxBytesLimitWrapper(funcStreamTST, persist.maxBytesErr)
+			}
+
+			if len(page) > 0 {
+				if err := fn(tsid, page); err != nil {
+					return numBytes, errPersist(funcStreamTST, err)
+				}
+			}
+
+			if len(pageState) == 0 {
+				break
+			}
+		}
+
+		persist.statsSelect(funcStreamTST, keyset, keyspace, typeText, time.Since(track), countRows)
+	}
+
+	persist.statsQueryBytes(funcStreamTST, keyset, keyspace, typeText, float64(numBytes))
+
+	return numBytes, nil
+}
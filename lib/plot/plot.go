@@ -2,6 +2,7 @@ package plot
 
 import (
 	"errors"
+	"fmt"
 	"unsafe"
 
 	"github.com/uol/logh"
@@ -9,8 +10,10 @@ import (
 	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
 	tlmanager "github.com/uol/timelinemanager"
 )
 
@@ -39,6 +42,10 @@ func New(
 	unlimitedBytesKeysetWhiteList []string,
 	timelineManager *tlmanager.Instance,
 	clusteringOrder constants.ClusteringOrder,
+	queryCacheConf *structs.QueryCacheConfiguration,
+	authService *auth.Service,
+	jobsKeyspace string,
+	deleteJobsConf *structs.DeleteJobsConfiguration,
 ) (*Plot, gobol.Error) {
 
 	if maxTimeseries < 1 {
@@ -56,7 +63,17 @@ func New(
 		unlimitedBytesKeysetWhiteMap[keyset] = true
 	}
 
-	return &Plot{
+	var qc *queryCache
+	if queryCacheConf != nil && queryCacheConf.Enabled {
+		// the other nodes are not invalidated by a deletion, their entries are only dropped when expired
+		if queryCacheConf.TTL.Duration > cQueryCacheMaxTTL {
+			return nil, errInit(fmt.Sprintf("QueryCache.TTL needs to be at most %s", cQueryCacheMaxTTL))
+		}
+
+		qc = newQueryCache(queryCacheConf)
+	}
+
+	plot := &Plot{
 		MaxTimeseries:       maxTimeseries,
 		LogQueryTSThreshold: logQueryTSthreshold,
 		persist: &persistence{
@@ -77,7 +94,15 @@ func New(
 		maxBytesLimit:     maxBytesLimit,
 		logger:            logh.CreateContextualLogger(constants.StringsPKG, "plot"),
 		timelineManager:   timelineManager,
-	}, nil
+		queryCache:        qc,
+		auth:              authService,
+	}
+
+	if err := plot.newDeleteJobs(jobsKeyspace, deleteJobsConf); err != nil {
+		return nil, errInit(fmt.Sprintf("error creating the deletion jobs: %s", err.Error()))
+	}
+
+	return plot, nil
 }
 
 type Plot struct {
@@ -90,6 +115,9 @@ type Plot struct {
 	maxBytesLimit       uint32
 	timelineManager     *tlmanager.Instance
 	logger              *logh.ContextualLogger
+	queryCache          *queryCache
+	auth                *auth.Service
+	deleteJobs          *deleteJobs
 }
 
 // getStringSize - calculates the string size
@@ -1,6 +1,8 @@
 package plot
 
 import (
+	"time"
+
 	"github.com/uol/logh"
 
 	"github.com/uol/gobol"
@@ -59,7 +61,7 @@ func (plot *Plot) checkTotalTSLimits(message, keyset, metric string, total int)
 	return nil
 }
 
-func (plot *Plot) FilterMetrics(keyset, metricName string, size int) ([]string, int, gobol.Error) {
+func (plot *Plot) FilterMetrics(keyset, metricName string, activeSince int64, size int) ([]string, int, gobol.Error) {
 
 	err := plot.validateKeyset(keyset)
 	if err != nil {
@@ -70,10 +72,10 @@ func (plot *Plot) FilterMetrics(keyset, metricName string, size int) ([]string,
 		size = plot.defaultMaxResults
 	}
 
-	return plot.persist.metaStorage.FilterMetrics(keyset, metricName, size)
+	return plot.persist.metaStorage.FilterMetrics(keyset, metricName, activeSince, size)
 }
 
-func (plot *Plot) FilterTagKeys(keyset, tagKname string, size int) ([]string, int, gobol.Error) {
+func (plot *Plot) FilterTagKeys(keyset, tagKname string, activeSince int64, size int) ([]string, int, gobol.Error) {
 
 	err := plot.validateKeyset(keyset)
 	if err != nil {
@@ -84,10 +86,10 @@ func (plot *Plot) FilterTagKeys(keyset, tagKname string, size int) ([]string, in
 		size = plot.defaultMaxResults
 	}
 
-	return plot.persist.metaStorage.FilterTagKeys(keyset, tagKname, size)
+	return plot.persist.metaStorage.FilterTagKeys(keyset, tagKname, activeSince, size)
 }
 
-func (plot *Plot) FilterTagValues(keyset, tagVname string, size int) ([]string, int, gobol.Error) {
+func (plot *Plot) FilterTagValues(keyset, tagVname string, activeSince int64, size int) ([]string, int, gobol.Error) {
 
 	err := plot.validateKeyset(keyset)
 	if err != nil {
@@ -98,7 +100,7 @@ func (plot *Plot) FilterTagValues(keyset, tagVname string, size int) ([]string,
 		size = plot.defaultMaxResults
 	}
 
-	return plot.persist.metaStorage.FilterTagValues(keyset, tagVname, size)
+	return plot.persist.metaStorage.FilterTagValues(keyset, tagVname, activeSince, size)
 }
 
 // toMetaParam - converts metric and tags to a Metadata struct to be used as query
@@ -207,3 +209,17 @@ func (plot *Plot) ListMeta(keyset, tsType, metric string, tags map[string]string
 
 	return tsMetaInfos, total, gerr
 }
+
+func (plot *Plot) FilterCardinality(keyset, metric, tagKey string, since time.Time, size int) (*metadata.Cardinality, gobol.Error) {
+
+	err := plot.validateKeyset(keyset)
+	if err != nil {
+		return nil, errNotFound("FilterCardinality")
+	}
+
+	if size <= 0 {
+		size = plot.defaultMaxResults
+	}
+
+	return plot.persist.metaStorage.FilterCardinality(keyset, metric, tagKey, since, size)
+}
@@ -36,6 +36,7 @@ func (plot *Plot) GetTimeSeries(
 
 	resultTSs := TS{}
 	numNonEmptyTS := 0
+	series := []Pnts{}
 
 	for _, ts := range tsMap {
 
@@ -43,6 +44,7 @@ func (plot *Plot) GetTimeSeries(
 			numNonEmptyTS++
 			resultTSs.Data = append(resultTSs.Data, ts.Data...)
 			resultTSs.Total += ts.Total
+			series = append(series, ts.Data)
 		}
 	}
 
@@ -56,6 +58,9 @@ func (plot *Plot) GetTimeSeries(
 		case "aggregation":
 			exec = true
 			if numNonEmptyTS > 1 {
+				if structs.IsInterpolatingFill(opers.Downsample.Options.Fill) {
+					resultTSs.Data = interpolateSeries(opers.Downsample.Options, series)
+				}
 				sort.Sort(resultTSs.Data)
 				resultTSs.Data = merge(opers.Merge, keepEmpties, resultTSs.Data)
 			}
@@ -60,11 +60,14 @@ func (plot Plot) GetGroups(filters []structs.TSDBfilter, tsobs []TSDBobj) (group
 	return groups
 }
 
-func (plot *Plot) MetaOpenTSDB(keyset, metric string, tags map[string][]string, size, from int) ([]TSDBobj, int, gobol.Error) {
+func (plot *Plot) MetaOpenTSDB(keyset, metric string, tags map[string][]string, activeSince int64, size, from int) ([]TSDBobj, int, gobol.Error) {
 
 	from, size = plot.checkParams(from, size)
 
-	metadatas, total, gerr := plot.persist.metaStorage.FilterMetadata(keyset, plot.toMetaParamArray(metric, "meta", tags), from, size)
+	query := plot.toMetaParamArray(metric, "meta", tags)
+	query.ActiveSince = activeSince
+
+	metadatas, total, gerr := plot.persist.metaStorage.FilterMetadata(keyset, query, from, size)
 
 	var tsds []TSDBobj
 
@@ -97,14 +100,15 @@ func (plot *Plot) splitTagFilters(value string) []string {
 }
 
 // MetaFilterOpenTSDB - creates a metadata query
-func (plot *Plot) MetaFilterOpenTSDB(keyset, metric string, filters []structs.TSDBfilter, size int) ([]TSDBobj, int, gobol.Error) {
+func (plot *Plot) MetaFilterOpenTSDB(keyset, metric string, filters []structs.TSDBfilter, activeSince int64, size int) ([]TSDBobj, int, gobol.Error) {
 
 	from, size := plot.checkParams(0, size)
 
 	query := &metadata.Query{
-		Metric:   metric,
-		MetaType: "meta",
-		Tags:     make([]metadata.QueryTag, len(filters)),
+		Metric:      metric,
+		MetaType:    "meta",
+		Tags:        make([]metadata.QueryTag, len(filters)),
+		ActiveSince: activeSince,
 	}
 
 	for i, filter := range filters {
@@ -132,6 +136,12 @@ func (plot *Plot) MetaFilterOpenTSDB(keyset, metric string, filters []structs.TS
 
 	}
 
+	return plot.metaFilterQuery(keyset, query, from, size)
+}
+
+// metaFilterQuery - runs the metadata query and converts the results
+func (plot *Plot) metaFilterQuery(keyset string, query *metadata.Query, from, size int) ([]TSDBobj, int, gobol.Error) {
+
 	metadatas, total, gerr := plot.persist.metaStorage.FilterMetadata(keyset, query, from, size)
 
 	var tsds []TSDBobj
@@ -0,0 +1,424 @@
+package plot
+
+import (
+	"container/list"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Caches the /api/query and expression results. Queries with a moving window (relative or without end)
+// only fetch the tail window not cached yet and splice it onto the cached head.
+//
+// The cache is kept in the memory of each node: a deletion only invalidates the entries of the node that
+// received it, the other nodes keep returning the deleted points until their entries expire, so the TTL
+// is bounded by cQueryCacheMaxTTL.
+//
+
+const (
+	cQueryCacheDefaultMaxEntries int           = 1000
+	cQueryCacheDefaultTTL        time.Duration = time.Minute
+	cQueryCacheMaxTTL            time.Duration = 5 * time.Minute
+	cQueryCacheMaxGridSteps      int           = 100000
+	funcCachedTimeseries         string        = "cachedTimeseries"
+)
+
+// queryCacheEntry - a cached query result (never changed after stored)
+type queryCacheEntry struct {
+	key     string
+	keyset  string
+	metrics []string
+	start   int64
+	end     int64
+	anchor  int64
+	created time.Time
+	resps   TSDBresponses
+}
+
+// queryCache - an in memory LRU cache of query results (local to the node)
+type queryCache struct {
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	maxEntries int
+	ttl        time.Duration
+}
+
+// newQueryCache - creates a new query cache
+func newQueryCache(conf *structs.QueryCacheConfiguration) *queryCache {
+
+	qc := &queryCache{
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		maxEntries: conf.MaxEntries,
+		ttl:        conf.TTL.Duration,
+	}
+
+	if qc.maxEntries <= 0 {
+		qc.maxEntries = cQueryCacheDefaultMaxEntries
+	}
+
+	if qc.ttl <= 0 {
+		qc.ttl = cQueryCacheDefaultTTL
+	}
+
+	return qc
+}
+
+// get - returns the entry if it exists and is not expired
+func (qc *queryCache) get(key string) (*queryCacheEntry, bool) {
+
+	qc.mutex.Lock()
+	defer qc.mutex.Unlock()
+
+	element, ok := qc.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*queryCacheEntry)
+
+	if time.Since(entry.created) > qc.ttl {
+		qc.lru.Remove(element)
+		delete(qc.entries, key)
+		return nil, false
+	}
+
+	qc.lru.MoveToFront(element)
+
+	return entry, true
+}
+
+// put - stores the entry, removing the least recently used ones if the cache is full
+func (qc *queryCache) put(entry *queryCacheEntry) {
+
+	qc.mutex.Lock()
+	defer qc.mutex.Unlock()
+
+	if element, ok := qc.entries[entry.key]; ok {
+		element.Value = entry
+		qc.lru.MoveToFront(element)
+		return
+	}
+
+	qc.entries[entry.key] = qc.lru.PushFront(entry)
+
+	for qc.lru.Len() > qc.maxEntries {
+		last := qc.lru.Back()
+		qc.lru.Remove(last)
+		delete(qc.entries, last.Value.(*queryCacheEntry).key)
+	}
+}
+
+// invalidate - removes all entries of the keyset containing the metric (all entries of the keyset if the metric is empty)
+func (qc *queryCache) invalidate(keyset, metric string) {
+
+	qc.mutex.Lock()
+	defer qc.mutex.Unlock()
+
+	for key, element := range qc.entries {
+
+		entry := element.Value.(*queryCacheEntry)
+		if entry.keyset != keyset {
+			continue
+		}
+
+		if metric == "" {
+			qc.lru.Remove(element)
+			delete(qc.entries, key)
+			continue
+		}
+
+		for _, m := range entry.metrics {
+			if m == metric {
+				qc.lru.Remove(element)
+				delete(qc.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// queryCacheKey - builds the cache key using the normalized payload (the moving window is not part of the key)
+func queryCacheKey(keyset string, query structs.TSDBqueryPayload, moving bool) (string, error) {
+
+	if moving {
+		query.End = 0
+		if query.Relative != "" {
+			query.Start = 0
+		}
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+
+	return keyset + "/" + string(data), nil
+}
+
+// invalidateQueryCache - removes the cached results of the metric (all results of the keyset if the metric is empty)
+func (plot *Plot) invalidateQueryCache(keyset, metric string) {
+
+	if plot.queryCache != nil {
+		plot.queryCache.invalidate(keyset, metric)
+	}
+}
+
+// cachedTimeseries - returns the query result from the cache, fetching only what is missing
+func (plot *Plot) cachedTimeseries(keyset string, query structs.TSDBqueryPayload, moving bool) (TSDBresponses, uint32, gobol.Error) {
+
+	key, err := queryCacheKey(keyset, query, moving)
+	if err != nil {
+		return plot.queryTimeseries(keyset, query)
+	}
+
+	entry, found := plot.queryCache.get(key)
+
+	if found && entry.start == query.Start && entry.end == query.End {
+		plot.statsQueryCache(funcCachedTimeseries, keyset, "hit")
+		return entry.resps, 0, nil
+	}
+
+	if found && moving {
+
+		tailStart, boundary, head, ok := spliceWindow(query, entry)
+
+		if ok {
+
+			tailQuery := query
+			tailQuery.Start = tailStart
+
+			tail, numBytes, gerr := plot.queryTimeseries(keyset, tailQuery)
+			if gerr != nil {
+				return nil, numBytes, gerr
+			}
+
+			resps := spliceResponses(entry.resps, tail, head, boundary, query.MsResolution)
+
+			plot.queryCache.put(&queryCacheEntry{
+				key:     key,
+				keyset:  keyset,
+				metrics: entry.metrics,
+				start:   query.Start,
+				end:     query.End,
+				anchor:  entry.anchor,
+				created: entry.created,
+				resps:   resps,
+			})
+
+			plot.statsQueryCache(funcCachedTimeseries, keyset, "splice")
+
+			return resps, numBytes, nil
+		}
+	}
+
+	resps, numBytes, gerr := plot.queryTimeseries(keyset, query)
+	if gerr != nil {
+		return resps, numBytes, gerr
+	}
+
+	metrics := make([]string, len(query.Queries))
+	for i, q := range query.Queries {
+		metrics[i] = q.Metric
+	}
+
+	var anchor int64
+	if ds, ok := payloadDownsample(query); ok && ds.Enabled {
+		anchor = alignStart(query.Start, ds.Options, downsampleLocation(ds.Options))
+	}
+
+	plot.queryCache.put(&queryCacheEntry{
+		key:     key,
+		keyset:  keyset,
+		metrics: metrics,
+		start:   query.Start,
+		end:     query.End,
+		anchor:  anchor,
+		created: time.Now(),
+		resps:   resps,
+	})
+
+	plot.statsQueryCache(funcCachedTimeseries, keyset, "miss")
+
+	return resps, numBytes, nil
+}
+
+// payloadDownsample - returns the downsample of the payload if all queries use the same one
+func payloadDownsample(query structs.TSDBqueryPayload) (structs.Downsample, bool) {
+
+	var ds structs.Downsample
+
+	for i, q := range query.Queries {
+
+		qds := parseTSDBdownsample(query, q)
+
+		if i == 0 {
+			ds = qds
+			continue
+		}
+
+		if qds != ds {
+			return ds, false
+		}
+	}
+
+	return ds, true
+}
+
+// spliceWindow - returns the tail window to be fetched, the date where the tail points replace the cached ones
+// and the first date kept from the cache (the downsample grid of the cached result must match the new one)
+func spliceWindow(query structs.TSDBqueryPayload, entry *queryCacheEntry) (tailStart, boundary, head int64, ok bool) {
+
+	if query.Start < entry.start || query.End <= entry.end || entry.end < query.Start {
+		return
+	}
+
+	ds, ok := payloadDownsample(query)
+	if !ok || structs.IsInterpolatingFill(ds.Options.Fill) {
+		return 0, 0, 0, false
+	}
+
+	rate := false
+	for _, q := range query.Queries {
+		rate = rate || q.Rate
+	}
+
+	if !ds.Enabled {
+		if rate {
+			return 0, 0, 0, false
+		}
+
+		return entry.end, entry.end + 1, query.Start, true
+	}
+
+	loc := downsampleLocation(ds.Options)
+
+	head = alignStart(query.Start, ds.Options, loc)
+	boundary = entry.anchor
+	previous := entry.anchor
+	headOnGrid := head == boundary
+
+	for i := 0; ; i++ {
+
+		next := getEndInterval(boundary, ds.Options, loc)
+
+		if next <= boundary || i == cQueryCacheMaxGridSteps {
+			return 0, 0, 0, false
+		}
+
+		if next > entry.end {
+			break
+		}
+
+		previous = boundary
+		boundary = next
+
+		if boundary == head {
+			headOnGrid = true
+		}
+	}
+
+	if !headOnGrid {
+		return 0, 0, 0, false
+	}
+
+	tailStart = boundary
+	if rate {
+		tailStart = previous
+	}
+
+	return tailStart, boundary, head, true
+}
+
+// responseKey - identifies a response by its metric and tags
+func responseKey(resp TSDBresponse) string {
+
+	tags := make([]string, 0, len(resp.Tags))
+	for k, v := range resp.Tags {
+		tags = append(tags, k+"="+v)
+	}
+
+	sort.Strings(tags)
+
+	return resp.Metric + "{" + strings.Join(tags, ",") + "}" + strings.Join(resp.AggregatedTags, ",")
+}
+
+// filterDps - copies the points between the dates (the end is not included, zero means no end)
+func filterDps(dst, dps map[string]interface{}, from, to int64, ms bool) {
+
+	for k, v := range dps {
+
+		date, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if !ms {
+			date *= 1000
+		}
+
+		if date >= from && (to == 0 || date < to) {
+			dst[k] = v
+		}
+	}
+}
+
+// spliceResponses - joins the cached points before the boundary with the tail points after it
+func spliceResponses(cached, tail TSDBresponses, head, boundary int64, ms bool) TSDBresponses {
+
+	tailMap := make(map[string]TSDBresponse, len(tail))
+	for _, resp := range tail {
+		tailMap[responseKey(resp)] = resp
+	}
+
+	spliced := TSDBresponses{}
+
+	for _, resp := range cached {
+
+		key := responseKey(resp)
+
+		dps := map[string]interface{}{}
+		filterDps(dps, resp.Dps, head, boundary, ms)
+
+		if tailResp, ok := tailMap[key]; ok {
+			filterDps(dps, tailResp.Dps, boundary, 0, ms)
+			if len(tailResp.Tsuids) > 0 {
+				resp.Tsuids = tailResp.Tsuids
+			}
+			delete(tailMap, key)
+		}
+
+		if len(dps) > 0 {
+			resp.Dps = dps
+			spliced = append(spliced, resp)
+		}
+	}
+
+	for _, resp := range tail {
+
+		if _, ok := tailMap[responseKey(resp)]; !ok {
+			continue
+		}
+
+		dps := map[string]interface{}{}
+		filterDps(dps, resp.Dps, boundary, 0, ms)
+
+		if len(dps) > 0 {
+			resp.Dps = dps
+			spliced = append(spliced, resp)
+		}
+	}
+
+	sort.Sort(spliced)
+
+	return spliced
+}
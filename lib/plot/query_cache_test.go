@@ -0,0 +1,264 @@
+package plot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/funks"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func newTestQueryCache(maxEntries int, ttl time.Duration) *queryCache {
+
+	return newQueryCache(&structs.QueryCacheConfiguration{
+		Enabled:    true,
+		MaxEntries: maxEntries,
+		TTL:        funks.Duration{Duration: ttl},
+	})
+}
+
+func TestQueryCacheDefaults(t *testing.T) {
+
+	qc := newTestQueryCache(0, 0)
+
+	assert.Equal(t, cQueryCacheDefaultMaxEntries, qc.maxEntries)
+	assert.Equal(t, cQueryCacheDefaultTTL, qc.ttl)
+}
+
+func TestQueryCacheMaxTTL(t *testing.T) {
+
+	_, gerr := New(nil, nil, 1, 1, nil, 1, 1, 0, nil, nil, constants.ClusteringOrderASC, &structs.QueryCacheConfiguration{
+		Enabled: true,
+		TTL:     funks.Duration{Duration: cQueryCacheMaxTTL + time.Second},
+	}, nil, "", nil)
+
+	if assert.NotNil(t, gerr, "the entries of the other nodes are not invalidated") {
+		assert.Equal(t, "QueryCache.TTL needs to be at most 5m0s", gerr.Message())
+	}
+}
+
+func TestQueryCacheLRU(t *testing.T) {
+
+	qc := newTestQueryCache(2, time.Minute)
+
+	qc.put(&queryCacheEntry{key: "a", created: time.Now()})
+	qc.put(&queryCacheEntry{key: "b", created: time.Now()})
+
+	// "a" becomes the most recently used
+	_, ok := qc.get("a")
+	assert.True(t, ok)
+
+	qc.put(&queryCacheEntry{key: "c", created: time.Now()})
+
+	_, ok = qc.get("b")
+	assert.False(t, ok, "the least recently used entry must be removed")
+
+	for _, key := range []string{"a", "c"} {
+		_, ok = qc.get(key)
+		assert.True(t, ok, key)
+	}
+
+	qc.put(&queryCacheEntry{key: "c", end: 10, created: time.Now()})
+
+	entry, ok := qc.get("c")
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(10), entry.end, "the entry must be replaced")
+	}
+
+	assert.Equal(t, 2, qc.lru.Len())
+	assert.Len(t, qc.entries, 2)
+}
+
+func TestQueryCacheExpiration(t *testing.T) {
+
+	qc := newTestQueryCache(10, time.Minute)
+
+	qc.put(&queryCacheEntry{key: "old", created: time.Now().Add(-2 * time.Minute)})
+	qc.put(&queryCacheEntry{key: "new", created: time.Now()})
+
+	_, ok := qc.get("old")
+	assert.False(t, ok)
+	assert.NotContains(t, qc.entries, "old", "the expired entry must be removed")
+
+	_, ok = qc.get("new")
+	assert.True(t, ok)
+}
+
+func TestQueryCacheInvalidate(t *testing.T) {
+
+	qc := newTestQueryCache(10, time.Minute)
+
+	qc.put(&queryCacheEntry{key: "1", keyset: "ks1", metrics: []string{"cpu", "mem"}, created: time.Now()})
+	qc.put(&queryCacheEntry{key: "2", keyset: "ks1", metrics: []string{"disk"}, created: time.Now()})
+	qc.put(&queryCacheEntry{key: "3", keyset: "ks2", metrics: []string{"mem"}, created: time.Now()})
+
+	qc.invalidate("ks1", "mem")
+
+	_, ok := qc.get("1")
+	assert.False(t, ok, "the entry with the metric must be removed")
+
+	_, ok = qc.get("2")
+	assert.True(t, ok, "the other metrics of the keyset are kept")
+
+	_, ok = qc.get("3")
+	assert.True(t, ok, "the other keysets are kept")
+
+	assert.Equal(t, 2, qc.lru.Len())
+
+	// the disabled cache is ignored
+	var plot Plot
+	plot.invalidateQueryCache("ks1", "disk")
+}
+
+func TestQueryCacheKey(t *testing.T) {
+
+	payload := func(start, end int64, relative string) structs.TSDBqueryPayload {
+		return structs.TSDBqueryPayload{
+			Start:    start,
+			End:      end,
+			Relative: relative,
+			Queries:  []structs.TSDBquery{{Metric: "cpu", Aggregator: "sum"}},
+		}
+	}
+
+	key := func(keyset string, query structs.TSDBqueryPayload, moving bool) string {
+		k, err := queryCacheKey(keyset, query, moving)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	assert.Equal(t, key("ks", payload(100, 200, "5m"), true), key("ks", payload(300, 400, "5m"), true), "the relative window moves")
+	assert.Equal(t, key("ks", payload(100, 200, ""), true), key("ks", payload(100, 400, ""), true), "the query without end moves")
+	assert.NotEqual(t, key("ks", payload(100, 200, ""), true), key("ks", payload(150, 200, ""), true), "the absolute start is part of the key")
+	assert.NotEqual(t, key("ks", payload(100, 200, ""), false), key("ks", payload(100, 400, ""), false))
+	assert.NotEqual(t, key("ks1", payload(100, 200, "5m"), true), key("ks2", payload(100, 200, "5m"), true))
+}
+
+func TestSpliceWindow(t *testing.T) {
+
+	minute := int64(60e3)
+	start := time.Date(2020, time.January, 1, 10, 0, 0, 0, time.Local).Unix() * 1e3
+
+	payload := func(downsample string, rate bool, from, to int64) structs.TSDBqueryPayload {
+		return structs.TSDBqueryPayload{
+			Start:   from,
+			End:     to,
+			Queries: []structs.TSDBquery{{Metric: "cpu", Aggregator: "sum", Downsample: downsample, Rate: rate}},
+		}
+	}
+
+	entry := &queryCacheEntry{start: start, end: start + 10*minute + 30e3, anchor: start}
+
+	type window struct {
+		tailStart, boundary, head int64
+		ok                        bool
+	}
+
+	cases := map[string]struct {
+		query    structs.TSDBqueryPayload
+		expected window
+	}{
+		"Downsampled": {
+			payload("1m-avg", false, start+2*minute, start+12*minute),
+			window{start + 10*minute, start + 10*minute, start + 2*minute, true},
+		},
+		"DownsampledWithRate": {
+			payload("1m-avg", true, start+2*minute, start+12*minute),
+			window{start + 9*minute, start + 10*minute, start + 2*minute, true},
+		},
+		"WithoutDownsample": {
+			payload("", false, start+2*minute, start+12*minute),
+			window{entry.end, entry.end + 1, start + 2*minute, true},
+		},
+		"WithoutDownsampleWithRate": {
+			payload("", true, start+2*minute, start+12*minute),
+			window{},
+		},
+		"HeadOutOfTheCachedGrid": {
+			payload("2m-avg", false, start+3*minute, start+12*minute),
+			window{},
+		},
+		"InterpolatingFill": {
+			payload("1m-avg-linear", false, start+2*minute, start+12*minute),
+			window{},
+		},
+		"StartBeforeTheCache": {
+			payload("1m-avg", false, start-minute, start+12*minute),
+			window{},
+		},
+		"EndNotAfterTheCache": {
+			payload("1m-avg", false, start+2*minute, entry.end),
+			window{},
+		},
+		"StartAfterTheCache": {
+			payload("1m-avg", false, entry.end+minute, entry.end+2*minute),
+			window{},
+		},
+	}
+
+	for name, c := range cases {
+
+		tailStart, boundary, head, ok := spliceWindow(c.query, entry)
+
+		if assert.Equal(t, c.expected.ok, ok, name) && ok {
+			assert.Equal(t, c.expected, window{tailStart, boundary, head, ok}, name)
+		}
+	}
+
+	mixed := payload("1m-avg", false, start+2*minute, start+12*minute)
+	mixed.Queries = append(mixed.Queries, structs.TSDBquery{Metric: "mem", Aggregator: "sum", Downsample: "5m-avg"})
+
+	_, _, _, ok := spliceWindow(mixed, entry)
+	assert.False(t, ok, "the queries must share the downsample")
+}
+
+func TestSpliceResponses(t *testing.T) {
+
+	cached := TSDBresponses{
+		{Metric: "cpu", Tags: map[string]string{"host": "a"}, Dps: map[string]interface{}{"100": 1.0, "200": 2.0, "300": 3.0}},
+		{Metric: "cpu", Tags: map[string]string{"host": "b"}, Dps: map[string]interface{}{"100": 5.0}},
+	}
+
+	tail := TSDBresponses{
+		{Metric: "cpu", Tags: map[string]string{"host": "a"}, Tsuids: []string{"tsid-a"}, Dps: map[string]interface{}{"300": 30.0, "400": 4.0}},
+		{Metric: "cpu", Tags: map[string]string{"host": "c"}, Dps: map[string]interface{}{"250": 9.0, "300": 7.0}},
+	}
+
+	spliced := spliceResponses(cached, tail, 200e3, 300e3, false)
+
+	expected := TSDBresponses{
+		{Metric: "cpu", Tags: map[string]string{"host": "a"}, Tsuids: []string{"tsid-a"}, Dps: map[string]interface{}{"200": 2.0, "300": 30.0, "400": 4.0}},
+		{Metric: "cpu", Tags: map[string]string{"host": "c"}, Dps: map[string]interface{}{"300": 7.0}},
+	}
+
+	assert.ElementsMatch(t, expected, spliced, "the series without points in the new window are removed")
+
+	assert.Equal(t, map[string]interface{}{"100": 1.0, "200": 2.0, "300": 3.0}, cached[0].Dps, "the cached entry must not be changed")
+
+	msCached := TSDBresponses{{Metric: "cpu", Dps: map[string]interface{}{"1500": 1.0, "2500": 2.0}}}
+	msTail := TSDBresponses{{Metric: "cpu", Dps: map[string]interface{}{"2500": 20.0}}}
+
+	spliced = spliceResponses(msCached, msTail, 1000, 2000, true)
+
+	if assert.Len(t, spliced, 1) {
+		assert.Equal(t, map[string]interface{}{"1500": 1.0, "2500": 20.0}, spliced[0].Dps)
+	}
+}
+
+func TestResponseKey(t *testing.T) {
+
+	a := TSDBresponse{Metric: "cpu", Tags: map[string]string{"host": "a", "app": "x"}}
+	b := TSDBresponse{Metric: "cpu", Tags: map[string]string{"app": "x", "host": "a"}}
+
+	assert.Equal(t, responseKey(a), responseKey(b))
+
+	b.AggregatedTags = []string{"dc"}
+	assert.NotEqual(t, responseKey(a), responseKey(b))
+
+	assert.NotEqual(t, responseKey(a), responseKey(TSDBresponse{Metric: "mem", Tags: a.Tags}))
+}
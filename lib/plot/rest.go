@@ -6,12 +6,15 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/rip"
 	"github.com/uol/logh"
 	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/parser"
+	"github.com/uol/mycenae/lib/utils"
 )
 
 // getSizeParameter - return parameter 'size'
@@ -59,7 +62,7 @@ func (plot *Plot) listTags(w http.ResponseWriter, r *http.Request, ps httprouter
 		return
 	}
 
-	tags, total, gerr := plot.FilterTagKeys(keyset, q.Get("tag"), size)
+	tags, total, gerr := plot.FilterTagKeys(keyset, q.Get("tag"), 0, size)
 	if gerr != nil {
 		rip.Fail(w, gerr)
 		return
@@ -105,7 +108,7 @@ func (plot *Plot) listMetrics(w http.ResponseWriter, r *http.Request, ps httprou
 		return
 	}
 
-	metrics, total, gerr := plot.FilterMetrics(keyset, q.Get("metric"), size)
+	metrics, total, gerr := plot.FilterMetrics(keyset, q.Get("metric"), 0, size)
 	if gerr != nil {
 		rip.Fail(w, gerr)
 		return
@@ -298,28 +301,27 @@ func (plot *Plot) deleteTS(w http.ResponseWriter, r *http.Request, ps httprouter
 	commit := q.Get("commit")
 
 	if commit != "true" {
-
 		rip.SuccessJSON(w, http.StatusOK, out)
+		return
+	}
 
-	} else {
-
-		for _, key := range keys {
-			gerr = plot.persist.metaStorage.DeleteDocumentByID(*keyset, tsType, key.TsId)
-			if gerr != nil {
-				rip.Fail(w, gerr)
-				return
-			}
-			ttl := key.Tags["ttl"]
-			gerr = plot.DeletePoint(key.TsId, ttl, *keyset, key.Metric)
-			if gerr != nil {
-				rip.Fail(w, gerr)
-				return
-			}
-		}
+	jobKeys, gerr := plot.deletionKeys(keys, 0)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
 
-		rip.SuccessJSON(w, http.StatusAccepted, out)
+	job, gerr := plot.createDeleteJob(*keyset, tsType, DeleteJobSeries, 0, 0, jobKeys)
+	if gerr != nil {
+		rip.Fail(w, gerr)
 		return
 	}
+
+	rip.SuccessJSON(w, http.StatusAccepted, SeriesDeletionResponse{
+		TotalRecords: total,
+		Payload:      keys,
+		Job:          job,
+	})
 }
 
 // ListNumberTagValuesByMetric - returns tag values filtered by metric
@@ -412,6 +414,93 @@ func (plot *Plot) listTagsByMetric(w http.ResponseWriter, r *http.Request, ps ht
 	return
 }
 
+const cFuncListCardinality string = "ListCardinality"
+
+// ListCardinality - returns the number of timeseries by metric, tag key and the top tag values of a keyset
+func (plot *Plot) ListCardinality(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, fail := plot.getKeysetParameter(w, r, ps, cFuncListCardinality)
+	if fail {
+		return
+	}
+
+	q := r.URL.Query()
+
+	size, fail := plot.getSizeParameter(w, q, cFuncListCardinality)
+	if fail {
+		return
+	}
+
+	since, fail := plot.getTimeParameter(w, q, "since", cFuncListCardinality)
+	if fail {
+		return
+	}
+
+	cardinality, gerr := plot.FilterCardinality(*keyset, q.Get("metric"), q.Get("tag"), since, size)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	if cardinality.Total == 0 {
+		rip.Fail(w, errNoContent(cFuncListCardinality))
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, cardinality)
+}
+
+// parseSince - parses a timestamp in seconds or milliseconds or a relative time like "1h" or "7d"
+func parseSince(value string) (time.Time, error) {
+
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+
+		millis, err := utils.MilliToSeconds(timestamp)
+		if err != nil || timestamp <= 0 {
+			return time.Time{}, errors.New(value)
+		}
+
+		return time.Unix(0, millis*int64(time.Millisecond)), nil
+	}
+
+	since, gerr := parser.GetRelativeStart(time.Now(), value)
+	if gerr != nil {
+		return time.Time{}, gerr
+	}
+
+	return since, nil
+}
+
+// getTimeParameter - parses a time parameter (a timestamp in seconds or milliseconds or a relative time)
+func (plot *Plot) getTimeParameter(w http.ResponseWriter, q url.Values, param, function string) (time.Time, bool) {
+
+	value := q.Get(param)
+	if value == constants.StringsEmpty {
+		return time.Time{}, false
+	}
+
+	since, err := parseSince(value)
+	if err != nil {
+		rip.Fail(w, errParamTime(function, param, err))
+		return time.Time{}, true
+	}
+
+	return since, false
+}
+
+const cParamActiveSince string = "activeSince"
+
+// getActiveSinceParameter - parses the "activeSince" parameter as milliseconds (zero if not informed)
+func (plot *Plot) getActiveSinceParameter(w http.ResponseWriter, q url.Values, function string) (int64, bool) {
+
+	activeSince, fail := plot.getTimeParameter(w, q, cParamActiveSince, function)
+	if fail || activeSince.IsZero() {
+		return 0, fail
+	}
+
+	return activeSince.UnixNano() / int64(time.Millisecond), false
+}
+
 // addProcessedBytesHeader - adds the number of processed bytes in the response header
 func addProcessedBytesHeader(w http.ResponseWriter, numBytes uint32) {
 
@@ -0,0 +1,293 @@
+package plot
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// Deletes the points of a time range keeping the timeseries and its metadata,
+// the series are selected by metric and tags or by their ids.
+//
+
+const (
+	cFuncDeletePoints     string = "deletePoints"
+	cFuncDeletePointRange string = "DeletePointRange"
+	cTableNumberStamp     string = "ts_number_stamp"
+	cTableTextStamp       string = "ts_text_stamp"
+	fmtDeleteRangeQuery   string = `DELETE FROM %v.%v WHERE id = ? AND date >= ? AND date <= ?`
+)
+
+// PointsDeletion - the series selection of a points deletion (by metric and tags or by ids)
+type PointsDeletion struct {
+	Metric string   `json:"metric"`
+	Tags   []Tag    `json:"tags"`
+	TSIDs  []string `json:"tsids"`
+	TTL    int      `json:"ttl"`
+}
+
+// Validate - implements the rip validation
+func (pd PointsDeletion) Validate() gobol.Error {
+
+	if len(pd.TSIDs) > 0 && (pd.Metric != constants.StringsEmpty || len(pd.Tags) > 0) {
+		return errValidationS(cFuncDeletePoints, "the series must be selected by \"tsids\" or by \"metric\" and \"tags\", not both")
+	}
+
+	if len(pd.TSIDs) == 0 && pd.Metric == constants.StringsEmpty && len(pd.Tags) == 0 {
+		return errValidationS(cFuncDeletePoints, "the series must be selected by \"tsids\" or by \"metric\" and \"tags\"")
+	}
+
+	return nil
+}
+
+// PointsDeletionResponse - the selected series and the deleted time range (in milliseconds)
+type PointsDeletionResponse struct {
+	TotalRecords int          `json:"totalRecords"`
+	Start        int64        `json:"start"`
+	End          int64        `json:"end"`
+	Payload      []TsMetaInfo `json:"payload"`
+	Job          *DeleteJob   `json:"job,omitempty"`
+}
+
+// SeriesDeletionResponse - the selected series and the job deleting them
+type SeriesDeletionResponse struct {
+	TotalRecords int          `json:"totalRecords"`
+	Payload      []TsMetaInfo `json:"payload"`
+	Job          *DeleteJob   `json:"job"`
+}
+
+// GetDeleteJobREST - returns the progress of a deletion job (GET /jobs/:id)
+func (plot *Plot) GetDeleteJobREST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	job, gerr := plot.GetDeleteJob(ps.ByName("id"))
+	if gerr == nil {
+		gerr = plot.auth.Authorize(auth.FromContext(r.Context()), job.Keyset, auth.OperationDelete, constants.StringsHTTP)
+	}
+
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, job)
+}
+
+// CancelDeleteJobREST - cancels a running deletion job (DELETE /jobs/:id)
+func (plot *Plot) CancelDeleteJobREST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	job, gerr := plot.GetDeleteJob(ps.ByName("id"))
+	if gerr == nil {
+		gerr = plot.auth.Authorize(auth.FromContext(r.Context()), job.Keyset, auth.OperationDelete, constants.StringsHTTP)
+	}
+
+	if gerr == nil {
+		job, gerr = plot.CancelDeleteJob(job.ID)
+	}
+
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, job)
+}
+
+// DeleteNumberPoints - deletes the number points of a time range (POST /keysets/:keyset/delete/points?start=x&end=y&commit=true)
+func (plot *Plot) DeleteNumberPoints(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	plot.deletePoints(w, r, ps, "meta", cTableNumberStamp)
+}
+
+// DeleteTextPoints - deletes the text points of a time range (POST /keysets/:keyset/delete/text/points?start=x&end=y&commit=true)
+func (plot *Plot) DeleteTextPoints(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	plot.deletePoints(w, r, ps, "metatext", cTableTextStamp)
+}
+
+// deletePoints - lists the selected series (dry run) or starts a job deleting their points in the time range when "commit" is true
+func (plot *Plot) deletePoints(w http.ResponseWriter, r *http.Request, ps httprouter.Params, tsType, table string) {
+
+	keyset, fail := plot.getKeysetParameter(w, r, ps, cFuncDeletePoints)
+	if fail {
+		return
+	}
+
+	if err := plot.validateKeyset(*keyset); err != nil {
+		rip.Fail(w, errNotFound(cFuncDeletePoints))
+		return
+	}
+
+	q := r.URL.Query()
+
+	start, fail := plot.getTimeParameter(w, q, "start", cFuncDeletePoints)
+	if fail {
+		return
+	}
+
+	if start.IsZero() {
+		rip.Fail(w, errMandatoryParam(cFuncDeletePoints, "start"))
+		return
+	}
+
+	end, fail := plot.getTimeParameter(w, q, "end", cFuncDeletePoints)
+	if fail {
+		return
+	}
+
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	if end.Before(start) {
+		rip.Fail(w, errValidationS(cFuncDeletePoints, "query param \"end\" must be after \"start\""))
+		return
+	}
+
+	commit := false
+	if commitStr := q.Get("commit"); commitStr != constants.StringsEmpty {
+		var err error
+		commit, err = strconv.ParseBool(commitStr)
+		if err != nil {
+			rip.Fail(w, errValidation(cFuncDeletePoints, `query param "commit" should be a boolean`, err))
+			return
+		}
+	}
+
+	size, fail := plot.getSizeParameter(w, q, cFuncDeletePoints)
+	if fail {
+		return
+	}
+
+	deletion := PointsDeletion{}
+	if gerr := rip.FromJSON(r, &deletion); gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	keys, total, gerr := plot.selectDeletionSeries(*keyset, tsType, &deletion, size)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	if len(keys) == 0 {
+		rip.Fail(w, errNoContent(cFuncDeletePoints))
+		return
+	}
+
+	out := PointsDeletionResponse{
+		TotalRecords: total,
+		Start:        start.UnixNano() / int64(time.Millisecond),
+		End:          end.UnixNano() / int64(time.Millisecond),
+		Payload:      keys,
+	}
+
+	if !commit {
+		rip.SuccessJSON(w, http.StatusOK, out)
+		return
+	}
+
+	jobKeys, gerr := plot.deletionKeys(keys, deletion.TTL)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	out.Job, gerr = plot.createDeleteJob(*keyset, tsType, DeleteJobPoints, out.Start, out.End, jobKeys)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusAccepted, out)
+}
+
+// selectDeletionSeries - returns the series selected by the metric and tags or by their ids (the metric is unknown in this case,
+// the ids must be indexed in the keyset)
+func (plot *Plot) selectDeletionSeries(keyset, tsType string, deletion *PointsDeletion, size int) ([]TsMetaInfo, int, gobol.Error) {
+
+	if len(deletion.TSIDs) == 0 {
+
+		tags := map[string]string{}
+		for _, tag := range deletion.Tags {
+			tags[tag.Key] = tag.Value
+		}
+
+		return plot.ListMeta(keyset, tsType, deletion.Metric, tags, false, size, 0)
+	}
+
+	if deletion.TTL == 0 {
+		deletion.TTL = plot.defaultTTL
+	}
+
+	if _, ok := plot.keyspaceTTLMap[deletion.TTL]; !ok {
+		return nil, 0, errValidationS(cFuncDeletePoints, fmt.Sprintf("ttl %d do not exists", deletion.TTL))
+	}
+
+	keys := make([]TsMetaInfo, len(deletion.TSIDs))
+	for i, tsid := range deletion.TSIDs {
+
+		// only the series of the keyset are deleted, an unknown id rejects the whole deletion
+		found, gerr := plot.persist.metaStorage.CheckMetadata(keyset, tsType, tsid, []byte(tsid))
+		if gerr != nil {
+			return nil, 0, gerr
+		}
+
+		if !found {
+			return nil, 0, errNotFoundS(cFuncDeletePoints, fmt.Sprintf("tsid %s not found in keyset %s", tsid, keyset))
+		}
+
+		keys[i] = TsMetaInfo{TsId: tsid}
+	}
+
+	return keys, len(keys), nil
+}
+
+// parseDeletionTTL - parses the ttl tag of a series
+func (plot *Plot) parseDeletionTTL(ttlStr string) (int, gobol.Error) {
+
+	ttl, err := strconv.Atoi(ttlStr)
+	if err != nil {
+		return 0, errValidation(cFuncDeletePoints, fmt.Sprintf("invalid series ttl: %s", ttlStr), err)
+	}
+
+	return ttl, nil
+}
+
+// DeletePointRange - deletes the points of the series between start and end (in milliseconds, inclusive)
+func (plot *Plot) DeletePointRange(tsID string, ttl int, keyset, metric, table string, start, end int64) gobol.Error {
+
+	keyspace, ok := plot.keyspaceTTLMap[ttl]
+	if !ok {
+		return errValidationS(cFuncDeletePointRange, fmt.Sprintf("ttl %d do not exists", ttl))
+	}
+
+	if err := plot.persist.cassandra.Query(
+		fmt.Sprintf(fmtDeleteRangeQuery, keyspace, table),
+		tsID,
+		start,
+		end,
+	).Exec(); err != nil {
+		if logh.ErrorEnabled {
+			plot.logger.Error().Str(constants.StringsFunc, cFuncDeletePointRange).Err(err).Msgf("error deleting the points of tsid %s", tsID)
+		}
+		plot.statsDeletePointsError(cFuncDeletePointRange, keyset, metric)
+		return errPersist(cFuncDeletePointRange, err)
+	}
+
+	plot.statsDeletePointsSuccess(cFuncDeletePointRange, keyset, metric)
+
+	if logh.InfoEnabled {
+		plot.logger.Info().Str(constants.StringsFunc, cFuncDeletePointRange).Msgf("points of tsid %s from %d to %d successfully removed", tsID, start, end)
+	}
+
+	return nil
+}
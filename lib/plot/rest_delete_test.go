@@ -0,0 +1,89 @@
+package plot
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/metadata"
+)
+
+// indexedBackend - a metadata backend indexing the ids of each keyset
+type indexedBackend struct {
+	metadata.Backend
+	ids map[string][]string
+	err gobol.Error
+}
+
+func (b *indexedBackend) CheckMetadata(collection, tsType, tsid string, tsidBytes []byte) (bool, gobol.Error) {
+
+	if b.err != nil {
+		return false, b.err
+	}
+
+	for _, id := range b.ids[collection] {
+		if id == tsid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func TestPointsDeletionValidate(t *testing.T) {
+
+	assert.NoError(t, PointsDeletion{Metric: "cpu"}.Validate())
+	assert.NoError(t, PointsDeletion{Tags: []Tag{{Key: "host", Value: "a"}}}.Validate())
+	assert.NoError(t, PointsDeletion{TSIDs: []string{"a"}}.Validate())
+
+	gerr := PointsDeletion{}.Validate()
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusBadRequest, gerr.StatusCode())
+	}
+
+	assert.Error(t, PointsDeletion{TSIDs: []string{"a"}, Metric: "cpu"}.Validate())
+	assert.Error(t, PointsDeletion{TSIDs: []string{"a"}, Tags: []Tag{{Key: "host", Value: "a"}}}.Validate())
+}
+
+func TestSelectDeletionSeriesByTSID(t *testing.T) {
+
+	backend := &indexedBackend{ids: map[string][]string{"ks": {"a", "b"}, "other": {"c"}}}
+
+	plot := &Plot{
+		defaultTTL:     1,
+		keyspaceTTLMap: map[int]string{1: "ts01", 7: "ts07"},
+		persist:        &persistence{metaStorage: &metadata.Storage{Backend: backend}},
+	}
+
+	deletion := &PointsDeletion{TSIDs: []string{"a", "b"}}
+
+	keys, total, gerr := plot.selectDeletionSeries("ks", "meta", deletion, 10)
+	assert.NoError(t, gerr)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []TsMetaInfo{{TsId: "a"}, {TsId: "b"}}, keys)
+	assert.Equal(t, 1, deletion.TTL, "the default ttl")
+
+	_, _, gerr = plot.selectDeletionSeries("ks", "meta", &PointsDeletion{TSIDs: []string{"a"}, TTL: 30}, 10)
+	assert.EqualError(t, gerr, "ttl 30 do not exists")
+
+	keys, _, gerr = plot.selectDeletionSeries("ks", "meta", &PointsDeletion{TSIDs: []string{"a", "c"}}, 10)
+	if assert.Error(t, gerr, "the series of another keyset") {
+		assert.Equal(t, http.StatusNotFound, gerr.StatusCode())
+		assert.Equal(t, "tsid c not found in keyset ks", gerr.Message())
+	}
+	assert.Nil(t, keys, "nothing is deleted")
+
+	backend.err = errInternalServer("test", errors.New("unavailable"))
+	_, _, gerr = plot.selectDeletionSeries("ks", "meta", &PointsDeletion{TSIDs: []string{"a"}}, 10)
+	assert.Equal(t, backend.err, gerr)
+
+	ttl, gerr := plot.parseDeletionTTL("7")
+	assert.NoError(t, gerr)
+	assert.Equal(t, 7, ttl)
+
+	_, gerr = plot.parseDeletionTTL("x")
+	assert.Error(t, gerr)
+}
@@ -420,7 +420,7 @@ func (plot *Plot) expandStruct(
 
 	if needExpand {
 
-		tsobs, total, gerr := plot.MetaFilterOpenTSDB(keyset, tsdb.Metric, tsdb.Filters, plot.MaxTimeseries)
+		tsobs, total, gerr := plot.MetaFilterOpenTSDB(keyset, tsdb.Metric, tsdb.Filters, 0, plot.MaxTimeseries)
 		if gerr != nil {
 			return groupQueries, gerr
 		}
@@ -492,6 +492,8 @@ func (plot *Plot) expandStruct(
 						Order:       tsdb.Order,
 						FilterValue: tsdb.FilterValue,
 						Filters:     filtersPlain,
+						UseCalendar: tsdb.UseCalendar,
+						Timezone:    tsdb.Timezone,
 					},
 				},
 			}
@@ -0,0 +1,216 @@
+package plot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/golang/snappy"
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/prompb"
+)
+
+//
+// Implements the prometheus remote read endpoint.
+//
+
+const (
+	funcPrometheusRead        string = "PrometheusRead"
+	cPrometheusMetricLabel    string = "__name__"
+	cMsgErrDecodingProtobuf   string = "error decoding the protobuf payload"
+	cHeaderContentEncoding    string = "Content-Encoding"
+	cHeaderContentType        string = "Content-Type"
+	cContentTypeProtobuf      string = "application/x-protobuf"
+	cContentEncodingSnappy    string = "snappy"
+	cMsgUnsupportedMetricOper string = "only the equal and regexp matchers are supported for the metric name"
+)
+
+// PrometheusRead - handles the snappy compressed protobuf prometheus remote read request
+func (plot *Plot) PrometheusRead(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset := ps.ByName(constants.StringsKeyset)
+	if keyset == constants.StringsEmpty {
+		rip.Fail(w, errNotFound(funcPrometheusRead))
+		return
+	}
+
+	gerr := plot.validateKeyset(keyset)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	defer r.Body.Close()
+
+	compressed, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rip.Fail(w, errValidation(funcPrometheusRead, cMsgErrDecodingProtobuf, err))
+		return
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		rip.Fail(w, errValidation(funcPrometheusRead, cMsgErrDecodingProtobuf, err))
+		return
+	}
+
+	request := prompb.ReadRequest{}
+	if err = request.Unmarshal(data); err != nil {
+		rip.Fail(w, errValidation(funcPrometheusRead, cMsgErrDecodingProtobuf, err))
+		return
+	}
+
+	response := prompb.ReadResponse{
+		Results: make([]prompb.QueryResult, len(request.Queries)),
+	}
+
+	var sumBytes uint32
+
+	// the bytes limit is shared by all queries of the request (a query only succeeds below the remaining bytes)
+	for i := 0; i < len(request.Queries); i++ {
+
+		timeseries, numBytes, gerr := plot.prometheusQuery(keyset, &request.Queries[i], plot.maxBytesLimit-sumBytes)
+		if gerr != nil {
+			rip.Fail(w, gerr)
+			return
+		}
+
+		response.Results[i].Timeseries = timeseries
+		sumBytes += numBytes
+	}
+
+	addProcessedBytesHeader(w, sumBytes)
+
+	w.Header().Set(cHeaderContentType, cContentTypeProtobuf)
+	w.Header().Set(cHeaderContentEncoding, cContentEncodingSnappy)
+
+	rip.Success(w, http.StatusOK, snappy.Encode(nil, response.Marshal()))
+}
+
+// prometheusQuery - translates the label matchers to a metadata query and fetches the raw points
+// limited to the remaining bytes of the request, the query is built here and not by MetaFilterOpenTSDB
+// because the opentsdb filters have no metric regexp nor negated regexp and split the literal values by '|'
+func (plot *Plot) prometheusQuery(keyset string, promQuery *prompb.Query, maxBytesLimit uint32) ([]prompb.TimeSeries, uint32, gobol.Error) {
+
+	query := &metadata.Query{
+		MetaType: "meta",
+		Tags:     []metadata.QueryTag{},
+	}
+
+	ttl := plot.defaultTTL
+
+	for _, matcher := range promQuery.Matchers {
+
+		if matcher.Name == cPrometheusMetricLabel {
+
+			switch matcher.Type {
+			case prompb.MatchEqual:
+				query.Metric = matcher.Value
+			case prompb.MatchRegexp:
+				query.Metric = matcher.Value
+				query.Regexp = true
+			default:
+				return nil, 0, errValidationS(funcPrometheusRead, cMsgUnsupportedMetricOper)
+			}
+
+			continue
+		}
+
+		if matcher.Name == constants.StringsTTL && matcher.Type == prompb.MatchEqual {
+			v, err := strconv.Atoi(matcher.Value)
+			if err != nil {
+				return nil, 0, errValidationE(funcPrometheusRead, err)
+			}
+			ttl = v
+		}
+
+		// an empty equal matcher selects series without the label, which is not indexed
+		if matcher.Type == prompb.MatchEqual && matcher.Value == constants.StringsEmpty {
+			continue
+		}
+
+		query.Tags = append(query.Tags, metadata.QueryTag{
+			Key:    matcher.Name,
+			Values: []string{matcher.Value},
+			Negate: matcher.Type == prompb.MatchNotEqual || matcher.Type == prompb.MatchNotRegexp,
+			Regexp: matcher.Type == prompb.MatchRegexp || matcher.Type == prompb.MatchNotRegexp,
+		})
+	}
+
+	keyspace, ok := plot.keyspaceTTLMap[ttl]
+	if !ok {
+		return nil, 0, errNotFound("invalid ttl found: " + strconv.Itoa(ttl))
+	}
+
+	metric := query.Metric
+
+	from, size := plot.checkParams(0, plot.MaxTimeseries)
+
+	tsobs, total, gerr := plot.metaFilterQuery(keyset, query, from, size)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	logIfExceeded := fmt.Sprintf("TS THRESHOLD/MAX EXCEEDED for prometheus query: %+v", *promQuery)
+	gerr = plot.checkTotalTSLimits(logIfExceeded, keyset, metric, total)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	if len(tsobs) == 0 {
+		return []prompb.TimeSeries{}, 0, nil
+	}
+
+	ids := make([]string, len(tsobs))
+	for i, tsob := range tsobs {
+		ids[i] = tsob.Tsuid
+	}
+
+	pointMap, numBytes, gerr := plot.persist.GetTS(keyspace, ids, promQuery.StartTimestampMs, promQuery.EndTimestampMs, true, false, maxBytesLimit, keyset)
+	if gerr != nil {
+		if gerr.Error() == plot.persist.maxBytesErr.Error() {
+			return nil, numBytes, errMaxBytesLimit(funcPrometheusRead, keyset, metric, promQuery.StartTimestampMs, promQuery.EndTimestampMs, ttl)
+		}
+
+		return nil, numBytes, gerr
+	}
+
+	timeseries := make([]prompb.TimeSeries, 0, len(pointMap))
+
+	for _, tsob := range tsobs {
+
+		points, ok := pointMap[tsob.Tsuid]
+		if !ok || len(points) == 0 {
+			continue
+		}
+
+		ts := prompb.TimeSeries{
+			Labels:  make([]prompb.Label, 0, len(tsob.Tags)+1),
+			Samples: make([]prompb.Sample, 0, len(points)),
+		}
+
+		ts.Labels = append(ts.Labels, prompb.Label{Name: cPrometheusMetricLabel, Value: tsob.Metric})
+		for k, v := range tsob.Tags {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: k, Value: v})
+		}
+
+		sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+
+		for _, p := range points {
+			ts.Samples = append(ts.Samples, prompb.Sample{Value: p.Value, Timestamp: p.Date})
+		}
+
+		timeseries = append(timeseries, ts)
+	}
+
+	plot.statsActiveMetric(funcPrometheusRead, keyset, metric)
+
+	return timeseries, numBytes, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/uol/gobol"
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/utils"
 
@@ -116,6 +117,13 @@ func (plot *Plot) RawDataQuery(w http.ResponseWriter, r *http.Request, ps httpro
 		return
 	}
 
+	// the keyset is only known here, the route only checks the read permission
+	gerr = plot.auth.Authorize(auth.FromContext(r.Context()), qp.keyset, auth.OperationRead, constants.StringsHTTP)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
 	metadataArray, _, gerr := plot.persist.metaStorage.FilterMetadata(rawQuery.Tags[rawDataQueryKSID], &metadataQuery, 0, plot.MaxTimeseries)
 	if gerr != nil {
 		rip.Fail(w, gerr)
@@ -137,6 +145,11 @@ func (plot *Plot) RawDataQuery(w http.ResponseWriter, r *http.Request, ps httpro
 		}
 	}
 
+	if rawQuery.Stream != constants.StringsEmpty && !qp.last && !qp.estimateSize {
+		plot.streamRawPoints(w, &qp, rawQuery.Type, rawQuery.Stream)
+		return
+	}
+
 	var results interface{}
 	var numBytes uint32
 	if rawQuery.Type == rawDataQueryTextType {
@@ -0,0 +1,243 @@
+package plot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+)
+
+//
+// Streams the raw query results page by page, keeping the memory bounded.
+//
+
+const (
+	rawDataStreamPageSize     int    = 5000
+	rawDataStreamBytesTrailer string = "X-Processed-Bytes"
+	rawDataStreamErrorTrailer string = "X-Stream-Error"
+)
+
+// rawStreamWriter - writes the raw query pages as NDJSON (one line per page) or as chunked JSON
+type rawStreamWriter struct {
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	ndjson      bool
+	metadataMap map[string]RawDataMetadata
+	started     bool
+	current     string
+	total       int
+	buffer      bytes.Buffer
+}
+
+// newRawStreamWriter - creates a new stream writer
+func newRawStreamWriter(w http.ResponseWriter, format string, metadataMap map[string]RawDataMetadata) *rawStreamWriter {
+
+	flusher, _ := w.(http.Flusher)
+
+	return &rawStreamWriter{
+		w:           w,
+		flusher:     flusher,
+		ndjson:      format == rawDataStreamNDJSON,
+		metadataMap: metadataMap,
+	}
+}
+
+// begin - writes the headers and the JSON opening (only when the first page arrives)
+func (sw *rawStreamWriter) begin() {
+
+	if sw.started {
+		return
+	}
+
+	sw.started = true
+
+	header := sw.w.Header()
+	header.Set("Trailer", rawDataStreamBytesTrailer+", "+rawDataStreamErrorTrailer)
+
+	if sw.ndjson {
+		header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		header.Set("Content-Type", "application/json")
+	}
+
+	sw.w.WriteHeader(http.StatusOK)
+
+	if !sw.ndjson {
+		sw.buffer.WriteString(`{"results":[`)
+	}
+}
+
+// writePage - writes the encoded points of a serie page
+func (sw *rawStreamWriter) writePage(tsid string, points interface{}) error {
+
+	sw.begin()
+
+	metadata, err := json.Marshal(sw.metadataMap[tsid])
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+
+	if sw.ndjson {
+
+		if tsid != sw.current {
+			sw.current = tsid
+			sw.total++
+		}
+
+		sw.buffer.WriteString(`{"metadata":`)
+		sw.buffer.Write(metadata)
+		sw.buffer.WriteString(`,"points":`)
+		sw.buffer.Write(encoded)
+		sw.buffer.WriteString("}\n")
+
+	} else {
+
+		if tsid != sw.current {
+
+			if sw.current != "" {
+				sw.buffer.WriteString("]},")
+			}
+
+			sw.current = tsid
+			sw.total++
+
+			sw.buffer.WriteString(`{"metadata":`)
+			sw.buffer.Write(metadata)
+			sw.buffer.WriteString(`,"points":[`)
+
+		} else {
+			sw.buffer.WriteByte(',')
+		}
+
+		sw.buffer.Write(encoded[1 : len(encoded)-1])
+	}
+
+	return sw.flush()
+}
+
+// flush - sends the buffered data to the client
+func (sw *rawStreamWriter) flush() error {
+
+	_, err := sw.w.Write(sw.buffer.Bytes())
+	sw.buffer.Reset()
+
+	if err != nil {
+		return err
+	}
+
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+
+	return nil
+}
+
+// writeNumberPage - writes a number points page
+func (sw *rawStreamWriter) writeNumberPage(tsid string, page []Pnt) error {
+
+	values := make([]RawDataNumberPoint, len(page))
+	for i, p := range page {
+		values[i] = RawDataNumberPoint{
+			Timestamp: p.Date,
+			Value:     p.Value,
+		}
+	}
+
+	return sw.writePage(tsid, values)
+}
+
+// writeTextPage - writes a text points page
+func (sw *rawStreamWriter) writeTextPage(tsid string, page []TextPnt) error {
+
+	texts := make([]RawDataTextPoint, len(page))
+	for i, p := range page {
+		texts[i] = RawDataTextPoint{
+			Timestamp: p.Date,
+			Text:      p.Value,
+		}
+	}
+
+	return sw.writePage(tsid, texts)
+}
+
+// end - closes the stream, the error is written as the last element and as a trailer (if any), so a
+// client can tell the stream cut by an error from the complete one
+func (sw *rawStreamWriter) end(numBytes uint32, gerr gobol.Error) {
+
+	if !sw.started {
+
+		addProcessedBytesHeader(sw.w, numBytes)
+
+		if gerr != nil {
+			rip.Fail(sw.w, gerr)
+			return
+		}
+
+		rip.Success(sw.w, http.StatusNoContent, nil)
+		return
+	}
+
+	var message []byte
+	if gerr != nil {
+		message, _ = json.Marshal(gerr.Message())
+	}
+
+	if sw.ndjson {
+
+		if gerr != nil {
+			sw.buffer.WriteString(`{"error":`)
+			sw.buffer.Write(message)
+			sw.buffer.WriteString("}\n")
+		}
+
+	} else {
+
+		if sw.current != "" {
+			sw.buffer.WriteString("]}")
+		}
+
+		sw.buffer.WriteByte(']')
+
+		if gerr != nil {
+			sw.buffer.WriteString(`,"error":`)
+			sw.buffer.Write(message)
+		}
+
+		sw.buffer.WriteString(`,"total":`)
+		sw.buffer.WriteString(strconv.Itoa(sw.total))
+		sw.buffer.WriteByte('}')
+	}
+
+	sw.w.Header().Set(rawDataStreamBytesTrailer, strconv.FormatUint(uint64(numBytes), 10))
+
+	if gerr != nil {
+		sw.w.Header().Set(rawDataStreamErrorTrailer, gerr.Message())
+	}
+
+	sw.flush()
+}
+
+// streamRawPoints - streams the number or text points filtered by the query
+func (plot *Plot) streamRawPoints(w http.ResponseWriter, qp *queryParameters, queryType, format string) {
+
+	sw := newRawStreamWriter(w, format, qp.metadataMap)
+
+	var numBytes uint32
+	var gerr gobol.Error
+
+	if queryType == rawDataQueryTextType {
+		numBytes, gerr = plot.persist.StreamTST(qp.keyspace, qp.tsids, qp.since, qp.until, rawDataStreamPageSize, plot.maxBytesLimit, qp.keyset, sw.writeTextPage)
+	} else {
+		numBytes, gerr = plot.persist.StreamTS(qp.keyspace, qp.tsids, qp.since, qp.until, rawDataStreamPageSize, plot.maxBytesLimit, qp.keyset, sw.writeNumberPage)
+	}
+
+	sw.end(numBytes, gerr)
+}
@@ -0,0 +1,206 @@
+package plot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testStreamMetadata = map[string]RawDataMetadata{
+	"a": {Metric: "cpu", Tags: map[string]string{"host": "a"}},
+	"b": {Metric: "cpu", Tags: map[string]string{"host": "b"}},
+}
+
+type testStreamPage struct {
+	Metadata RawDataMetadata      `json:"metadata"`
+	Points   []RawDataNumberPoint `json:"points"`
+	Error    string               `json:"error"`
+}
+
+type testStreamResult struct {
+	Results []testStreamPage `json:"results"`
+	Error   string           `json:"error"`
+	Total   int              `json:"total"`
+}
+
+// ndjsonLines - decodes each line of the NDJSON response
+func ndjsonLines(t *testing.T, body []byte) []testStreamPage {
+
+	pages := []testStreamPage{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		page := testStreamPage{}
+		if err := json.Unmarshal(scanner.Bytes(), &page); err != nil {
+			t.Fatalf("invalid line %q: %s", scanner.Text(), err)
+		}
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+func writeTestPages(t *testing.T, sw *rawStreamWriter) {
+
+	pages := []struct {
+		tsid   string
+		points []Pnt
+	}{
+		{"a", []Pnt{{Date: 1, Value: 1}, {Date: 2, Value: 2}}},
+		{"a", []Pnt{{Date: 3, Value: 3}}},
+		{"b", []Pnt{{Date: 1, Value: 10}}},
+	}
+
+	for _, p := range pages {
+		assert.NoError(t, sw.writeNumberPage(p.tsid, p.points))
+	}
+}
+
+func TestRawStreamNDJSON(t *testing.T) {
+
+	w := httptest.NewRecorder()
+
+	sw := newRawStreamWriter(w, rawDataStreamNDJSON, testStreamMetadata)
+	writeTestPages(t, sw)
+	sw.end(300, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, "300", w.Header().Get(rawDataStreamBytesTrailer))
+	assert.Empty(t, w.Header().Get(rawDataStreamErrorTrailer), "the stream is complete")
+	assert.True(t, w.Flushed)
+
+	expected := []testStreamPage{
+		{Metadata: testStreamMetadata["a"], Points: []RawDataNumberPoint{{1, 1}, {2, 2}}},
+		{Metadata: testStreamMetadata["a"], Points: []RawDataNumberPoint{{3, 3}}},
+		{Metadata: testStreamMetadata["b"], Points: []RawDataNumberPoint{{1, 10}}},
+	}
+
+	assert.Equal(t, expected, ndjsonLines(t, w.Body.Bytes()), "each page is a line")
+}
+
+func TestRawStreamJSON(t *testing.T) {
+
+	w := httptest.NewRecorder()
+
+	sw := newRawStreamWriter(w, rawDataStreamJSON, testStreamMetadata)
+	writeTestPages(t, sw)
+	sw.end(300, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	result := testStreamResult{}
+	if !assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), w.Body.String()) {
+		return
+	}
+
+	expected := testStreamResult{
+		Results: []testStreamPage{
+			{Metadata: testStreamMetadata["a"], Points: []RawDataNumberPoint{{1, 1}, {2, 2}, {3, 3}}},
+			{Metadata: testStreamMetadata["b"], Points: []RawDataNumberPoint{{1, 10}}},
+		},
+		Total: 2,
+	}
+
+	assert.Equal(t, expected, result, "the pages of a serie are joined")
+}
+
+func TestRawStreamTextPages(t *testing.T) {
+
+	w := httptest.NewRecorder()
+
+	sw := newRawStreamWriter(w, rawDataStreamJSON, testStreamMetadata)
+	assert.NoError(t, sw.writeTextPage("a", []TextPnt{{Date: 1, Value: "up"}, {Date: 2, Value: `"quoted"`}}))
+	sw.end(10, nil)
+
+	result := struct {
+		Results []struct {
+			Points []RawDataTextPoint `json:"points"`
+		} `json:"results"`
+		Total int `json:"total"`
+	}{}
+
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), w.Body.String()) && assert.Len(t, result.Results, 1) {
+		assert.Equal(t, []RawDataTextPoint{{1, "up"}, {2, `"quoted"`}}, result.Results[0].Points)
+		assert.Equal(t, 1, result.Total)
+	}
+}
+
+func TestRawStreamErrorAfterTheFirstPage(t *testing.T) {
+
+	gerr := errMaxBytesLimitWrapper("StreamTS", errNoContent("StreamTS"))
+
+	w := httptest.NewRecorder()
+	sw := newRawStreamWriter(w, rawDataStreamNDJSON, testStreamMetadata)
+	writeTestPages(t, sw)
+	sw.end(500, gerr)
+
+	assert.Equal(t, http.StatusOK, w.Code, "the status was already sent")
+
+	lines := ndjsonLines(t, w.Body.Bytes())
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, gerr.Message(), lines[3].Error, "the error is the last line")
+	}
+	assert.Equal(t, gerr.Message(), w.Header().Get(rawDataStreamErrorTrailer))
+
+	w = httptest.NewRecorder()
+	sw = newRawStreamWriter(w, rawDataStreamJSON, testStreamMetadata)
+	writeTestPages(t, sw)
+	sw.end(500, gerr)
+
+	result := testStreamResult{}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), w.Body.String()) {
+		assert.Equal(t, gerr.Message(), result.Error)
+		assert.Equal(t, 2, result.Total)
+	}
+}
+
+func TestRawStreamWithoutPages(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	newRawStreamWriter(w, rawDataStreamNDJSON, testStreamMetadata).end(0, nil)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	newRawStreamWriter(w, rawDataStreamJSON, testStreamMetadata).end(0, errNoContent("StreamTS"))
+
+	assert.Equal(t, errNoContent("StreamTS").StatusCode(), w.Code, "the error status is sent before the stream starts")
+}
+
+func TestRawDataQueryParseStream(t *testing.T) {
+
+	cases := map[string]struct {
+		body   string
+		stream string
+		valid  bool
+	}{
+		"NoStream":      {`{"type":"number","metric":"cpu","since":"1h","tags":{"ksid":"ks"}}`, "", true},
+		"NDJSON":        {`{"type":"number","metric":"cpu","since":"1h","stream":"ndjson","tags":{"ksid":"ks"}}`, "ndjson", true},
+		"JSON":          {`{"type":"text","metric":"cpu","since":"1h","stream":"json","tags":{"ksid":"ks"}}`, "json", true},
+		"UnknownFormat": {`{"type":"number","metric":"cpu","since":"1h","stream":"csv","tags":{"ksid":"ks"}}`, "", false},
+		"NotAString":    {`{"type":"number","metric":"cpu","since":"1h","stream":true,"tags":{"ksid":"ks"}}`, "", false},
+	}
+
+	for name, c := range cases {
+
+		r := httptest.NewRequest(http.MethodPost, "/api/query/raw", bytes.NewBufferString(c.body))
+
+		rq := RawDataQuery{}
+		gerr := rq.Parse(r)
+
+		if c.valid {
+			assert.Nil(t, gerr, name)
+			assert.Equal(t, c.stream, rq.Stream, name)
+		} else if assert.NotNil(t, gerr, name) {
+			assert.Equal(t, http.StatusBadRequest, gerr.StatusCode(), name)
+		}
+	}
+}
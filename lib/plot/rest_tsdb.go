@@ -31,7 +31,8 @@ func (plot *Plot) Lookup(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		return
 	}
 
-	m := r.URL.Query().Get("m")
+	q := r.URL.Query()
+	m := q.Get("m")
 
 	if m == constants.StringsEmpty {
 		gerr := errValidationS("Lookup", `missing query parameter "m"`)
@@ -53,7 +54,12 @@ func (plot *Plot) Lookup(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		tagMap[tag.Key] = append(tagMap[tag.Key], tag.Value)
 	}
 
-	tsds, total, gerr := plot.MetaOpenTSDB(keyset, metric, tagMap, plot.MaxTimeseries, 0)
+	activeSince, fail := plot.getActiveSinceParameter(w, q, "Lookup")
+	if fail {
+		return
+	}
+
+	tsds, total, gerr := plot.MetaOpenTSDB(keyset, metric, tagMap, activeSince, plot.MaxTimeseries, 0)
 	if gerr != nil {
 		rip.Fail(w, gerr)
 		return
@@ -110,6 +116,11 @@ func (plot *Plot) Suggest(w http.ResponseWriter, r *http.Request, ps httprouter.
 		}
 	}
 
+	activeSince, fail := plot.getActiveSinceParameter(w, queryString, "Suggest")
+	if fail {
+		return
+	}
+
 	switch queryString.Get("type") {
 	case constants.StringsEmpty:
 		gerr = errValidationS("Suggest", "type required")
@@ -117,13 +128,13 @@ func (plot *Plot) Suggest(w http.ResponseWriter, r *http.Request, ps httprouter.
 		return
 	case "metrics":
 		q := fmt.Sprintf("%v*", queryString.Get("q"))
-		resp, _, gerr = plot.FilterMetrics(keyset, q, max)
+		resp, _, gerr = plot.FilterMetrics(keyset, q, activeSince, max)
 	case "tagk":
 		q := fmt.Sprintf("%v*", queryString.Get("q"))
-		resp, _, gerr = plot.FilterTagKeys(keyset, q, max)
+		resp, _, gerr = plot.FilterTagKeys(keyset, q, activeSince, max)
 	case "tagv":
 		q := fmt.Sprintf("%v*", queryString.Get("q"))
-		resp, _, gerr = plot.FilterTagValues(keyset, q, max)
+		resp, _, gerr = plot.FilterTagValues(keyset, q, activeSince, max)
 	default:
 		gerr = errValidationS("Suggest", "unsupported type")
 		rip.Fail(w, gerr)
@@ -190,19 +201,35 @@ const funcGetTimeseries string = "getTimeseries"
 func (plot *Plot) getTimeseries(
 	keyset string,
 	query structs.TSDBqueryPayload,
-) (resps TSDBresponses, sumBytes uint32, gerr gobol.Error) {
+) (TSDBresponses, uint32, gobol.Error) {
+
+	moving := query.Relative != constants.StringsEmpty || query.End == 0
+
+	if gerr := resolveQueryWindow(&query); gerr != nil {
+		return nil, 0, gerr
+	}
+
+	if plot.queryCache == nil || query.EstimateSize {
+		return plot.queryTimeseries(keyset, query)
+	}
+
+	return plot.cachedTimeseries(keyset, query, moving)
+}
+
+// resolveQueryWindow - sets the absolute start and end of the query
+func resolveQueryWindow(query *structs.TSDBqueryPayload) gobol.Error {
 
 	if query.Relative != constants.StringsEmpty {
 		now := time.Now()
 		start, gerr := parser.GetRelativeStart(now, query.Relative)
 		if gerr != nil {
-			return resps, 0, gerr
+			return gerr
 		}
 		query.Start = start.UnixNano() / 1e+6
 		query.End = now.UnixNano() / 1e+6
 	} else {
 		if query.Start == 0 {
-			return resps, 0, errValidationS(funcGetTimeseries, "start cannot be zero")
+			return errValidationS(funcGetTimeseries, "start cannot be zero")
 		}
 
 		if query.End == 0 {
@@ -210,67 +237,103 @@ func (plot *Plot) getTimeseries(
 		}
 
 		if query.End < query.Start {
-			return resps, 0, errValidationS(funcGetTimeseries, "end date should be equal or bigger than start date")
+			return errValidationS(funcGetTimeseries, "end date should be equal or bigger than start date")
 		}
 	}
 
+	return nil
+}
+
+// parseTSDBdownsample - converts the query downsample to the data operation format
+func parseTSDBdownsample(query structs.TSDBqueryPayload, q structs.TSDBquery) structs.Downsample {
+
 	oldDs := structs.Downsample{}
 
-	sumTotalPoints := 0
-	sumCountPoints := 0
+	if q.Downsample == constants.StringsEmpty {
+		return oldDs
+	}
 
-	for _, q := range query.Queries {
+	ds := strings.Split(q.Downsample, "-")
+	var unit string
+	var val int
 
-		if q.Downsample != constants.StringsEmpty {
+	if string(ds[0][len(ds[0])-2:]) == "ms" {
+		unit = ds[0][len(ds[0])-2:]
+		val, _ = strconv.Atoi(ds[0][:len(ds[0])-2])
+	} else {
+		unit = ds[0][len(ds[0])-1:]
+		val, _ = strconv.Atoi(ds[0][:len(ds[0])-1])
+	}
 
-			ds := strings.Split(q.Downsample, "-")
-			var unit string
-			var val int
+	apporx := ds[1]
 
-			if string(ds[0][len(ds[0])-2:]) == "ms" {
-				unit = ds[0][len(ds[0])-2:]
-				val, _ = strconv.Atoi(ds[0][:len(ds[0])-2])
-			} else {
-				unit = ds[0][len(ds[0])-1:]
-				val, _ = strconv.Atoi(ds[0][:len(ds[0])-1])
-			}
+	if apporx == "count" {
+		apporx = "pnt"
+	}
 
-			apporx := ds[1]
+	switch unit {
+	case "ms":
+		oldDs.Options.Unit = "ms"
+	case "s":
+		oldDs.Options.Unit = "sec"
+	case "m":
+		oldDs.Options.Unit = "min"
+	case "h":
+		oldDs.Options.Unit = "hour"
+	case "d":
+		oldDs.Options.Unit = "day"
+	case "w":
+		oldDs.Options.Unit = "week"
+	case "n":
+		oldDs.Options.Unit = "month"
+	case "y":
+		oldDs.Options.Unit = "year"
+	}
 
-			if apporx == "count" {
-				apporx = "pnt"
-			}
+	if len(ds) >= 3 {
+		oldDs.Options.Fill = ds[2]
+	} else {
+		oldDs.Options.Fill = "none"
+	}
 
-			switch unit {
-			case "ms":
-				oldDs.Options.Unit = "ms"
-			case "s":
-				oldDs.Options.Unit = "sec"
-			case "m":
-				oldDs.Options.Unit = "min"
-			case "h":
-				oldDs.Options.Unit = "hour"
-			case "d":
-				oldDs.Options.Unit = "day"
-			case "w":
-				oldDs.Options.Unit = "week"
-			case "n":
-				oldDs.Options.Unit = "month"
-			case "y":
-				oldDs.Options.Unit = "year"
-			}
+	if len(ds) == 4 {
+		oldDs.Options.MaxGap, _ = structs.DurationToMs(ds[3])
+	}
 
-			if len(ds) == 3 {
-				oldDs.Options.Fill = ds[2]
-			} else {
-				oldDs.Options.Fill = "none"
-			}
+	oldDs.Options.UseCalendar = q.UseCalendar || query.UseCalendar
+	oldDs.Options.Timezone = q.Timezone
+	if oldDs.Options.Timezone == constants.StringsEmpty {
+		oldDs.Options.Timezone = query.Timezone
+	}
+
+	oldDs.Options.Downsample = apporx
+	oldDs.Options.Value = val
+	oldDs.Enabled = true
 
-			oldDs.Options.Downsample = apporx
-			oldDs.Options.Value = val
-			oldDs.Enabled = true
+	return oldDs
+}
 
+// queryTimeseries - queries the timeseries of the payload (the query window must be resolved)
+func (plot *Plot) queryTimeseries(
+	keyset string,
+	query structs.TSDBqueryPayload,
+) (resps TSDBresponses, sumBytes uint32, gerr gobol.Error) {
+
+	sumTotalPoints := 0
+	sumCountPoints := 0
+
+	var activeSince int64
+	if query.ActiveSince != constants.StringsEmpty {
+		since, err := parseSince(query.ActiveSince)
+		if err != nil {
+			return resps, sumBytes, errValidationS(funcGetTimeseries, `"activeSince" should be a timestamp or a relative time like "1h" or "7d"`)
 		}
+		activeSince = since.UnixNano() / int64(time.Millisecond)
+	}
+
+	for _, q := range query.Queries {
+
+		oldDs := parseTSDBdownsample(query, q)
 
 		for k, v := range q.Tags {
 
@@ -309,7 +372,7 @@ func (plot *Plot) getTimeseries(
 			q.Filters = append(q.Filters[:ttlIndex], q.Filters[ttlIndex+1:]...)
 		}
 
-		tsobs, total, gerr := plot.MetaFilterOpenTSDB(keyset, q.Metric, q.Filters, plot.MaxTimeseries)
+		tsobs, total, gerr := plot.MetaFilterOpenTSDB(keyset, q.Metric, q.Filters, activeSince, plot.MaxTimeseries)
 		if gerr != nil {
 			return resps, sumBytes, gerr
 		}
@@ -442,7 +505,7 @@ func (plot *Plot) getTimeseries(
 				ksrt := strconv.FormatInt(k, 10)
 				if point.Empty {
 					switch oldDs.Options.Fill {
-					case "null":
+					case "null", "previous", "linear":
 						points[ksrt] = nil
 					case "nan":
 						points[ksrt] = "NaN"
@@ -12,6 +12,9 @@ const (
 	metricActiveMetric      string = "mycenae.active.metric"
 	metricDeleteMetaError   string = "metadata.delete.error"
 	metricDeleteMetaSuccess string = "metadata.delete.success"
+	metricQueryCache        string = "plot.query.cache"
+	metricDeletePointsError string = "points.delete.error"
+	metricDeletePointsOK    string = "points.delete.success"
 )
 
 func (plot *Plot) statsQueryTSThreshold(function, keyset string, total int) {
@@ -78,3 +81,30 @@ func (plot *Plot) statsDeleteMetaSuccess(function, keyset, metric string) {
 		constants.StringsMetric, metric,
 	)
 }
+
+func (plot *Plot) statsDeletePointsError(function, keyset, metric string) {
+	plot.timelineManager.FlattenCountIncA(
+		function,
+		metricDeletePointsError,
+		constants.StringsKeyset, keyset,
+		constants.StringsMetric, metric,
+	)
+}
+
+func (plot *Plot) statsDeletePointsSuccess(function, keyset, metric string) {
+	plot.timelineManager.FlattenCountIncA(
+		function,
+		metricDeletePointsOK,
+		constants.StringsKeyset, keyset,
+		constants.StringsMetric, metric,
+	)
+}
+
+func (plot *Plot) statsQueryCache(function, keyset, result string) {
+	plot.timelineManager.FlattenCountIncA(
+		function,
+		metricQueryCache,
+		constants.StringsKeyset, keyset,
+		"result", result,
+	)
+}
@@ -399,6 +399,7 @@ type RawDataQuery struct {
 	Since        string `json:"since"`
 	Until        string `json:"until"`
 	EstimateSize bool   `json:"estimateSize"`
+	Stream       string `json:"stream"`
 }
 
 const (
@@ -414,6 +415,9 @@ const (
 	rawDataQueryKSID         string = "ksid"
 	rawDataQueryTTL          string = "ttl"
 	rawDataQueryLast         string = "last"
+	rawDataQueryStream       string = "stream"
+	rawDataStreamNDJSON      string = "ndjson"
+	rawDataStreamJSON        string = "json"
 )
 
 // Parse - parses the bytes tol JSON
@@ -448,6 +452,14 @@ func (rq *RawDataQuery) Parse(r *http.Request) gobol.Error {
 		return errUnmarshal(rawDataQueryFunc, err)
 	}
 
+	if rq.Stream, err = jsonparser.GetString(data, rawDataQueryStream); err != nil && err != jsonparser.KeyPathNotFoundError {
+		return errUnmarshal(rawDataQueryFunc, err)
+	}
+
+	if rq.Stream != constants.StringsEmpty && rq.Stream != rawDataStreamNDJSON && rq.Stream != rawDataStreamJSON {
+		return errValidationS(rawDataQueryFunc, `parameter "stream" must be "ndjson" or "json"`)
+	}
+
 	rq.Tags = map[string]string{}
 	err = jsonparser.ObjectEach(data, func(key, value []byte, dataType jsonparser.ValueType, offset int) error {
 
@@ -0,0 +1,361 @@
+package prompb
+
+import (
+	"github.com/uol/mycenae/lib/protowire"
+)
+
+//
+// Prometheus remote storage protobuf messages (only the fields used by mycenae).
+//
+
+// Label - a prometheus label pair
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample - a prometheus sample
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// TimeSeries - a prometheus timeseries with its labels and samples
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// WriteRequest - the remote write request
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// Unmarshal - decodes the remote write request
+func (wr *WriteRequest) Unmarshal(data []byte) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		if field == 1 && wt == protowire.WireBytes {
+
+			b, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+
+			ts := TimeSeries{}
+			if err = ts.Unmarshal(b); err != nil {
+				return err
+			}
+
+			wr.Timeseries = append(wr.Timeseries, ts)
+
+			continue
+		}
+
+		if err = r.Skip(wt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unmarshal - decodes a timeseries
+func (ts *TimeSeries) Unmarshal(data []byte) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		if wt != protowire.WireBytes || (field != 1 && field != 2) {
+			if err = r.Skip(wt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		b, err := r.Bytes()
+		if err != nil {
+			return err
+		}
+
+		if field == 1 {
+			l := Label{}
+			if err = l.Unmarshal(b); err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		} else {
+			s := Sample{}
+			if err = s.Unmarshal(b); err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+	}
+
+	return nil
+}
+
+// Unmarshal - decodes a label
+func (l *Label) Unmarshal(data []byte) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case field == 1 && wt == protowire.WireBytes:
+			l.Name, err = r.String()
+		case field == 2 && wt == protowire.WireBytes:
+			l.Value, err = r.String()
+		default:
+			err = r.Skip(wt)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unmarshal - decodes a sample
+func (s *Sample) Unmarshal(data []byte) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case field == 1 && wt == protowire.WireFixed64:
+			s.Value, err = r.Double()
+		case field == 2 && wt == protowire.WireVarint:
+			s.Timestamp, err = r.Int64()
+		default:
+			err = r.Skip(wt)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MatchType - the label matcher type
+type MatchType uint8
+
+const (
+	// MatchEqual - label value must be equal
+	MatchEqual MatchType = 0
+	// MatchNotEqual - label value must not be equal
+	MatchNotEqual MatchType = 1
+	// MatchRegexp - label value must match the regular expression
+	MatchRegexp MatchType = 2
+	// MatchNotRegexp - label value must not match the regular expression
+	MatchNotRegexp MatchType = 3
+)
+
+// LabelMatcher - a label matcher from a remote read query
+type LabelMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+}
+
+// Query - a remote read query
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []LabelMatcher
+}
+
+// ReadRequest - the remote read request
+type ReadRequest struct {
+	Queries []Query
+}
+
+// QueryResult - the result of one query
+type QueryResult struct {
+	Timeseries []TimeSeries
+}
+
+// ReadResponse - the remote read response
+type ReadResponse struct {
+	Results []QueryResult
+}
+
+// Unmarshal - decodes the remote read request
+func (rr *ReadRequest) Unmarshal(data []byte) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		if field == 1 && wt == protowire.WireBytes {
+
+			b, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+
+			q := Query{}
+			if err = q.Unmarshal(b); err != nil {
+				return err
+			}
+
+			rr.Queries = append(rr.Queries, q)
+
+			continue
+		}
+
+		if err = r.Skip(wt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unmarshal - decodes a query
+func (q *Query) Unmarshal(data []byte) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case field == 1 && wt == protowire.WireVarint:
+			q.StartTimestampMs, err = r.Int64()
+		case field == 2 && wt == protowire.WireVarint:
+			q.EndTimestampMs, err = r.Int64()
+		case field == 3 && wt == protowire.WireBytes:
+			var b []byte
+			b, err = r.Bytes()
+			if err == nil {
+				m := LabelMatcher{}
+				if err = m.Unmarshal(b); err == nil {
+					q.Matchers = append(q.Matchers, m)
+				}
+			}
+		default:
+			err = r.Skip(wt)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unmarshal - decodes a label matcher
+func (m *LabelMatcher) Unmarshal(data []byte) error {
+
+	r := protowire.NewReader(data)
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case field == 1 && wt == protowire.WireVarint:
+			var v uint64
+			v, err = r.Varint()
+			m.Type = MatchType(v)
+		case field == 2 && wt == protowire.WireBytes:
+			m.Name, err = r.String()
+		case field == 3 && wt == protowire.WireBytes:
+			m.Value, err = r.String()
+		default:
+			err = r.Skip(wt)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Marshal - encodes the remote read response
+func (rr *ReadResponse) Marshal() []byte {
+
+	w := protowire.NewWriter(1024)
+	result := protowire.NewWriter(1024)
+	series := protowire.NewWriter(256)
+	item := protowire.NewWriter(64)
+
+	for i := 0; i < len(rr.Results); i++ {
+
+		result.Reset()
+
+		for j := 0; j < len(rr.Results[i].Timeseries); j++ {
+
+			ts := &rr.Results[i].Timeseries[j]
+
+			series.Reset()
+
+			for _, l := range ts.Labels {
+				item.Reset()
+				item.String(1, l.Name)
+				item.String(2, l.Value)
+				series.BytesField(1, item.Bytes())
+			}
+
+			for _, s := range ts.Samples {
+				item.Reset()
+				item.Double(1, s.Value)
+				item.Int64(2, s.Timestamp)
+				series.BytesField(2, item.Bytes())
+			}
+
+			result.BytesField(1, series.Bytes())
+		}
+
+		w.BytesField(1, result.Bytes())
+	}
+
+	return w.Bytes()
+}
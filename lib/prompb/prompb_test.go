@@ -0,0 +1,194 @@
+package prompb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/protowire"
+)
+
+func encodeLabel(name, value string) []byte {
+
+	w := protowire.NewWriter(32)
+	w.String(1, name)
+	w.String(2, value)
+
+	return w.Bytes()
+}
+
+func encodeSample(value float64, timestamp int64) []byte {
+
+	w := protowire.NewWriter(32)
+	w.Double(1, value)
+	w.Int64(2, timestamp)
+
+	return w.Bytes()
+}
+
+func TestWriteRequestUnmarshal(t *testing.T) {
+
+	ts := protowire.NewWriter(128)
+	ts.BytesField(1, encodeLabel("__name__", "up"))
+	ts.BytesField(1, encodeLabel("ksid", "stats"))
+	ts.BytesField(2, encodeSample(1, 1600000000000))
+	ts.BytesField(2, encodeSample(-0.5, 1600000015000))
+
+	request := protowire.NewWriter(256)
+	request.BytesField(1, ts.Bytes())
+	request.BytesField(1, ts.Bytes())
+	// the metadata field (3) is not used
+	request.BytesField(3, []byte{0x08, 0x01})
+
+	wr := WriteRequest{}
+	if !assert.NoError(t, wr.Unmarshal(request.Bytes())) {
+		return
+	}
+
+	expected := TimeSeries{
+		Labels:  []Label{{Name: "__name__", Value: "up"}, {Name: "ksid", Value: "stats"}},
+		Samples: []Sample{{Value: 1, Timestamp: 1600000000000}, {Value: -0.5, Timestamp: 1600000015000}},
+	}
+
+	assert.Equal(t, []TimeSeries{expected, expected}, wr.Timeseries)
+}
+
+func TestWriteRequestUnmarshalUnknownFields(t *testing.T) {
+
+	label := protowire.NewWriter(32)
+	label.Varint(5, 1)
+	label.String(1, "job")
+	label.String(2, "node")
+
+	sample := protowire.NewWriter(32)
+	sample.String(7, "exemplar")
+	sample.Double(1, 2)
+	// a timestamp encoded with an unexpected wire type is skipped
+	sample.Double(2, 3)
+
+	ts := protowire.NewWriter(64)
+	ts.BytesField(1, label.Bytes())
+	ts.Varint(3, 10)
+	ts.BytesField(2, sample.Bytes())
+
+	request := protowire.NewWriter(64)
+	request.BytesField(1, ts.Bytes())
+
+	wr := WriteRequest{}
+	if !assert.NoError(t, wr.Unmarshal(request.Bytes())) {
+		return
+	}
+
+	assert.Equal(t, []TimeSeries{{
+		Labels:  []Label{{Name: "job", Value: "node"}},
+		Samples: []Sample{{Value: 2}},
+	}}, wr.Timeseries)
+}
+
+func TestWriteRequestUnmarshalInvalid(t *testing.T) {
+
+	truncatedSample := protowire.NewWriter(32)
+	truncatedSample.BytesField(2, []byte{0x09, 0x00, 0x00})
+
+	cases := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"TruncatedKey":        {data: []byte{0x80}, err: protowire.ErrTruncated},
+		"TruncatedTimeseries": {data: []byte{0x0a, 0x05, 0x0a}, err: protowire.ErrTruncated},
+		"TruncatedSample":     {data: append([]byte{0x0a, byte(len(truncatedSample.Bytes()))}, truncatedSample.Bytes()...), err: protowire.ErrTruncated},
+		"InvalidWireType":     {data: []byte{0x0b}, err: protowire.ErrInvalidWireType},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			wr := WriteRequest{}
+
+			assert.Equal(t, c.err, wr.Unmarshal(c.data))
+		})
+	}
+}
+
+func TestReadRequestUnmarshal(t *testing.T) {
+
+	matcher := func(mt MatchType, name, value string) []byte {
+		w := protowire.NewWriter(32)
+		w.Varint(1, uint64(mt))
+		w.String(2, name)
+		w.String(3, value)
+		return w.Bytes()
+	}
+
+	query := protowire.NewWriter(128)
+	query.Int64(1, 1600000000000)
+	query.Int64(2, 1600003600000)
+	query.BytesField(3, matcher(MatchEqual, "__name__", "up"))
+	query.BytesField(3, matcher(MatchNotRegexp, "job", "node.*"))
+	// the read hints (4) are ignored
+	query.BytesField(4, []byte{0x08, 0x0f})
+
+	request := protowire.NewWriter(256)
+	request.BytesField(1, query.Bytes())
+	// the accepted response types (2) are ignored
+	request.Varint(2, 1)
+
+	rr := ReadRequest{}
+	if !assert.NoError(t, rr.Unmarshal(request.Bytes())) {
+		return
+	}
+
+	assert.Equal(t, []Query{{
+		StartTimestampMs: 1600000000000,
+		EndTimestampMs:   1600003600000,
+		Matchers: []LabelMatcher{
+			{Type: MatchEqual, Name: "__name__", Value: "up"},
+			{Type: MatchNotRegexp, Name: "job", Value: "node.*"},
+		},
+	}}, rr.Queries)
+
+	rr = ReadRequest{}
+	assert.Equal(t, protowire.ErrTruncated, rr.Unmarshal([]byte{0x0a, 0x02, 0x1a, 0x05}))
+}
+
+func TestReadResponseMarshal(t *testing.T) {
+
+	series := TimeSeries{
+		Labels:  []Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "node"}},
+		Samples: []Sample{{Value: 1, Timestamp: 1600000000000}, {Value: 0, Timestamp: 1600000015000}},
+	}
+
+	response := ReadResponse{
+		Results: []QueryResult{
+			{Timeseries: []TimeSeries{series, series}},
+			{},
+		},
+	}
+
+	r := protowire.NewReader(response.Marshal())
+
+	var results [][]TimeSeries
+
+	for !r.Done() {
+
+		field, wt, err := r.Next()
+		if !assert.NoError(t, err) || !assert.Equal(t, 1, field) || !assert.Equal(t, protowire.WireBytes, wt) {
+			return
+		}
+
+		b, err := r.Bytes()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// a query result has the same layout of a write request
+		result := WriteRequest{}
+		if !assert.NoError(t, result.Unmarshal(b)) {
+			return
+		}
+
+		results = append(results, result.Timeseries)
+	}
+
+	assert.Equal(t, [][]TimeSeries{{series, series}, nil}, results)
+}
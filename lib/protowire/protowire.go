@@ -0,0 +1,260 @@
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+//
+// Minimal protocol buffers wire format reader and writer, used by the
+// protocols that speak protobuf (prometheus, opentelemetry).
+//
+
+// WireType - the protobuf wire type
+type WireType uint8
+
+const (
+	// WireVarint - varint encoded value
+	WireVarint WireType = 0
+	// WireFixed64 - fixed 64 bits value
+	WireFixed64 WireType = 1
+	// WireBytes - length delimited value
+	WireBytes WireType = 2
+	// WireFixed32 - fixed 32 bits value
+	WireFixed32 WireType = 5
+)
+
+var (
+	// ErrTruncated - the buffer ended before the value
+	ErrTruncated = errors.New("protobuf: truncated buffer")
+
+	// ErrOverflow - the varint is bigger than 64 bits
+	ErrOverflow = errors.New("protobuf: varint overflow")
+
+	// ErrInvalidWireType - the wire type is unknown or not expected
+	ErrInvalidWireType = errors.New("protobuf: invalid wire type")
+)
+
+// Reader - reads fields from a protobuf encoded message
+type Reader struct {
+	buf []byte
+	pos int
+}
+
+// NewReader - creates a new reader over the buffer
+func NewReader(buf []byte) *Reader {
+
+	return &Reader{
+		buf: buf,
+	}
+}
+
+// Done - returns true if there is nothing left to read
+func (r *Reader) Done() bool {
+
+	return r.pos >= len(r.buf)
+}
+
+// Next - reads the next field number and wire type
+func (r *Reader) Next() (int, WireType, error) {
+
+	key, err := r.Varint()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(key >> 3), WireType(key & 7), nil
+}
+
+// Varint - reads a varint
+func (r *Reader) Varint() (uint64, error) {
+
+	var value uint64
+
+	for shift := uint(0); shift < 64; shift += 7 {
+
+		if r.pos >= len(r.buf) {
+			return 0, ErrTruncated
+		}
+
+		b := r.buf[r.pos]
+		r.pos++
+
+		value |= uint64(b&0x7f) << shift
+
+		if b < 0x80 {
+			return value, nil
+		}
+	}
+
+	return 0, ErrOverflow
+}
+
+// Int64 - reads a varint as int64
+func (r *Reader) Int64() (int64, error) {
+
+	v, err := r.Varint()
+
+	return int64(v), err
+}
+
+// Fixed64 - reads a fixed 64 bits value
+func (r *Reader) Fixed64() (uint64, error) {
+
+	if r.pos+8 > len(r.buf) {
+		return 0, ErrTruncated
+	}
+
+	v := binary.LittleEndian.Uint64(r.buf[r.pos:])
+	r.pos += 8
+
+	return v, nil
+}
+
+// Double - reads a fixed 64 bits float
+func (r *Reader) Double() (float64, error) {
+
+	v, err := r.Fixed64()
+
+	return math.Float64frombits(v), err
+}
+
+// Fixed32 - reads a fixed 32 bits value
+func (r *Reader) Fixed32() (uint32, error) {
+
+	if r.pos+4 > len(r.buf) {
+		return 0, ErrTruncated
+	}
+
+	v := binary.LittleEndian.Uint32(r.buf[r.pos:])
+	r.pos += 4
+
+	return v, nil
+}
+
+// Bytes - reads a length delimited value (the returned slice is not copied)
+func (r *Reader) Bytes() ([]byte, error) {
+
+	l, err := r.Varint()
+	if err != nil {
+		return nil, err
+	}
+
+	// compared before the conversion, a huge length would overflow the end position
+	if l > uint64(len(r.buf)-r.pos) {
+		return nil, ErrTruncated
+	}
+
+	end := r.pos + int(l)
+	b := r.buf[r.pos:end]
+	r.pos = end
+
+	return b, nil
+}
+
+// String - reads a length delimited string
+func (r *Reader) String() (string, error) {
+
+	b, err := r.Bytes()
+
+	return string(b), err
+}
+
+// Skip - skips a value of the specified wire type
+func (r *Reader) Skip(wt WireType) error {
+
+	var err error
+
+	switch wt {
+	case WireVarint:
+		_, err = r.Varint()
+	case WireFixed64:
+		_, err = r.Fixed64()
+	case WireBytes:
+		_, err = r.Bytes()
+	case WireFixed32:
+		_, err = r.Fixed32()
+	default:
+		err = ErrInvalidWireType
+	}
+
+	return err
+}
+
+// Writer - writes fields of a protobuf encoded message
+type Writer struct {
+	buf []byte
+}
+
+// NewWriter - creates a new writer with the specified initial capacity
+func NewWriter(capacity int) *Writer {
+
+	return &Writer{
+		buf: make([]byte, 0, capacity),
+	}
+}
+
+// Bytes - returns the encoded message
+func (w *Writer) Bytes() []byte {
+
+	return w.buf
+}
+
+// Reset - resets the writer keeping the allocated buffer
+func (w *Writer) Reset() {
+
+	w.buf = w.buf[:0]
+}
+
+func (w *Writer) appendVarint(v uint64) {
+
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *Writer) appendKey(field int, wt WireType) {
+
+	w.appendVarint(uint64(field)<<3 | uint64(wt))
+}
+
+// Varint - writes a varint field
+func (w *Writer) Varint(field int, v uint64) {
+
+	w.appendKey(field, WireVarint)
+	w.appendVarint(v)
+}
+
+// Int64 - writes a int64 varint field
+func (w *Writer) Int64(field int, v int64) {
+
+	w.Varint(field, uint64(v))
+}
+
+// Double - writes a fixed 64 bits float field
+func (w *Writer) Double(field int, v float64) {
+
+	w.appendKey(field, WireFixed64)
+	w.buf = append(w.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(w.buf[len(w.buf)-8:], math.Float64bits(v))
+}
+
+// BytesField - writes a length delimited field
+func (w *Writer) BytesField(field int, b []byte) {
+
+	w.appendKey(field, WireBytes)
+	w.appendVarint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// String - writes a string field
+func (w *Writer) String(field int, s string) {
+
+	w.appendKey(field, WireBytes)
+	w.appendVarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
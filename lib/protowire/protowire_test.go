@@ -0,0 +1,213 @@
+package protowire
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderVarint(t *testing.T) {
+
+	cases := []struct {
+		buf      []byte
+		expected uint64
+		err      error
+	}{
+		{[]byte{0x00}, 0, nil},
+		{[]byte{0x7f}, 127, nil},
+		{[]byte{0xac, 0x02}, 300, nil},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, math.MaxUint64, nil},
+		{[]byte{}, 0, ErrTruncated},
+		{[]byte{0xac}, 0, ErrTruncated},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, 0, ErrOverflow},
+	}
+
+	for _, c := range cases {
+
+		v, err := NewReader(c.buf).Varint()
+
+		assert.Equal(t, c.err, err, "%x", c.buf)
+		assert.Equal(t, c.expected, v, "%x", c.buf)
+	}
+}
+
+func TestReaderNextTruncatedMessage(t *testing.T) {
+
+	// field 1, length delimited, announcing 5 bytes and carrying 2
+	r := NewReader([]byte{0x0a, 0x05, 'a', 'b'})
+
+	field, wt, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, field)
+	assert.Equal(t, WireBytes, wt)
+
+	_, err = r.Bytes()
+	assert.Equal(t, ErrTruncated, err)
+
+	_, _, err = NewReader([]byte{0x80}).Next()
+	assert.Equal(t, ErrTruncated, err)
+}
+
+func TestReaderBytes(t *testing.T) {
+
+	cases := map[string]struct {
+		buf      []byte
+		expected []byte
+		err      error
+	}{
+		"Empty":             {buf: []byte{0x00}, expected: []byte{}},
+		"Value":             {buf: []byte{0x03, 'a', 'b', 'c'}, expected: []byte("abc")},
+		"TrailingData":      {buf: []byte{0x01, 'a', 'b'}, expected: []byte("a")},
+		"Truncated":         {buf: []byte{0x04, 'a', 'b', 'c'}, err: ErrTruncated},
+		"TruncatedLength":   {buf: []byte{0x80}, err: ErrTruncated},
+		"NegativeAsInt":     {buf: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 'a'}, err: ErrTruncated},
+		"HugeLength":        {buf: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40, 'a'}, err: ErrTruncated},
+		"OverflowingLength": {buf: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 'a'}, err: ErrTruncated},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			b, err := NewReader(c.buf).Bytes()
+
+			assert.Equal(t, c.err, err)
+			assert.Equal(t, c.expected, b)
+		})
+	}
+}
+
+func TestReaderFixed(t *testing.T) {
+
+	cases := map[string]struct {
+		buf   []byte
+		read  func(r *Reader) (interface{}, error)
+		value interface{}
+		err   error
+	}{
+		"Fixed64": {
+			buf:   []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			read:  func(r *Reader) (interface{}, error) { return r.Fixed64() },
+			value: uint64(0x0807060504030201),
+		},
+		"Fixed64Truncated": {
+			buf:   []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+			read:  func(r *Reader) (interface{}, error) { return r.Fixed64() },
+			value: uint64(0),
+			err:   ErrTruncated,
+		},
+		"Double": {
+			buf:   []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x40},
+			read:  func(r *Reader) (interface{}, error) { return r.Double() },
+			value: 2.5,
+		},
+		"Fixed32": {
+			buf:   []byte{0x01, 0x02, 0x03, 0x04},
+			read:  func(r *Reader) (interface{}, error) { return r.Fixed32() },
+			value: uint32(0x04030201),
+		},
+		"Fixed32Truncated": {
+			buf:   []byte{0x01, 0x02, 0x03},
+			read:  func(r *Reader) (interface{}, error) { return r.Fixed32() },
+			value: uint32(0),
+			err:   ErrTruncated,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			v, err := c.read(NewReader(c.buf))
+
+			assert.Equal(t, c.err, err)
+			assert.Equal(t, c.value, v)
+		})
+	}
+}
+
+func TestReaderSkip(t *testing.T) {
+
+	cases := map[string]struct {
+		wt  WireType
+		buf []byte
+		pos int
+		err error
+	}{
+		"Varint":           {wt: WireVarint, buf: []byte{0xac, 0x02, 0x01}, pos: 2},
+		"Fixed64":          {wt: WireFixed64, buf: make([]byte, 9), pos: 8},
+		"Bytes":            {wt: WireBytes, buf: []byte{0x02, 'a', 'b', 0x01}, pos: 3},
+		"Fixed32":          {wt: WireFixed32, buf: make([]byte, 5), pos: 4},
+		"InvalidWireType":  {wt: 3, buf: []byte{0x01}, err: ErrInvalidWireType},
+		"TruncatedFixed64": {wt: WireFixed64, buf: make([]byte, 4), err: ErrTruncated},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			r := NewReader(c.buf)
+
+			assert.Equal(t, c.err, r.Skip(c.wt))
+			assert.Equal(t, c.pos, r.pos)
+		})
+	}
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+
+	w := NewWriter(64)
+	w.Varint(1, 300)
+	w.Int64(2, -1)
+	w.Double(3, -2.5)
+	w.BytesField(4, []byte{0x00, 0xff})
+	w.String(5, "mycenae")
+	w.Varint(536870911, 1)
+
+	expected := []struct {
+		field int
+		wt    WireType
+		value interface{}
+	}{
+		{1, WireVarint, uint64(300)},
+		{2, WireVarint, int64(-1)},
+		{3, WireFixed64, -2.5},
+		{4, WireBytes, []byte{0x00, 0xff}},
+		{5, WireBytes, "mycenae"},
+		{536870911, WireVarint, uint64(1)},
+	}
+
+	r := NewReader(w.Bytes())
+
+	for _, e := range expected {
+
+		field, wt, err := r.Next()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, e.field, field)
+		assert.Equal(t, e.wt, wt)
+
+		var value interface{}
+
+		switch e.value.(type) {
+		case uint64:
+			value, err = r.Varint()
+		case int64:
+			value, err = r.Int64()
+		case float64:
+			value, err = r.Double()
+		case []byte:
+			value, err = r.Bytes()
+		case string:
+			value, err = r.String()
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, e.value, value)
+	}
+
+	assert.True(t, r.Done())
+
+	w.Reset()
+	assert.Empty(t, w.Bytes())
+}
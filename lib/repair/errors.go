@@ -0,0 +1,39 @@
+package repair
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+const (
+	cPackage string = "repair"
+)
+
+func errBasic(function, message string, code int, e error) gobol.Error {
+	if e != nil {
+		return tserr.New(
+			e,
+			message,
+			cPackage,
+			function,
+			code,
+		)
+	}
+	return nil
+}
+
+func errBadRequest(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusBadRequest, errors.New(message))
+}
+
+func errConflict(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusConflict, errors.New(message))
+}
+
+func errNotFound(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusNotFound, errors.New(message))
+}
@@ -0,0 +1,311 @@
+package repair
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+)
+
+const (
+	cFuncStart            string = "Start"
+	cFuncCancel           string = "Cancel"
+	cFuncRun              string = "run"
+	cqlSelectDistinctIDs  string = `SELECT DISTINCT id FROM %s.%s`
+	cMetaTypeNumber       string = "meta"
+	cMetaTypeText         string = "metatext"
+	cMaxUnrecoverableIDs  int    = 100
+	jobStatusRunning      string = "running"
+	jobStatusFinished     string = "finished"
+	jobStatusCancelled    string = "cancelled"
+	jobStatusFailed       string = "failed"
+	cMsgJobAlreadyRunning string = "a metadata repair job is already running"
+	cMsgNoJob             string = "no metadata repair job was started"
+)
+
+// tables - the point tables scanned and the metadata type of their ids
+var tables = [][2]string{
+	{"ts_number_stamp", cMetaTypeNumber},
+	{"ts_text_stamp", cMetaTypeText},
+}
+
+// Job - the progress and the counters of a repair job
+type Job struct {
+	ID                  int64      `json:"id"`
+	Keyset              string     `json:"keyset,omitempty"`
+	Commit              bool       `json:"commit"`
+	Status              string     `json:"status"`
+	Error               string     `json:"error,omitempty"`
+	Started             time.Time  `json:"started"`
+	Finished            *time.Time `json:"finished,omitempty"`
+	Keyspace            string     `json:"keyspace"`
+	Table               string     `json:"table"`
+	Scanned             int64      `json:"scanned"`
+	Indexed             int64      `json:"indexed"`
+	Missing             int64      `json:"missing"`
+	Repaired            int64      `json:"repaired"`
+	Unrecoverable       int64      `json:"unrecoverable"`
+	Errors              int64      `json:"errors"`
+	UnrecoverableSample []string   `json:"unrecoverableSample,omitempty"`
+	cancelled           bool
+}
+
+// Start - starts a new repair job, only reporting the missing documents if commit is false
+func (s *Service) Start(keyset string, commit bool) (*Job, gobol.Error) {
+
+	if keyset != constants.StringsEmpty && !s.metaStorage.CheckKeyset(keyset) {
+		return nil, errNotFound(cFuncStart, "keyset not found")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.job != nil && s.job.Status == jobStatusRunning {
+		return nil, errConflict(cFuncStart, cMsgJobAlreadyRunning)
+	}
+
+	s.nextJobID++
+
+	s.job = &Job{
+		ID:      s.nextJobID,
+		Keyset:  keyset,
+		Commit:  commit,
+		Status:  jobStatusRunning,
+		Started: time.Now(),
+	}
+
+	go s.run(s.job)
+
+	job := *s.job
+
+	return &job, nil
+}
+
+// Status - returns a copy of the last job
+func (s *Service) Status() (*Job, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.job == nil {
+		return nil, false
+	}
+
+	job := *s.job
+	job.UnrecoverableSample = append([]string(nil), s.job.UnrecoverableSample...)
+
+	return &job, true
+}
+
+// Cancel - stops the running job
+func (s *Service) Cancel() (*Job, gobol.Error) {
+
+	s.mutex.Lock()
+
+	if s.job == nil || s.job.Status != jobStatusRunning {
+		s.mutex.Unlock()
+		return nil, errNotFound(cFuncCancel, "no metadata repair job is running")
+	}
+
+	s.job.cancelled = true
+
+	s.mutex.Unlock()
+
+	job, _ := s.Status()
+
+	return job, nil
+}
+
+// update - changes the job under the lock
+func (s *Service) update(f func(job *Job)) {
+
+	s.mutex.Lock()
+	f(s.job)
+	s.mutex.Unlock()
+}
+
+// finish - sets the final status of the job
+func (s *Service) finish(status string, err error) {
+
+	now := time.Now()
+	var final Job
+
+	s.update(func(job *Job) {
+		job.Status = status
+		job.Finished = &now
+		if err != nil {
+			job.Error = err.Error()
+		}
+		final = *job
+	})
+
+	if logh.InfoEnabled {
+		s.logger.Info().Str(constants.StringsFunc, cFuncRun).Msgf("metadata repair job %d %s: %d scanned, %d indexed, %d missing, %d repaired, %d unrecoverable, %d errors",
+			final.ID, status, final.Scanned, final.Indexed, final.Missing, final.Repaired, final.Unrecoverable, final.Errors)
+	}
+}
+
+// run - scans the distinct ids of all keyspace tables
+func (s *Service) run(job *Job) {
+
+	ttls := make([]int, 0, len(s.keyspaceTTLMap))
+	for ttl := range s.keyspaceTTLMap {
+		ttls = append(ttls, ttl)
+	}
+
+	sort.Ints(ttls)
+
+	if logh.InfoEnabled {
+		s.logger.Info().Str(constants.StringsFunc, cFuncRun).Msgf("metadata repair job %d started (keyset: %s, commit: %t)", job.ID, job.Keyset, job.Commit)
+	}
+
+	for _, ttl := range ttls {
+
+		keyspace := s.keyspaceTTLMap[ttl]
+
+		for _, table := range tables {
+
+			s.update(func(job *Job) {
+				job.Keyspace = keyspace
+				job.Table = table[0]
+			})
+
+			iter := s.session.Query(fmt.Sprintf(cqlSelectDistinctIDs, keyspace, table[0])).PageSize(s.conf.PageSize).Iter()
+
+			var id string
+			for iter.Scan(&id) {
+
+				s.mutex.Lock()
+				cancelled := job.cancelled
+				s.mutex.Unlock()
+
+				if cancelled {
+					iter.Close()
+					s.finish(jobStatusCancelled, nil)
+					return
+				}
+
+				s.check(job, id, table[1])
+			}
+
+			if err := iter.Close(); err != nil {
+				if logh.ErrorEnabled {
+					s.logger.Error().Str(constants.StringsFunc, cFuncRun).Str("keyspace", keyspace).Str("table", table[0]).Err(err).Msg("error scanning the table ids")
+				}
+				s.finish(jobStatusFailed, err)
+				return
+			}
+		}
+	}
+
+	s.finish(jobStatusFinished, nil)
+}
+
+// check - verifies if the tsid has a metadata document, rebuilding it from its mapping if commit is enabled
+func (s *Service) check(job *Job, id, tsType string) {
+
+	m, found, err := s.mapping(id)
+	if err != nil {
+		s.countError(id, err)
+		return
+	}
+
+	if found && job.Keyset != constants.StringsEmpty && m.Keyset != job.Keyset {
+		return
+	}
+
+	// the unmapped tsid may belong to any keyset, all of them are checked before applying the filter
+	keysets := s.metaStorage.ListKeysets()
+	if found {
+		keysets = []string{m.Keyset}
+	}
+
+	indexedKeyset, gerr := s.indexedKeyset(keysets, tsType, id)
+	if gerr != nil {
+		s.countError(id, gerr)
+		return
+	}
+
+	if indexedKeyset != constants.StringsEmpty && job.Keyset != constants.StringsEmpty && indexedKeyset != job.Keyset {
+		return
+	}
+
+	s.update(func(job *Job) {
+		job.Scanned++
+		if job.Scanned%int64(s.conf.LogInterval) == 0 && logh.InfoEnabled {
+			s.logger.Info().Str(constants.StringsFunc, cFuncRun).Msgf("metadata repair job %d progress: %d scanned (%s.%s)", job.ID, job.Scanned, job.Keyspace, job.Table)
+		}
+	})
+
+	if indexedKeyset != constants.StringsEmpty {
+		s.update(func(job *Job) { job.Indexed++ })
+		return
+	}
+
+	if !found {
+		s.update(func(job *Job) {
+			job.Missing++
+			job.Unrecoverable++
+			if len(job.UnrecoverableSample) < cMaxUnrecoverableIDs {
+				job.UnrecoverableSample = append(job.UnrecoverableSample, id)
+			}
+		})
+		return
+	}
+
+	s.update(func(job *Job) { job.Missing++ })
+
+	if !job.Commit {
+		return
+	}
+
+	keyset := m.Keyset
+	doc := &metadata.Metadata{
+		ID:       m.ID,
+		Metric:   m.Metric,
+		MetaType: m.MetaType,
+		TagKey:   m.TagKey,
+		TagValue: m.TagValue,
+	}
+
+	if gerr := s.metaStorage.AddDocument(keyset, doc); gerr != nil {
+		s.countError(id, gerr)
+		return
+	}
+
+	s.update(func(job *Job) { job.Repaired++ })
+}
+
+// indexedKeyset - returns the first keyset with the tsid document, empty if none has it
+func (s *Service) indexedKeyset(keysets []string, tsType, id string) (string, gobol.Error) {
+
+	for _, keyset := range keysets {
+
+		// the id cache would hide the documents lost by the storage
+		indexed, gerr := s.metaStorage.CheckStoredMetadata(keyset, tsType, id)
+		if gerr != nil {
+			return constants.StringsEmpty, gerr
+		}
+
+		if indexed {
+			return keyset, nil
+		}
+	}
+
+	return constants.StringsEmpty, nil
+}
+
+// countError - counts and logs an error
+func (s *Service) countError(id string, err error) {
+
+	s.update(func(job *Job) { job.Errors++ })
+
+	if logh.ErrorEnabled {
+		s.logger.Error().Str(constants.StringsFunc, cFuncRun).Str("tsid", id).Err(err).Msg("error repairing the timeseries metadata")
+	}
+}
@@ -0,0 +1,97 @@
+package repair
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Rebuilds the lost metadata documents using the tsid to tags mapping written at ingest time.
+//
+
+const (
+	cqlCreateMappingTable = `CREATE TABLE IF NOT EXISTS %s.ts_tsid_tags (
+		id text PRIMARY KEY, keyset text, type text, metric text, tag_key list<text>, tag_value list<text>, creation_date timestamp)`
+
+	cqlInsertMapping = `INSERT INTO %s.ts_tsid_tags (id, keyset, type, metric, tag_key, tag_value, creation_date) VALUES (?, ?, ?, ?, ?, ?, toTimestamp(now()))`
+	cqlSelectMapping = `SELECT keyset, type, metric, tag_key, tag_value FROM %s.ts_tsid_tags WHERE id = ?`
+
+	cDefaultPageSize    int = 1000
+	cDefaultLogInterval int = 100000
+)
+
+// Service - writes the tsid mapping and runs the repair jobs
+type Service struct {
+	session        *gocql.Session
+	metaStorage    *metadata.Storage
+	keyspace       string
+	keyspaceTTLMap map[int]string
+	conf           *structs.MetadataRepairConfiguration
+	logger         *logh.ContextualLogger
+	mutex          sync.Mutex
+	job            *Job
+	nextJobID      int64
+}
+
+// New - creates the service and the mapping table (nil is returned if it is disabled)
+func New(conf *structs.MetadataRepairConfiguration, session *gocql.Session, metaStorage *metadata.Storage, keyspace string, keyspaceTTLMap map[int]string) (*Service, error) {
+
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	if conf.PageSize <= 0 {
+		conf.PageSize = cDefaultPageSize
+	}
+
+	if conf.LogInterval <= 0 {
+		conf.LogInterval = cDefaultLogInterval
+	}
+
+	if err := session.Query(fmt.Sprintf(cqlCreateMappingTable, keyspace)).Exec(); err != nil {
+		return nil, fmt.Errorf("error creating the tsid mapping table: %s", err.Error())
+	}
+
+	return &Service{
+		session:        session,
+		metaStorage:    metaStorage,
+		keyspace:       keyspace,
+		keyspaceTTLMap: keyspaceTTLMap,
+		conf:           conf,
+		logger:         logh.CreateContextualLogger(constants.StringsPKG, cPackage),
+	}, nil
+}
+
+// SaveMapping - stores the tags of a new timeseries
+func (s *Service) SaveMapping(keyset string, m *metadata.Metadata) error {
+
+	if s == nil {
+		return nil
+	}
+
+	return s.session.Query(fmt.Sprintf(cqlInsertMapping, s.keyspace), m.ID, keyset, m.MetaType, m.Metric, m.TagKey, m.TagValue).Exec()
+}
+
+// mapping - returns the stored metadata of a tsid
+func (s *Service) mapping(id string) (*metadata.Metadata, bool, error) {
+
+	m := &metadata.Metadata{ID: id}
+
+	err := s.session.Query(fmt.Sprintf(cqlSelectMapping, s.keyspace), id).Scan(&m.Keyset, &m.MetaType, &m.Metric, &m.TagKey, &m.TagValue)
+	if err == gocql.ErrNotFound {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return m, true, nil
+}
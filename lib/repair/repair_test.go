@@ -0,0 +1,171 @@
+package repair
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// newTestService - a service without keyspaces, so the jobs finish without scanning
+func newTestService() *Service {
+
+	return &Service{
+		keyspaceTTLMap: map[int]string{},
+		conf:           &structs.MetadataRepairConfiguration{Enabled: true, PageSize: 10, LogInterval: 10},
+	}
+}
+
+func TestNewDisabled(t *testing.T) {
+
+	s, err := New(&structs.MetadataRepairConfiguration{}, nil, nil, "mycenae", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	assert.NoError(t, s.SaveMapping("ks", nil), "nothing is stored when disabled")
+}
+
+func TestJobLifecycle(t *testing.T) {
+
+	s := newTestService()
+
+	_, ok := s.Status()
+	assert.False(t, ok, "no job was started")
+
+	_, gerr := s.Cancel()
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusNotFound, gerr.StatusCode())
+	}
+
+	job, gerr := s.Start("", true)
+	assert.NoError(t, gerr)
+	assert.Equal(t, int64(1), job.ID)
+	assert.True(t, job.Commit)
+
+	assert.Eventually(t, func() bool {
+		job, _ := s.Status()
+		return job.Status == jobStatusFinished
+	}, time.Second, 10*time.Millisecond)
+
+	job, _ = s.Status()
+	assert.NotNil(t, job.Finished)
+	assert.Zero(t, job.Scanned)
+
+	_, gerr = s.Cancel()
+	assert.Error(t, gerr, "the job is not running anymore")
+
+	job, gerr = s.Start("", false)
+	assert.NoError(t, gerr)
+	assert.Equal(t, int64(2), job.ID, "a new job is started after the last one finished")
+}
+
+func TestStartWhileRunning(t *testing.T) {
+
+	s := newTestService()
+	s.job = &Job{ID: 7, Status: jobStatusRunning, UnrecoverableSample: []string{"a"}}
+
+	_, gerr := s.Start("", false)
+	if assert.Error(t, gerr) {
+		assert.Equal(t, http.StatusConflict, gerr.StatusCode())
+		assert.Equal(t, cMsgJobAlreadyRunning, gerr.Message())
+	}
+
+	job, gerr := s.Cancel()
+	assert.NoError(t, gerr)
+	assert.Equal(t, int64(7), job.ID)
+	assert.True(t, s.job.cancelled, "the scan stops on the next id")
+
+	job.UnrecoverableSample[0] = "b"
+	assert.Equal(t, "a", s.job.UnrecoverableSample[0], "the status is a copy")
+}
+
+func TestRepairEndpoints(t *testing.T) {
+
+	var disabled *Service
+
+	router := httprouter.New()
+	router.POST("/disabled", disabled.StartRepair)
+	router.GET("/disabled", disabled.RepairStatus)
+	router.DELETE("/disabled", disabled.CancelRepair)
+
+	for _, method := range []string{http.MethodPost, http.MethodGet, http.MethodDelete} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/disabled", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+	}
+
+	s := newTestService()
+	router.POST("/repair", s.StartRepair)
+	router.GET("/repair", s.RepairStatus)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/repair", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/repair?commit=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/repair?commit=true", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	job := Job{}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job)) {
+		assert.True(t, job.Commit)
+		assert.Equal(t, jobStatusRunning, job.Status)
+	}
+}
+
+// storedBackend - a metadata backend storing the ids of each keyset
+type storedBackend struct {
+	metadata.Backend
+	ids map[string][]string
+}
+
+func (b *storedBackend) ListKeysets() []string {
+	return []string{"ks", "other"}
+}
+
+func (b *storedBackend) CheckStoredMetadata(collection, tsType, tsid string) (bool, gobol.Error) {
+
+	for _, id := range b.ids[collection] {
+		if id == tsid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func TestIndexedKeyset(t *testing.T) {
+
+	s := newTestService()
+	s.metaStorage = &metadata.Storage{Backend: &storedBackend{ids: map[string][]string{"ks": {"a"}, "other": {"b"}}}}
+
+	testCases := []struct {
+		keysets  []string
+		id       string
+		expected string
+	}{
+		{[]string{"ks"}, "a", "ks"},
+		{[]string{"ks"}, "b", ""},
+		{[]string{"ks", "other"}, "b", "other"},
+		{[]string{"ks", "other"}, "c", ""},
+	}
+
+	for _, test := range testCases {
+
+		keyset, gerr := s.indexedKeyset(test.keysets, "meta", test.id)
+		assert.Nil(t, gerr)
+		assert.Equal(t, test.expected, keyset, "%v %s", test.keysets, test.id)
+	}
+}
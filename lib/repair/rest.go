@@ -0,0 +1,86 @@
+package repair
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// Admin endpoints to start, follow and cancel the metadata repair job.
+//
+
+const (
+	cFuncStartRepair   string = "StartRepair"
+	cFuncRepairStatus  string = "RepairStatus"
+	cFuncCancelRepair  string = "CancelRepair"
+	cMsgRepairDisabled string = "the metadata repair is disabled"
+	cMsgInvalidCommit  string = "query param \"commit\" should be a boolean"
+)
+
+// StartRepair - starts the metadata repair job (POST /admin/metadata/repair?keyset=x&commit=true)
+func (s *Service) StartRepair(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if s == nil {
+		rip.Fail(w, errNotFound(cFuncStartRepair, cMsgRepairDisabled))
+		return
+	}
+
+	q := r.URL.Query()
+
+	commit := false
+	if commitStr := q.Get("commit"); commitStr != constants.StringsEmpty {
+		var err error
+		commit, err = strconv.ParseBool(commitStr)
+		if err != nil {
+			rip.Fail(w, errBadRequest(cFuncStartRepair, cMsgInvalidCommit))
+			return
+		}
+	}
+
+	job, gerr := s.Start(q.Get(constants.StringsKeyset), commit)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusAccepted, job)
+}
+
+// RepairStatus - returns the progress of the last metadata repair job
+func (s *Service) RepairStatus(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if s == nil {
+		rip.Fail(w, errNotFound(cFuncRepairStatus, cMsgRepairDisabled))
+		return
+	}
+
+	job, ok := s.Status()
+	if !ok {
+		rip.Fail(w, errNotFound(cFuncRepairStatus, cMsgNoJob))
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, job)
+}
+
+// CancelRepair - cancels the running metadata repair job
+func (s *Service) CancelRepair(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if s == nil {
+		rip.Fail(w, errNotFound(cFuncCancelRepair, cMsgRepairDisabled))
+		return
+	}
+
+	job, gerr := s.Cancel()
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, job)
+}
@@ -15,13 +15,17 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol/rip"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/config"
 	"github.com/uol/mycenae/lib/keyset"
 	"github.com/uol/mycenae/lib/keyspace"
 	"github.com/uol/mycenae/lib/memcached"
 	"github.com/uol/mycenae/lib/plot"
+	"github.com/uol/mycenae/lib/repair"
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tlsconfig"
+	"github.com/uol/mycenae/lib/validation"
 	tlmanager "github.com/uol/timelinemanager"
 )
 
@@ -35,6 +39,9 @@ func New(
 	set structs.SettingsHTTP,
 	ks *keyset.Manager,
 	telnetManager *telnetmgr.Manager,
+	validationService *validation.Service,
+	repairService *repair.Service,
+	authService *auth.Service,
 ) *REST {
 
 	return &REST{
@@ -48,6 +55,9 @@ func New(
 		settings:        set,
 		keyset:          ks,
 		telnetManager:   telnetManager,
+		validation:      validationService,
+		repair:          repairService,
+		auth:            authService,
 	}
 }
 
@@ -65,6 +75,9 @@ type REST struct {
 	server          *http.Server
 	keyset          *keyset.Manager
 	telnetManager   *telnetmgr.Manager
+	validation      *validation.Service
+	repair          *repair.Service
+	auth            *auth.Service
 }
 
 // Start asynchronously the handler of the APIs
@@ -79,67 +92,94 @@ func (trest *REST) asyncStart() {
 	rip.SetLogger(trest.settings.ForceErrorAsDebug)
 
 	router := rip.NewCustomRouter()
+	a := trest.auth
 	//NODE TO NODE
-	router.HEAD("/node/connections", trest.telnetManager.CountConnections)
-	router.HEAD("/node/halt/balancing", trest.telnetManager.HaltTelnetBalancingProcess)
+	router.HEAD("/node/connections", a.HandleGlobal(auth.OperationAdmin, trest.telnetManager.CountConnections))
+	router.HEAD("/node/halt/balancing", a.HandleGlobal(auth.OperationAdmin, trest.telnetManager.HaltTelnetBalancingProcess))
 	//PROBE
 	router.GET("/probe", trest.check)
 	//EXPRESSION
-	router.GET("/expression/check", trest.reader.ExpressionCheckGET)
-	router.POST("/expression/check", trest.reader.ExpressionCheckPOST)
-	router.POST("/expression/compile", trest.reader.ExpressionCompile)
-	router.GET("/expression/parse", trest.reader.ExpressionParseGET)
-	router.POST("/expression/parse", trest.reader.ExpressionParsePOST)
-	router.GET("/keysets/:keyset/expression/expand", trest.reader.ExpressionExpandGET)
-	router.POST("/keysets/:keyset/expression/expand", trest.reader.ExpressionExpandPOST)
+	router.GET("/expression/check", a.HandleAny(auth.OperationRead, trest.reader.ExpressionCheckGET))
+	router.POST("/expression/check", a.HandleAny(auth.OperationRead, trest.reader.ExpressionCheckPOST))
+	router.POST("/expression/compile", a.HandleAny(auth.OperationRead, trest.reader.ExpressionCompile))
+	router.GET("/expression/parse", a.HandleAny(auth.OperationRead, trest.reader.ExpressionParseGET))
+	router.POST("/expression/parse", a.HandleAny(auth.OperationRead, trest.reader.ExpressionParsePOST))
+	router.GET("/keysets/:keyset/expression/expand", a.Handle(auth.OperationRead, trest.reader.ExpressionExpandGET))
+	router.POST("/keysets/:keyset/expression/expand", a.Handle(auth.OperationRead, trest.reader.ExpressionExpandPOST))
 	//NUMBER
-	router.GET("/keysets/:keyset/tags", trest.reader.ListTagsNumber)
-	router.GET("/keysets/:keyset/metrics", trest.reader.ListMetricsNumber)
-	router.POST("/keysets/:keyset/meta", trest.reader.ListMetaNumber)
-	router.GET("/keysets/:keyset/values", trest.reader.ListMetaNumber)
-	router.GET("/keysets/:keyset/metric/tag/keys", trest.reader.ListNumberTagKeysByMetric)
-	router.GET("/keysets/:keyset/metric/tag/values", trest.reader.ListNumberTagValuesByMetric)
+	router.GET("/keysets/:keyset/tags", a.Handle(auth.OperationRead, trest.reader.ListTagsNumber))
+	router.GET("/keysets/:keyset/metrics", a.Handle(auth.OperationRead, trest.reader.ListMetricsNumber))
+	router.POST("/keysets/:keyset/meta", a.Handle(auth.OperationRead, trest.reader.ListMetaNumber))
+	router.GET("/keysets/:keyset/values", a.Handle(auth.OperationRead, trest.reader.ListMetaNumber))
+	router.GET("/keysets/:keyset/metric/tag/keys", a.Handle(auth.OperationRead, trest.reader.ListNumberTagKeysByMetric))
+	router.GET("/keysets/:keyset/metric/tag/values", a.Handle(auth.OperationRead, trest.reader.ListNumberTagValuesByMetric))
+	router.GET("/keysets/:keyset/cardinality", a.Handle(auth.OperationRead, trest.reader.ListCardinality))
 	//TEXT
-	router.GET("/keysets/:keyset/text/tags", trest.reader.ListTagsText)
-	router.GET("/keysets/:keyset/text/metrics", trest.reader.ListMetricsText)
-	router.POST("/keysets/:keyset/text/meta", trest.reader.ListMetaText)
-	router.GET("/keysets/:keyset/text/tag/keys", trest.reader.ListTextTagKeysByMetric)
-	router.GET("/keysets/:keyset/text/tag/values", trest.reader.ListTextTagValuesByMetric)
+	router.GET("/keysets/:keyset/text/tags", a.Handle(auth.OperationRead, trest.reader.ListTagsText))
+	router.GET("/keysets/:keyset/text/metrics", a.Handle(auth.OperationRead, trest.reader.ListMetricsText))
+	router.POST("/keysets/:keyset/text/meta", a.Handle(auth.OperationRead, trest.reader.ListMetaText))
+	router.GET("/keysets/:keyset/text/tag/keys", a.Handle(auth.OperationRead, trest.reader.ListTextTagKeysByMetric))
+	router.GET("/keysets/:keyset/text/tag/values", a.Handle(auth.OperationRead, trest.reader.ListTextTagValuesByMetric))
 	//KEYSPACE
-	router.GET("/datacenters", trest.kspace.ListDC)
-	router.HEAD("/keyspaces/:keyspace", trest.kspace.Check)
-	router.POST("/keyspaces/:keyspace", trest.kspace.Create)
-	router.PUT("/keyspaces/:keyspace", trest.kspace.Update)
-	router.GET("/keyspaces", trest.kspace.GetAll)
+	router.GET("/datacenters", a.HandleAny(auth.OperationRead, trest.kspace.ListDC))
+	router.HEAD("/keyspaces/:keyspace", a.HandleAny(auth.OperationRead, trest.kspace.Check))
+	router.POST("/keyspaces/:keyspace", a.HandleGlobal(auth.OperationAdmin, trest.kspace.Create))
+	router.PUT("/keyspaces/:keyspace", a.HandleGlobal(auth.OperationAdmin, trest.kspace.Update))
+	router.GET("/keyspaces", a.HandleAny(auth.OperationRead, trest.kspace.GetAll))
 	//WRITE
-	router.POST("/api/put", trest.writer.HandleNumber)
-	router.PUT("/api/put", trest.writer.HandleNumber)
-	router.POST("/api/text/put", trest.writer.HandleText)
+	router.POST("/api/put", a.HandleAny(auth.OperationWrite, trest.writer.HandleNumber))
+	router.PUT("/api/put", a.HandleAny(auth.OperationWrite, trest.writer.HandleNumber))
+	router.POST("/api/text/put", a.HandleAny(auth.OperationWrite, trest.writer.HandleText))
+	//PROMETHEUS
+	router.POST("/api/prom/write", a.HandleAny(auth.OperationWrite, trest.writer.HandlePrometheusWrite))
+	router.POST("/keysets/:keyset/api/prom/read", a.Handle(auth.OperationRead, trest.reader.PrometheusRead))
+	//OPENTELEMETRY
+	router.POST("/v1/metrics", a.HandleAny(auth.OperationWrite, trest.writer.HandleOTLPMetrics))
+	//INFLUXDB
+	router.POST("/write", a.HandleAny(auth.OperationWrite, trest.writer.HandleInfluxWrite))
 	//OPENTSDB
-	router.POST("/keysets/:keyset/api/query", trest.reader.Query)
-	router.GET("/keysets/:keyset/api/suggest", trest.reader.Suggest)
-	router.GET("/keysets/:keyset/api/search/lookup", trest.reader.Lookup)
-	router.GET("/keysets/:keyset/api/aggregators", config.Aggregators)
-	router.GET("/keysets/:keyset/api/config/filters", config.Filters)
+	router.POST("/keysets/:keyset/api/query", a.Handle(auth.OperationRead, trest.reader.Query))
+	router.GET("/keysets/:keyset/api/suggest", a.Handle(auth.OperationRead, trest.reader.Suggest))
+	router.GET("/keysets/:keyset/api/search/lookup", a.Handle(auth.OperationRead, trest.reader.Lookup))
+	router.GET("/keysets/:keyset/api/aggregators", a.Handle(auth.OperationRead, config.Aggregators))
+	router.GET("/keysets/:keyset/api/config/filters", a.Handle(auth.OperationRead, config.Filters))
 	//HYBRIDS
-	router.POST("/keysets/:keyset/query/expression", trest.reader.ExpressionQueryPOST)
-	router.GET("/keysets/:keyset/query/expression", trest.reader.ExpressionQueryGET)
+	router.POST("/keysets/:keyset/query/expression", a.Handle(auth.OperationRead, trest.reader.ExpressionQueryPOST))
+	router.GET("/keysets/:keyset/query/expression", a.Handle(auth.OperationRead, trest.reader.ExpressionQueryGET))
 	//RAW POINTS API
-	router.POST("/api/query/raw", trest.reader.RawDataQuery)
+	router.POST("/api/query/raw", a.HandleAny(auth.OperationRead, trest.reader.RawDataQuery))
 	//KEYSETS
-	router.POST("/keysets/:keyset", trest.keyset.CreateKeyset)
-	router.HEAD("/keysets/:keyset", trest.keyset.Check)
-	router.DELETE("/keysets/:keyset", trest.keyset.DeleteKeyset)
-	router.GET("/keysets", trest.keyset.GetKeysets)
+	router.POST("/keysets/:keyset", a.HandleGlobal(auth.OperationAdmin, trest.keyset.CreateKeyset))
+	router.HEAD("/keysets/:keyset", a.Handle(auth.OperationRead, trest.keyset.Check))
+	router.DELETE("/keysets/:keyset", a.HandleGlobal(auth.OperationAdmin, trest.keyset.DeleteKeyset))
+	router.GET("/keysets", a.HandleAny(auth.OperationRead, trest.keyset.GetKeysets))
 	//DELETE
-	router.POST("/keysets/:keyset/delete/meta", trest.reader.DeleteNumberTS)
-	router.POST("/keysets/:keyset/delete/text/meta", trest.reader.DeleteTextTS)
+	router.POST("/keysets/:keyset/delete/meta", a.Handle(auth.OperationDelete, trest.reader.DeleteNumberTS))
+	router.POST("/keysets/:keyset/delete/text/meta", a.Handle(auth.OperationDelete, trest.reader.DeleteTextTS))
+	router.POST("/keysets/:keyset/delete/points", a.Handle(auth.OperationDelete, trest.reader.DeleteNumberPoints))
+	router.POST("/keysets/:keyset/delete/text/points", a.Handle(auth.OperationDelete, trest.reader.DeleteTextPoints))
+	router.GET("/jobs/:id", a.HandleAny(auth.OperationDelete, trest.reader.GetDeleteJobREST))
+	router.DELETE("/jobs/:id", a.HandleAny(auth.OperationDelete, trest.reader.CancelDeleteJobREST))
 	//DEPRECATED
-	router.POST("/keysets/:keyset/points", trest.reader.ListPoints)
+	router.POST("/keysets/:keyset/points", a.Handle(auth.OperationRead, trest.reader.ListPoints))
 	//ADMINISTRATIVE
-	router.POST("/admin/free-os-memory", trest.freeOSMemory)
-	router.POST("/admin/set-gc-percent", trest.setGCPercent)
-	router.GET("/admin/read-gc-stats", trest.readGCStats)
+	router.POST("/admin/free-os-memory", a.HandleGlobal(auth.OperationAdmin, trest.freeOSMemory))
+	router.POST("/admin/set-gc-percent", a.HandleGlobal(auth.OperationAdmin, trest.setGCPercent))
+	router.GET("/admin/read-gc-stats", a.HandleGlobal(auth.OperationAdmin, trest.readGCStats))
+	router.GET("/admin/keysets/:keyset/limits", a.HandleGlobal(auth.OperationAdmin, trest.validation.GetKeysetLimits))
+	router.PUT("/admin/keysets/:keyset/limits", a.HandleGlobal(auth.OperationAdmin, trest.validation.SetKeysetLimits))
+	router.DELETE("/admin/keysets/:keyset/limits", a.HandleGlobal(auth.OperationAdmin, trest.validation.DeleteKeysetLimits))
+	router.GET("/admin/keysets/:keyset/policy", a.HandleGlobal(auth.OperationAdmin, trest.validation.GetKeysetPolicy))
+	router.PUT("/admin/keysets/:keyset/policy", a.HandleGlobal(auth.OperationAdmin, trest.validation.SetKeysetPolicy))
+	router.DELETE("/admin/keysets/:keyset/policy", a.HandleGlobal(auth.OperationAdmin, trest.validation.DeleteKeysetPolicy))
+	router.POST("/admin/metadata/repair", a.HandleGlobal(auth.OperationAdmin, trest.repair.StartRepair))
+	router.GET("/admin/metadata/repair", a.HandleGlobal(auth.OperationAdmin, trest.repair.RepairStatus))
+	router.DELETE("/admin/metadata/repair", a.HandleGlobal(auth.OperationAdmin, trest.repair.CancelRepair))
+	//AUTHENTICATION
+	router.POST("/admin/tokens", a.HandleGlobal(auth.OperationAdmin, trest.auth.CreateTokenREST))
+	router.GET("/admin/tokens", a.HandleGlobal(auth.OperationAdmin, trest.auth.ListTokensREST))
+	router.GET("/admin/tokens/:id", a.HandleGlobal(auth.OperationAdmin, trest.auth.GetTokenREST))
+	router.DELETE("/admin/tokens/:id", a.HandleGlobal(auth.OperationAdmin, trest.auth.DeleteTokenREST))
 
 	if trest.settings.EnableProfiling {
 
@@ -147,7 +187,9 @@ func (trest *REST) asyncStart() {
 			trest.logger.Warn().Msg("WARNING - http profiling is enabled!!!")
 		}
 
-		router.Handler(http.MethodGet, "/debug/pprof/:item", http.DefaultServeMux)
+		router.GET("/debug/pprof/:item", a.HandleGlobal(auth.OperationAdmin, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			http.DefaultServeMux.ServeHTTP(w, r)
+		}))
 	}
 
 	var compositeHTTPHandlers http.Handler
@@ -160,6 +202,8 @@ func (trest *REST) asyncStart() {
 		compositeHTTPHandlers = logHandler
 	}
 
+	tlsConfig, err := tlsconfig.New(&trest.settings.TLS)
+
 	trest.server = &http.Server{
 		Addr:              fmt.Sprintf("%s:%d", trest.settings.Bind, trest.settings.Port),
 		Handler:           compositeHTTPHandlers,
@@ -167,9 +211,22 @@ func (trest *REST) asyncStart() {
 		ReadHeaderTimeout: 60 * time.Second,
 		WriteTimeout:      60 * time.Second,
 		MaxHeaderBytes:    10485760,
+		TLSConfig:         tlsConfig,
+	}
+
+	if err != nil {
+		if logh.FatalEnabled {
+			trest.logger.Fatal().Err(err).Msg("error loading the http tls configuration")
+		}
+		return
+	}
+
+	if tlsConfig != nil {
+		err = trest.server.ListenAndServeTLS(constants.StringsEmpty, constants.StringsEmpty)
+	} else {
+		err = trest.server.ListenAndServe()
 	}
 
-	err := trest.server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		if logh.ErrorEnabled {
 			trest.logger.Error().Err(err).Send()
@@ -16,12 +16,25 @@ type SettingsHTTP struct {
 	EnableProfiling   bool
 	ForceErrorAsDebug bool
 	AllowCORS         bool
+	TLS               TLSConfiguration
 }
 
 type SettingsUDP struct {
 	Port             int
 	SendStatsTimeout string
 	ReadBuffer       int
+	DefaultKeyset    string
+	DefaultTTL       int
+	TLSPort          int
+	TLS              TLSConfiguration
+}
+
+// TLSConfiguration - the listener certificate and the client certificate verification (disabled without a certificate)
+type TLSConfiguration struct {
+	CertFile          string
+	KeyFile           string
+	ClientCAFile      string
+	RequireClientCert bool
 }
 
 type LoggerSettings struct {
@@ -29,6 +42,40 @@ type LoggerSettings struct {
 	Format logh.Format
 }
 
+// KeysetLimits - the ingestion limits of a keyset (zero means unlimited)
+type KeysetLimits struct {
+	PointsPerSecond  float64 `json:"pointsPerSecond"`
+	Burst            int     `json:"burst"`
+	NewSeriesPerHour int64   `json:"newSeriesPerHour"`
+	MaxActiveSeries  int64   `json:"maxActiveSeries"`
+}
+
+// LimitsConfiguration - the per keyset ingestion limits
+type LimitsConfiguration struct {
+	Enabled                     bool
+	ActiveSeriesRefreshInterval funks.Duration
+	Default                     KeysetLimits
+	Keysets                     map[string]KeysetLimits
+}
+
+// KeysetPolicy - the tag and metric rules of a keyset (empty or zero means no rule)
+type KeysetPolicy struct {
+	RequiredTags      []string          `json:"requiredTags"`
+	ForbiddenTagKeys  []string          `json:"forbiddenTagKeys"`
+	MaxTags           int               `json:"maxTags"`
+	MaxTagValueLength int               `json:"maxTagValueLength"`
+	AllowedTagValues  map[string]string `json:"allowedTagValues"`
+	DeniedTagValues   map[string]string `json:"deniedTagValues"`
+	MetricPrefixes    []string          `json:"metricPrefixes"`
+}
+
+// PoliciesConfiguration - the per keyset tag and metric policies
+type PoliciesConfiguration struct {
+	Enabled bool
+	Default KeysetPolicy
+	Keysets map[string]KeysetPolicy
+}
+
 // ValidationConfiguration - validation configurations
 type ValidationConfiguration struct {
 	MaxTextValueSize int
@@ -37,6 +84,8 @@ type ValidationConfiguration struct {
 	KeysetNameRegexp string
 	DefaultTTL       int
 	MaxPropertySize  int
+	Limits           LimitsConfiguration
+	Policies         PoliciesConfiguration
 }
 
 // TelnetManagerConfiguration - the main and shared configuration for all telnet servers
@@ -63,6 +112,114 @@ type TelnetServerConfiguration struct {
 	SilenceLogs                    bool
 	RemoveMultipleConnsRestriction bool
 	MultipleConnsAllowedHosts      []string
+	DefaultKeyset                  string
+	DefaultTTL                     int
+	Templates                      []string
+	MaxPendingBuffers              int
+	TLS                            TLSConfiguration
+	ErrorResponses                 bool
+	MaxErrorResponsesPerSecond     float64
+}
+
+// InfluxConfiguration - the influxdb line protocol http endpoint configuration
+type InfluxConfiguration struct {
+	DefaultKeyset string
+	DefaultTTL    int
+}
+
+// PrometheusConfiguration - the prometheus remote storage configuration
+type PrometheusConfiguration struct {
+	KeysetLabel   string
+	TTLLabel      string
+	DefaultKeyset string
+	DefaultTTL    int
+}
+
+// OTLPConfiguration - the opentelemetry metrics receiver configuration
+type OTLPConfiguration struct {
+	KeysetAttribute    string
+	TTLAttribute       string
+	DefaultKeyset      string
+	DefaultTTL         int
+	CumulativeToDelta  bool
+	DeltaExpiration    funks.Duration
+	ResourceAttributes []string
+}
+
+// WriteBatchConfiguration - the scylla write batcher configuration
+type WriteBatchConfiguration struct {
+	Enabled          bool
+	MaxBatchSize     int
+	MaxPendingPoints int
+	FlushInterval    funks.Duration
+	Workers          int
+	MaxRetries       int
+	RetryInterval    funks.Duration
+}
+
+// WriteAheadQueueConfiguration - the on disk queue used when scylla fails or the collector is full
+type WriteAheadQueueConfiguration struct {
+	Enabled       bool
+	Directory     string
+	SegmentSize   int64
+	MaxSize       int64
+	RetryInterval funks.Duration
+	SyncPolicy    string
+}
+
+// MetadataRepairConfiguration - the tsid to tags mapping written at ingest time and the metadata repair job
+type MetadataRepairConfiguration struct {
+	Enabled     bool
+	PageSize    int
+	LogInterval int
+}
+
+// LastSeenConfiguration - the timeseries last write tracking and the stale metadata janitor
+type LastSeenConfiguration struct {
+	Enabled          bool
+	SampleInterval   funks.Duration
+	Janitor          bool
+	JanitorInterval  funks.Duration
+	JanitorBatchSize int
+}
+
+// AuthConfiguration - the token authentication of the http, telnet and udp endpoints
+type AuthConfiguration struct {
+	Enabled         bool
+	AdminToken      string
+	UDPSecret       string
+	RefreshInterval funks.Duration
+}
+
+// DeleteJobsConfiguration - the background jobs running the committed timeseries and points deletions
+type DeleteJobsConfiguration struct {
+	PageSize         int
+	ProgressInterval int
+	CheckInterval    funks.Duration
+	StaleTimeout     funks.Duration
+	Retention        funks.Duration
+}
+
+// KeysetQueueConfiguration - overrides the collector queue configuration of a keyset
+type KeysetQueueConfiguration struct {
+	QueueSize      int
+	Weight         int
+	MaxConcurrency int
+}
+
+// FairQueueConfiguration - the collector per keyset queues (the weight is the number of points taken in a row)
+type FairQueueConfiguration struct {
+	QueueSize      int
+	Weight         int
+	MaxConcurrency int
+	Keysets        map[string]KeysetQueueConfiguration
+}
+
+// QueryCacheConfiguration - the query result cache configuration
+type QueryCacheConfiguration struct {
+	Enabled    bool
+	MaxEntries int
+	TTL        funks.Duration
 }
 
 type Settings struct {
@@ -82,6 +239,10 @@ type Settings struct {
 	UDPserver                          SettingsUDP
 	TELNETserver                       []TelnetServerConfiguration
 	NetdataServer                      []TelnetServerConfiguration
+	InfluxServer                       []TelnetServerConfiguration
+	InfluxUDPserver                    SettingsUDP
+	GraphiteServer                     []TelnetServerConfiguration
+	GraphitePickleServer               []TelnetServerConfiguration
 	MaxAllowedTTL                      int
 	DefaultKeysets                     []string
 	BlacklistedKeysets                 []string
@@ -94,4 +255,15 @@ type Settings struct {
 	Stats                              tlmanager.Configuration
 	MetadataSettings                   metadata.Settings
 	Validation                         ValidationConfiguration
+	Prometheus                         PrometheusConfiguration
+	Influx                             InfluxConfiguration
+	OTLP                               OTLPConfiguration
+	QueryCache                         QueryCacheConfiguration
+	WriteBatch                         WriteBatchConfiguration
+	WriteAheadQueue                    WriteAheadQueueConfiguration
+	FairQueue                          FairQueueConfiguration
+	MetadataRepair                     MetadataRepairConfiguration
+	LastSeen                           LastSeenConfiguration
+	Auth                               AuthConfiguration
+	DeleteJobs                         DeleteJobsConfiguration
 }
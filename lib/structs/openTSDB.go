@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/uol/gobol"
 
@@ -29,6 +30,8 @@ type TSDBquery struct {
 	Order       []string          `json:"order,omitempty"`
 	FilterValue string            `json:"filterValue,omitempty"`
 	Filters     []TSDBfilter      `json:"filters,omitempty"`
+	UseCalendar bool              `json:"useCalendar,omitempty"`
+	Timezone    string            `json:"timezone,omitempty"`
 }
 
 type TSDBqueryPayload struct {
@@ -39,6 +42,9 @@ type TSDBqueryPayload struct {
 	ShowTSUIDs   bool        `json:"showTSUIDs"`
 	MsResolution bool        `json:"msResolution"`
 	EstimateSize bool        `json:"estimateSize"`
+	UseCalendar  bool        `json:"useCalendar,omitempty"`
+	Timezone     string      `json:"timezone,omitempty"`
+	ActiveSince  string      `json:"activeSince,omitempty"`
 }
 
 func (query TSDBqueryPayload) Validate() gobol.Error {
@@ -53,6 +59,10 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 		return errValidation(errors.New("At least one query should be present"))
 	}
 
+	if err := query.checkTimezone(query.Timezone); err != nil {
+		return err
+	}
+
 	for i, q := range query.Queries {
 
 		if err := query.checkField("metric", q.Metric); err != nil {
@@ -63,6 +73,15 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 			return err
 		}
 
+		if err := query.checkTimezone(q.Timezone); err != nil {
+			return err
+		}
+
+		// the fixed intervals are not aligned to a timezone, only the calendar ones
+		if (q.Timezone != constants.StringsEmpty || query.Timezone != constants.StringsEmpty) && !q.UseCalendar && !query.UseCalendar {
+			return errValidation(errors.New("the timezone requires the calendar option (useCalendar)"))
+		}
+
 		if q.Downsample != constants.StringsEmpty {
 
 			ds := strings.Split(q.Downsample, "-")
@@ -85,6 +104,16 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 				}
 			}
 
+			if len(ds) > 3 {
+				if err := query.checkMaxGap(ds[2], ds[3]); err != nil {
+					return err
+				}
+			}
+
+			if len(ds) > 4 {
+				return errValidation(errors.New("invalid downsample format"))
+			}
+
 		}
 
 		if q.Rate {
@@ -316,6 +345,32 @@ func (query TSDBqueryPayload) checkFiller(DSf string) gobol.Error {
 	return nil
 }
 
+func (query TSDBqueryPayload) checkMaxGap(DSf, gap string) gobol.Error {
+
+	if !IsInterpolatingFill(DSf) {
+		return errFiller("max gap is only allowed with the previous and linear fill values")
+	}
+
+	if _, err := DurationToMs(gap); err != nil {
+		return errFiller(fmt.Sprintf("Invalid max gap: %s", err.Error()))
+	}
+
+	return nil
+}
+
+func (query TSDBqueryPayload) checkTimezone(tz string) gobol.Error {
+
+	if tz == constants.StringsEmpty {
+		return nil
+	}
+
+	if _, err := time.LoadLocation(tz); err != nil {
+		return errValidation(fmt.Errorf("invalid timezone %s: %s", tz, err.Error()))
+	}
+
+	return nil
+}
+
 func (query TSDBqueryPayload) checkFilter(filters []TSDBfilter) gobol.Error {
 
 	vFilters := config.GetFilters()
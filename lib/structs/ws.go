@@ -1,7 +1,11 @@
 package structs
 
 import (
+	"errors"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/uol/gobol"
 	"github.com/uol/mycenae/lib/constants"
@@ -15,10 +19,58 @@ type Downsample struct {
 }
 
 type DSoptions struct {
-	Downsample string `json:"approximation"`
-	Unit       string `json:"unit"`
-	Value      int    `json:"value"`
-	Fill       string
+	Downsample  string `json:"approximation"`
+	Unit        string `json:"unit"`
+	Value       int    `json:"value"`
+	Fill        string
+	MaxGap      int64
+	UseCalendar bool   `json:"useCalendar,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+}
+
+// IsInterpolatingFill - checks if the fill value calculates the empty points using the real ones
+func IsInterpolatingFill(fill string) bool {
+	return fill == "previous" || fill == "linear"
+}
+
+// DurationToMs - converts a fixed duration (ms, s, m, h, d or w) to milliseconds
+func DurationToMs(s string) (int64, error) {
+
+	unit := 1
+
+	if strings.HasSuffix(s, "ms") {
+		unit = 2
+	}
+
+	if len(s) <= unit {
+		return 0, errors.New("invalid time interval")
+	}
+
+	n, err := strconv.ParseInt(s[:len(s)-unit], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 1 {
+		return 0, errors.New("interval needs to be bigger than 0")
+	}
+
+	switch s[len(s)-unit:] {
+	case "ms":
+		return n, nil
+	case "s":
+		return n * int64(time.Second/time.Millisecond), nil
+	case "m":
+		return n * int64(time.Minute/time.Millisecond), nil
+	case "h":
+		return n * int64(time.Hour/time.Millisecond), nil
+	case "d":
+		return n * int64(24*time.Hour/time.Millisecond), nil
+	case "w":
+		return n * int64(7*24*time.Hour/time.Millisecond), nil
+	}
+
+	return 0, errors.New("invalid unit")
 }
 
 type DataOperations struct {
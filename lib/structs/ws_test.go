@@ -0,0 +1,104 @@
+package structs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationToMs(t *testing.T) {
+
+	valid := map[string]int64{
+		"1500ms": 1500,
+		"30s":    30000,
+		"5m":     300000,
+		"2h":     7200000,
+		"1d":     86400000,
+		"1w":     604800000,
+	}
+
+	for duration, expected := range valid {
+		ms, err := DurationToMs(duration)
+		if assert.NoError(t, err, duration) {
+			assert.Equal(t, expected, ms, duration)
+		}
+	}
+
+	invalid := []string{"", "m", "ms", "0m", "-1h", "1n", "1y", "xm", "1.5h"}
+
+	for _, duration := range invalid {
+		_, err := DurationToMs(duration)
+		assert.Error(t, err, duration)
+	}
+}
+
+func TestIsInterpolatingFill(t *testing.T) {
+
+	assert.True(t, IsInterpolatingFill("previous"))
+	assert.True(t, IsInterpolatingFill("linear"))
+
+	for _, fill := range []string{"none", "nan", "null", "zero", ""} {
+		assert.False(t, IsInterpolatingFill(fill), fill)
+	}
+}
+
+func TestValidateDownsampleMaxGap(t *testing.T) {
+
+	payload := func(downsample string) TSDBqueryPayload {
+		return TSDBqueryPayload{
+			Relative: "5m",
+			Queries: []TSDBquery{
+				{Metric: "os.cpu", Aggregator: "sum", Downsample: downsample},
+			},
+		}
+	}
+
+	for _, downsample := range []string{"1m-avg-linear", "1m-avg-linear-5m", "1m-max-previous-90s"} {
+		assert.Nil(t, payload(downsample).Validate(), downsample)
+	}
+
+	invalid := map[string]string{
+		"1m-avg-none-5m":       "max gap is only allowed with the previous and linear fill values",
+		"1m-avg-linear-0m":     "Invalid max gap: interval needs to be bigger than 0",
+		"1m-avg-linear-5x":     "Invalid max gap: invalid unit",
+		"1m-avg-linear-5m-10m": "invalid downsample format",
+	}
+
+	for downsample, message := range invalid {
+		gerr := payload(downsample).Validate()
+		if assert.NotNil(t, gerr, downsample) {
+			assert.Equal(t, message, gerr.Message(), downsample)
+		}
+	}
+}
+
+func TestValidateTimezone(t *testing.T) {
+
+	payload := TSDBqueryPayload{
+		Relative: "1w",
+		Queries:  []TSDBquery{{Metric: "os.cpu", Aggregator: "sum", Downsample: "1d-sum", UseCalendar: true}},
+	}
+
+	for _, tz := range []string{"", "UTC", "America/Sao_Paulo"} {
+		payload.Timezone = tz
+		assert.Nil(t, payload.Validate(), tz)
+	}
+
+	payload.Timezone = "Mars/Olympus"
+	if gerr := payload.Validate(); assert.NotNil(t, gerr) {
+		assert.Equal(t, "invalid timezone Mars/Olympus: unknown time zone Mars/Olympus", gerr.Message())
+	}
+
+	payload.Timezone = ""
+	payload.Queries[0].Timezone = "Europe/Nowhere"
+	assert.NotNil(t, payload.Validate(), "the query timezone is validated too")
+
+	payload.Queries[0].Timezone = "UTC"
+	payload.Queries[0].UseCalendar = false
+	if gerr := payload.Validate(); assert.NotNil(t, gerr) {
+		assert.Equal(t, "the timezone requires the calendar option (useCalendar)", gerr.Message())
+	}
+
+	payload.UseCalendar = true
+	assert.Nil(t, payload.Validate(), "the calendar option of the payload")
+}
@@ -29,6 +29,9 @@ const (
 	cMsgFInvalidTags        string = "tags validation failure: %s"
 	cMsgFPointCreationError string = "point creation error: %s"
 	cMsgFInvalidLineContent string = "error reading line content: %s"
+	cMsgFPointRejected      string = "point rejected: %s"
+	cMsgFPolicyViolation    string = "keyset policy violation: %s"
+	cMsgFLimitExceeded      string = "keyset limit exceeded: %s"
 	cMsgEmptyLine           string = "empty line received"
 )
 
@@ -0,0 +1,94 @@
+package telnet
+
+import (
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/graphite"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+//
+// Implements the graphite plaintext telnet handler.
+//
+
+const (
+	cMsgFInvalidGraphiteLine string = "invalid graphite line: %s"
+)
+
+// GraphiteHandler - handles graphite plaintext format data
+type GraphiteHandler struct {
+	converter         *graphite.Converter
+	logger            *logh.ContextualLogger
+	configuration     *structs.TelnetServerConfiguration
+	validationService *validation.Service
+}
+
+// NewGraphiteHandler - creates the new handler (returns an error if the templates are invalid)
+func NewGraphiteHandler(collector *collector.Collector, configuration *structs.TelnetServerConfiguration, validationService *validation.Service) (*GraphiteHandler, error) {
+
+	converter, err := graphite.NewConverter(collector, configuration, validationService)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GraphiteHandler{
+		converter:         converter,
+		logger:            logh.CreateContextualLogger(constants.StringsPKG, "telnet", constants.StringsFunc, "Handle"),
+		configuration:     configuration,
+		validationService: validationService,
+	}, nil
+}
+
+// Handle - extracts the point received by telnet
+func (gh *GraphiteHandler) Handle(line string, ip string, token *auth.Token) bool {
+
+	if len(line) == 0 {
+		if !gh.configuration.SilenceLogs && logh.DebugEnabled {
+			gh.logger.Debug().Msg(cMsgEmptyLine)
+		}
+		return true
+	}
+
+	path, value, timestamp, gerr := graphite.ParseLine(line)
+	if gerr != nil {
+		logAndStats(gh, gerr, cFuncHandle, gh.configuration.DefaultKeyset, ip, cMsgFInvalidGraphiteLine, line)
+		return false
+	}
+
+	keyset, gerr := gh.converter.Handle(path, value, timestamp, gh.GetSourceType(), token)
+	if gerr != nil {
+		logAndStats(gh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidGraphiteLine, line)
+		return false
+	}
+
+	return true
+}
+
+// GetSourceType - returns the source type
+func (gh *GraphiteHandler) GetSourceType() *constants.SourceType {
+	return constants.SourceTypeTelnetGraphite
+}
+
+// GetLogger - returns the logger
+func (gh *GraphiteHandler) GetLogger() *logh.ContextualLogger {
+	return gh.logger
+}
+
+// GetValidationService - returns the validation service instance
+func (gh *GraphiteHandler) GetValidationService() *validation.Service {
+	return gh.validationService
+}
+
+// SilenceLogs - checks the configuration to silence all validation logs
+func (gh *GraphiteHandler) SilenceLogs() bool {
+	return gh.configuration.SilenceLogs
+}
+
+// GetConfiguration - returns this handler configuration
+func (gh *GraphiteHandler) GetConfiguration() *structs.TelnetServerConfiguration {
+	return gh.configuration
+}
@@ -0,0 +1,103 @@
+package telnet
+
+import (
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+//
+// Implements the influxdb line protocol telnet handler.
+//
+
+const (
+	cMsgFInvalidLine string = "invalid line protocol: %s"
+)
+
+// InfluxHandler - handles influxdb line protocol data
+type InfluxHandler struct {
+	collector         *collector.Collector
+	logger            *logh.ContextualLogger
+	configuration     *structs.TelnetServerConfiguration
+	validationService *validation.Service
+}
+
+// NewInfluxHandler - creates the new handler
+func NewInfluxHandler(collector *collector.Collector, configuration *structs.TelnetServerConfiguration, validationService *validation.Service) *InfluxHandler {
+
+	return &InfluxHandler{
+		collector:         collector,
+		logger:            logh.CreateContextualLogger(constants.StringsPKG, "telnet", constants.StringsFunc, "Handle"),
+		configuration:     configuration,
+		validationService: validationService,
+	}
+}
+
+// Handle - extracts the points received by telnet, one point is created for each field
+func (ih *InfluxHandler) Handle(line string, ip string, token *auth.Token) bool {
+
+	if len(line) == 0 {
+		if !ih.configuration.SilenceLogs && logh.DebugEnabled {
+			ih.logger.Debug().Msg(cMsgEmptyLine)
+		}
+		return true
+	}
+
+	points, keyset, gerr := ih.collector.ParseInfluxLine(line, collector.InfluxPrecisionNanoseconds, ih.configuration.DefaultKeyset, ih.configuration.DefaultTTL)
+	if gerr != nil {
+		logAndStats(ih, gerr, cFuncHandle, keyset, ip, cMsgFInvalidLine, line)
+		return false
+	}
+
+	for _, point := range points {
+
+		validatedPoint, gerr := ih.collector.MakePacket(point, point.Value != nil)
+		if gerr != nil {
+			logAndStats(ih, gerr, cFuncHandle, keyset, ip, cMsgFPointCreationError, line)
+			return false
+		}
+
+		gerr = ih.collector.ValidateLimits(validatedPoint)
+		if gerr != nil {
+			logAndStats(ih, gerr, cFuncHandle, keyset, ip, cMsgFLimitExceeded, line)
+			return false
+		}
+
+		gerr = ih.collector.HandlePacket(validatedPoint, ih.GetSourceType(), token)
+		if gerr != nil {
+			logAndStats(ih, gerr, cFuncHandle, keyset, ip, cMsgFPointRejected, line)
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetSourceType - returns the source type
+func (ih *InfluxHandler) GetSourceType() *constants.SourceType {
+	return constants.SourceTypeTelnetInflux
+}
+
+// GetLogger - returns the logger
+func (ih *InfluxHandler) GetLogger() *logh.ContextualLogger {
+	return ih.logger
+}
+
+// GetValidationService - returns the validation service instance
+func (ih *InfluxHandler) GetValidationService() *validation.Service {
+	return ih.validationService
+}
+
+// SilenceLogs - checks the configuration to silence all validation logs
+func (ih *InfluxHandler) SilenceLogs() bool {
+	return ih.configuration.SilenceLogs
+}
+
+// GetConfiguration - returns this handler configuration
+func (ih *InfluxHandler) GetConfiguration() *structs.TelnetServerConfiguration {
+	return ih.configuration
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/buger/jsonparser"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/structs"
@@ -192,7 +193,7 @@ func (nh *NetdataHandler) expireCachedRegexp(regexp string) {
 }
 
 // Handle - extracts the points received by telnet
-func (nh *NetdataHandler) Handle(line, ip string) bool {
+func (nh *NetdataHandler) Handle(line, ip string, token *auth.Token) bool {
 
 	if len(line) == 0 {
 		if !nh.configuration.SilenceLogs && logh.DebugEnabled {
@@ -360,13 +361,29 @@ func (nh *NetdataHandler) Handle(line, ip string) bool {
 		return false
 	}
 
+	gerr = nh.validationService.ValidatePolicy(&point)
+	if gerr != nil {
+		logAndStats(nh, gerr, cFuncHandle, pointJSON.Keyset, pointJSON.HostName, cMsgFPolicyViolation, line)
+		return false
+	}
+
 	packet, gerr := nh.collector.MakePacket(&point, true)
 	if gerr != nil {
 		logAndStats(nh, gerr, cFuncHandle, pointJSON.Keyset, pointJSON.HostName, cMsgFPointCreationError, line)
 		return false
 	}
 
-	nh.collector.HandlePacket(packet, nh.GetSourceType())
+	gerr = nh.collector.ValidateLimits(packet)
+	if gerr != nil {
+		logAndStats(nh, gerr, cFuncHandle, pointJSON.Keyset, pointJSON.HostName, cMsgFLimitExceeded, line)
+		return false
+	}
+
+	gerr = nh.collector.HandlePacket(packet, nh.GetSourceType(), token)
+	if gerr != nil {
+		logAndStats(nh, gerr, cFuncHandle, pointJSON.Keyset, pointJSON.HostName, cMsgFPointRejected, line)
+		return false
+	}
 
 	return true
 }
@@ -1,12 +1,16 @@
 package telnet
 
 import (
+	"encoding/base64"
+	"os"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/uol/gobol"
 	"github.com/uol/logh"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/structs"
@@ -21,53 +25,201 @@ import (
 const (
 	cMsgFNoParseableTagsFound  string = "error reading line content: %s"
 	cMsgFNoParseableValueFound string = "error parsing value: %s"
+	cMsgFInvalidHistogram      string = "invalid histogram: %s"
+	cMsgFReservedTag           string = "tag set by the command: %s"
+	cTagRollupInterval         string = "rollup_interval"
+	cTagRollupAggregator       string = "rollup_aggregator"
+	cTagRollupGroupBy          string = "rollup_groupby"
+	cHistogramBucketSuffix     string = ".bucket"
+	cHistogramCountSuffix      string = ".count"
+	cHistogramBucketTag        string = "le"
+	cHistogramInfBucket        string = "inf"
+	cUnknownHost               string = "unknown"
 )
 
 // OpenTSDBHandler - handles opentsdb telnet format data
 type OpenTSDBHandler struct {
 	formatRegexp      *regexp.Regexp
+	rollupRegexp      *regexp.Regexp
+	histogramRegexp   *regexp.Regexp
 	tagsRegexp        *regexp.Regexp
 	collector         *collector.Collector
 	logger            *logh.ContextualLogger
 	configuration     *structs.TelnetServerConfiguration
 	validationService *validation.Service
+	hostName          string
 }
 
 // NewOpenTSDBHandler - creates the new handler
 func NewOpenTSDBHandler(collector *collector.Collector, configuration *structs.TelnetServerConfiguration, validationService *validation.Service) *OpenTSDBHandler {
 
+	hostName, err := os.Hostname()
+	if err != nil {
+		hostName = cUnknownHost
+	}
+
 	return &OpenTSDBHandler{
 		formatRegexp:      regexp.MustCompile(`put ([0-9A-Za-z-\._\%\&\#\;\/]+) ([0-9]+) ([0-9Ee\.\-\,]+) ([0-9A-Za-z-\._\%\&\#\;\/ =]+)`),
+		rollupRegexp:      regexp.MustCompile(`^rollup (?:([0-9]+[a-z]+)-([A-Za-z]+))?(?::([A-Za-z]+))? ([0-9A-Za-z-\._\%\&\#\;\/]+) ([0-9]+) ([0-9Ee\.\-\,]+) ([0-9A-Za-z-\._\%\&\#\;\/ =]+)`),
+		histogramRegexp:   regexp.MustCompile(`^histogram ([0-9A-Za-z-\._\%\&\#\;\/]+) ([0-9]+) (?:([0-9]+) )?([0-9A-Za-z\+\/]+={0,2}) ([0-9A-Za-z-\._\%\&\#\;\/ =]+)`),
 		tagsRegexp:        regexp.MustCompile(`([0-9A-Za-z-\._\%\&\#\;\/]+)=([0-9A-Za-z-\._\%\&\#\;\/]+)`),
 		collector:         collector,
 		logger:            logh.CreateContextualLogger(constants.StringsPKG, "telnet", constants.StringsFunc, "Handle"),
 		configuration:     configuration,
 		validationService: validationService,
+		hostName:          hostName,
 	}
 }
 
-// Handle - extracts the points received by telnet
-func (otsdbh *OpenTSDBHandler) Handle(line string, ip string) bool {
+// Handle - extracts the points received by telnet (put, rollup and histogram lines)
+func (otsdbh *OpenTSDBHandler) Handle(line string, ip string, token *auth.Token) bool {
+	return otsdbh.HandleError(line, ip, token) == nil
+}
+
+// HandleError - handles the line like Handle, returning the validation error of a rejected line
+func (otsdbh *OpenTSDBHandler) HandleError(line string, ip string, token *auth.Token) gobol.Error {
 
 	if len(line) == 0 {
 		if !otsdbh.configuration.SilenceLogs && logh.DebugEnabled {
 			otsdbh.logger.Debug().Msg(cMsgEmptyLine)
 		}
-		return true
+		return nil
 	}
 
 	keyset := extractKeysetValue(line)
 
+	switch {
+	case strings.HasPrefix(line, cCommandRollup+constants.StringsWhitespace):
+		return otsdbh.handleRollup(line, ip, keyset, token)
+	case strings.HasPrefix(line, cCommandHistogram+constants.StringsWhitespace):
+		return otsdbh.handleHistogram(line, ip, keyset, token)
+	}
+
 	matches := otsdbh.formatRegexp.FindStringSubmatch(line)
 	if len(matches) != 5 {
-		logAndStats(otsdbh, errDataFormatParse, cFuncHandle, keyset, ip, cMsgFInvalidLineContent, line)
-		return false
+		return otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFInvalidLineContent, line)
+	}
+
+	point, gerr := otsdbh.parsePoint(line, ip, keyset, matches[1], matches[2], matches[4])
+	if gerr != nil {
+		return gerr
+	}
+
+	value, gerr := otsdbh.parseValue(line, ip, keyset, matches[3])
+	if gerr != nil {
+		return gerr
+	}
+
+	return otsdbh.send(point, value, line, ip, keyset, token)
+}
+
+// handleRollup - stores the rollup point tagged with its interval and aggregators
+// (rollup [<interval>-<aggregator>][:<group by aggregator>] <metric> <timestamp> <value> <tags>)
+func (otsdbh *OpenTSDBHandler) handleRollup(line, ip, keyset string, token *auth.Token) gobol.Error {
+
+	matches := otsdbh.rollupRegexp.FindStringSubmatch(line)
+	if len(matches) != 8 || (matches[2] == constants.StringsEmpty && matches[3] == constants.StringsEmpty) {
+		return otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFInvalidLineContent, line)
+	}
+
+	point, gerr := otsdbh.parsePoint(line, ip, keyset, matches[4], matches[5], matches[7], cTagRollupInterval, cTagRollupAggregator, cTagRollupGroupBy)
+	if gerr != nil {
+		return gerr
+	}
+
+	value, gerr := otsdbh.parseValue(line, ip, keyset, matches[6])
+	if gerr != nil {
+		return gerr
+	}
+
+	rollupTags := [][2]string{
+		{cTagRollupInterval, matches[1]},
+		{cTagRollupAggregator, strings.ToLower(matches[2])},
+		{cTagRollupGroupBy, strings.ToLower(matches[3])},
+	}
+
+	for _, tag := range rollupTags {
+		if tag[1] != constants.StringsEmpty {
+			point.Tags = append(point.Tags, structs.TSDBTag{Name: tag[0], Value: tag[1]})
+		}
+	}
+
+	return otsdbh.send(point, value, line, ip, keyset, token)
+}
+
+// handleHistogram - explodes the opentsdb histogram in the cumulative bucket and count series, the values below
+// the first bucket are counted by all buckets and the ones above the last bucket only by the inf bucket (the simple
+// histogram has no sum) (histogram <metric> <timestamp> [<codec id>] <base64 histogram> <tags>, without the codec
+// id it is the first byte of the histogram)
+func (otsdbh *OpenTSDBHandler) handleHistogram(line, ip, keyset string, token *auth.Token) gobol.Error {
+
+	matches := otsdbh.histogramRegexp.FindStringSubmatch(line)
+	if len(matches) != 6 {
+		return otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFInvalidLineContent, line)
+	}
+
+	point, gerr := otsdbh.parsePoint(line, ip, keyset, matches[1], matches[2], matches[5], cHistogramBucketTag)
+	if gerr != nil {
+		return gerr
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(matches[4])
+	if err != nil || len(raw) == 0 {
+		return otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFInvalidHistogram, line)
+	}
+
+	var id int
+	if matches[3] != constants.StringsEmpty {
+		if id, err = strconv.Atoi(matches[3]); err != nil {
+			return otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFInvalidHistogram, line)
+		}
+	} else {
+		id, raw = int(raw[0]), raw[1:]
 	}
 
-	tagMatches := otsdbh.tagsRegexp.FindAllStringSubmatch(matches[4], -1)
+	histogram, err := decodeHistogram(id, raw)
+	if err != nil {
+		return otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFInvalidHistogram, line)
+	}
+
+	accumulated := histogram.underflow
+
+	sendBucket := func(bound string, count uint64) gobol.Error {
+
+		bucketPoint := *point
+		bucketPoint.Metric = point.Metric + cHistogramBucketSuffix
+		bucketPoint.Tags = append(append(make([]structs.TSDBTag, 0, len(point.Tags)+1), point.Tags...), structs.TSDBTag{Name: cHistogramBucketTag, Value: bound})
+
+		return otsdbh.send(&bucketPoint, float64(count), line, ip, keyset, token)
+	}
+
+	for _, bucket := range histogram.buckets {
+
+		accumulated += bucket.count
+
+		if gerr := sendBucket(strconv.FormatFloat(float64(bucket.max), 'f', -1, 32), accumulated); gerr != nil {
+			return gerr
+		}
+	}
+
+	accumulated += histogram.overflow
+
+	if gerr := sendBucket(cHistogramInfBucket, accumulated); gerr != nil {
+		return gerr
+	}
+
+	countPoint := *point
+	countPoint.Metric = point.Metric + cHistogramCountSuffix
+
+	return otsdbh.send(&countPoint, float64(accumulated), line, ip, keyset, token)
+}
+
+// parsePoint - validates the metric, the timestamp and the tags of the line, the reserved tags are set by the command
+func (otsdbh *OpenTSDBHandler) parsePoint(line, ip, keyset, metric, timestamp, tags string, reserved ...string) (*structs.TSDBpoint, gobol.Error) {
+
+	tagMatches := otsdbh.tagsRegexp.FindAllStringSubmatch(tags, -1)
 	if len(tagMatches) == 0 {
-		logAndStats(otsdbh, errDataFormatParse, cFuncHandle, keyset, ip, cMsgFNoParseableTagsFound, line)
-		return false
+		return nil, otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFNoParseableTagsFound, line)
 	}
 
 	var err error
@@ -83,8 +235,7 @@ func (otsdbh *OpenTSDBHandler) Handle(line string, ip string) bool {
 		case constants.StringsTTL:
 			ttl, ttlStr, gerr := otsdbh.validationService.ParseTTL(tagMatches[i][2])
 			if gerr != nil {
-				logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidTTL, line)
-				return false
+				return nil, otsdbh.reject(gerr, keyset, ip, cMsgFInvalidTTL, line)
 			}
 			point.TTL = ttl
 			tagMatches[i][2] = ttlStr
@@ -92,22 +243,25 @@ func (otsdbh *OpenTSDBHandler) Handle(line string, ip string) bool {
 		case constants.StringsKSID:
 			gerr = otsdbh.validationService.ValidateKeyset(tagMatches[i][2])
 			if gerr != nil {
-				logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidKSID, line)
-				return false
+				return nil, otsdbh.reject(gerr, keyset, ip, cMsgFInvalidKSID, line)
 			}
 			point.Keyset = tagMatches[i][2]
 			ksidFound = true
 		default:
+			for _, name := range reserved {
+				if tagMatches[i][1] == name {
+					return nil, otsdbh.reject(errDataFormatParse, keyset, ip, cMsgFReservedTag, line)
+				}
+			}
+
 			gerr = otsdbh.validationService.ValidateProperty(tagMatches[i][1], validation.TagKeyType)
 			if gerr != nil {
-				logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidKey, line)
-				return false
+				return nil, otsdbh.reject(gerr, keyset, ip, cMsgFInvalidKey, line)
 			}
 
 			gerr = otsdbh.validationService.ValidateProperty(tagMatches[i][2], validation.TagValueType)
 			if gerr != nil {
-				logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidValue, line)
-				return false
+				return nil, otsdbh.reject(gerr, keyset, ip, cMsgFInvalidValue, line)
 			}
 		}
 
@@ -121,13 +275,11 @@ func (otsdbh *OpenTSDBHandler) Handle(line string, ip string) bool {
 
 	gerr = otsdbh.validationService.ValidateTags(&point)
 	if gerr != nil {
-		logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidTags, line)
-		return false
+		return nil, otsdbh.reject(gerr, keyset, ip, cMsgFInvalidTags, line)
 	}
 
 	if !ksidFound {
-		logAndStats(otsdbh, validation.ErrNoKeysetTag, cFuncHandle, keyset, ip, cMsgFKSIDTagNotFound, line)
-		return false
+		return nil, otsdbh.reject(validation.ErrNoKeysetTag, keyset, ip, cMsgFKSIDTagNotFound, line)
 	}
 
 	if !ttlFound {
@@ -136,43 +288,71 @@ func (otsdbh *OpenTSDBHandler) Handle(line string, ip string) bool {
 		point.TTL = ttl
 	}
 
-	gerr = otsdbh.validationService.ValidateProperty(matches[1], validation.MetricType)
+	gerr = otsdbh.validationService.ValidateProperty(metric, validation.MetricType)
 	if gerr != nil {
-		logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidMetric, line)
-		return false
+		return nil, otsdbh.reject(gerr, keyset, ip, cMsgFInvalidMetric, line)
 	}
 
-	point.Metric = matches[1]
+	point.Metric = metric
 
-	point.Timestamp, err = strconv.ParseInt(matches[2], 10, 64)
+	point.Timestamp, err = strconv.ParseInt(timestamp, 10, 64)
 	if err != nil {
-		logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidTimestamp, line)
-		return false
+		return nil, otsdbh.reject(validation.ErrInvalidTimestamp, keyset, ip, cMsgFInvalidTimestamp, line)
 	}
 
 	point.Timestamp, gerr = otsdbh.validationService.ValidateTimestamp(point.Timestamp)
 	if gerr != nil {
-		logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFInvalidTimestamp, line)
-		return false
+		return nil, otsdbh.reject(gerr, keyset, ip, cMsgFInvalidTimestamp, line)
 	}
 
-	value, err := strconv.ParseFloat(matches[3], 64)
+	return &point, nil
+}
+
+// parseValue - parses the point value
+func (otsdbh *OpenTSDBHandler) parseValue(line, ip, keyset, valueStr string) (float64, gobol.Error) {
+
+	value, err := strconv.ParseFloat(valueStr, 64)
 	if err != nil {
-		logAndStats(otsdbh, validation.ErrParsingValue, cFuncHandle, keyset, ip, cMsgFInvalidValue, line)
-		return false
+		return 0, otsdbh.reject(validation.ErrParsingValue, keyset, ip, cMsgFInvalidValue, line)
 	}
 
+	return value, nil
+}
+
+// send - checks the keyset policy and limits of the point (its final metric and tags) and sends it to the collector
+func (otsdbh *OpenTSDBHandler) send(point *structs.TSDBpoint, value float64, line, ip, keyset string, token *auth.Token) gobol.Error {
+
 	point.Value = &value
 
-	validatedPoint, gerr := otsdbh.collector.MakePacket(&point, true)
-	if err != nil {
-		logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, cMsgFPointCreationError, line)
-		return false
+	gerr := otsdbh.validationService.ValidatePolicy(point)
+	if gerr != nil {
+		return otsdbh.reject(gerr, keyset, ip, cMsgFPolicyViolation, line)
 	}
 
-	otsdbh.collector.HandlePacket(validatedPoint, otsdbh.GetSourceType())
+	validatedPoint, gerr := otsdbh.collector.MakePacket(point, true)
+	if gerr != nil {
+		return otsdbh.reject(gerr, keyset, ip, cMsgFPointCreationError, line)
+	}
+
+	gerr = otsdbh.collector.ValidateLimits(validatedPoint)
+	if gerr != nil {
+		return otsdbh.reject(gerr, keyset, ip, cMsgFLimitExceeded, line)
+	}
+
+	gerr = otsdbh.collector.HandlePacket(validatedPoint, otsdbh.GetSourceType(), token)
+	if gerr != nil {
+		return otsdbh.reject(gerr, keyset, ip, cMsgFPointRejected, line)
+	}
+
+	return nil
+}
+
+// reject - logs and counts the validation error of the line, returning it
+func (otsdbh *OpenTSDBHandler) reject(gerr gobol.Error, keyset, ip, message, line string) gobol.Error {
+
+	logAndStats(otsdbh, gerr, cFuncHandle, keyset, ip, message, line)
 
-	return true
+	return gerr
 }
 
 // GetSourceType - returns the source type
@@ -0,0 +1,122 @@
+package telnet
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/telnetsrv"
+)
+
+//
+// Implements the opentsdb telnet commands answered to the client (the put variants are handled as data).
+//
+
+const (
+	cCommandPut       string = "put"
+	cCommandRollup    string = "rollup"
+	cCommandHistogram string = "histogram"
+	cCommandVersion   string = "version"
+	cCommandStats     string = "stats"
+	cCommandHelp      string = "help"
+	cCommandExit      string = "exit"
+	cFuncHandleCmd    string = "HandleCommand"
+	cIllegalArgument  string = ": illegal argument: "
+)
+
+// commandList - the commands listed by "help"
+var commandList = []string{cCommandExit, cCommandHelp, cCommandHistogram, cCommandPut, cCommandRollup, cCommandStats, cCommandVersion}
+
+// HandleCommand - answers the version, stats, help and exit commands
+func (otsdbh *OpenTSDBHandler) HandleCommand(line, ip string, session *telnetsrv.Session) bool {
+
+	var response []byte
+
+	switch strings.TrimSpace(line) {
+	case cCommandVersion:
+		response = otsdbh.version()
+	case cCommandStats:
+		response = otsdbh.stats(session.Stats())
+	case cCommandHelp:
+		response = []byte("available commands: " + strings.Join(commandList, constants.StringsWhitespace) + "\n")
+	case cCommandExit:
+		session.Exit()
+		return true
+	default:
+		return false
+	}
+
+	if err := session.Write(response); err != nil && !otsdbh.configuration.SilenceLogs && logh.WarnEnabled {
+		otsdbh.logger.Warn().Str(constants.StringsFunc, cFuncHandleCmd).Err(err).Msgf("error answering the command %q from %s", line, ip)
+	}
+
+	return true
+}
+
+// version - the opentsdb version lines
+func (otsdbh *OpenTSDBHandler) version() []byte {
+	return []byte("mycenae " + constants.Version + "\nBuilt on " + otsdbh.hostName + "\n")
+}
+
+// stats - the server counters in the opentsdb format (<metric> <timestamp> <value> <tags>)
+func (otsdbh *OpenTSDBHandler) stats(stats *telnetsrv.Stats) []byte {
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	tags := " host=" + otsdbh.hostName + " port=" + stats.Port + " source=" + stats.Source + "\n"
+
+	buffer := bytes.Buffer{}
+
+	write := func(metric string, value uint64, tag string) {
+		buffer.WriteString(metric)
+		buffer.WriteString(constants.StringsWhitespace)
+		buffer.WriteString(now)
+		buffer.WriteString(constants.StringsWhitespace)
+		buffer.WriteString(strconv.FormatUint(value, 10))
+		buffer.WriteString(constants.StringsWhitespace)
+		buffer.WriteString(tag)
+		buffer.WriteString(tags)
+	}
+
+	write("tsd.connectionmgr.connections", uint64(stats.Connections), "type=open")
+	write("tsd.connectionmgr.connections", uint64(stats.NodeConnections), "type=node")
+	write("tsd.rpc.received", stats.Received, "type=put")
+	write("tsd.rpc.errors", stats.Failures, "type=put")
+	write("tsd.rpc.success", stats.Successes, "type=put")
+
+	return buffer.Bytes()
+}
+
+// ErrorResponse - the opentsdb error line of the rejected line, prefixed by the validation error code
+// (<command>: illegal argument: [<code>] <message>)
+func (otsdbh *OpenTSDBHandler) ErrorResponse(line string, gerr gobol.Error) []byte {
+
+	command := cCommandPut
+	if i := strings.IndexByte(line, ' '); i > 0 && (line[:i] == cCommandRollup || line[:i] == cCommandHistogram) {
+		command = line[:i]
+	}
+
+	message := gerr.Message()
+	if message == constants.StringsEmpty {
+		message = gerr.Error()
+	}
+
+	buffer := bytes.Buffer{}
+	buffer.WriteString(command)
+	buffer.WriteString(cIllegalArgument)
+
+	if code := gerr.ErrorCode(); code != constants.StringsEmpty {
+		buffer.WriteString("[")
+		buffer.WriteString(code)
+		buffer.WriteString("] ")
+	}
+
+	buffer.WriteString(message)
+	buffer.WriteByte('\n')
+
+	return buffer.Bytes()
+}
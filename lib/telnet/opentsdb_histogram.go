@@ -0,0 +1,104 @@
+package telnet
+
+import (
+	"errors"
+	"math"
+
+	"github.com/uol/mycenae/lib/protowire"
+)
+
+//
+// Decodes the opentsdb histograms sent by the histogram puts.
+//
+
+const (
+	// cSimpleHistogramID - the id of the default opentsdb codec (net.opentsdb.core.SimpleHistogramDecoder)
+	cSimpleHistogramID int = 0
+
+	// cSimpleHistogramMinBucketSize - two floats and a one byte varint
+	cSimpleHistogramMinBucketSize int = 9
+)
+
+var (
+	errUnknownHistogramCodec = errors.New("unknown histogram codec")
+	errInvalidHistogramBound = errors.New("invalid histogram bucket bounds")
+	errTrailingHistogramData = errors.New("unexpected data after the histogram")
+)
+
+// simpleHistogramBucket - a bucket of the opentsdb simple histogram
+type simpleHistogramBucket struct {
+	min   float32
+	max   float32
+	count uint64
+}
+
+// simpleHistogram - the buckets and the counts of the values out of their bounds
+type simpleHistogram struct {
+	buckets   []simpleHistogramBucket
+	underflow uint64
+	overflow  uint64
+}
+
+// decodeHistogram - decodes the histogram encoded by the opentsdb codec with the id, only the simple histogram
+// is known (<varint buckets> [<float min> <float max> <varint count>]... <varint underflow> <varint overflow>)
+func decodeHistogram(id int, raw []byte) (*simpleHistogram, error) {
+
+	if id != cSimpleHistogramID {
+		return nil, errUnknownHistogramCodec
+	}
+
+	r := protowire.NewReader(raw)
+
+	numBuckets, err := r.Varint()
+	if err != nil {
+		return nil, err
+	}
+
+	// the bucket count can not be used to allocate before checking the buffer size
+	if numBuckets > uint64(len(raw)/cSimpleHistogramMinBucketSize) {
+		return nil, protowire.ErrTruncated
+	}
+
+	h := &simpleHistogram{
+		buckets: make([]simpleHistogramBucket, numBuckets),
+	}
+
+	for i := range h.buckets {
+
+		min, err := r.Fixed32()
+		if err != nil {
+			return nil, err
+		}
+
+		max, err := r.Fixed32()
+		if err != nil {
+			return nil, err
+		}
+
+		b := &h.buckets[i]
+		b.min = math.Float32frombits(min)
+		b.max = math.Float32frombits(max)
+
+		if !(b.min <= b.max) || math.IsInf(float64(b.max), 0) || (i > 0 && b.min < h.buckets[i-1].max) {
+			return nil, errInvalidHistogramBound
+		}
+
+		if b.count, err = r.Varint(); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.underflow, err = r.Varint(); err != nil {
+		return nil, err
+	}
+
+	if h.overflow, err = r.Varint(); err != nil {
+		return nil, err
+	}
+
+	if !r.Done() {
+		return nil, errTrailingHistogramData
+	}
+
+	return h, nil
+}
@@ -1,7 +1,6 @@
 package telnetmgr
 
 import (
-	"crypto/tls"
 	"fmt"
 	"math"
 	"net/http"
@@ -13,10 +12,12 @@ import (
 
 	"github.com/uol/logh"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/telnetsrv"
+	"github.com/uol/mycenae/lib/tlsconfig"
 
 	tlmanager "github.com/uol/timelinemanager"
 )
@@ -29,6 +30,7 @@ import (
 // Manager - controls the telnet servers
 type Manager struct {
 	collector                *collector.Collector
+	auth                     *auth.Service
 	logger                   *logh.ContextualLogger
 	timelineManager          *tlmanager.Instance
 	terminate                bool
@@ -39,13 +41,14 @@ type Manager struct {
 	otherNodes               []string
 	numOtherNodes            int
 	httpListenPort           int
+	httpScheme               string
 	closeConnectionChannel   chan struct{}
 	httpClient               *http.Client
 	servers                  []*telnetsrv.Server
 }
 
 // New - creates a new manager instance
-func New(globalConfiguration *structs.TelnetManagerConfiguration, httpListenPort int, collector *collector.Collector, timelineManager *tlmanager.Instance) (*Manager, error) {
+func New(globalConfiguration *structs.TelnetManagerConfiguration, httpListenPort int, httpTLS *structs.TLSConfiguration, collector *collector.Collector, authService *auth.Service, timelineManager *tlmanager.Instance) (*Manager, error) {
 
 	hostName, err := os.Hostname()
 	if err != nil {
@@ -59,11 +62,20 @@ func New(globalConfiguration *structs.TelnetManagerConfiguration, httpListenPort
 		}
 	}
 
+	// the node certificate is also presented to the nodes requiring client certificates
+	tlsClientConfig, err := tlsconfig.NewClient(httpTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	httpScheme := "http"
+	if tlsconfig.Enabled(httpTLS) {
+		httpScheme = "https"
+	}
+
 	httpClient := &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+			TLSClientConfig: tlsClientConfig,
 		},
 		Timeout: globalConfiguration.NodeToNodeRequestTimeout.Duration,
 	}
@@ -71,10 +83,12 @@ func New(globalConfiguration *structs.TelnetManagerConfiguration, httpListenPort
 	return &Manager{
 		connectionBalanceStarted: false,
 		collector:                collector,
+		auth:                     authService,
 		logger:                   logh.CreateContextualLogger(constants.StringsPKG, "telnetmgr"),
 		timelineManager:          timelineManager,
 		terminate:                false,
 		httpListenPort:           httpListenPort,
+		httpScheme:               httpScheme,
 		sharedConnectionCounter:  0,
 		haltBalancingProcess:     0,
 		globalConfiguration:      globalConfiguration,
@@ -96,6 +110,7 @@ func (manager *Manager) AddServer(serverConfiguration *structs.TelnetServerConfi
 		manager.globalConfiguration.MaxTelnetConnections,
 		&manager.closeConnectionChannel,
 		manager.collector,
+		manager.auth,
 		manager.timelineManager,
 		telnetHandler,
 	)
@@ -309,6 +324,21 @@ const (
 	cNode                          string = "node"
 )
 
+// headNode - does a HEAD request to another node, authenticated with the node token
+func (manager *Manager) headNode(url string) (*http.Response, error) {
+
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := manager.auth.NodeToken(); token != constants.StringsEmpty {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return manager.httpClient.Do(req)
+}
+
 // getNumConnectionsFromNode - does a HEAD request to get number of connections from another node
 func (manager *Manager) getNumConnectionsFromNode(node string, result *uint32, wg *sync.WaitGroup) {
 
@@ -318,9 +348,9 @@ func (manager *Manager) getNumConnectionsFromNode(node string, result *uint32, w
 		manager.logger.Debug().Str(constants.StringsFunc, cFuncGetNumConnectionsFromNode).Str(cNode, node).Msg("asking node for the number of connections...")
 	}
 
-	url := fmt.Sprintf("http://%s:%d/%s", node, manager.httpListenPort, CountConnsURI)
+	url := fmt.Sprintf("%s://%s:%d/%s", manager.httpScheme, node, manager.httpListenPort, CountConnsURI)
 
-	resp, err := manager.httpClient.Head(url)
+	resp, err := manager.headNode(url)
 	if err != nil {
 		if logh.ErrorEnabled {
 			manager.logger.Error().Str(constants.StringsFunc, cFuncGetNumConnectionsFromNode).Str(cNode, node).Err(err).Send()
@@ -372,9 +402,9 @@ func (manager *Manager) haltBalancingOnOtherNodes() {
 			manager.logger.Info().Str(constants.StringsFunc, cFuncHaltBalancingOnOtherNodes).Str(cNode, node).Msg("notifying node to halt the balancing process")
 		}
 
-		url := fmt.Sprintf("http://%s:%d/%s", node, manager.httpListenPort, HaltConnsURI)
+		url := fmt.Sprintf("%s://%s:%d/%s", manager.httpScheme, node, manager.httpListenPort, HaltConnsURI)
 
-		resp, err := manager.httpClient.Head(url)
+		resp, err := manager.headNode(url)
 		if err != nil {
 			if logh.ErrorEnabled {
 				manager.logger.Error().Str(constants.StringsFunc, cFuncHaltBalancingOnOtherNodes).Str(cNode, node).Err(err).Send()
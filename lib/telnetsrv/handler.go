@@ -1,7 +1,9 @@
 package telnetsrv
 
 import (
+	"github.com/uol/gobol"
 	"github.com/uol/logh"
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/validation"
@@ -15,8 +17,8 @@ import (
 // TelnetDataHandler - handles the data from the telnet interface
 type TelnetDataHandler interface {
 
-	// Handle - handles the data and send
-	Handle(line, ip string) bool
+	// Handle - handles the data and send (the token is nil if the authentication is disabled)
+	Handle(line, ip string, token *auth.Token) bool
 
 	// GetSourceType - returns the source type
 	GetSourceType() *constants.SourceType
@@ -30,3 +32,20 @@ type TelnetDataHandler interface {
 	// GetConfiguration - returns this handler configuration
 	GetConfiguration() *structs.TelnetServerConfiguration
 }
+
+// TelnetCommandHandler - a data handler also answering the protocol commands (optional)
+type TelnetCommandHandler interface {
+
+	// HandleCommand - answers the line if it is a command, returning false if it must be handled as data
+	HandleCommand(line, ip string, session *Session) bool
+}
+
+// TelnetErrorHandler - a data handler describing the rejected lines to the client (optional)
+type TelnetErrorHandler interface {
+
+	// HandleError - handles the data like Handle, returning the error of a rejected line
+	HandleError(line, ip string, token *auth.Token) gobol.Error
+
+	// ErrorResponse - returns the error line answered to the client
+	ErrorResponse(line string, gerr gobol.Error) []byte
+}
@@ -2,6 +2,7 @@ package telnetsrv
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -10,11 +11,14 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/uol/gobol"
 	"github.com/uol/logh"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tlsconfig"
 	"github.com/uol/mycenae/lib/utils"
 	tlmanager "github.com/uol/timelinemanager"
 )
@@ -24,7 +28,13 @@ import (
 // author: rnojiri
 //
 
-const lineSeparator byte = 10
+const (
+	lineSeparator             byte          = 10
+	cDefaultMaxPendingBuffers int32         = 64
+	cSlowDownInterval         time.Duration = 10 * time.Millisecond
+
+	cDefaultMaxErrorResponsesPerSecond float64 = 10
+)
 
 type connCloseReason string
 
@@ -46,14 +56,26 @@ const (
 	ccrEOF       connCloseReason = "eof"
 	ccrTimeout   connCloseReason = "timeout"
 	ccrUnknown   connCloseReason = "unknown"
+	ccrAuth      connCloseReason = "auth"
+	ccrExit      connCloseReason = "exit"
+	ccrTLS       connCloseReason = "tls"
 )
 
 // Server - the telnet server struct
 type Server struct {
+	// the 64 bits counters are kept first to be aligned on 32 bits platforms
+	received                         uint64
+	failures                         uint64
+	successes                        uint64
 	listenAddress                    string
 	listener                         net.Listener
+	tlsConfig                        *tls.Config
 	maxBufferSize                    int64
+	maxPendingBuffers                int32
+	errorHandler                     TelnetErrorHandler
+	maxErrorResponsesPerSecond       float64
 	collector                        *collector.Collector
+	auth                             *auth.Service
 	logger                           *logh.ContextualLogger
 	timelineManager                  *tlmanager.Instance
 	telnetHandler                    TelnetDataHandler
@@ -76,7 +98,7 @@ type Server struct {
 }
 
 // New - creates a new telnet server
-func New(telnetServerConfiguration *structs.TelnetServerConfiguration, globalTelnetConfiguration *structs.TelnetManagerConfiguration, sharedConnectionCounter *uint32, maxConnections uint32, closeConnectionChannel *chan struct{}, collector *collector.Collector, timelineManager *tlmanager.Instance, telnetHandler TelnetDataHandler) (*Server, error) {
+func New(telnetServerConfiguration *structs.TelnetServerConfiguration, globalTelnetConfiguration *structs.TelnetManagerConfiguration, sharedConnectionCounter *uint32, maxConnections uint32, closeConnectionChannel *chan struct{}, collector *collector.Collector, authService *auth.Service, timelineManager *tlmanager.Instance, telnetHandler TelnetDataHandler) (*Server, error) {
 
 	logger := logh.CreateContextualLogger(constants.StringsPKG, "telnetsrv")
 
@@ -96,10 +118,36 @@ func New(telnetServerConfiguration *structs.TelnetServerConfiguration, globalTel
 
 	strPort := fmt.Sprintf("%d", telnetServerConfiguration.Port)
 
+	maxPendingBuffers := int32(telnetServerConfiguration.MaxPendingBuffers)
+	if maxPendingBuffers <= 0 {
+		maxPendingBuffers = cDefaultMaxPendingBuffers
+	}
+
+	// the rejected lines are only answered if the handler describes its errors
+	var errorHandler TelnetErrorHandler
+	if telnetServerConfiguration.ErrorResponses {
+		errorHandler, _ = telnetHandler.(TelnetErrorHandler)
+	}
+
+	maxErrorResponsesPerSecond := telnetServerConfiguration.MaxErrorResponsesPerSecond
+	if maxErrorResponsesPerSecond <= 0 {
+		maxErrorResponsesPerSecond = cDefaultMaxErrorResponsesPerSecond
+	}
+
+	tlsConfig, err := tlsconfig.New(&telnetServerConfiguration.TLS)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Server{
 		listenAddress:                fmt.Sprintf("%s:%d", telnetServerConfiguration.Host, telnetServerConfiguration.Port),
+		tlsConfig:                    tlsConfig,
 		maxBufferSize:                telnetServerConfiguration.MaxBufferSize,
+		maxPendingBuffers:            maxPendingBuffers,
+		errorHandler:                 errorHandler,
+		maxErrorResponsesPerSecond:   maxErrorResponsesPerSecond,
 		collector:                    collector,
+		auth:                         authService,
 		logger:                       logger,
 		timelineManager:              timelineManager,
 		telnetHandler:                telnetHandler,
@@ -149,7 +197,7 @@ const (
 func (server *Server) Listen() error {
 
 	var err error
-	server.listener, err = net.Listen("tcp", server.listenAddress)
+	server.listener, err = tlsconfig.Listen(server.listenAddress, server.tlsConfig)
 	if nil != err {
 		return err
 	}
@@ -157,7 +205,7 @@ func (server *Server) Listen() error {
 	go server.collectStats()
 
 	if logh.InfoEnabled {
-		server.logger.Info().Str(constants.StringsFunc, cFuncListen).Msgf("listening telnet connections at %q...", server.listener.Addr())
+		server.logger.Info().Str(constants.StringsFunc, cFuncListen).Msgf("listening telnet connections at %q (tls: %t)...", server.listener.Addr(), server.tlsConfig != nil)
 	}
 
 	go func() {
@@ -249,17 +297,35 @@ func (server *Server) Listen() error {
 	return nil
 }
 
-// handleConnection - handles an incoming connection
+// handleConnection - handles an incoming connection (the client certificate identity replaces the ip in the handlers)
 func (server *Server) handleConnection(conn net.Conn, ip string) {
 
 	defer server.recover(conn, "handleConnection")
 
 	startTime := time.Now()
 
+	identity, err := tlsconfig.Handshake(conn)
+	if err != nil {
+		if !server.telnetServerConfiguration.SilenceLogs && logh.WarnEnabled {
+			server.logger.Warn().Str(constants.StringsFunc, cFuncListen).Err(err).Msgf("tls handshake failed, closing connection from %s", ip)
+		}
+		go server.closeConnection(conn, ccrTLS, true)
+		return
+	}
+
+	if identity != constants.StringsEmpty {
+		server.statsNetworkIdentity(cFuncListen, identity)
+		ip = identity
+	}
+
 	buffer := make([]byte, server.maxBufferSize)
 	data := make([]byte, 0)
-	var err error
 	var n int
+	var pendingBuffers int32
+	var token *auth.Token
+	var authenticated bool
+	session := newSession(conn, server)
+	commandHandler, _ := server.telnetHandler.(TelnetCommandHandler)
 ConnLoop:
 	for {
 		select {
@@ -269,29 +335,44 @@ ConnLoop:
 		default:
 		}
 
-		err = conn.SetWriteDeadline(time.Now().Add(server.telnetServerConfiguration.MaxIdleConnectionTimeout.Duration))
-		if err != nil {
-			go server.closeConnection(conn, ccrWDeadline, true)
-			break ConnLoop
-		}
+		// the collector is saturated, stops reading (and sending the OK) to slow down the client
+		if atomic.LoadInt32(&pendingBuffers) >= server.maxPendingBuffers {
 
-		_, err = conn.Write(okResponse)
-		if err != nil {
-			if err == io.EOF {
-				go server.closeConnection(conn, ccrWEOF, true)
-				break ConnLoop
+			server.statsTelnetSlowDown(cFuncListen)
+
+			for atomic.LoadInt32(&pendingBuffers) >= server.maxPendingBuffers && !server.terminate {
+				time.Sleep(cSlowDownInterval)
 			}
 
-			if castedErr, ok := err.(net.Error); ok && castedErr.Timeout() {
-				go server.closeConnection(conn, ccrWTimeout, true)
+			continue
+		}
+
+		// the protocols answering their own commands (opentsdb) do not expect an OK before each read
+		if commandHandler == nil {
+			err = conn.SetWriteDeadline(time.Now().Add(server.telnetServerConfiguration.MaxIdleConnectionTimeout.Duration))
+			if err != nil {
+				go server.closeConnection(conn, ccrWDeadline, true)
 				break ConnLoop
 			}
 
-			go server.closeConnection(conn, ccrWUnknown, true)
-			if !server.telnetServerConfiguration.SilenceLogs && logh.WarnEnabled {
-				server.logger.Warn().Err(err).Msg("telnet connection write: unexpected error")
+			_, err = conn.Write(okResponse)
+			if err != nil {
+				if err == io.EOF {
+					go server.closeConnection(conn, ccrWEOF, true)
+					break ConnLoop
+				}
+
+				if castedErr, ok := err.(net.Error); ok && castedErr.Timeout() {
+					go server.closeConnection(conn, ccrWTimeout, true)
+					break ConnLoop
+				}
+
+				go server.closeConnection(conn, ccrWUnknown, true)
+				if !server.telnetServerConfiguration.SilenceLogs && logh.WarnEnabled {
+					server.logger.Warn().Err(err).Msg("telnet connection write: unexpected error")
+				}
+				break ConnLoop
 			}
-			break ConnLoop
 		}
 
 		err = conn.SetReadDeadline(time.Now().Add(server.telnetServerConfiguration.MaxIdleConnectionTimeout.Duration))
@@ -300,8 +381,20 @@ ConnLoop:
 			break ConnLoop
 		}
 
+		// checked after the new deadline, the exit may have interrupted the previous one
+		if session.exiting() {
+			go server.closeConnection(conn, ccrExit, true)
+			break ConnLoop
+		}
+
 		n, err = conn.Read(buffer)
 		if err != nil {
+			if session.exiting() {
+				err = nil
+				go server.closeConnection(conn, ccrExit, true)
+				break ConnLoop
+			}
+
 			if err == io.EOF {
 				go server.closeConnection(conn, ccrEOF, true)
 				break ConnLoop
@@ -326,13 +419,42 @@ ConnLoop:
 		data = append(data, buffer[0:n]...)
 
 		if data[len(data)-1] == lineSeparator {
+
+			// the authentication lines are handled before the data lines of the same buffer
+			if server.auth.Enabled() {
+				data, token, authenticated = server.authenticate(data, token, ip)
+				if !authenticated {
+					go server.closeConnection(conn, ccrAuth, true)
+					break ConnLoop
+				}
+
+				if len(data) == 0 {
+					continue
+				}
+			}
+
 			dataCopy := append(make([]byte, 0, len(data)), data...)
 			data = make([]byte, 0)
+			connToken := token
+
+			atomic.AddInt32(&pendingBuffers, 1)
 
 			go func() {
+				defer atomic.AddInt32(&pendingBuffers, -1)
+
 				byteLines := bytes.Split(dataCopy, lineSplitter)
 				for _, byteLine := range byteLines {
-					if ok := server.telnetHandler.Handle(string(byteLine), ip); ok {
+
+					if session.exiting() {
+						return
+					}
+
+					line := string(byteLine)
+					if commandHandler != nil && commandHandler.HandleCommand(line, ip, session) {
+						continue
+					}
+
+					if server.handle(line, ip, connToken, session) {
 						server.statsTelnetCommandSuccessesInc()
 					} else {
 						server.statsTelnetCommandFailuresInc()
@@ -358,6 +480,81 @@ ConnLoop:
 	}
 }
 
+const cFuncHandleLine string = "handle"
+
+// handle - handles the data line, answering the error to the client if the error responses are enabled
+func (server *Server) handle(line, ip string, token *auth.Token, session *Session) bool {
+
+	if server.errorHandler == nil {
+		return server.telnetHandler.Handle(line, ip, token)
+	}
+
+	gerr := server.errorHandler.HandleError(line, ip, token)
+	if gerr == nil {
+		return true
+	}
+
+	// a broken client would receive one error per line, the exceeding ones are only counted
+	if !session.takeErrorToken() {
+		server.statsTelnetErrorResponse(cFuncHandleLine, true)
+		return false
+	}
+
+	server.statsTelnetErrorResponse(cFuncHandleLine, false)
+
+	err := session.Write(server.errorHandler.ErrorResponse(line, gerr))
+	if err != nil && !server.telnetServerConfiguration.SilenceLogs && logh.WarnEnabled {
+		server.logger.Warn().Str(constants.StringsFunc, cFuncHandleLine).Err(err).Msgf("error answering the rejected line to %s", ip)
+	}
+
+	return false
+}
+
+const cFuncAuthenticate string = "authenticate"
+
+// authenticate - handles the leading "auth <token>" lines of the buffer, returning the remaining lines
+// and the connection token (data sent before the authentication is refused)
+func (server *Server) authenticate(data []byte, token *auth.Token, ip string) ([]byte, *auth.Token, bool) {
+
+	for len(data) > 0 {
+
+		end := bytes.IndexByte(data, lineSeparator)
+		line := strings.TrimSpace(string(data[:end]))
+
+		if line == constants.StringsEmpty {
+			data = data[end+1:]
+			continue
+		}
+
+		if !auth.IsHandshake(line) {
+			break
+		}
+
+		var gerr gobol.Error
+		token, gerr = server.auth.Handshake(line)
+		if gerr != nil {
+			server.telnetHandler.GetValidationService().StatsValidationError(cFuncAuthenticate, constants.StringsEmpty, ip, server.telnetHandler.GetSourceType(), gerr)
+			if !server.telnetServerConfiguration.SilenceLogs && logh.WarnEnabled {
+				server.logger.Warn().Str(constants.StringsFunc, cFuncAuthenticate).Err(gerr).Msgf("authentication failed, closing connection from %s", ip)
+			}
+			return nil, nil, false
+		}
+
+		data = data[end+1:]
+	}
+
+	if token == nil && len(data) > 0 {
+		gerr := server.auth.Authorize(nil, constants.StringsEmpty, auth.OperationWrite, server.telnetHandler.GetSourceType().Name)
+		server.telnetHandler.GetValidationService().StatsValidationError(cFuncAuthenticate, constants.StringsEmpty, ip, server.telnetHandler.GetSourceType(), gerr)
+		if !server.telnetServerConfiguration.SilenceLogs && logh.WarnEnabled {
+			server.logger.Warn().Str(constants.StringsFunc, cFuncAuthenticate).Msgf("data received before the authentication, closing connection from %s", ip)
+		}
+		return nil, nil, false
+	}
+
+	return data, token, true
+}
+
 // increaseCounter - increases the counter
 func (server *Server) increaseCounter(num *uint32) uint32 {
 
@@ -0,0 +1,93 @@
+package telnetsrv
+
+import (
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// The connection of the lines being handled, used by the commands to answer the client.
+//
+
+// Session - the telnet connection exposed to the command handlers
+type Session struct {
+	conn        net.Conn
+	server      *Server
+	exit        int32
+	errorMutex  sync.Mutex
+	errorTokens float64
+	lastRefill  time.Time
+}
+
+// newSession - creates the session of the connection
+func newSession(conn net.Conn, server *Server) *Session {
+
+	return &Session{
+		conn:        conn,
+		server:      server,
+		errorTokens: math.Max(server.maxErrorResponsesPerSecond, 1),
+		lastRefill:  time.Now(),
+	}
+}
+
+// Write - writes the response to the client
+func (s *Session) Write(response []byte) error {
+
+	err := s.conn.SetWriteDeadline(time.Now().Add(s.server.telnetServerConfiguration.MaxIdleConnectionTimeout.Duration))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(response)
+
+	return err
+}
+
+// Exit - closes the connection, the remaining lines of the buffer are discarded
+func (s *Session) Exit() {
+
+	atomic.StoreInt32(&s.exit, 1)
+
+	// unblocks the connection read, the connection is closed by its loop
+	s.conn.SetReadDeadline(time.Now())
+}
+
+// exiting - checks if the client asked to close the connection
+func (s *Session) exiting() bool {
+	return atomic.LoadInt32(&s.exit) == 1
+}
+
+// Stats - returns the counters of the server
+func (s *Session) Stats() *Stats {
+	return s.server.Stats()
+}
+
+// takeErrorToken - refills the error responses bucket and takes one token, the bucket
+// holds one second of error responses and at least one token (the lines of a connection are
+// handled concurrently)
+func (s *Session) takeErrorToken() bool {
+
+	s.errorMutex.Lock()
+	defer s.errorMutex.Unlock()
+
+	now := time.Now()
+	rate := s.server.maxErrorResponsesPerSecond
+
+	s.errorTokens += now.Sub(s.lastRefill).Seconds() * rate
+	if max := math.Max(rate, 1); s.errorTokens > max {
+		s.errorTokens = max
+	}
+
+	s.lastRefill = now
+
+	if s.errorTokens < 1 {
+		return false
+	}
+
+	s.errorTokens--
+
+	return true
+}
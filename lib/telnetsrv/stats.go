@@ -1,6 +1,8 @@
 package telnetsrv
 
 import (
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/uol/mycenae/lib/constants"
@@ -20,8 +22,36 @@ const (
 	metricTelnetCommandCount         string = "telnet.command.count"
 	metricTelnetCommandFailures      string = "telnet.command.fail.count"
 	metricTelnetCommandSuccesses     string = "telnet.command.success.count"
+	metricTelnetSlowDown             string = "telnet.slowdown.count"
+	metricTelnetErrorResponse        string = "telnet.error.response.count"
+	stringSuppressed                 string = "suppressed"
 )
 
+// Stats - the counters of a telnet server since it was started
+type Stats struct {
+	Port            string
+	Source          string
+	Connections     uint32
+	NodeConnections uint32
+	Received        uint64
+	Failures        uint64
+	Successes       uint64
+}
+
+// Stats - returns the current counters of the server
+func (server *Server) Stats() *Stats {
+
+	return &Stats{
+		Port:            server.port,
+		Source:          server.telnetHandler.GetSourceType().Name,
+		Connections:     atomic.LoadUint32(&server.numLocalConnections),
+		NodeConnections: atomic.LoadUint32(server.sharedConnectionCounter),
+		Received:        atomic.LoadUint64(&server.received),
+		Failures:        atomic.LoadUint64(&server.failures),
+		Successes:       atomic.LoadUint64(&server.successes),
+	}
+}
+
 func (server *Server) statsNetworkConnection(function string) {
 
 	server.timelineManager.FlattenAvgN(
@@ -42,6 +72,16 @@ func (server *Server) statsNetworkIP(function, ip string) {
 	)
 }
 
+func (server *Server) statsNetworkIdentity(function, identity string) {
+
+	server.timelineManager.FlattenCountIncN(
+		function,
+		constants.StringsMetricNetworkIdentity,
+		constants.StringsIdentity, identity,
+		constants.StringsSource, server.telnetHandler.GetSourceType().Name,
+	)
+}
+
 func (server *Server) statsNetworkConnectionOpen(function string) {
 
 	server.timelineManager.FlattenCountIncN(
@@ -134,15 +174,42 @@ func (server *Server) statsTelnetAccumulationHashes() error {
 
 func (server *Server) statsTelnetCommandCountInc() {
 
+	atomic.AddUint64(&server.received, 1)
+
 	server.timelineManager.AccumulateCustomHashN(server.hashMetricTelnetCommandCount)
 }
 
 func (server *Server) statsTelnetCommandFailuresInc() {
 
+	atomic.AddUint64(&server.failures, 1)
+
 	server.timelineManager.AccumulateCustomHashN(server.hashMetricTelnetCommandFailures)
 }
 
 func (server *Server) statsTelnetCommandSuccessesInc() {
 
+	atomic.AddUint64(&server.successes, 1)
+
 	server.timelineManager.AccumulateCustomHashN(server.hashMetricTelnetCommandSuccesses)
 }
+
+func (server *Server) statsTelnetSlowDown(function string) {
+
+	server.timelineManager.FlattenCountIncN(
+		function,
+		metricTelnetSlowDown,
+		stringPort, server.port,
+		constants.StringsSource, server.telnetHandler.GetSourceType().Name,
+	)
+}
+
+func (server *Server) statsTelnetErrorResponse(function string, suppressed bool) {
+
+	server.timelineManager.FlattenCountIncN(
+		function,
+		metricTelnetErrorResponse,
+		stringPort, server.port,
+		constants.StringsSource, server.telnetHandler.GetSourceType().Name,
+		stringSuppressed, strconv.FormatBool(suppressed),
+	)
+}
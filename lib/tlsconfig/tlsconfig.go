@@ -0,0 +1,152 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Builds the tls configuration of the listeners and extracts the client certificate identity.
+//
+
+// Enabled - checks if the tls is configured
+func Enabled(conf *structs.TLSConfiguration) bool {
+	return conf != nil && conf.CertFile != constants.StringsEmpty
+}
+
+// New - creates the server tls configuration, nil is returned if no certificate is configured
+func New(conf *structs.TLSConfiguration) (*tls.Config, error) {
+
+	if !Enabled(conf) {
+		return nil, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the tls certificate: %s", err.Error())
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if conf.ClientCAFile == constants.StringsEmpty {
+		if conf.RequireClientCert {
+			return nil, errors.New("a client ca file is required to verify the client certificates")
+		}
+		return tlsConfig, nil
+	}
+
+	tlsConfig.ClientCAs, err = loadCertPool(conf.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.RequireClientCert {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// NewClient - creates the configuration of the requests to the other nodes, presenting the
+// listener certificate as the client certificate, the node certificates are verified using the
+// client ca file (the nodes are expected to share the same ca) or the system roots if there is none
+func NewClient(conf *structs.TLSConfiguration) (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if !Enabled(conf) {
+		return tlsConfig, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the tls certificate: %s", err.Error())
+	}
+
+	tlsConfig.Certificates = []tls.Certificate{certificate}
+
+	if conf.ClientCAFile != constants.StringsEmpty {
+		tlsConfig.RootCAs, err = loadCertPool(conf.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCertPool - loads the ca certificates of the file
+func loadCertPool(file string) (*x509.CertPool, error) {
+
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the client ca file: %s", err.Error())
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in the client ca file: %s", file)
+	}
+
+	return pool, nil
+}
+
+// Listen - listens tcp connections, using tls if it is configured
+func Listen(address string, tlsConfig *tls.Config) (net.Listener, error) {
+
+	if tlsConfig == nil {
+		return net.Listen("tcp", address)
+	}
+
+	return tls.Listen("tcp", address, tlsConfig)
+}
+
+// Handshake - runs the tls handshake of the connection (if it is a tls one), returning the client identity
+func Handshake(conn net.Conn) (string, error) {
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return constants.StringsEmpty, nil
+	}
+
+	if err := tlsConn.Handshake(); err != nil {
+		return constants.StringsEmpty, err
+	}
+
+	return identity(tlsConn.ConnectionState()), nil
+}
+
+// RequestIdentity - returns the client identity of the http request (empty if there is none)
+func RequestIdentity(r *http.Request) string {
+
+	if r.TLS == nil {
+		return constants.StringsEmpty
+	}
+
+	return identity(*r.TLS)
+}
+
+// identity - the common name of the verified client certificate
+func identity(state tls.ConnectionState) string {
+
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return constants.StringsEmpty
+	}
+
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
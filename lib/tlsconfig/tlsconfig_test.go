@@ -0,0 +1,275 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// testPKI - a ca, the server certificate and a client certificate signed by it
+type testPKI struct {
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caFile string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pki := &testPKI{dir: t.TempDir(), ca: ca, caKey: key}
+	pki.caFile = pki.write(t, "ca.pem", "CERTIFICATE", der)
+
+	return pki
+}
+
+// write - writes the pem block to a file of the temporary directory
+func (pki *testPKI) write(t *testing.T, name, blockType string, der []byte) string {
+
+	file := filepath.Join(pki.dir, name)
+
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+// issue - creates a certificate signed by the ca, returning its certificate and key files
+func (pki *testPKI) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) (string, string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, pki.ca, &key.PublicKey, pki.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pki.write(t, commonName+".pem", "CERTIFICATE", der), pki.write(t, commonName+".key", "EC PRIVATE KEY", keyDER)
+}
+
+func TestNewDisabled(t *testing.T) {
+
+	assert.False(t, Enabled(nil))
+	assert.False(t, Enabled(&structs.TLSConfiguration{KeyFile: "server.key"}), "the certificate enables the tls")
+
+	tlsConfig, err := New(&structs.TLSConfiguration{})
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	tlsConfig, err = NewClient(&structs.TLSConfiguration{})
+	assert.NoError(t, err)
+	assert.False(t, tlsConfig.InsecureSkipVerify, "the node certificates are verified")
+	assert.Nil(t, tlsConfig.RootCAs, "the system roots")
+	assert.Empty(t, tlsConfig.Certificates)
+}
+
+func TestNewInvalid(t *testing.T) {
+
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+
+	invalid := map[string]structs.TLSConfiguration{
+		"missing key":               {CertFile: certFile},
+		"missing certificate file":  {CertFile: filepath.Join(pki.dir, "none.pem"), KeyFile: keyFile},
+		"client ca required":        {CertFile: certFile, KeyFile: keyFile, RequireClientCert: true},
+		"missing client ca file":    {CertFile: certFile, KeyFile: keyFile, ClientCAFile: filepath.Join(pki.dir, "none.pem")},
+		"client ca without any pem": {CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile + ".none"},
+	}
+
+	if err := ioutil.WriteFile(keyFile+".none", []byte("no certificates here"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, conf := range invalid {
+		conf := conf
+		_, err := New(&conf)
+		assert.Error(t, err, name)
+	}
+
+	_, err := NewClient(&structs.TLSConfiguration{CertFile: certFile})
+	assert.Error(t, err)
+}
+
+func TestNewClientAuth(t *testing.T) {
+
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+
+	tlsConfig, err := New(&structs.TLSConfiguration{CertFile: certFile, KeyFile: keyFile})
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+		assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	}
+
+	tlsConfig, err = New(&structs.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, ClientCAFile: pki.caFile})
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+	}
+
+	tlsConfig, err = New(&structs.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, ClientCAFile: pki.caFile, RequireClientCert: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	}
+
+	tlsConfig, err = NewClient(&structs.TLSConfiguration{CertFile: certFile, KeyFile: keyFile})
+	if assert.NoError(t, err) {
+		assert.Len(t, tlsConfig.Certificates, 1, "the listener certificate is presented to the other nodes")
+	}
+
+	tlsConfig, err = NewClient(&structs.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, ClientCAFile: pki.caFile})
+	if assert.NoError(t, err) {
+		assert.NotNil(t, tlsConfig.RootCAs, "the nodes share the client ca")
+	}
+
+	_, err = NewClient(&structs.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, ClientCAFile: filepath.Join(pki.dir, "none.pem")})
+	assert.Error(t, err)
+}
+
+// handshake - connects to a listener using the client configuration (a plain connection if nil), returning the server side identity
+func handshake(t *testing.T, serverConf *tls.Config, clientConf *tls.Config) (string, error) {
+
+	listener, err := Listen("127.0.0.1:0", serverConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	type result struct {
+		identity string
+		err      error
+	}
+
+	results := make(chan result, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		identity, err := Handshake(conn)
+		results <- result{identity, err}
+	}()
+
+	if clientConf == nil {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	} else if conn, err := tls.Dial("tcp", listener.Addr().String(), clientConf); err == nil {
+		// waits the server side of the handshake
+		conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+
+	r := <-results
+
+	return r.identity, r.err
+}
+
+func TestHandshakeIdentity(t *testing.T) {
+
+	pki := newTestPKI(t)
+	serverCert, serverKey := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := pki.issue(t, "client-1", x509.ExtKeyUsageClientAuth)
+
+	serverConf, err := New(&structs.TLSConfiguration{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: pki.caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ca)
+
+	identity, err := handshake(t, serverConf, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{certificate}})
+	assert.NoError(t, err)
+	assert.Equal(t, "client-1", identity)
+
+	identity, err = handshake(t, serverConf, &tls.Config{RootCAs: roots})
+	assert.NoError(t, err, "the client certificate is optional")
+	assert.Empty(t, identity)
+
+	serverConf.ClientAuth = tls.RequireAndVerifyClientCert
+
+	_, err = handshake(t, serverConf, &tls.Config{RootCAs: roots})
+	assert.Error(t, err, "the client certificate is required")
+
+	identity, err = handshake(t, nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, identity, "not a tls connection")
+}
+
+func TestRequestIdentity(t *testing.T) {
+
+	assert.Empty(t, RequestIdentity(&http.Request{}))
+	assert.Empty(t, RequestIdentity(&http.Request{TLS: &tls.ConnectionState{}}), "no verified certificate")
+
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "client-1"}}}}}
+	assert.Equal(t, "client-1", RequestIdentity(&http.Request{TLS: verified}))
+}
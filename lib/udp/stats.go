@@ -2,6 +2,8 @@ package udp
 
 import "github.com/uol/mycenae/lib/constants"
 
+const metricRejected string = "udp.rejected"
+
 func (us *UDPserver) statsNetworkConnection(function string) {
 
 	us.timelineManager.FlattenCountIncN(
@@ -10,3 +12,29 @@ func (us *UDPserver) statsNetworkConnection(function string) {
 		constants.StringsSource, constants.StringsUDP,
 	)
 }
+
+func (us *UDPserver) statsRejected(function string) {
+
+	us.timelineManager.FlattenCountIncN(
+		function,
+		metricRejected,
+		constants.StringsSource, constants.StringsUDP,
+	)
+}
+
+func (us *UDPserver) statsNetworkConnectionTLS(function string) {
+	us.timelineManager.FlattenCountIncN(
+		function,
+		constants.StringsMetricNetworkConnection,
+		constants.StringsSource, constants.StringsUDP+cTLSSourceSuffix,
+	)
+}
+
+func (us *UDPserver) statsNetworkIdentity(function, identity string) {
+	us.timelineManager.FlattenCountIncN(
+		function,
+		constants.StringsMetricNetworkIdentity,
+		constants.StringsIdentity, identity,
+		constants.StringsSource, constants.StringsUDP+cTLSSourceSuffix,
+	)
+}
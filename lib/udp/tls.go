@@ -0,0 +1,151 @@
+package udp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/tlsconfig"
+	"github.com/uol/mycenae/lib/utils"
+)
+
+//
+// A tls over tcp alternative to the udp protocol: each line of the connection is handled as a udp packet.
+// When the authentication is enabled, the clients without a verified certificate must send the udp
+// shared secret as the first line.
+//
+
+const (
+	cFuncStartTLS        string        = "startTLS"
+	cFuncHandleTLSConn   string        = "handleTLSConnection"
+	cTLSIdleTimeout      time.Duration = 5 * time.Minute
+	cTLSMaxLineSize      int           = 1024 * 1024
+	cTLSSourceSuffix     string        = "-tls"
+	cMsgTLSSecretMissing string        = "the first line is not the shared secret, closing connection from %s"
+)
+
+// startTLS - listens the tls connections
+func (us *UDPserver) startTLS() {
+
+	tlsConfig, err := tlsconfig.New(&us.settings.TLS)
+	if err == nil && tlsConfig == nil {
+		err = fmt.Errorf("no tls certificate configured for the tls port %d", us.settings.TLSPort)
+	}
+
+	if err == nil {
+		us.tlsListener, err = tlsconfig.Listen(":"+fmt.Sprint(us.settings.TLSPort), tlsConfig)
+	}
+
+	if err != nil {
+		if logh.FatalEnabled {
+			us.logger.Fatal().Str(constants.StringsFunc, cFuncStartTLS).Err(err).Send()
+		}
+		return
+	}
+
+	if logh.InfoEnabled {
+		us.logger.Info().Str(constants.StringsFunc, cFuncStartTLS).Msgf("listen: tls binded to port: %d", us.settings.TLSPort)
+	}
+
+	for {
+
+		conn, err := us.tlsListener.Accept()
+		if err != nil {
+			if utils.IsConnectionClosedError(err) {
+				break
+			}
+
+			if logh.ErrorEnabled {
+				us.logger.Error().Str(constants.StringsFunc, cFuncStartTLS).Err(err).Send()
+			}
+
+			continue
+		}
+
+		go us.handleTLSConnection(conn)
+	}
+
+	if logh.InfoEnabled {
+		us.logger.Info().Str(constants.StringsFunc, cFuncStartTLS).Msg("stopping to listen tls connections")
+	}
+}
+
+// handleTLSConnection - handles each line of the connection as a packet
+func (us *UDPserver) handleTLSConnection(conn net.Conn) {
+
+	us.tlsConnections.Store(conn, struct{}{})
+
+	defer func() {
+		if r := recover(); r != nil {
+			if logh.ErrorEnabled {
+				us.logger.Error().Str(constants.StringsFunc, cFuncHandleTLSConn).Msgf("panic recovery: %v", r)
+			}
+		}
+
+		us.tlsConnections.Delete(conn)
+		conn.Close()
+	}()
+
+	saddr, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	if err := conn.SetDeadline(time.Now().Add(cTLSIdleTimeout)); err != nil {
+		return
+	}
+
+	identity, err := tlsconfig.Handshake(conn)
+	if err != nil {
+		if logh.WarnEnabled {
+			us.logger.Warn().Str(constants.StringsFunc, cFuncHandleTLSConn).Err(err).Msgf("tls handshake failed, closing connection from %s", saddr)
+		}
+		return
+	}
+
+	// the client certificate identity replaces the ip in the statistics
+	if identity != constants.StringsEmpty {
+		us.statsNetworkIdentity(cFuncHandleTLSConn, identity)
+		saddr = identity
+	}
+
+	secretRequired := us.auth.Enabled() && identity == constants.StringsEmpty
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), cTLSMaxLineSize)
+
+	for scanner.Scan() {
+
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		us.statsNetworkConnectionTLS(cFuncHandleTLSConn)
+
+		packet := append(make([]byte, 0, len(line)+1), line...)
+
+		if secretRequired {
+			if _, ok := us.auth.VerifySecret(append(packet, '\n')); !ok {
+				us.statsRejected(cFuncHandleTLSConn)
+				if logh.WarnEnabled {
+					us.logger.Warn().Str(constants.StringsFunc, cFuncHandleTLSConn).Msgf(cMsgTLSSecretMissing, saddr)
+				}
+				return
+			}
+			secretRequired = false
+			continue
+		}
+
+		us.handler.HandleUDPpacket(packet, saddr)
+
+		if err := conn.SetDeadline(time.Now().Add(cTLSIdleTimeout)); err != nil {
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil && !utils.IsConnectionClosedError(err) && logh.WarnEnabled {
+		us.logger.Warn().Str(constants.StringsFunc, cFuncHandleTLSConn).Err(err).Msgf("error reading the connection from %s", saddr)
+	}
+}
@@ -3,8 +3,10 @@ package udp
 import (
 	"net"
 	"strconv"
+	"sync"
 
 	"github.com/uol/logh"
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/utils"
 
@@ -18,10 +20,11 @@ type udpHandler interface {
 }
 
 // New - creates a new udp server instance
-func New(setUDP structs.SettingsUDP, handler udpHandler, timelineManager *tlmanager.Instance) *UDPserver {
+func New(setUDP structs.SettingsUDP, handler udpHandler, authService *auth.Service, timelineManager *tlmanager.Instance) *UDPserver {
 
 	return &UDPserver{
 		handler:         handler,
+		auth:            authService,
 		settings:        setUDP,
 		timelineManager: timelineManager,
 		logger:          logh.CreateContextualLogger(constants.StringsPKG, "udp", "source", "udp-json"),
@@ -31,8 +34,11 @@ func New(setUDP structs.SettingsUDP, handler udpHandler, timelineManager *tlmana
 // UDPserver - the server struct
 type UDPserver struct {
 	handler         udpHandler
+	auth            *auth.Service
 	settings        structs.SettingsUDP
 	sock            *net.UDPConn
+	tlsListener     net.Listener
+	tlsConnections  sync.Map
 	timelineManager *tlmanager.Instance
 	logger          *logh.ContextualLogger
 }
@@ -40,6 +46,10 @@ type UDPserver struct {
 // Start - starts the udp server
 func (us *UDPserver) Start() {
 	go us.asyncStart()
+
+	if us.settings.TLSPort > 0 {
+		go us.startTLS()
+	}
 }
 
 const cFuncAsyncStart string = "asyncStart"
@@ -103,8 +113,10 @@ func (us *UDPserver) asyncStart() {
 			if logh.ErrorEnabled {
 				us.logger.Error().Str(constants.StringsFunc, cFuncAsyncStart).Err(err).Msgf("read buffer from %s", saddr)
 			}
+		} else if packet, ok := us.auth.VerifySecret(buf[0:rlen]); ok {
+			go us.handler.HandleUDPpacket(packet, saddr)
 		} else {
-			go us.handler.HandleUDPpacket(buf[0:rlen], saddr)
+			us.statsRejected(cFuncAsyncStart)
 		}
 	}
 
@@ -121,4 +133,13 @@ func (us *UDPserver) Stop() {
 			us.logger.Error().Str(constants.StringsFunc, "Stop").Err(err).Send()
 		}
 	}
+
+	if us.tlsListener != nil {
+		us.tlsListener.Close()
+
+		us.tlsConnections.Range(func(conn, _ interface{}) bool {
+			conn.(net.Conn).Close()
+			return true
+		})
+	}
 }
@@ -1,8 +1,10 @@
 package validation
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/uol/gobol"
 
@@ -32,6 +34,18 @@ func NewValidationError(function, message string, err error, errCode string) gob
 	)
 }
 
+// errLimitValidation - too many requests error (the keyset has reached one of its limits)
+func errLimitValidation(function, message, errCode string) gobol.Error {
+	return tserr.NewErrorWithCode(
+		fmt.Errorf(message),
+		message,
+		cPackage,
+		function,
+		http.StatusTooManyRequests,
+		errCode,
+	)
+}
+
 // errCommonValidation - bad request error without error
 func errCommonValidation(function, message, errCode string) gobol.Error {
 	return NewValidationError(function, message, fmt.Errorf(message), errCode)
@@ -61,4 +75,55 @@ var (
 	ErrMalformedJSON       = errCommonValidation("ParsePoint", `JSON is malformed.`, "C21")
 	ErrInvalidTimestamp    = errCommonValidation("ValidateTimestamp", `Wrong Format: timestamp has a invalid format.`, "C22")
 	ErrReadingJSONBytes    = errCommonValidation("ParsePointArray", "Error reading JSON bytes.", "C23")
+	ErrNoMetricLabel       = errCommonValidation("ParseTimeseries", `Wrong Format: Label "__name__" is required.`, "C24")
+	ErrMalformedLine       = errCommonValidation("ParseInfluxLine", `Wrong Format: line protocol is malformed.`, "C25")
+	ErrParsingFieldValue   = errCommonValidation("ParseInfluxLine", `Error parsing field value from line protocol.`, "C26")
+	ErrMalformedGraphite   = errCommonValidation("ParseLine", `Wrong Format: graphite line must be "path value timestamp".`, "C27")
+	ErrMalformedPickle     = errCommonValidation("HandlePickle", `Wrong Format: graphite pickle payload is malformed.`, "C28")
+	ErrUnsupportedOTLPType = errCommonValidation("HandleOTLPMetrics", `Only the gauge, sum and histogram metric types are supported.`, "C29")
+	ErrPointsRateLimit     = errLimitValidation("ValidateLimits", `Limit exceeded: the keyset points per second limit was reached.`, "C30")
+	ErrNewSeriesLimit      = errLimitValidation("ValidateLimits", `Limit exceeded: the keyset new timeseries per hour limit was reached.`, "C31")
+	ErrActiveSeriesLimit   = errLimitValidation("ValidateLimits", `Limit exceeded: the keyset active timeseries limit was reached.`, "C32")
 )
+
+// errPolicyViolation - bad request error describing the policy violation of the point
+func errPolicyViolation(message, errCode string, args ...interface{}) gobol.Error {
+	message = "Policy violation: " + fmt.Sprintf(message, args...)
+
+	return NewValidationError("ValidatePolicy", message, errors.New(message), errCode)
+}
+
+// errMissingRequiredTag - a tag required by the keyset is missing
+func errMissingRequiredTag(keyset, tagKey string) gobol.Error {
+	return errPolicyViolation(`the tag "%s" required by the keyset "%s" is missing.`, "C33", tagKey, keyset)
+}
+
+// errForbiddenTagKey - the tag key is forbidden in the keyset
+func errForbiddenTagKey(keyset, tagKey string) gobol.Error {
+	return errPolicyViolation(`the tag key "%s" is forbidden in the keyset "%s".`, "C34", tagKey, keyset)
+}
+
+// errMaxTagsPerPoint - the point has more tags than allowed in the keyset
+func errMaxTagsPerPoint(keyset string, numTags, maxTags int) gobol.Error {
+	return errPolicyViolation(`the point has %d tags, the maximum allowed in the keyset "%s" is %d.`, "C35", numTags, keyset, maxTags)
+}
+
+// errMaxTagValueLength - the tag value is longer than allowed in the keyset
+func errMaxTagValueLength(keyset, tagKey, tagValue string, maxLength int) gobol.Error {
+	return errPolicyViolation(`the value "%s" of the tag "%s" has exceeded the maximum of %d characters allowed in the keyset "%s".`, "C36", tagValue, tagKey, maxLength, keyset)
+}
+
+// errTagValueNotAllowed - the tag value does not match the allowed values
+func errTagValueNotAllowed(keyset, tagKey, tagValue string) gobol.Error {
+	return errPolicyViolation(`the value "%s" of the tag "%s" does not match the values allowed in the keyset "%s".`, "C37", tagValue, tagKey, keyset)
+}
+
+// errTagValueDenied - the tag value matches the denied values
+func errTagValueDenied(keyset, tagKey, tagValue string) gobol.Error {
+	return errPolicyViolation(`the value "%s" of the tag "%s" is denied in the keyset "%s".`, "C38", tagValue, tagKey, keyset)
+}
+
+// errMetricPrefix - the metric has none of the allowed prefixes
+func errMetricPrefix(keyset, metric string, prefixes []string) gobol.Error {
+	return errPolicyViolation(`the metric "%s" does not start with a prefix allowed in the keyset "%s" (%s).`, "C39", metric, keyset, strings.Join(prefixes, ", "))
+}
@@ -0,0 +1,323 @@
+package validation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Per keyset ingestion limits: points per second, new timeseries per hour and active timeseries.
+// The timeseries are only checked in the metadata storage when the keyset has reached its limit.
+//
+
+const (
+	cFuncValidateLimits              string        = "ValidateLimits"
+	cFuncRefreshActiveSeries         string        = "refreshActiveSeries"
+	cDefaultActiveSeriesRefresh      time.Duration = 5 * time.Minute
+	limitTypePoints                  string        = "points"
+	limitTypeNewSeries               string        = "new_series"
+	limitTypeActiveSeries            string        = "active_series"
+	cLimitsUsageHourSeconds          int64         = 3600
+	cLimitsActiveSeriesQueryMaxItems int           = 0
+)
+
+// KeysetUsage - the current usage of a keyset
+type KeysetUsage struct {
+	Limits        structs.KeysetLimits `json:"limits"`
+	Custom        bool                 `json:"custom"`
+	TokensLeft    float64              `json:"tokensLeft"`
+	NewSeriesHour int64                `json:"newSeriesThisHour"`
+	ActiveSeries  int64                `json:"activeSeries"`
+}
+
+// keysetUsage - the counters of a keyset
+type keysetUsage struct {
+	tokens       float64
+	lastRefill   time.Time
+	hour         int64
+	newSeries    int64
+	activeSeries int64
+	activeLoaded bool
+	loading      bool
+}
+
+// limiter - keeps the limits and the usage of all keysets
+type limiter struct {
+	mutex    sync.Mutex
+	defaults structs.KeysetLimits
+	keysets  map[string]structs.KeysetLimits
+	usage    map[string]*keysetUsage
+}
+
+// newLimiter - creates the limiter using the configured limits
+func newLimiter(conf *structs.LimitsConfiguration) *limiter {
+
+	l := &limiter{
+		defaults: conf.Default,
+		keysets:  map[string]structs.KeysetLimits{},
+		usage:    map[string]*keysetUsage{},
+	}
+
+	for keyset, limits := range conf.Keysets {
+		l.keysets[keyset] = limits
+	}
+
+	return l
+}
+
+// limits - returns the keyset limits (must be called with the lock)
+func (l *limiter) limits(keyset string) (structs.KeysetLimits, bool) {
+
+	if limits, ok := l.keysets[keyset]; ok {
+		return limits, true
+	}
+
+	return l.defaults, false
+}
+
+// keysetUsage - returns the keyset counters, creating them if they do not exist (must be called with the lock)
+func (l *limiter) keysetUsage(keyset string, now time.Time) *keysetUsage {
+
+	usage, ok := l.usage[keyset]
+	if !ok {
+		limits, _ := l.limits(keyset)
+		usage = &keysetUsage{
+			tokens:     burst(limits),
+			lastRefill: now,
+			hour:       now.Unix() / cLimitsUsageHourSeconds,
+		}
+		l.usage[keyset] = usage
+	}
+
+	hour := now.Unix() / cLimitsUsageHourSeconds
+	if usage.hour != hour {
+		usage.hour = hour
+		usage.newSeries = 0
+	}
+
+	return usage
+}
+
+// burst - returns the maximum number of tokens of the keyset bucket (at least one token, or the rates
+// below one point per second would never accumulate a whole token)
+func burst(limits structs.KeysetLimits) float64 {
+
+	max := limits.PointsPerSecond
+	if limits.Burst > 0 {
+		max = float64(limits.Burst)
+	}
+
+	if max < 1 {
+		return 1
+	}
+
+	return max
+}
+
+// takeToken - refills the token bucket and takes one token (must be called with the lock)
+func (usage *keysetUsage) takeToken(limits structs.KeysetLimits, now time.Time) bool {
+
+	if limits.PointsPerSecond <= 0 {
+		return true
+	}
+
+	max := burst(limits)
+
+	usage.tokens += now.Sub(usage.lastRefill).Seconds() * limits.PointsPerSecond
+	if usage.tokens > max {
+		usage.tokens = max
+	}
+
+	usage.lastRefill = now
+
+	if usage.tokens < 1 {
+		return false
+	}
+
+	usage.tokens--
+
+	return true
+}
+
+// ValidateLimits - checks the keyset limits, the metadata storage is only checked
+// when the keyset has reached one of the timeseries limits (existing timeseries are always accepted)
+func (v *Service) ValidateLimits(p *structs.TSDBpoint, tsType, tsid string, tsidBytes []byte) gobol.Error {
+
+	if v.limiter == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	v.limiter.mutex.Lock()
+
+	limits, _ := v.limiter.limits(p.Keyset)
+	usage := v.limiter.keysetUsage(p.Keyset, now)
+
+	if !usage.takeToken(limits, now) {
+		v.limiter.mutex.Unlock()
+		v.statsLimitRejected(p.Keyset, limitTypePoints)
+		return ErrPointsRateLimit
+	}
+
+	newSeriesReached := limits.NewSeriesPerHour > 0 && usage.newSeries >= limits.NewSeriesPerHour
+	activeSeriesReached := limits.MaxActiveSeries > 0 && usage.activeSeries >= limits.MaxActiveSeries
+
+	if limits.MaxActiveSeries > 0 && !usage.activeLoaded && !usage.loading {
+		usage.loading = true
+		go v.loadActiveSeries(p.Keyset)
+	}
+
+	v.limiter.mutex.Unlock()
+
+	if !newSeriesReached && !activeSeriesReached {
+		return nil
+	}
+
+	found, gerr := v.metadataStorage.CheckMetadata(p.Keyset, tsType, tsid, tsidBytes)
+	if gerr != nil {
+		if logh.ErrorEnabled {
+			v.logger.Error().Str(constants.StringsFunc, cFuncValidateLimits).Err(gerr).Msg("error checking the timeseries, accepting the point")
+		}
+		return nil
+	}
+
+	if found {
+		return nil
+	}
+
+	if newSeriesReached {
+		v.statsLimitRejected(p.Keyset, limitTypeNewSeries)
+		return ErrNewSeriesLimit
+	}
+
+	v.statsLimitRejected(p.Keyset, limitTypeActiveSeries)
+
+	return ErrActiveSeriesLimit
+}
+
+// RegisterNewSeries - counts a new timeseries of the keyset (called when its metadata is created)
+func (v *Service) RegisterNewSeries(keyset string) {
+
+	if v.limiter == nil {
+		return
+	}
+
+	v.limiter.mutex.Lock()
+	defer v.limiter.mutex.Unlock()
+
+	usage := v.limiter.keysetUsage(keyset, time.Now())
+	usage.newSeries++
+	usage.activeSeries++
+}
+
+// GetLimits - returns the limits and the usage of the keyset
+func (v *Service) GetLimits(keyset string) (*KeysetUsage, bool) {
+
+	if v.limiter == nil {
+		return nil, false
+	}
+
+	v.limiter.mutex.Lock()
+	defer v.limiter.mutex.Unlock()
+
+	limits, custom := v.limiter.limits(keyset)
+	usage := v.limiter.keysetUsage(keyset, time.Now())
+
+	return &KeysetUsage{
+		Limits:        limits,
+		Custom:        custom,
+		TokensLeft:    usage.tokens,
+		NewSeriesHour: usage.newSeries,
+		ActiveSeries:  usage.activeSeries,
+	}, true
+}
+
+// SetLimits - changes the limits of the keyset (only in this node and until it is restarted)
+func (v *Service) SetLimits(keyset string, limits structs.KeysetLimits) bool {
+
+	if v.limiter == nil {
+		return false
+	}
+
+	v.limiter.mutex.Lock()
+	defer v.limiter.mutex.Unlock()
+
+	v.limiter.keysets[keyset] = limits
+
+	if usage, ok := v.limiter.usage[keyset]; ok && usage.tokens > burst(limits) {
+		usage.tokens = burst(limits)
+	}
+
+	return true
+}
+
+// DeleteLimits - the keyset goes back to the default limits
+func (v *Service) DeleteLimits(keyset string) bool {
+
+	if v.limiter == nil {
+		return false
+	}
+
+	v.limiter.mutex.Lock()
+	defer v.limiter.mutex.Unlock()
+
+	delete(v.limiter.keysets, keyset)
+
+	return true
+}
+
+// loadActiveSeries - counts the timeseries of the keyset in the metadata storage
+func (v *Service) loadActiveSeries(keyset string) {
+
+	_, total, gerr := v.metadataStorage.FilterMetadata(keyset, &metadata.Query{}, 0, cLimitsActiveSeriesQueryMaxItems)
+
+	v.limiter.mutex.Lock()
+	defer v.limiter.mutex.Unlock()
+
+	usage := v.limiter.keysetUsage(keyset, time.Now())
+	usage.loading = false
+	usage.activeLoaded = true
+
+	if gerr != nil {
+		if logh.ErrorEnabled {
+			v.logger.Error().Str(constants.StringsFunc, cFuncRefreshActiveSeries).Str(constants.StringsKeyset, keyset).Err(gerr).Send()
+		}
+		return
+	}
+
+	usage.activeSeries = int64(total)
+}
+
+// refreshActiveSeries - periodically counts the timeseries of the keysets having an active series limit
+func (v *Service) refreshActiveSeries(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		v.limiter.mutex.Lock()
+
+		keysets := make([]string, 0, len(v.limiter.usage))
+		for keyset := range v.limiter.usage {
+			if limits, _ := v.limiter.limits(keyset); limits.MaxActiveSeries > 0 {
+				keysets = append(keysets, keyset)
+			}
+		}
+
+		v.limiter.mutex.Unlock()
+
+		for _, keyset := range keysets {
+			v.loadActiveSeries(keyset)
+		}
+	}
+}
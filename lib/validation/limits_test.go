@@ -0,0 +1,260 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+// seriesBackend - a metadata backend knowing some timeseries of the keysets
+type seriesBackend struct {
+	metadata.Backend
+	mutex  sync.Mutex
+	series map[string][]string
+	fail   bool
+}
+
+// CheckMetadata - checks the known timeseries
+func (sb *seriesBackend) CheckMetadata(keyset, tsType, tsid string, tsidBytes []byte) (bool, gobol.Error) {
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if sb.fail {
+		return false, tserr.New(errors.New("solr is down"), "solr is down", cPackage, "CheckMetadata", http.StatusInternalServerError)
+	}
+
+	for _, known := range sb.series[keyset] {
+		if known == tsid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// FilterMetadata - returns only the total of timeseries of the keyset
+func (sb *seriesBackend) FilterMetadata(keyset string, query *metadata.Query, from, maxResults int) ([]metadata.Metadata, int, gobol.Error) {
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	return nil, len(sb.series[keyset]), nil
+}
+
+func newTestLimitsService(t *testing.T, limits structs.LimitsConfiguration, backend *seriesBackend) *Service {
+
+	limits.Enabled = true
+
+	s, err := New(
+		&structs.ValidationConfiguration{
+			KeysetNameRegexp: `(?i)^[a-z_]{1}[a-z0-9_\-]+[a-z0-9]{1}$`,
+			DefaultTTL:       1,
+			Limits:           limits,
+		},
+		&metadata.Storage{Backend: backend},
+		map[int]string{1: "ts01"},
+		&tlmanager.Instance{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestTakeToken(t *testing.T) {
+
+	start := time.Now()
+	limits := structs.KeysetLimits{PointsPerSecond: 2, Burst: 4}
+	usage := &keysetUsage{tokens: burst(limits), lastRefill: start}
+
+	steps := []struct {
+		at       time.Duration
+		accepted bool
+	}{
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},                      // the burst was used
+		{250 * time.Millisecond, false}, // half a token
+		{500 * time.Millisecond, true},
+		{500 * time.Millisecond, false},
+		{time.Hour, true}, // the refill is limited by the burst
+		{time.Hour, true},
+		{time.Hour, true},
+		{time.Hour, true},
+		{time.Hour, false},
+	}
+
+	for i, step := range steps {
+		assert.Equal(t, step.accepted, usage.takeToken(limits, start.Add(step.at)), "step %d", i)
+	}
+
+	unlimited := &keysetUsage{}
+	for i := 0; i < 100; i++ {
+		assert.True(t, unlimited.takeToken(structs.KeysetLimits{}, start))
+	}
+
+	assert.Equal(t, float64(4), burst(limits))
+	assert.Equal(t, float64(2), burst(structs.KeysetLimits{PointsPerSecond: 2}), "the rate is the default burst")
+	assert.Equal(t, float64(1), burst(structs.KeysetLimits{PointsPerSecond: 0.1}), "at least one token")
+
+	slow := structs.KeysetLimits{PointsPerSecond: 0.1}
+	usage = &keysetUsage{tokens: burst(slow), lastRefill: start}
+
+	assert.True(t, usage.takeToken(slow, start))
+	assert.False(t, usage.takeToken(slow, start.Add(5*time.Second)))
+	assert.True(t, usage.takeToken(slow, start.Add(10*time.Second)), "one point each ten seconds")
+}
+
+func TestKeysetUsageNewHour(t *testing.T) {
+
+	l := newLimiter(&structs.LimitsConfiguration{})
+
+	now := time.Date(2020, time.March, 1, 10, 59, 0, 0, time.UTC)
+
+	usage := l.keysetUsage("ks", now)
+	usage.newSeries = 10
+	usage.activeSeries = 10
+
+	assert.True(t, usage == l.keysetUsage("ks", now.Add(59*time.Second)))
+	assert.Equal(t, int64(10), usage.newSeries)
+
+	l.keysetUsage("ks", now.Add(time.Minute))
+	assert.Zero(t, usage.newSeries, "the new timeseries are counted by hour")
+	assert.Equal(t, int64(10), usage.activeSeries, "the active timeseries are kept")
+}
+
+func TestValidateLimitsPointsPerSecond(t *testing.T) {
+
+	s := newTestLimitsService(t, structs.LimitsConfiguration{
+		Default: structs.KeysetLimits{PointsPerSecond: 2},
+		Keysets: map[string]structs.KeysetLimits{"vip": {}},
+	}, &seriesBackend{})
+
+	point := func(keyset string) *structs.TSDBpoint {
+		return &structs.TSDBpoint{Metric: "cpu", Keyset: keyset}
+	}
+
+	assert.Nil(t, s.ValidateLimits(point("noisy"), "meta", "a", nil))
+	assert.Nil(t, s.ValidateLimits(point("noisy"), "meta", "a", nil))
+	assert.Equal(t, ErrPointsRateLimit, s.ValidateLimits(point("noisy"), "meta", "a", nil))
+	assert.Equal(t, http.StatusTooManyRequests, ErrPointsRateLimit.StatusCode())
+
+	assert.Nil(t, s.ValidateLimits(point("other"), "meta", "a", nil), "each keyset has its own bucket")
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, s.ValidateLimits(point("vip"), "meta", "a", nil), "the custom limits are unlimited")
+	}
+}
+
+func TestValidateLimitsNewSeriesPerHour(t *testing.T) {
+
+	backend := &seriesBackend{series: map[string][]string{"ks": {"known"}}}
+
+	s := newTestLimitsService(t, structs.LimitsConfiguration{
+		Default: structs.KeysetLimits{NewSeriesPerHour: 2},
+	}, backend)
+
+	point := &structs.TSDBpoint{Metric: "cpu", Keyset: "ks"}
+
+	s.RegisterNewSeries("ks")
+	assert.Nil(t, s.ValidateLimits(point, "meta", "new", nil), "the limit was not reached")
+
+	s.RegisterNewSeries("ks")
+	assert.Equal(t, ErrNewSeriesLimit, s.ValidateLimits(point, "meta", "new", nil))
+	assert.Nil(t, s.ValidateLimits(point, "meta", "known", nil), "the existing timeseries are accepted")
+
+	backend.fail = true
+	assert.Nil(t, s.ValidateLimits(point, "meta", "new", nil), "the point is accepted when the storage fails")
+}
+
+func TestValidateLimitsActiveSeries(t *testing.T) {
+
+	backend := &seriesBackend{series: map[string][]string{"ks": {"a", "b"}}}
+
+	s := newTestLimitsService(t, structs.LimitsConfiguration{
+		Default: structs.KeysetLimits{MaxActiveSeries: 2},
+	}, backend)
+
+	point := &structs.TSDBpoint{Metric: "cpu", Keyset: "ks"}
+
+	// the first point starts counting the timeseries
+	assert.Nil(t, s.ValidateLimits(point, "meta", "c", nil))
+
+	assert.Eventually(t, func() bool {
+		usage, _ := s.GetLimits("ks")
+		return usage.ActiveSeries == 2
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, ErrActiveSeriesLimit, s.ValidateLimits(point, "meta", "c", nil))
+	assert.Nil(t, s.ValidateLimits(point, "meta", "b", nil))
+}
+
+func TestLimitsDisabled(t *testing.T) {
+
+	s := &Service{}
+
+	assert.Nil(t, s.ValidateLimits(&structs.TSDBpoint{Keyset: "ks"}, "meta", "a", nil))
+	s.RegisterNewSeries("ks")
+
+	_, ok := s.GetLimits("ks")
+	assert.False(t, ok)
+	assert.False(t, s.SetLimits("ks", structs.KeysetLimits{}))
+	assert.False(t, s.DeleteLimits("ks"))
+
+	w := httptest.NewRecorder()
+	s.GetKeysetLimits(w, httptest.NewRequest(http.MethodGet, "/admin/keysets/ks/limits", nil), httprouter.Params{{Key: "keyset", Value: "ks"}})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestKeysetLimitsEndpoints(t *testing.T) {
+
+	s := newTestLimitsService(t, structs.LimitsConfiguration{
+		Default: structs.KeysetLimits{PointsPerSecond: 100},
+	}, &seriesBackend{})
+
+	router := httprouter.New()
+	router.GET("/admin/keysets/:keyset/limits", s.GetKeysetLimits)
+	router.PUT("/admin/keysets/:keyset/limits", s.SetKeysetLimits)
+	router.DELETE("/admin/keysets/:keyset/limits", s.DeleteKeysetLimits)
+
+	request := func(method, keyset, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/admin/keysets/"+keyset+"/limits", strings.NewReader(body)))
+		return w
+	}
+
+	w := request(http.MethodGet, "tenant", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"limits":{"pointsPerSecond":100,"burst":0,"newSeriesPerHour":0,"maxActiveSeries":0},"custom":false,"tokensLeft":100,"newSeriesThisHour":0,"activeSeries":0}`, w.Body.String())
+
+	w = request(http.MethodPut, "tenant", `{"pointsPerSecond":5,"burst":10}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"limits":{"pointsPerSecond":5,"burst":10,"newSeriesPerHour":0,"maxActiveSeries":0},"custom":true,"tokensLeft":10,"newSeriesThisHour":0,"activeSeries":0}`, w.Body.String(), "the tokens are limited by the new burst")
+
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPut, "tenant", `{"maxActiveSeries":-1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPut, "tenant", `{"burst":"x"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "-ks", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "tenant", "").Code)
+
+	usage, _ := s.GetLimits("tenant")
+	assert.False(t, usage.Custom)
+	assert.Equal(t, float64(100), usage.Limits.PointsPerSecond)
+}
@@ -0,0 +1,251 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Per keyset tag and metric policies: required tags, forbidden tag keys, maximum number of tags,
+// maximum tag value length, allowed and denied tag values (regular expressions) and metric prefixes.
+//
+
+// KeysetPolicyInfo - the policy applied to a keyset
+type KeysetPolicyInfo struct {
+	Policy structs.KeysetPolicy `json:"policy"`
+	Custom bool                 `json:"custom"`
+}
+
+// keysetPolicy - the compiled policy of a keyset
+type keysetPolicy struct {
+	conf      structs.KeysetPolicy
+	forbidden map[string]struct{}
+	allowed   map[string]*regexp.Regexp
+	denied    map[string]*regexp.Regexp
+}
+
+// policies - keeps the policies of all keysets
+type policies struct {
+	mutex    sync.RWMutex
+	defaults *keysetPolicy
+	keysets  map[string]*keysetPolicy
+}
+
+// compileRegexps - compiles the tag value regular expressions
+func compileRegexps(name string, exprs map[string]string) (map[string]*regexp.Regexp, error) {
+
+	compiled := make(map[string]*regexp.Regexp, len(exprs))
+
+	for tagKey, expr := range exprs {
+
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s regular expression for tag \"%s\": %s", name, tagKey, err.Error())
+		}
+
+		compiled[tagKey] = r
+	}
+
+	return compiled, nil
+}
+
+// newKeysetPolicy - compiles the policy
+func newKeysetPolicy(conf structs.KeysetPolicy) (*keysetPolicy, error) {
+
+	if conf.MaxTags < 0 || conf.MaxTagValueLength < 0 {
+		return nil, fmt.Errorf("the policy limits must not be negative")
+	}
+
+	allowed, err := compileRegexps("allowed", conf.AllowedTagValues)
+	if err != nil {
+		return nil, err
+	}
+
+	denied, err := compileRegexps("denied", conf.DeniedTagValues)
+	if err != nil {
+		return nil, err
+	}
+
+	forbidden := make(map[string]struct{}, len(conf.ForbiddenTagKeys))
+	for _, tagKey := range conf.ForbiddenTagKeys {
+		forbidden[tagKey] = struct{}{}
+	}
+
+	return &keysetPolicy{
+		conf:      conf,
+		forbidden: forbidden,
+		allowed:   allowed,
+		denied:    denied,
+	}, nil
+}
+
+// newPolicies - compiles the configured policies
+func newPolicies(conf *structs.PoliciesConfiguration) (*policies, error) {
+
+	defaults, err := newKeysetPolicy(conf.Default)
+	if err != nil {
+		return nil, fmt.Errorf("default policy: %s", err.Error())
+	}
+
+	p := &policies{
+		defaults: defaults,
+		keysets:  map[string]*keysetPolicy{},
+	}
+
+	for keyset, ksConf := range conf.Keysets {
+
+		policy, err := newKeysetPolicy(ksConf)
+		if err != nil {
+			return nil, fmt.Errorf("keyset \"%s\" policy: %s", keyset, err.Error())
+		}
+
+		p.keysets[keyset] = policy
+	}
+
+	return p, nil
+}
+
+// policy - returns the keyset policy (must be called with the lock)
+func (p *policies) policy(keyset string) (*keysetPolicy, bool) {
+
+	if policy, ok := p.keysets[keyset]; ok {
+		return policy, true
+	}
+
+	return p.defaults, false
+}
+
+// ValidatePolicy - checks the point against its keyset policy
+func (v *Service) ValidatePolicy(p *structs.TSDBpoint) gobol.Error {
+
+	if v.policies == nil {
+		return nil
+	}
+
+	v.policies.mutex.RLock()
+	policy, _ := v.policies.policy(p.Keyset)
+	v.policies.mutex.RUnlock()
+
+	if len(policy.conf.MetricPrefixes) > 0 {
+
+		found := false
+		for _, prefix := range policy.conf.MetricPrefixes {
+			if strings.HasPrefix(p.Metric, prefix) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return errMetricPrefix(p.Keyset, p.Metric, policy.conf.MetricPrefixes)
+		}
+	}
+
+	numTags := 0
+
+	for _, tag := range p.Tags {
+
+		if tag.Name == constants.StringsKSID || tag.Name == constants.StringsTTL {
+			continue
+		}
+
+		numTags++
+
+		if _, ok := policy.forbidden[tag.Name]; ok {
+			return errForbiddenTagKey(p.Keyset, tag.Name)
+		}
+
+		if policy.conf.MaxTagValueLength > 0 && len(tag.Value) > policy.conf.MaxTagValueLength {
+			return errMaxTagValueLength(p.Keyset, tag.Name, tag.Value, policy.conf.MaxTagValueLength)
+		}
+
+		if r, ok := policy.allowed[tag.Name]; ok && !r.MatchString(tag.Value) {
+			return errTagValueNotAllowed(p.Keyset, tag.Name, tag.Value)
+		}
+
+		if r, ok := policy.denied[tag.Name]; ok && r.MatchString(tag.Value) {
+			return errTagValueDenied(p.Keyset, tag.Name, tag.Value)
+		}
+	}
+
+	if policy.conf.MaxTags > 0 && numTags > policy.conf.MaxTags {
+		return errMaxTagsPerPoint(p.Keyset, numTags, policy.conf.MaxTags)
+	}
+
+	for _, required := range policy.conf.RequiredTags {
+
+		found := false
+		for _, tag := range p.Tags {
+			if tag.Name == required {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return errMissingRequiredTag(p.Keyset, required)
+		}
+	}
+
+	return nil
+}
+
+// GetPolicy - returns the keyset policy
+func (v *Service) GetPolicy(keyset string) (*KeysetPolicyInfo, bool) {
+
+	if v.policies == nil {
+		return nil, false
+	}
+
+	v.policies.mutex.RLock()
+	defer v.policies.mutex.RUnlock()
+
+	policy, custom := v.policies.policy(keyset)
+
+	return &KeysetPolicyInfo{
+		Policy: policy.conf,
+		Custom: custom,
+	}, true
+}
+
+// SetPolicy - changes the policy of the keyset (only in this node and until it is restarted)
+func (v *Service) SetPolicy(keyset string, conf structs.KeysetPolicy) error {
+
+	if v.policies == nil {
+		return nil
+	}
+
+	policy, err := newKeysetPolicy(conf)
+	if err != nil {
+		return err
+	}
+
+	v.policies.mutex.Lock()
+	defer v.policies.mutex.Unlock()
+
+	v.policies.keysets[keyset] = policy
+
+	return nil
+}
+
+// DeletePolicy - the keyset goes back to the default policy
+func (v *Service) DeletePolicy(keyset string) bool {
+
+	if v.policies == nil {
+		return false
+	}
+
+	v.policies.mutex.Lock()
+	defer v.policies.mutex.Unlock()
+
+	delete(v.policies.keysets, keyset)
+
+	return true
+}
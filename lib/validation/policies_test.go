@@ -0,0 +1,190 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+	tlmanager "github.com/uol/timelinemanager"
+
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func newTestPoliciesService(t *testing.T, conf structs.PoliciesConfiguration) *Service {
+
+	conf.Enabled = true
+
+	s, err := New(
+		&structs.ValidationConfiguration{
+			KeysetNameRegexp: `(?i)^[a-z_]{1}[a-z0-9_\-]+[a-z0-9]{1}$`,
+			DefaultTTL:       1,
+			Policies:         conf,
+		},
+		&metadata.Storage{},
+		map[int]string{1: "ts01"},
+		&tlmanager.Instance{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// policyPoint - builds a point of the keyset using the "key=value" tags
+func policyPoint(keyset, metric string, tags ...string) *structs.TSDBpoint {
+
+	p := &structs.TSDBpoint{
+		Metric: metric,
+		Keyset: keyset,
+		Tags: []structs.TSDBTag{
+			{Name: "ksid", Value: keyset},
+			{Name: "ttl", Value: "1"},
+		},
+	}
+
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, "=", 2)
+		p.Tags = append(p.Tags, structs.TSDBTag{Name: kv[0], Value: kv[1]})
+	}
+
+	return p
+}
+
+func TestValidatePolicy(t *testing.T) {
+
+	s := newTestPoliciesService(t, structs.PoliciesConfiguration{
+		Default: structs.KeysetPolicy{
+			RequiredTags:      []string{"host"},
+			ForbiddenTagKeys:  []string{"request_id"},
+			MaxTags:           3,
+			MaxTagValueLength: 8,
+			AllowedTagValues:  map[string]string{"env": "^(prod|dev)$"},
+			DeniedTagValues:   map[string]string{"host": "^tmp-"},
+			MetricPrefixes:    []string{"app.", "sys."},
+		},
+		Keysets: map[string]structs.KeysetPolicy{
+			"free": {},
+		},
+	})
+
+	checks := []struct {
+		point    *structs.TSDBpoint
+		expected gobol.Error
+	}{
+		{policyPoint("ks", "app.cpu", "host=a"), nil},
+		{policyPoint("ks", "sys.mem", "host=a", "env=prod", "dc=x"), nil},
+		{policyPoint("ks", "cpu", "host=a"), errMetricPrefix("ks", "cpu", []string{"app.", "sys."})},
+		{policyPoint("ks", "app.cpu", "dc=x"), errMissingRequiredTag("ks", "host")},
+		{policyPoint("ks", "app.cpu", "host=a", "request_id=1"), errForbiddenTagKey("ks", "request_id")},
+		{policyPoint("ks", "app.cpu", "host=a", "b=1", "c=1", "d=1"), errMaxTagsPerPoint("ks", 4, 3)},
+		{policyPoint("ks", "app.cpu", "host=a-long-name"), errMaxTagValueLength("ks", "host", "a-long-name", 8)},
+		{policyPoint("ks", "app.cpu", "host=a", "env=qa"), errTagValueNotAllowed("ks", "env", "qa")},
+		{policyPoint("ks", "app.cpu", "host=tmp-1"), errTagValueDenied("ks", "host", "tmp-1")},
+		{policyPoint("free", "cpu", "request_id=1", "b=1", "c=1", "d=1"), nil},
+	}
+
+	for i, check := range checks {
+		assert.Equal(t, check.expected, s.ValidatePolicy(check.point), "check %d (%s %v)", i, check.point.Metric, check.point.Tags)
+	}
+
+	assert.Nil(t, (&Service{}).ValidatePolicy(policyPoint("ks", "cpu")), "the policies are disabled")
+
+	gerr := s.ValidatePolicy(policyPoint("ks", "app.cpu", "host=tmp-1"))
+	if assert.NotNil(t, gerr) {
+		assert.Equal(t, `Policy violation: the value "tmp-1" of the tag "host" is denied in the keyset "ks".`, gerr.Message())
+		assert.Equal(t, "C38", gerr.ErrorCode())
+	}
+}
+
+func TestNewPoliciesInvalid(t *testing.T) {
+
+	invalid := []structs.PoliciesConfiguration{
+		{Default: structs.KeysetPolicy{MaxTags: -1}},
+		{Default: structs.KeysetPolicy{AllowedTagValues: map[string]string{"host": "("}}},
+		{Keysets: map[string]structs.KeysetPolicy{"ks": {DeniedTagValues: map[string]string{"host": "[a-"}}}},
+	}
+
+	for i, conf := range invalid {
+		_, err := newPolicies(&conf)
+		assert.Error(t, err, "configuration %d", i)
+	}
+
+	_, err := New(
+		&structs.ValidationConfiguration{Policies: structs.PoliciesConfiguration{Enabled: true, Default: invalid[0].Default}},
+		&metadata.Storage{},
+		nil,
+		&tlmanager.Instance{},
+	)
+	assert.Error(t, err, "the service is not created with an invalid policy")
+}
+
+func TestKeysetPolicyEndpoints(t *testing.T) {
+
+	s := newTestPoliciesService(t, structs.PoliciesConfiguration{
+		Default: structs.KeysetPolicy{MaxTags: 10},
+	})
+
+	router := httprouter.New()
+	router.GET("/admin/keysets/:keyset/policy", s.GetKeysetPolicy)
+	router.PUT("/admin/keysets/:keyset/policy", s.SetKeysetPolicy)
+	router.DELETE("/admin/keysets/:keyset/policy", s.DeleteKeysetPolicy)
+
+	request := func(method, keyset, body string) (int, KeysetPolicyInfo) {
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/admin/keysets/"+keyset+"/policy", strings.NewReader(body)))
+
+		info := KeysetPolicyInfo{}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return w.Code, info
+	}
+
+	code, info := request(http.MethodGet, "tenant", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, info.Custom)
+	assert.Equal(t, 10, info.Policy.MaxTags)
+
+	code, info = request(http.MethodPut, "tenant", `{"requiredTags":["host"],"forbiddenTagKeys":["tmp"]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, info.Custom)
+	assert.Equal(t, []string{"host"}, info.Policy.RequiredTags)
+	assert.Zero(t, info.Policy.MaxTags, "the custom policy replaces the default one")
+
+	assert.Equal(t, errForbiddenTagKey("tenant", "tmp"), s.ValidatePolicy(policyPoint("tenant", "cpu", "host=a", "tmp=1")))
+
+	code, _ = request(http.MethodPut, "tenant", `{"allowedTagValues":{"host":"("}}`)
+	assert.Equal(t, http.StatusBadRequest, code, "invalid regular expression")
+
+	code, _ = request(http.MethodPut, "tenant", `{"maxTags":-1}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = request(http.MethodPut, "tenant", `[]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	_, info = request(http.MethodGet, "tenant", "")
+	assert.Equal(t, []string{"host"}, info.Policy.RequiredTags, "the invalid policies are not stored")
+
+	code, _ = request(http.MethodDelete, "tenant", "")
+	assert.Equal(t, http.StatusNoContent, code)
+
+	assert.Nil(t, s.ValidatePolicy(policyPoint("tenant", "cpu", "host=a", "tmp=1")))
+
+	code, _ = request(http.MethodGet, "-x", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	w := httptest.NewRecorder()
+	(&Service{}).SetKeysetPolicy(w, httptest.NewRequest(http.MethodPut, "/admin/keysets/tenant/policy", strings.NewReader("{}")), httprouter.Params{{Key: "keyset", Value: "tenant"}})
+	assert.Equal(t, http.StatusNotFound, w.Code, "the policies are disabled")
+}
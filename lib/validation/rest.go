@@ -0,0 +1,172 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+//
+// Admin endpoints to read and change the keyset limits and policies at runtime.
+//
+
+const (
+	cFuncGetKeysetLimits    string = "GetKeysetLimits"
+	cFuncSetKeysetLimits    string = "SetKeysetLimits"
+	cFuncDeleteKeysetLimits string = "DeleteKeysetLimits"
+	cFuncGetKeysetPolicy    string = "GetKeysetPolicy"
+	cFuncSetKeysetPolicy    string = "SetKeysetPolicy"
+	cFuncDeleteKeysetPolicy string = "DeleteKeysetPolicy"
+	cMsgLimitsDisabled      string = "the keyset limits are disabled"
+	cMsgPoliciesDisabled    string = "the keyset policies are disabled"
+	cMsgInvalidKeyset       string = "parameter 'keyset' has an invalid format"
+	cMsgNegativeLimits      string = "the limits must not be negative"
+)
+
+func errAdminRequest(function, message string, code int) gobol.Error {
+	return tserr.New(
+		errors.New(message),
+		message,
+		cPackage,
+		function,
+		code,
+	)
+}
+
+// keysetParam - returns the validated keyset parameter
+func (v *Service) keysetParam(function string, enabled bool, disabledMessage string, ps httprouter.Params) (string, gobol.Error) {
+
+	if !enabled {
+		return constants.StringsEmpty, errAdminRequest(function, disabledMessage, http.StatusNotFound)
+	}
+
+	keyset := ps.ByName(constants.StringsKeyset)
+
+	if !v.keysetRegexp.MatchString(keyset) {
+		return constants.StringsEmpty, errAdminRequest(function, cMsgInvalidKeyset, http.StatusBadRequest)
+	}
+
+	return keyset, nil
+}
+
+// GetKeysetLimits - returns the keyset limits and its current usage
+func (v *Service) GetKeysetLimits(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := v.keysetParam(cFuncGetKeysetLimits, v.limiter != nil, cMsgLimitsDisabled, ps)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	usage, _ := v.GetLimits(keyset)
+
+	rip.SuccessJSON(w, http.StatusOK, usage)
+}
+
+// SetKeysetLimits - changes the keyset limits (only in this node, the configuration is used again after a restart)
+func (v *Service) SetKeysetLimits(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := v.keysetParam(cFuncSetKeysetLimits, v.limiter != nil, cMsgLimitsDisabled, ps)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	defer r.Body.Close()
+
+	limits := structs.KeysetLimits{}
+
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		rip.Fail(w, errAdminRequest(cFuncSetKeysetLimits, cWrongFormat, http.StatusBadRequest))
+		return
+	}
+
+	if limits.PointsPerSecond < 0 || limits.Burst < 0 || limits.NewSeriesPerHour < 0 || limits.MaxActiveSeries < 0 {
+		rip.Fail(w, errAdminRequest(cFuncSetKeysetLimits, cMsgNegativeLimits, http.StatusBadRequest))
+		return
+	}
+
+	v.SetLimits(keyset, limits)
+
+	usage, _ := v.GetLimits(keyset)
+
+	rip.SuccessJSON(w, http.StatusOK, usage)
+}
+
+// DeleteKeysetLimits - removes the keyset custom limits (the default limits are used)
+func (v *Service) DeleteKeysetLimits(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := v.keysetParam(cFuncDeleteKeysetLimits, v.limiter != nil, cMsgLimitsDisabled, ps)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	v.DeleteLimits(keyset)
+
+	rip.Success(w, http.StatusNoContent, nil)
+}
+
+// GetKeysetPolicy - returns the keyset policy
+func (v *Service) GetKeysetPolicy(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := v.keysetParam(cFuncGetKeysetPolicy, v.policies != nil, cMsgPoliciesDisabled, ps)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	info, _ := v.GetPolicy(keyset)
+
+	rip.SuccessJSON(w, http.StatusOK, info)
+}
+
+// SetKeysetPolicy - changes the keyset policy (only in this node, the configuration is used again after a restart)
+func (v *Service) SetKeysetPolicy(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := v.keysetParam(cFuncSetKeysetPolicy, v.policies != nil, cMsgPoliciesDisabled, ps)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	defer r.Body.Close()
+
+	policy := structs.KeysetPolicy{}
+
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		rip.Fail(w, errAdminRequest(cFuncSetKeysetPolicy, cWrongFormat, http.StatusBadRequest))
+		return
+	}
+
+	if err := v.SetPolicy(keyset, policy); err != nil {
+		rip.Fail(w, errAdminRequest(cFuncSetKeysetPolicy, err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	info, _ := v.GetPolicy(keyset)
+
+	rip.SuccessJSON(w, http.StatusOK, info)
+}
+
+// DeleteKeysetPolicy - removes the keyset custom policy (the default policy is used)
+func (v *Service) DeleteKeysetPolicy(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := v.keysetParam(cFuncDeleteKeysetPolicy, v.policies != nil, cMsgPoliciesDisabled, ps)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	v.DeletePolicy(keyset)
+
+	rip.Success(w, http.StatusNoContent, nil)
+}
@@ -19,6 +19,7 @@ type idType string
 const (
 	metricValidationError string                = "point.validation"
 	metricValidationCount string                = "point.validation.count"
+	metricLimitRejected   string                = "keyset.limit.rejected"
 	tagErrorCode          string                = "code"
 	tagIDType             string                = "id_type"
 	idTypeKeyset          idType                = "keyset"
@@ -173,3 +174,14 @@ func (v *Service) incValidationErrorCount() {
 		metricValidationCount,
 	)
 }
+
+// statsLimitRejected - counts the points rejected by the keyset limits
+func (v *Service) statsLimitRejected(keyset, limitType string) {
+
+	v.timelineManager.FlattenCountIncN(
+		cFuncValidateLimits,
+		metricLimitRejected,
+		constants.StringsTargetKSID, keyset,
+		constants.StringsType, limitType,
+	)
+}
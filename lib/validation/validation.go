@@ -42,6 +42,8 @@ type Service struct {
 	defaultTTLTag   structs.TSDBTag
 	keysetRegexp    *regexp.Regexp
 	timelineManager *tlmanager.Instance
+	limiter         *limiter
+	policies        *policies
 }
 
 // New - creates a new validation instance
@@ -67,6 +69,27 @@ func New(configuration *structs.ValidationConfiguration, metadataStorage *metada
 
 	s.storeValidationErrorCount()
 
+	if configuration.Limits.Enabled {
+
+		s.limiter = newLimiter(&configuration.Limits)
+
+		refreshInterval := configuration.Limits.ActiveSeriesRefreshInterval.Duration
+		if refreshInterval <= 0 {
+			refreshInterval = cDefaultActiveSeriesRefresh
+		}
+
+		go s.refreshActiveSeries(refreshInterval)
+	}
+
+	if configuration.Policies.Enabled {
+
+		var err error
+		s.policies, err = newPolicies(&configuration.Policies)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return s, nil
 }
 
@@ -18,14 +18,17 @@ import (
 	"github.com/uol/gobol/cassandra"
 	"github.com/uol/gobol/loader"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/graphite"
 	"github.com/uol/mycenae/lib/keyset"
 	"github.com/uol/mycenae/lib/keyspace"
 	"github.com/uol/mycenae/lib/memcached"
 	"github.com/uol/mycenae/lib/metadata"
 	"github.com/uol/mycenae/lib/persistence"
 	"github.com/uol/mycenae/lib/plot"
+	"github.com/uol/mycenae/lib/repair"
 	"github.com/uol/mycenae/lib/rest"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/telnet"
@@ -80,11 +83,14 @@ func main() {
 	timelineManager := createTimelineManager(&settings.Stats)
 	scyllaConn := createScyllaConnection(&settings.Cassandra)
 	memcachedConn := createMemcachedConnection(&settings.Memcached, timelineManager)
-	metadataStorage := createMetadataStorageService(&settings.MetadataSettings, timelineManager, memcachedConn)
+	metadataStorage := createMetadataStorageService(settings, timelineManager, memcachedConn, scyllaConn)
 	scyllaStorageService, keyspaceTTLMap := createScyllaStorageService(settings, devMode, timelineManager, scyllaConn, metadataStorage)
 	validationService := createValidation(settings, metadataStorage, keyspaceTTLMap, timelineManager)
-	collectorService := createCollectorService(settings, timelineManager, metadataStorage, scyllaConn, validationService, keyspaceTTLMap)
-	telnetManager := createTelnetManager(settings, collectorService, timelineManager, validationService)
+	repairService := createRepairService(settings, scyllaConn, metadataStorage, keyspaceTTLMap)
+	authService := createAuthService(settings, scyllaConn, timelineManager)
+	metadataJanitor := createMetadataJanitor(settings, timelineManager, metadataStorage, scyllaConn, keyspaceTTLMap)
+	collectorService := createCollectorService(settings, timelineManager, metadataStorage, scyllaConn, validationService, repairService, authService, keyspaceTTLMap)
+	telnetManager := createTelnetManager(settings, collectorService, timelineManager, validationService, authService)
 
 	err = timelineManager.Start()
 	if err != nil {
@@ -96,9 +102,11 @@ func main() {
 
 	keyspaceManager := createKeyspaceManager(settings, devMode, timelineManager, scyllaStorageService)
 	keysetManager := createKeysetManager(settings, metadataStorage)
-	plotService := createPlotService(settings, timelineManager, metadataStorage, scyllaConn, keyspaceTTLMap)
-	udpServer := createUDPServer(&settings.UDPserver, collectorService, timelineManager)
-	restServer := createRESTserver(settings, timelineManager, plotService, collectorService, keyspaceManager, keysetManager, memcachedConn, telnetManager)
+	plotService := createPlotService(settings, timelineManager, metadataStorage, scyllaConn, keyspaceTTLMap, authService)
+	udpServer := createUDPServer(&settings.UDPserver, collectorService, authService, timelineManager)
+	influxUDPServer := createInfluxUDPServer(&settings.InfluxUDPserver, collectorService, authService, timelineManager)
+	pickleServers := createGraphitePickleServers(settings, collectorService, validationService, authService, timelineManager)
+	restServer := createRESTserver(settings, timelineManager, plotService, collectorService, keyspaceManager, keysetManager, memcachedConn, telnetManager, validationService, repairService, authService)
 
 	if logh.InfoEnabled {
 		logger.Info().Msg("mycenae started successfully")
@@ -113,6 +121,10 @@ func main() {
 		logger.Info().Msg("stopping mycenae...")
 	}
 
+	if metadataJanitor != nil {
+		metadataJanitor.Stop()
+	}
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("stopping rest server")
 	}
@@ -133,6 +145,34 @@ func main() {
 		logger.Info().Msg("udp server stopped")
 	}
 
+	if influxUDPServer != nil {
+
+		if logh.InfoEnabled {
+			logger.Info().Msg("stopping influx udp server")
+		}
+
+		influxUDPServer.Stop()
+
+		if logh.InfoEnabled {
+			logger.Info().Msg("influx udp server stopped")
+		}
+	}
+
+	if len(pickleServers) > 0 {
+
+		if logh.InfoEnabled {
+			logger.Info().Msg("stopping graphite pickle servers")
+		}
+
+		for _, pickleServer := range pickleServers {
+			pickleServer.Shutdown()
+		}
+
+		if logh.InfoEnabled {
+			logger.Info().Msg("graphite pickle servers stopped")
+		}
+	}
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("stopping telnet manager")
 	}
@@ -143,6 +183,16 @@ func main() {
 		logger.Info().Msg("opentsdb telnet manager stopped")
 	}
 
+	if logh.InfoEnabled {
+		logger.Info().Msg("flushing collector writes")
+	}
+
+	collectorService.Shutdown()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("collector writes flushed")
+	}
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("stopping statistics service")
 	}
@@ -233,12 +283,17 @@ func createMemcachedConnection(conf *memcached.Configuration, timelineManager *t
 }
 
 // createMetadataStorageService - creates a new metadata storage
-func createMetadataStorageService(conf *metadata.Settings, timelineManager *tlmanager.Instance, memcachedConn *memcached.Memcached) *metadata.Storage {
+func createMetadataStorageService(conf *structs.Settings, timelineManager *tlmanager.Instance, memcachedConn *memcached.Memcached, scyllaConn *gocql.Session) *metadata.Storage {
+
+	if conf.MetadataSettings.ScyllaKeyspace == constants.StringsEmpty {
+		conf.MetadataSettings.ScyllaKeyspace = conf.Cassandra.Keyspace
+	}
 
 	metaStorage, err := metadata.Create(
-		conf,
+		&conf.MetadataSettings,
 		timelineManager,
 		memcachedConn,
+		scyllaConn,
 	)
 
 	if err != nil {
@@ -360,8 +415,82 @@ func createKeysetManager(conf *structs.Settings, metadataStorage *metadata.Stora
 	return keyset
 }
 
+// createRepairService - creates the metadata repair service (nil if disabled)
+func createRepairService(conf *structs.Settings, scyllaConn *gocql.Session, metadataStorage *metadata.Storage, keyspaceTTLMap map[int]string) *repair.Service {
+
+	repairService, err := repair.New(
+		&conf.MetadataRepair,
+		scyllaConn,
+		metadataStorage,
+		conf.Cassandra.Keyspace,
+		keyspaceTTLMap,
+	)
+
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating metadata repair service")
+		}
+		os.Exit(1)
+	}
+
+	if logh.InfoEnabled && repairService != nil {
+		logger.Info().Msg("metadata repair service was created")
+	}
+
+	return repairService
+}
+
+// createAuthService - creates the token authentication service (nil if disabled)
+func createAuthService(conf *structs.Settings, scyllaConn *gocql.Session, timelineManager *tlmanager.Instance) *auth.Service {
+
+	authService, err := auth.New(
+		&conf.Auth,
+		scyllaConn,
+		conf.Cassandra.Keyspace,
+		timelineManager,
+	)
+
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating authentication service")
+		}
+		os.Exit(1)
+	}
+
+	if logh.InfoEnabled && authService != nil {
+		logger.Info().Msg("authentication service was created")
+	}
+
+	return authService
+}
+
+// createMetadataJanitor - creates and starts the stale metadata janitor (nil if disabled)
+func createMetadataJanitor(conf *structs.Settings, timelineManager *tlmanager.Instance, metadataStorage *metadata.Storage, scyllaConn *gocql.Session, keyspaceTTLMap map[int]string) *metadata.Janitor {
+
+	if !conf.LastSeen.Enabled || !conf.LastSeen.Janitor {
+		return nil
+	}
+
+	janitor := metadata.NewJanitor(
+		metadataStorage,
+		scyllaConn,
+		timelineManager,
+		keyspaceTTLMap,
+		conf.LastSeen.JanitorInterval.Duration,
+		conf.LastSeen.JanitorBatchSize,
+	)
+
+	janitor.Start()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("metadata janitor was started")
+	}
+
+	return janitor
+}
+
 // createCollectorService - creates a new collector service
-func createCollectorService(conf *structs.Settings, timelineManager *tlmanager.Instance, metadataStorage *metadata.Storage, scyllaConn *gocql.Session, validationService *validation.Service, keyspaceTTLMap map[int]string) *collector.Collector {
+func createCollectorService(conf *structs.Settings, timelineManager *tlmanager.Instance, metadataStorage *metadata.Storage, scyllaConn *gocql.Session, validationService *validation.Service, repairService *repair.Service, authService *auth.Service, keyspaceTTLMap map[int]string) *collector.Collector {
 
 	collector, err := collector.New(
 		timelineManager,
@@ -370,6 +499,8 @@ func createCollectorService(conf *structs.Settings, timelineManager *tlmanager.I
 		conf,
 		keyspaceTTLMap,
 		validationService,
+		repairService,
+		authService,
 	)
 
 	if err != nil {
@@ -387,7 +518,7 @@ func createCollectorService(conf *structs.Settings, timelineManager *tlmanager.I
 }
 
 // createPlotService - creates the plot service
-func createPlotService(conf *structs.Settings, timelineManager *tlmanager.Instance, metadataStorage *metadata.Storage, scyllaConn *gocql.Session, keyspaceTTLMap map[int]string) *plot.Plot {
+func createPlotService(conf *structs.Settings, timelineManager *tlmanager.Instance, metadataStorage *metadata.Storage, scyllaConn *gocql.Session, keyspaceTTLMap map[int]string, authService *auth.Service) *plot.Plot {
 
 	plotService, err := plot.New(
 		scyllaConn,
@@ -401,6 +532,10 @@ func createPlotService(conf *structs.Settings, timelineManager *tlmanager.Instan
 		conf.UnlimitedQueryBytesKeysetWhiteList,
 		timelineManager,
 		constants.ClusteringOrder(conf.ClusteringOrder),
+		&conf.QueryCache,
+		authService,
+		conf.Cassandra.Keyspace,
+		&conf.DeleteJobs,
 	)
 
 	if err != nil {
@@ -418,9 +553,9 @@ func createPlotService(conf *structs.Settings, timelineManager *tlmanager.Instan
 }
 
 // createUDPServer - creates the UDP server and starts it
-func createUDPServer(conf *structs.SettingsUDP, collectorService *collector.Collector, timelineManager *tlmanager.Instance) *udp.UDPserver {
+func createUDPServer(conf *structs.SettingsUDP, collectorService *collector.Collector, authService *auth.Service, timelineManager *tlmanager.Instance) *udp.UDPserver {
 
-	udpServer := udp.New(*conf, collectorService, timelineManager)
+	udpServer := udp.New(*conf, collectorService, authService, timelineManager)
 	udpServer.Start()
 
 	if logh.InfoEnabled {
@@ -430,8 +565,54 @@ func createUDPServer(conf *structs.SettingsUDP, collectorService *collector.Coll
 	return udpServer
 }
 
+// createInfluxUDPServer - creates the line protocol UDP server and starts it (only if a port is configured)
+func createInfluxUDPServer(conf *structs.SettingsUDP, collectorService *collector.Collector, authService *auth.Service, timelineManager *tlmanager.Instance) *udp.UDPserver {
+
+	if conf.Port <= 0 {
+		return nil
+	}
+
+	udpServer := udp.New(*conf, collectorService.NewInfluxUDPHandler(conf), authService, timelineManager)
+	udpServer.Start()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("influx udp server was created")
+	}
+
+	return udpServer
+}
+
+// createGraphitePickleServers - creates the graphite pickle protocol servers and starts them
+func createGraphitePickleServers(conf *structs.Settings, collectorService *collector.Collector, validationService *validation.Service, authService *auth.Service, timelineManager *tlmanager.Instance) []*graphite.PickleServer {
+
+	pickleServers := make([]*graphite.PickleServer, 0, len(conf.GraphitePickleServer))
+
+	for i := 0; i < len(conf.GraphitePickleServer); i++ {
+
+		pickleServer, err := graphite.NewPickleServer(&conf.GraphitePickleServer[i], collectorService, validationService, authService, timelineManager)
+		if err == nil {
+			err = pickleServer.Listen()
+		}
+
+		if err != nil {
+			if logh.FatalEnabled {
+				logger.Fatal().Err(err).Msg("error creating graphite pickle server")
+			}
+			os.Exit(1)
+		}
+
+		pickleServers = append(pickleServers, pickleServer)
+	}
+
+	if logh.InfoEnabled && len(pickleServers) > 0 {
+		logger.Info().Msg("graphite pickle servers were created")
+	}
+
+	return pickleServers
+}
+
 // createRESTserver - creates the REST server and starts it
-func createRESTserver(conf *structs.Settings, timelineManager *tlmanager.Instance, plotService *plot.Plot, collectorService *collector.Collector, keyspaceManager *keyspace.Keyspace, keysetManager *keyset.Manager, memcachedConn *memcached.Memcached, telnetManager *telnetmgr.Manager) *rest.REST {
+func createRESTserver(conf *structs.Settings, timelineManager *tlmanager.Instance, plotService *plot.Plot, collectorService *collector.Collector, keyspaceManager *keyspace.Keyspace, keysetManager *keyset.Manager, memcachedConn *memcached.Memcached, telnetManager *telnetmgr.Manager, validationService *validation.Service, repairService *repair.Service, authService *auth.Service) *rest.REST {
 
 	restServer := rest.New(
 		timelineManager,
@@ -442,6 +623,9 @@ func createRESTserver(conf *structs.Settings, timelineManager *tlmanager.Instanc
 		conf.HTTPserver,
 		keysetManager,
 		telnetManager,
+		validationService,
+		repairService,
+		authService,
 	)
 
 	restServer.Start()
@@ -454,15 +638,24 @@ func createRESTserver(conf *structs.Settings, timelineManager *tlmanager.Instanc
 }
 
 // createTelnetManager - creates a new telnet manager
-func createTelnetManager(conf *structs.Settings, collectorService *collector.Collector, timelineManager *tlmanager.Instance, validationService *validation.Service) *telnetmgr.Manager {
+func createTelnetManager(conf *structs.Settings, collectorService *collector.Collector, timelineManager *tlmanager.Instance, validationService *validation.Service, authService *auth.Service) *telnetmgr.Manager {
 
 	telnetManager, err := telnetmgr.New(
 		&conf.TelnetManagerConfiguration,
 		conf.HTTPserver.Port,
+		&conf.HTTPserver.TLS,
 		collectorService,
+		authService,
 		timelineManager,
 	)
 
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating telnet manager")
+		}
+		os.Exit(1)
+	}
+
 	for i := 0; i < len(conf.NetdataServer); i++ {
 		err = telnetManager.AddServer(&conf.NetdataServer[i], &conf.TelnetManagerConfiguration, telnet.NewNetdataHandler(conf.NetdataServer[i].CacheDuration, collectorService, &conf.NetdataServer[i], validationService))
 		if err != nil {
@@ -483,6 +676,31 @@ func createTelnetManager(conf *structs.Settings, collectorService *collector.Col
 		}
 	}
 
+	for i := 0; i < len(conf.InfluxServer); i++ {
+		err = telnetManager.AddServer(&conf.InfluxServer[i], &conf.TelnetManagerConfiguration, telnet.NewInfluxHandler(collectorService, &conf.InfluxServer[i], validationService))
+		if err != nil {
+			if logh.FatalEnabled {
+				logger.Fatal().Err(err).Msg("error creating telnet server 'influx'")
+			}
+			os.Exit(1)
+		}
+	}
+
+	for i := 0; i < len(conf.GraphiteServer); i++ {
+
+		graphiteHandler, err := telnet.NewGraphiteHandler(collectorService, &conf.GraphiteServer[i], validationService)
+		if err == nil {
+			err = telnetManager.AddServer(&conf.GraphiteServer[i], &conf.TelnetManagerConfiguration, graphiteHandler)
+		}
+
+		if err != nil {
+			if logh.FatalEnabled {
+				logger.Fatal().Err(err).Msg("error creating telnet server 'graphite'")
+			}
+			os.Exit(1)
+		}
+	}
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("telnet manager was created")
 	}
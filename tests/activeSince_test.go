@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/tests/tools"
+)
+
+var ksActiveSince string
+
+// sendPointsActiveSince - creates a keyset with one timeseries written now
+func sendPointsActiveSince(t *testing.T) string {
+
+	if ksActiveSince != "" {
+		return ksActiveSince
+	}
+
+	keyset := mycenaeTools.Mycenae.CreateKeyset(createKeysetName())
+
+	points := fmt.Sprintf(`[{"value": 1, "metric": "active.cpu", "tags": {"ksid": "%s", "host": "a"}}]`, keyset)
+
+	code, _, err := mycenaeTools.HTTP.POST("api/put", []byte(points))
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("error storing the points: %d %v", code, err)
+	}
+
+	time.Sleep(tools.Sleep3)
+
+	ksActiveSince = keyset
+
+	return keyset
+}
+
+func TestActiveSinceInvalid(t *testing.T) {
+
+	keyset := sendPointsActiveSince(t)
+
+	for _, path := range []string{
+		"api/search/lookup?m=active.cpu&activeSince=x",
+		"api/suggest?type=metrics&activeSince=x",
+		"api/suggest?type=tagk&activeSince=-1",
+	} {
+
+		code, resp, err := mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/%s", keyset, path))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		respErr := tools.Error{}
+		assert.NoError(t, json.Unmarshal(resp, &respErr), string(resp))
+		assert.Equal(t, http.StatusBadRequest, code, path)
+		assert.Equal(t, `query param "activeSince" should be a timestamp or a relative time like "1h" or "7d"`, respErr.Message, path)
+	}
+
+	query := `{"relative": "1h", "queries": [{"metric": "active.cpu", "aggregator": "sum"}], "activeSince": "yesterday"}`
+
+	code, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/api/query", keyset), []byte(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, code, string(resp))
+}
+
+func TestActiveSinceFuture(t *testing.T) {
+
+	keyset := sendPointsActiveSince(t)
+	future := time.Now().Add(time.Hour).Unix()
+
+	code, resp, err := mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/api/suggest?type=metrics&activeSince=%d", keyset, future))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := []string{}
+	assert.Equal(t, http.StatusOK, code)
+	assert.NoError(t, json.Unmarshal(resp, &metrics))
+	assert.Empty(t, metrics, "no timeseries written after the timestamp")
+
+	code, resp, err = mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/api/search/lookup?m=active.cpu&activeSince=%d", keyset, future))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := tools.LookupResultObject{}
+	assert.Equal(t, http.StatusOK, code)
+	assert.NoError(t, json.Unmarshal(resp, &lookup))
+	assert.Zero(t, lookup.TotalResults)
+
+	code, resp, err = mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/api/suggest?type=metrics", keyset))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.NoError(t, json.Unmarshal(resp, &metrics))
+	assert.Equal(t, []string{"active.cpu"}, metrics, "all timeseries without the filter")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/tests/tools"
+)
+
+// the test environment runs without authentication: the token endpoints are disabled and no token is required
+
+func TestAuthDisabledTokenEndpoints(t *testing.T) {
+
+	requests := []struct {
+		method, path string
+	}{
+		{http.MethodPost, "admin/tokens"},
+		{http.MethodGet, "admin/tokens"},
+		{http.MethodGet, "admin/tokens/abc"},
+		{http.MethodDelete, "admin/tokens/abc"},
+	}
+
+	for _, r := range requests {
+
+		var code int
+		var resp []byte
+		var err error
+
+		switch r.method {
+		case http.MethodPost:
+			code, resp, err = mycenaeTools.HTTP.POST(r.path, []byte(`{"name":"x","keysets":["*"],"operations":["read"]}`))
+		case http.MethodGet:
+			code, resp, err = mycenaeTools.HTTP.GET(r.path)
+		case http.MethodDelete:
+			code, resp, err = mycenaeTools.HTTP.DELETE(r.path)
+		}
+
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		respErr := tools.Error{}
+		assert.NoError(t, json.Unmarshal(resp, &respErr), string(resp))
+		assert.Equal(t, http.StatusNotFound, code, "%s %s", r.method, r.path)
+		assert.Equal(t, "the authentication is disabled", respErr.Message, "%s %s", r.method, r.path)
+	}
+}
+
+func TestAuthDisabledIgnoresTheToken(t *testing.T) {
+
+	header := map[string]string{"Authorization": "Bearer invalid.token"}
+
+	code, _, err := mycenaeTools.HTTP.CustomHeaderPOST("api/put", []byte(`[{"value": 1, "metric": "auth.cpu", "tags": {"ksid": "`+ksMycenae+`", "host": "a"}}]`), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, code)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/tests/tools"
+)
+
+type facetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type cardinality struct {
+	Total     int          `json:"total"`
+	Metrics   []facetCount `json:"metrics"`
+	TagKeys   []facetCount `json:"tagKeys"`
+	TagValues []facetCount `json:"tagValues"`
+}
+
+var ksCardinality string
+
+// sendPointsCardinality - creates a keyset with three timeseries: two cpu (hosts a and b) and one mem (host a)
+func sendPointsCardinality(t *testing.T) string {
+
+	if ksCardinality != "" {
+		return ksCardinality
+	}
+
+	keyset := mycenaeTools.Mycenae.CreateKeyset(createKeysetName())
+
+	points := fmt.Sprintf(`[
+		{"value": 1, "metric": "card.cpu", "tags": {"ksid": "%[1]s", "host": "a", "dc": "x"}},
+		{"value": 2, "metric": "card.cpu", "tags": {"ksid": "%[1]s", "host": "b", "dc": "x"}},
+		{"value": 3, "metric": "card.mem", "tags": {"ksid": "%[1]s", "host": "a"}}
+	]`, keyset)
+
+	code, _, err := mycenaeTools.HTTP.POST("api/put", []byte(points))
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("error storing the points: %d %v", code, err)
+	}
+
+	time.Sleep(tools.Sleep3)
+
+	ksCardinality = keyset
+
+	return keyset
+}
+
+func getCardinality(t *testing.T, keyset, params string) (int, cardinality) {
+
+	code, resp, err := mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/cardinality?%s", keyset, params))
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	result := cardinality{}
+
+	if code == http.StatusOK {
+		if err := json.Unmarshal(resp, &result); err != nil {
+			t.Fatal(err, string(resp))
+		}
+	}
+
+	return code, result
+}
+
+func TestCardinality(t *testing.T) {
+
+	keyset := sendPointsCardinality(t)
+
+	code, result := getCardinality(t, keyset, "")
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.Equal(t, 3, result.Total)
+	assert.Equal(t, []facetCount{{"card.cpu", 2}, {"card.mem", 1}}, result.Metrics)
+	assert.Contains(t, result.TagKeys, facetCount{"host", 3})
+	assert.Contains(t, result.TagKeys, facetCount{"dc", 2})
+	assert.Contains(t, result.TagValues, facetCount{"a", 2})
+	assert.Contains(t, result.TagValues, facetCount{"x", 2})
+}
+
+func TestCardinalityByMetricAndTag(t *testing.T) {
+
+	keyset := sendPointsCardinality(t)
+
+	code, result := getCardinality(t, keyset, "metric=card.cpu&tag=host")
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.Equal(t, 2, result.Total)
+	assert.Equal(t, []facetCount{{"card.cpu", 2}}, result.Metrics)
+	assert.ElementsMatch(t, []facetCount{{"a", 1}, {"b", 1}}, result.TagValues, "only the values of the tag")
+
+	code, result = getCardinality(t, keyset, "tag=host&size=1")
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.Equal(t, 3, result.Total, "the size does not change the total")
+	assert.Equal(t, []facetCount{{"card.cpu", 2}}, result.Metrics)
+	assert.Equal(t, []facetCount{{"a", 2}}, result.TagValues)
+}
+
+func TestCardinalitySince(t *testing.T) {
+
+	keyset := sendPointsCardinality(t)
+
+	code, result := getCardinality(t, keyset, "since=1d")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, result.Total)
+
+	code, _ = getCardinality(t, keyset, fmt.Sprintf("since=%d", time.Now().Add(time.Hour).Unix()))
+	assert.Equal(t, http.StatusNoContent, code, "no timeseries created after the timestamp")
+
+	code, _ = getCardinality(t, keyset, "metric=card.disk")
+	assert.Equal(t, http.StatusNoContent, code)
+}
+
+func TestCardinalityInvalid(t *testing.T) {
+
+	keyset := sendPointsCardinality(t)
+
+	cases := []struct {
+		keyset, params string
+		status         int
+	}{
+		{keyset, "since=x", http.StatusBadRequest},
+		{keyset, "since=-10", http.StatusBadRequest},
+		{keyset, "size=0", http.StatusBadRequest},
+		{keyset, "size=a", http.StatusBadRequest},
+		{"unknown_keyset", "", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		code, _ := getCardinality(t, c.keyset, c.params)
+		assert.Equal(t, c.status, code, "%s?%s", c.keyset, c.params)
+	}
+}
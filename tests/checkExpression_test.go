@@ -26,6 +26,12 @@ func TestCheckExpressionValidQuery(t *testing.T) {
 		"DownsampleFillNull":                  `groupBy({host2=*})|rate(true, null, 0, merge(sum, downsample(1m, min, null,query(testExpandExpression2, {app=app1}, 5m))))`,
 		"DownsampleFillNan":                   `groupBy({host2=*})|rate(true, null, 0, merge(sum, downsample(1m, min, nan,query(testExpandExpression2, {app=app1}, 5m))))`,
 		"DownsampleFillZero":                  `groupBy({host2=*})|rate(true, null, 0, merge(sum, downsample(1m, min, zero,query(testExpandExpression2, {app=app1}, 5m))))`,
+		"CalendarDay":                         `calendar(merge(sum,downsample(1d,sum,none,query(os.cpu,{app=test},1w))))`,
+		"CalendarMonthTimezone":               `calendar(America/Sao_Paulo,merge(sum,downsample(1n,avg,none,query(os.cpu,{app=test},1y))))`,
+		"DownsampleFillPrevious":              `merge(sum,downsample(1m,avg,previous,query(os.cpu,{app=test},5m)))`,
+		"DownsampleFillLinear":                `merge(sum,downsample(1m,avg,linear,query(os.cpu,{app=test},5m)))`,
+		"DownsampleFillLinearMaxGap":          `merge(sum,downsample(1m,avg,linear,5m,query(os.cpu,{app=test},5m)))`,
+		"DownsampleFillPreviousMaxGapMs":      `merge(sum,downsample(1s,avg,previous,1500ms,query(os.cpu,{app=test},5m)))`,
 		"MergeMax":                            `merge(max,downsample(1m,sum,none,query(os.cpu,{app=test},5m)))`,
 		"MergeAvg":                            `merge(avg,downsample(1m,sum,none,query(os.cpu,{app=test},5m)))`,
 		"MergeMin":                            `merge(min,downsample(1m,sum,none,query(os.cpu,{app=test},5m)))`,
@@ -85,8 +91,8 @@ func TestCheckExpressionInvalidQuery(t *testing.T) {
 		},
 		"MergeInvalidExpression": {
 			`merge(x, downsample(1m, min, none,query(os.cpu, {app=test}, 5m)))`,
-			"unkown aggregation value",
-			"unkown aggregation value",
+			"unknown merge operation x",
+			"unknown merge operation x",
 		},
 		"MergeEmptyExpression": {
 			`merge(downsample(1m, min, none,query(os.cpu, {app=test}, 5m)))`,
@@ -95,8 +101,8 @@ func TestCheckExpressionInvalidQuery(t *testing.T) {
 		},
 		"MergeNullExpression": {
 			`merge(null, downsample(1m, min, none,query(os.cpu, {app=test}, 5m)))`,
-			"unkown aggregation value",
-			"unkown aggregation value",
+			"unknown merge operation null",
+			"unknown merge operation null",
 		},
 		"MergeInvalidFunction": {
 			`merge(sum, x(1m, min, query(os.cpu, {app=test}, 5m)))`,
@@ -145,13 +151,13 @@ func TestCheckExpressionInvalidQuery(t *testing.T) {
 		},
 		"DownsampleInvalidExpression": {
 			`merge(sum, downsample(1m, x, none,query(os.cpu, {app=test}, 5m)))`,
-			"Invalid downsample",
-			"Invalid downsample",
+			"unknown downsample operation x",
+			"unknown downsample operation x",
 		},
 		"DownsampleNullExpression": {
 			`merge(sum, downsample(1m, null, none,query(os.cpu, {app=test}, 5m)))`,
-			"Invalid downsample",
-			"Invalid downsample",
+			"unknown downsample operation null",
+			"unknown downsample operation null",
 		},
 		"DownsampleEmptyExpression": {
 			`merge(sum, downsample(1m, min,none, x(os.cpu, {app=test}, 5m)))`,
@@ -210,8 +216,28 @@ func TestCheckExpressionInvalidQuery(t *testing.T) {
 		},
 		"DownsampleExtraParamsRelative": {
 			`merge(sum, downsample(1m ,1m, min,none,query(os.cpu, {app=test}, 5m)))`,
-			"downsample needs 4 parameters: downsample operation, downsample period, fill option and a function",
-			"downsample expects 4 parameters but found 5: [1m 1m min none query(os.cpu,{app=test},5m)]",
+			"unknown downsample operation 1m",
+			"unknown downsample operation 1m",
+		},
+		"DownsampleTooManyParams": {
+			`merge(sum, downsample(1m, min, linear, 5m, 10m, query(os.cpu, {app=test}, 5m)))`,
+			"downsample needs 4 parameters: downsample operation, downsample period, fill option and a function (the max gap of the previous and linear fill options can be set before the function)",
+			"downsample expects 4 or 5 parameters but found 6: [1m min linear 5m 10m query(os.cpu,{app=test},5m)]",
+		},
+		"DownsampleMaxGapWithoutInterpolation": {
+			`merge(sum, downsample(1m, min, zero, 5m, query(os.cpu, {app=test}, 5m)))`,
+			"max gap is only allowed with the previous and linear fill values",
+			"max gap is only allowed with the previous and linear fill values",
+		},
+		"DownsampleInvalidMaxGap": {
+			`merge(sum, downsample(1m, min, linear, 0m, query(os.cpu, {app=test}, 5m)))`,
+			"Invalid max gap: interval needs to be bigger than 0",
+			"Invalid max gap: interval needs to be bigger than 0",
+		},
+		"DownsampleMaxGapInvalidUnit": {
+			`merge(sum, downsample(1m, min, previous, 5n, query(os.cpu, {app=test}, 5m)))`,
+			"Invalid max gap: invalid unit",
+			"Invalid max gap: invalid unit",
 		},
 		"MergeExtraParamsRelative": {
 			`merge(sum, sum, downsample(1m, min,none, query(os.cpu, {app=test}, 5m)))`,
@@ -340,6 +366,12 @@ func TestCheckExpressionInvalidQuery(t *testing.T) {
 			"invalid filter value >",
 			"invalid filter value >",
 		},
+
+		"CalendarInvalidTimezone": {
+			`calendar(Mars/Olympus,merge(sum,downsample(1d,sum,none,query(os.cpu,{app=test},1w))))`,
+			"invalid timezone Mars/Olympus: unknown time zone Mars/Olympus",
+			"invalid timezone Mars/Olympus: unknown time zone Mars/Olympus",
+		},
 	}
 
 	for test, data := range cases {
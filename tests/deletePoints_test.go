@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/tests/tools"
+)
+
+type pointsDeletion struct {
+	TotalRecords int   `json:"totalRecords"`
+	Start        int64 `json:"start"`
+	End          int64 `json:"end"`
+	Payload      []struct {
+		ID     string            `json:"id"`
+		Metric string            `json:"metric"`
+		Tags   map[string]string `json:"tags"`
+	} `json:"payload"`
+	Job *tools.DeleteJob `json:"job"`
+}
+
+func getDeleteJob(t *testing.T, id string) (int, tools.DeleteJob) {
+
+	code, resp, err := mycenaeTools.HTTP.GET("jobs/" + id)
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	job := tools.DeleteJob{}
+
+	if code == http.StatusOK {
+		if err := json.Unmarshal(resp, &job); err != nil {
+			t.Fatal(err, string(resp))
+		}
+	}
+
+	return code, job
+}
+
+// waitDeleteJob - waits the deletion job to finish, returning its final state
+func waitDeleteJob(t *testing.T, id string) tools.DeleteJob {
+
+	var job tools.DeleteJob
+
+	for i := 0; i < 50; i++ {
+
+		var code int
+		code, job = getDeleteJob(t, id)
+		if code != http.StatusOK {
+			t.Fatalf("error getting the deletion job %s: %d", id, code)
+		}
+
+		if job.Status != "running" {
+			break
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	assert.Equal(t, "finished", job.Status, "job %s", id)
+	assert.Zero(t, job.Remaining)
+
+	return job
+}
+
+// sendDeletionPoints - stores one point per hour in the last four hours, returning the tsid and the timestamps (in seconds)
+func sendDeletionPoints(t *testing.T, metric string) (string, []int64) {
+
+	now := time.Now().Truncate(time.Second)
+
+	timestamps := make([]int64, 4)
+	points := make([]tools.Payload, 4)
+
+	for i := range points {
+		timestamps[i] = now.Add(-time.Duration(4-i) * time.Hour).Unix()
+		points[i] = tools.CreatePayloadTS(float32(i), metric, map[string]string{"ksid": ksMycenae, "ttl": "1", "host": "a"}, timestamps[i]*1000)
+	}
+
+	payload, err := json.Marshal(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := mycenaeTools.HTTP.POST("api/put", payload)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("error storing the points: %d %v", code, err)
+	}
+
+	time.Sleep(tools.Sleep3)
+
+	return tools.GetHashFromMetricAndTags(metric, map[string]string{"ksid": ksMycenae, "ttl": "1", "host": "a"}), timestamps
+}
+
+func postPointsDeletion(t *testing.T, params, payload string) (int, pointsDeletion) {
+
+	code, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/delete/points?%s", ksMycenae, params), []byte(payload))
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	deletion := pointsDeletion{}
+
+	if code == http.StatusOK || code == http.StatusAccepted {
+		if err := json.Unmarshal(resp, &deletion); err != nil {
+			t.Fatal(err, string(resp))
+		}
+	}
+
+	return code, deletion
+}
+
+func countPoints(t *testing.T, tsid string, start, end int64) int {
+
+	status, response := mycenaeTools.Mycenae.GetPoints(ksMycenae, start, end, tsid)
+	if status == http.StatusNoContent {
+		return 0
+	}
+
+	assert.Equal(t, http.StatusOK, status)
+
+	return len(response.Payload[tsid].Points.Ts)
+}
+
+func TestDeletePointsByMetric(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("delete.points.%d", rand.Int())
+	tsid, timestamps := sendDeletionPoints(t, metric)
+
+	payload := fmt.Sprintf(`{"metric": "%s", "tags": [{"tagKey": "host", "tagValue": "a"}]}`, metric)
+	params := fmt.Sprintf("start=%d&end=%d", timestamps[1], timestamps[2])
+
+	code, deletion := postPointsDeletion(t, params, payload)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, deletion.TotalRecords)
+	assert.Equal(t, timestamps[1]*1000, deletion.Start)
+	assert.Equal(t, timestamps[2]*1000, deletion.End)
+	if assert.Len(t, deletion.Payload, 1) {
+		assert.Equal(t, tsid, deletion.Payload[0].ID)
+	}
+
+	assert.Equal(t, 4, countPoints(t, tsid, timestamps[0], timestamps[3]), "nothing is deleted without commit")
+
+	code, deletion = postPointsDeletion(t, params+"&commit=true", payload)
+	assert.Equal(t, http.StatusAccepted, code)
+	if assert.NotNil(t, deletion.Job) {
+		assert.Equal(t, "points", deletion.Job.Kind)
+		assert.Equal(t, 1, deletion.Job.Total)
+		job := waitDeleteJob(t, deletion.Job.ID)
+		assert.Equal(t, 1, job.Deleted)
+	}
+
+	assert.Equal(t, 2, countPoints(t, tsid, timestamps[0], timestamps[3]), "the range limits are deleted")
+	assert.Equal(t, 0, countPoints(t, tsid, timestamps[1], timestamps[2]))
+}
+
+func TestDeletePointsByTSID(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("delete.points.%d", rand.Int())
+	tsid, timestamps := sendDeletionPoints(t, metric)
+
+	payload := fmt.Sprintf(`{"tsids": ["%s"], "ttl": 1}`, tsid)
+
+	code, deletion := postPointsDeletion(t, fmt.Sprintf("start=%d&commit=true", timestamps[2]), payload)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, 1, deletion.TotalRecords)
+	if assert.NotNil(t, deletion.Job) {
+		waitDeleteJob(t, deletion.Job.ID)
+	}
+
+	assert.Equal(t, 2, countPoints(t, tsid, timestamps[0], timestamps[3]), "deleted until now")
+	assert.Equal(t, 0, countPoints(t, tsid, timestamps[2], timestamps[3]))
+}
+
+func TestDeletePointsInvalid(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now().Add(-time.Hour).Unix()
+	metricPayload := `{"metric": "delete.points.invalid"}`
+
+	cases := []struct {
+		params, payload string
+		status          int
+	}{
+		{"", metricPayload, http.StatusBadRequest},
+		{"start=x", metricPayload, http.StatusBadRequest},
+		{fmt.Sprintf("start=%d&end=%d", start, start-10), metricPayload, http.StatusBadRequest},
+		{fmt.Sprintf("start=%d&commit=maybe", start), metricPayload, http.StatusBadRequest},
+		{fmt.Sprintf("start=%d", start), `{}`, http.StatusBadRequest},
+		{fmt.Sprintf("start=%d", start), `{"metric": "cpu", "tsids": ["abc"]}`, http.StatusBadRequest},
+		{fmt.Sprintf("start=%d", start), `{"tsids": ["abc"], "ttl": 999}`, http.StatusBadRequest},
+		{fmt.Sprintf("start=%d&commit=true", start), `{"tsids": ["unknown"]}`, http.StatusNotFound},
+		{fmt.Sprintf("start=%d", start), metricPayload, http.StatusNoContent},
+	}
+
+	for _, c := range cases {
+		code, _ := postPointsDeletion(t, c.params, c.payload)
+		assert.Equal(t, c.status, code, "%s %s", c.params, c.payload)
+	}
+}
+
+func TestDeleteJobs(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("delete.points.%d", rand.Int())
+	tsid, timestamps := sendDeletionPoints(t, metric)
+
+	code, deletion := postPointsDeletion(t, fmt.Sprintf("start=%d&commit=true", timestamps[0]), fmt.Sprintf(`{"tsids": ["%s"]}`, tsid))
+	assert.Equal(t, http.StatusAccepted, code)
+	if !assert.NotNil(t, deletion.Job) {
+		return
+	}
+
+	job := waitDeleteJob(t, deletion.Job.ID)
+	assert.Equal(t, ksMycenae, job.Keyset)
+	assert.Equal(t, 1, job.Total)
+
+	code, _, err := mycenaeTools.HTTP.DELETE("jobs/" + job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusConflict, code, "the job is finished")
+
+	code, _ = getDeleteJob(t, "unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	assert.Equal(t, 0, countPoints(t, tsid, timestamps[0], timestamps[3]))
+}
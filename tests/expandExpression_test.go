@@ -419,11 +419,11 @@ func TestExpandInvalidQuerySameErrorMessage(t *testing.T) {
 		},
 		"MergeInvalidExpression": {
 			"merge(x, downsample(1m, min, none,query(os.cpu, {app=test}, 5m)))",
-			"unkown aggregation value",
+			"unknown merge operation x",
 		},
 		"MergeNullExpression": {
 			"merge(null, downsample(1m, min, none,query(os.cpu, {app=test}, 5m)))",
-			"unkown aggregation value",
+			"unknown merge operation null",
 		},
 		"MergeInvalidFunction": {
 			"merge(sum, x(1m, min, query(os.cpu, {app=test}, 5m)))",
@@ -463,11 +463,11 @@ func TestExpandInvalidQuerySameErrorMessage(t *testing.T) {
 		},
 		"DownsampleInvalidExpression": {
 			"merge(sum, downsample(1m, x, none,query(os.cpu, {app=test}, 5m)))",
-			"Invalid downsample",
+			"unknown downsample operation x",
 		},
 		"DownsampleNullExpression": {
 			"merge(sum, downsample(1m, null, none,query(os.cpu, {app=test}, 5m)))",
-			"Invalid downsample",
+			"unknown downsample operation null",
 		},
 		"DownsampleEmptyExpression": {
 			"merge(sum, downsample(1m, min,none, x(os.cpu, {app=test}, 5m)))",
@@ -581,8 +581,8 @@ func TestExpandInvalidQueryDifferentErrorMessage(t *testing.T) {
 		},
 		"DownsampleExtraParamsRelative": {
 			"merge(sum, downsample(1m ,1m, min,none,query(os.cpu, {app=test}, 5m)))",
-			"downsample expects 4 parameters but found 5: [1m 1m min none query(os.cpu,{app=test},5m)]",
-			"downsample needs 4 parameters: downsample operation, downsample period, fill option and a function",
+			"unknown downsample operation 1m",
+			"unknown downsample operation 1m",
 		},
 		"MergeExtraParamsRelative": {
 			"merge(sum, sum, downsample(1m, min,none, query(os.cpu, {app=test}, 5m)))",
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/tests/tools"
+)
+
+var influxTelnet = tools.NewTelnetTool("mycenae", "8189", 10*time.Second)
+var influxUDP = &tools.Tool{}
+
+func init() {
+	influxUDP.InitUDP("mycenae", "8089")
+}
+
+func postInfluxWrite(t *testing.T, params string, body []byte, headers map[string]string) int {
+
+	code, _, err := mycenaeTools.HTTP.CustomHeaderPOST("write"+params, body, headers)
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	return code
+}
+
+func influxTSID(metric string, tags map[string]string) string {
+
+	all := map[string]string{"ksid": ksMycenae, "ttl": "1"}
+	for k, v := range tags {
+		all[k] = v
+	}
+
+	return tools.GetHashFromMetricAndTags(metric, all)
+}
+
+func TestInfluxWriteFields(t *testing.T) {
+	t.Parallel()
+
+	measurement := fmt.Sprintf("influx_%d", rand.Int())
+	now := time.Now().Unix()
+
+	lines := fmt.Sprintf("%s,host=a,ksid=%s load=1.5,count=3i,up=true,state=\"running\" %d\n", measurement, ksMycenae, now)
+
+	code := postInfluxWrite(t, "?precision=s", []byte(lines), nil)
+	assert.Equal(t, http.StatusNoContent, code)
+
+	time.Sleep(tools.Sleep3)
+
+	tags := map[string]string{"host": "a"}
+
+	assertMycenae(t, ksMycenae, now, now, 1.5, influxTSID(measurement+".load", tags))
+	assertMycenae(t, ksMycenae, now, now, 3, influxTSID(measurement+".count", tags))
+	assertMycenae(t, ksMycenae, now, now, 1, influxTSID(measurement+".up", tags))
+	assertMycenaeText(t, ksMycenae, now, now, "running", "T"+influxTSID(measurement+".state", tags))
+}
+
+func TestInfluxWriteDatabaseAndPrecision(t *testing.T) {
+	t.Parallel()
+
+	measurement := fmt.Sprintf("influx_%d", rand.Int())
+	now := time.Now().Unix()
+
+	cases := []struct {
+		precision string
+		timestamp int64
+		value     float32
+	}{
+		{"", now * 1e9, 1},
+		{"ns", (now - 60) * 1e9, 2},
+		{"us", (now - 120) * 1e6, 3},
+		{"ms", (now - 180) * 1e3, 4},
+		{"s", now - 240, 5},
+	}
+
+	for _, c := range cases {
+
+		params := "?db=" + ksMycenae
+		if c.precision != "" {
+			params += "&precision=" + c.precision
+		}
+
+		line := fmt.Sprintf("%s,host=a value=%v %d", measurement, c.value, c.timestamp)
+
+		assert.Equal(t, http.StatusNoContent, postInfluxWrite(t, params, []byte(line), nil), c.precision)
+	}
+
+	time.Sleep(tools.Sleep3)
+
+	tsid := influxTSID(measurement+".value", map[string]string{"host": "a"})
+
+	for i, c := range cases {
+		ts := now - int64(i*60)
+		assertMycenae(t, ksMycenae, ts, ts, c.value, tsid)
+	}
+}
+
+func TestInfluxWriteGzip(t *testing.T) {
+	t.Parallel()
+
+	measurement := fmt.Sprintf("influx_%d", rand.Int())
+	now := time.Now().Unix()
+
+	buffer := bytes.Buffer{}
+	writer := gzip.NewWriter(&buffer)
+	fmt.Fprintf(writer, "# a comment line\n\n%s,host=a,ksid=%s value=8 %d\n", measurement, ksMycenae, now)
+	writer.Close()
+
+	code := postInfluxWrite(t, "?precision=s", buffer.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusNoContent, code)
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenae(t, ksMycenae, now, now, 8, influxTSID(measurement+".value", map[string]string{"host": "a"}))
+}
+
+func TestInfluxWriteStoresTheValidLines(t *testing.T) {
+	t.Parallel()
+
+	measurement := fmt.Sprintf("influx_%d", rand.Int())
+	now := time.Now().Unix()
+
+	lines := fmt.Sprintf(
+		"%s,host=a,ksid=%s value=abc %d\n%s,host=b,ksid=%s value=9 %d\n",
+		measurement, ksMycenae, now,
+		measurement, ksMycenae, now,
+	)
+
+	code := postInfluxWrite(t, "?precision=s", []byte(lines), nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenaeEmpty(t, ksMycenae, now, now, influxTSID(measurement+".value", map[string]string{"host": "a"}))
+	assertMycenae(t, ksMycenae, now, now, 9, influxTSID(measurement+".value", map[string]string{"host": "b"}))
+}
+
+func TestInfluxWriteInvalid(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Unix()
+
+	cases := []struct {
+		name    string
+		params  string
+		line    string
+		headers map[string]string
+	}{
+		{"InvalidPrecision", "?precision=d&db=" + ksMycenae, fmt.Sprintf("cpu,host=a value=1 %d", now), nil},
+		{"NoKeyset", "?precision=s", fmt.Sprintf("cpu,host=a value=1 %d", now), nil},
+		{"UnknownKeyset", "?precision=s&db=unknown_keyset", fmt.Sprintf("cpu,host=a value=1 %d", now), nil},
+		{"NoFields", "?db=" + ksMycenae, "cpu,host=a", nil},
+		{"NoTags", "?db=" + ksMycenae, "cpu value=1", nil},
+		{"InvalidTimestamp", "?db=" + ksMycenae, "cpu,host=a value=1 abc", nil},
+		{"InvalidTagValue", "?db=" + ksMycenae, "cpu,host=a|b value=1", nil},
+		{"NotGzip", "?db=" + ksMycenae, "cpu,host=a value=1", map[string]string{"Content-Encoding": "gzip"}},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, http.StatusBadRequest, postInfluxWrite(t, c.params, []byte(c.line), c.headers), c.name)
+	}
+}
+
+func TestInfluxTelnetAndUDP(t *testing.T) {
+	t.Parallel()
+
+	measurement := fmt.Sprintf("influx_%d", rand.Int())
+	now := time.Now().Unix()
+
+	err := influxTelnet.Send(
+		fmt.Sprintf("%s,host=telnet,ksid=%s value=1 %d", measurement, ksMycenae, now*1e9),
+		"an invalid line",
+		fmt.Sprintf("%s,host=telnet2,ksid=%s value=2 %d", measurement, ksMycenae, now*1e9),
+	)
+	assert.NoError(t, err)
+
+	err = influxUDP.UDP.SendString(fmt.Sprintf("%s,host=udp,ksid=%s value=3 %d\n", measurement, ksMycenae, now*1e9))
+	assert.NoError(t, err)
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenae(t, ksMycenae, now, now, 1, influxTSID(measurement+".value", map[string]string{"host": "telnet"}))
+	assertMycenae(t, ksMycenae, now, now, 2, influxTSID(measurement+".value", map[string]string{"host": "telnet2"}))
+	assertMycenae(t, ksMycenae, now, now, 3, influxTSID(measurement+".value", map[string]string{"host": "udp"}))
+}
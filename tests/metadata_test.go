@@ -682,6 +682,11 @@ func TestDeleteMetadata(t *testing.T) {
 	assert.Equal(t, lengthPayload, response.TotalRecord)
 	assert.Equal(t, lengthPayload, len(response.Payload))
 
+	if assert.NotNil(t, response.Job) {
+		job := waitDeleteJob(t, response.Job.ID)
+		assert.Equal(t, lengthPayload, job.Deleted)
+	}
+
 	// commit true again
 	code, resp, err := mycenaeTools.HTTP.POST(url, []byte(payload))
 	if err != nil {
@@ -717,6 +722,11 @@ func TestDeleteMetadata(t *testing.T) {
 	assert.Equal(t, lengthPayload, response.TotalRecord)
 	assert.Equal(t, lengthPayload, len(response.Payload))
 
+	if assert.NotNil(t, response.Job) {
+		job := waitDeleteJob(t, response.Job.ID)
+		assert.Equal(t, lengthPayload, job.Deleted)
+	}
+
 	// commit true again
 	code, resp, err = mycenaeTools.HTTP.POST(url, []byte(payload))
 	if err != nil {
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/tests/tools"
+)
+
+var openTSDBTelnet = tools.NewTelnetTool("mycenae", "8123", 10*time.Second)
+
+func openTSDBTSID(metric string, tags map[string]string) string {
+
+	all := map[string]string{"ksid": ksMycenae, "ttl": "1"}
+	for k, v := range tags {
+		all[k] = v
+	}
+
+	return tools.GetHashFromMetricAndTags(metric, all)
+}
+
+func TestOpenTSDBTelnetCommands(t *testing.T) {
+	t.Parallel()
+
+	lines, err := openTSDBTelnet.Request("version", "help", "exit", "version")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, lines, 3, "the lines after exit are discarded") {
+		assert.True(t, strings.HasPrefix(lines[0], "mycenae "), lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "Built on "), lines[1])
+		assert.Equal(t, "available commands: exit help histogram put rollup stats version", lines[2])
+	}
+
+	lines, err = openTSDBTelnet.Request("stats", "exit")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	metrics := map[string]bool{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if assert.True(t, len(fields) >= 4, line) {
+			metrics[fields[0]] = true
+			assert.Contains(t, line, "port=8123")
+		}
+	}
+
+	assert.True(t, metrics["tsd.connectionmgr.connections"])
+	assert.True(t, metrics["tsd.rpc.received"])
+	assert.True(t, metrics["tsd.rpc.errors"])
+}
+
+func TestOpenTSDBTelnetPutAndRollup(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("otsdb_%d", rand.Int())
+	now := time.Now().Unix()
+
+	err := openTSDBTelnet.Send(
+		fmt.Sprintf("put %s %d 1 host=a ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("rollup 1h-SUM %s %d 2 host=a ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("rollup 1h-max:sum %s %d 3 host=a ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("rollup :count %s %d 4 host=a ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("rollup %s %d 5 host=a ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("rollup 1d-avg %s %d 6 host=b rollup_interval=1h ksid=%s", metric, now, ksMycenae),
+		"exit",
+	)
+	assert.NoError(t, err)
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenae(t, ksMycenae, now, now, 1, openTSDBTSID(metric, map[string]string{"host": "a"}))
+	assertMycenae(t, ksMycenae, now, now, 2, openTSDBTSID(metric, map[string]string{"host": "a", "rollup_interval": "1h", "rollup_aggregator": "sum"}))
+	assertMycenae(t, ksMycenae, now, now, 3, openTSDBTSID(metric, map[string]string{"host": "a", "rollup_interval": "1h", "rollup_aggregator": "max", "rollup_groupby": "sum"}))
+	assertMycenae(t, ksMycenae, now, now, 4, openTSDBTSID(metric, map[string]string{"host": "a", "rollup_groupby": "count"}))
+
+	// the rollup tags are only set by the command
+	assertMycenaeEmpty(t, ksMycenae, now, now, openTSDBTSID(metric, map[string]string{"host": "b", "rollup_interval": "1d", "rollup_aggregator": "avg"}))
+	assertMycenaeEmpty(t, ksMycenae, now, now, openTSDBTSID(metric, map[string]string{"host": "b", "rollup_interval": "1h", "rollup_aggregator": "avg"}))
+}
+
+func TestOpenTSDBTelnetHistogram(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("otsdb_%d", rand.Int())
+	now := time.Now().Unix()
+
+	// simple histogram: [0, 1] = 2, [1, 2.5] = 3, one value below and one above the buckets
+	err := openTSDBTelnet.Send(
+		fmt.Sprintf("histogram %s %d AAIAAAAAAACAPwIAAIA/AAAgQAMBAQ== host=a ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("histogram %s %d 0 AgAAAAAAAIA/AgAAgD8AACBAAwEB host=b ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("histogram %s %d AQIDBAU= host=c ksid=%s", metric, now, ksMycenae),
+		fmt.Sprintf("histogram %s %d AAIAAAAAAACAPwIAAIA/AAAgQAMBAQ== host=d le=1 ksid=%s", metric, now, ksMycenae),
+		"exit",
+	)
+	assert.NoError(t, err)
+
+	time.Sleep(tools.Sleep3)
+
+	for _, host := range []string{"a", "b"} {
+		assertMycenae(t, ksMycenae, now, now, 3, openTSDBTSID(metric+".bucket", map[string]string{"host": host, "le": "1"}))
+		assertMycenae(t, ksMycenae, now, now, 6, openTSDBTSID(metric+".bucket", map[string]string{"host": host, "le": "2.5"}))
+		assertMycenae(t, ksMycenae, now, now, 7, openTSDBTSID(metric+".bucket", map[string]string{"host": host, "le": "inf"}))
+		assertMycenae(t, ksMycenae, now, now, 7, openTSDBTSID(metric+".count", map[string]string{"host": host}))
+	}
+
+	// unknown codec and the reserved bucket tag
+	assertMycenaeEmpty(t, ksMycenae, now, now, openTSDBTSID(metric+".count", map[string]string{"host": "c"}))
+	assertMycenaeEmpty(t, ksMycenae, now, now, openTSDBTSID(metric+".count", map[string]string{"host": "d", "le": "1"}))
+}
+
+func TestOpenTSDBTelnetErrorResponses(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("otsdb_%d", rand.Int())
+	now := time.Now().Unix()
+
+	// the second opentsdb server answers up to two rejected lines per second
+	answering := tools.NewTelnetTool("mycenae", "8223", 3*time.Second)
+
+	lines, err := answering.Request(
+		"put "+metric,
+		fmt.Sprintf("rollup 1h-sum %s %d 1", metric, now),
+		"put invalid",
+		"put invalid",
+		fmt.Sprintf("put %s %d 1 host=a ksid=%s", metric, now, ksMycenae),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, lines, 2, "the exceeding error lines are not answered") {
+		for _, line := range lines {
+			assert.Regexp(t, `^(put|rollup): illegal argument: \[T01\] `, line)
+		}
+	}
+
+	lines, err = tools.NewTelnetTool("mycenae", "8123", 3*time.Second).Request("put invalid")
+	assert.NoError(t, err)
+	assert.Empty(t, lines, "the error responses are disabled")
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenae(t, ksMycenae, now, now, 1, openTSDBTSID(metric, map[string]string{"host": "a"}))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/protowire"
+	"github.com/uol/mycenae/tests/tools"
+)
+
+type otlpPoint struct {
+	attributes map[string]string
+	timestamp  int64
+	value      float64
+}
+
+// otlpAttributes - encodes the string attributes in the specified field
+func otlpAttributes(w *protowire.Writer, field int, attributes map[string]string) {
+
+	for k, v := range attributes {
+
+		value := protowire.NewWriter(32)
+		value.String(1, v)
+
+		kv := protowire.NewWriter(64)
+		kv.String(1, k)
+		kv.BytesField(2, value.Bytes())
+
+		w.BytesField(field, kv.Bytes())
+	}
+}
+
+// otlpGaugeRequest - encodes an export request with one gauge metric
+func otlpGaugeRequest(resource map[string]string, name string, points ...otlpPoint) []byte {
+
+	gauge := protowire.NewWriter(256)
+
+	for _, p := range points {
+		dp := protowire.NewWriter(128)
+		otlpAttributes(dp, 7, p.attributes)
+		// fixed64 time in nanoseconds, the writer only has the double method for the 64 bits wire type
+		dp.Double(3, math.Float64frombits(uint64(p.timestamp)*uint64(time.Second)))
+		dp.Double(4, p.value)
+		gauge.BytesField(1, dp.Bytes())
+	}
+
+	metric := protowire.NewWriter(256)
+	metric.String(1, name)
+	metric.BytesField(5, gauge.Bytes())
+
+	scope := protowire.NewWriter(256)
+	scope.BytesField(2, metric.Bytes())
+
+	res := protowire.NewWriter(128)
+	otlpAttributes(res, 1, resource)
+
+	rm := protowire.NewWriter(512)
+	rm.BytesField(1, res.Bytes())
+	rm.BytesField(2, scope.Bytes())
+
+	request := protowire.NewWriter(512)
+	request.BytesField(1, rm.Bytes())
+
+	return request.Bytes()
+}
+
+func postOTLP(t *testing.T, contentType string, payload []byte) (int, []byte) {
+
+	code, response, err := mycenaeTools.HTTP.CustomHeaderPOST("v1/metrics", payload, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	return code, response
+}
+
+// otlpRejectedPoints - decodes the rejected points of the partial success response
+func otlpRejectedPoints(t *testing.T, response []byte) int64 {
+
+	r := protowire.NewReader(response)
+	if r.Done() {
+		return 0
+	}
+
+	if _, _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	partial, err := r.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := protowire.NewReader(partial)
+	if _, _, err = pr.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := pr.Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rejected
+}
+
+func TestOTLPMetrics(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("otlp_%d", rand.Int())
+	now := time.Now().Unix()
+
+	resource := map[string]string{
+		"service.name": "api",
+		"process.pid":  "10",
+		"ksid":         ksMycenae,
+	}
+
+	payload := otlpGaugeRequest(resource, metric,
+		otlpPoint{map[string]string{"host": "a"}, now, 1.5},
+		otlpPoint{map[string]string{"host": "b"}, now, 2},
+	)
+
+	code, response := postOTLP(t, "application/x-protobuf", payload)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Zero(t, otlpRejectedPoints(t, response))
+
+	time.Sleep(tools.Sleep3)
+
+	// the process.pid resource attribute is not configured to be a tag
+	tsid := func(host string) string {
+		return tools.GetHashFromMetricAndTags(metric, map[string]string{"host": host, "service.name": "api", "ksid": ksMycenae, "ttl": "1"})
+	}
+
+	assertMycenae(t, ksMycenae, now, now, 1.5, tsid("a"))
+	assertMycenae(t, ksMycenae, now, now, 2, tsid("b"))
+}
+
+func TestOTLPMetricsPartialSuccess(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("otlp_%d", rand.Int())
+	now := time.Now().Unix()
+
+	payload := otlpGaugeRequest(map[string]string{"ksid": ksMycenae}, metric,
+		otlpPoint{map[string]string{"host": "a|b"}, now, 1},
+		otlpPoint{map[string]string{"host": "c"}, now, 3},
+		otlpPoint{map[string]string{"host": "d", "ksid": "unknown_keyset"}, now, 4},
+	)
+
+	code, response := postOTLP(t, "application/x-protobuf", payload)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(2), otlpRejectedPoints(t, response))
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenae(t, ksMycenae, now, now, 3, tools.GetHashFromMetricAndTags(metric, map[string]string{"host": "c", "ksid": ksMycenae, "ttl": "1"}))
+}
+
+func TestOTLPMetricsInvalid(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		contentType string
+		payload     []byte
+		status      int
+	}{
+		{"JSONEncoding", "application/json", []byte(`{"resourceMetrics":[]}`), http.StatusUnsupportedMediaType},
+		{"TruncatedPayload", "application/x-protobuf", []byte{0x0a, 0x05, 0x0a}, http.StatusBadRequest},
+		{"InvalidWireType", "application/x-protobuf", []byte{0x0f}, http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		code, _ := postOTLP(t, c.contentType, c.payload)
+		assert.Equal(t, c.status, code, c.name)
+	}
+}
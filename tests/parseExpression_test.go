@@ -604,6 +604,30 @@ func TestParseValidQueryDownsampleFillZero(t *testing.T) {
 
 }
 
+func TestParseValidQueryDownsampleFillInterpolation(t *testing.T) {
+
+	cases := map[string]struct {
+		exp        string
+		downsample string
+	}{
+		"Previous":           {`merge(sum, downsample(1m, sum, previous, query(os.cpu, {app=nonexistent}, 5m)))`, "1m-sum-previous"},
+		"Linear":             {`merge(sum, downsample(1m, sum, linear, query(os.cpu, {app=nonexistent}, 5m)))`, "1m-sum-linear"},
+		"PreviousWithMaxGap": {`merge(sum, downsample(1m, max, previous, 10m, query(os.cpu, {app=nonexistent}, 5m)))`, "1m-max-previous-10m"},
+		"LinearWithMaxGap":   {`merge(sum, downsample(30s, avg, linear, 90s, query(os.cpu, {app=nonexistent}, 5m)))`, "30s-avg-linear-90s"},
+	}
+
+	for test, data := range cases {
+
+		status, response := parseExp(t, fmt.Sprintf("exp=%s", url.QueryEscape(data.exp)))
+
+		if assert.Equal(t, 200, status, test) && assert.Len(t, response, 1, test) && assert.Len(t, response[0].Queries, 1, test) {
+			assert.Equal(t, data.downsample, response[0].Queries[0].Downsample, test)
+			assert.Equal(t, "os.cpu", response[0].Queries[0].Metric, test)
+			assert.Equal(t, []string{"downsample", "aggregation"}, response[0].Queries[0].Order, test)
+		}
+	}
+}
+
 func TestParseValidQueryDownsampleFilterGreaterThan(t *testing.T) {
 
 	expression := url.QueryEscape(
@@ -844,6 +868,64 @@ func TestParseValidQueryMergeCount(t *testing.T) {
 
 }
 
+func TestParseValidQueryHolisticOperations(t *testing.T) {
+
+	cases := map[string]struct {
+		downsample string
+		merge      string
+	}{
+		"Percentile":        {"p99", "p50"},
+		"HighPercentile":    {"p999", "p95"},
+		"Median":            {"median", "median"},
+		"StandardDeviation": {"stddev", "dev"},
+	}
+
+	for test, data := range cases {
+
+		expression := url.QueryEscape(fmt.Sprintf(
+			`merge(%s, downsample(1m, %s, none, query(os.cpu, {app=nonexistent}, 5m)))`, data.merge, data.downsample))
+
+		status, response := parseExp(t, fmt.Sprintf("exp=%s", expression))
+
+		if assert.Equal(t, 200, status, test) && assert.Len(t, response, 1, test) && assert.Len(t, response[0].Queries, 1, test) {
+			assert.Equal(t, data.merge, response[0].Queries[0].Aggregator, test)
+			assert.Equal(t, "1m-"+data.downsample+"-none", response[0].Queries[0].Downsample, test)
+		}
+	}
+}
+
+func TestParseValidQueryCalendar(t *testing.T) {
+
+	cases := map[string]struct {
+		exp      string
+		timezone string
+	}{
+		"WithoutTimezone": {
+			`calendar(merge(sum, downsample(1d, sum, none, query(os.cpu, {app=nonexistent}, 1w))))`,
+			"",
+		},
+		"WithTimezone": {
+			`calendar(America/Sao_Paulo, merge(sum, downsample(1n, sum, none, query(os.cpu, {app=nonexistent}, 1y))))`,
+			"America/Sao_Paulo",
+		},
+		"UTC": {
+			`calendar(UTC, merge(max, downsample(1w, max, none, query(os.cpu, null, 1n))))`,
+			"UTC",
+		},
+	}
+
+	for test, data := range cases {
+
+		status, response := parseExp(t, fmt.Sprintf("exp=%s&ksid=%s", url.QueryEscape(data.exp), ksMycenae))
+
+		if assert.Equal(t, 200, status, test) && assert.NotEmpty(t, response, test) && assert.Len(t, response[0].Queries, 1, test) {
+			assert.True(t, response[0].Queries[0].UseCalendar, test)
+			assert.Equal(t, data.timezone, response[0].Queries[0].Timezone, test)
+			assert.Equal(t, "os.cpu", response[0].Queries[0].Metric, test)
+		}
+	}
+}
+
 func TestParseValidQueryRelativeSec(t *testing.T) {
 
 	expression := url.QueryEscape(
@@ -1777,8 +1859,13 @@ func TestParseInvalidQuery(t *testing.T) {
 		},
 		"MergeInvalidExpression": {
 			`merge(x, downsample(1m, min, none, query(os.cpu, {app=nonexistent}, 5m)))`,
-			"unkown aggregation value",
-			"unkown aggregation value",
+			"unknown merge operation x",
+			"unknown merge operation x",
+		},
+		"DownsampleInvalidOperation": {
+			`merge(sum, downsample(1m, p42, none, query(os.cpu, {app=nonexistent}, 5m)))`,
+			"unknown downsample operation p42",
+			"unknown downsample operation p42",
 		},
 		"MergeEmptyExpression": {
 			`merge(downsample(1m, min, none, query(os.cpu, {app=nonexistent}, 5m)))`,
@@ -1787,8 +1874,8 @@ func TestParseInvalidQuery(t *testing.T) {
 		},
 		"MergeNullExpression": {
 			`merge(null, downsample(1m, min, none, query(os.cpu, {app=nonexistent}, 5m)))`,
-			"unkown aggregation value",
-			"unkown aggregation value",
+			"unknown merge operation null",
+			"unknown merge operation null",
 		},
 		"MergeInvalidFunction": {
 			`merge(sum, x(1m, min, query(os.cpu, {app=nonexistent}, 5m)))`,
@@ -1902,8 +1989,8 @@ func TestParseInvalidQuery(t *testing.T) {
 		},
 		"DownsampleExtraParamsRelative": {
 			`merge(sum, downsample(1m ,1m, min, none, query(os.cpu, {app=nonexistent}, 5m)))`,
-			"downsample expects 4 parameters but found 5: [1m 1m min none query(os.cpu,{app=nonexistent},5m)]",
-			"downsample needs 4 parameters: downsample operation, downsample period, fill option and a function",
+			"unknown downsample operation 1m",
+			"unknown downsample operation 1m",
 		},
 		"MergeExtraParamsRelative": {
 			`merge(sum, sum, downsample(1m, min, none, query(os.cpu, {app=nonexistent}, 5m)))`,
@@ -2005,6 +2092,16 @@ func TestParseInvalidQuery(t *testing.T) {
 			"invalid filter value >",
 			"invalid filter value >",
 		},
+		"CalendarTwice": {
+			`calendar(UTC, calendar(merge(sum, downsample(1d, sum, none, query(os.cpu, {app=nonexistent}, 5m)))))`,
+			"You can use only one calendar function per expression",
+			"You can use only one calendar function per expression",
+		},
+		"CalendarExtraParams": {
+			`calendar(UTC, 1d, merge(sum, downsample(1d, sum, none, query(os.cpu, {app=nonexistent}, 5m))))`,
+			"calendar expects 1 or 2 parameters but found 3: [UTC 1d merge(sum,downsample(1d,sum,none,query(os.cpu,{app=nonexistent},5m)))]",
+			"calendar needs 1 or 2 parameters: an optional timezone and a function",
+		},
 		"ParseEmptyQueryExpression": {
 			``,
 			"no expression found",
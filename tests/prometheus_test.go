@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/prompb"
+	"github.com/uol/mycenae/lib/protowire"
+	"github.com/uol/mycenae/tests/tools"
+)
+
+type promSample struct {
+	value     float64
+	timestamp int64
+}
+
+type promSeries struct {
+	labels  map[string]string
+	samples []promSample
+}
+
+// promWriteRequest - encodes and compresses a remote write request with the series
+func promWriteRequest(series ...promSeries) []byte {
+
+	request := protowire.NewWriter(256)
+
+	for _, s := range series {
+
+		ts := protowire.NewWriter(128)
+
+		for name, value := range s.labels {
+			label := protowire.NewWriter(64)
+			label.String(1, name)
+			label.String(2, value)
+			ts.BytesField(1, label.Bytes())
+		}
+
+		for _, sample := range s.samples {
+			ps := protowire.NewWriter(32)
+			ps.Double(1, sample.value)
+			ps.Int64(2, sample.timestamp)
+			ts.BytesField(2, ps.Bytes())
+		}
+
+		request.BytesField(1, ts.Bytes())
+	}
+
+	return snappy.Encode(nil, request.Bytes())
+}
+
+func postPromWrite(t *testing.T, payload []byte) int {
+
+	headers := map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+
+	code, _, err := mycenaeTools.HTTP.CustomHeaderPOST("api/prom/write", payload, headers)
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	return code
+}
+
+// promTSID - the tsid of a prometheus series (the metric name label becomes the metric)
+func promTSID(metric, keyset string, tags map[string]string) string {
+
+	all := map[string]string{"ksid": keyset, "ttl": "1"}
+	for k, v := range tags {
+		all[k] = v
+	}
+
+	return tools.GetHashFromMetricAndTags(metric, all)
+}
+
+func TestPrometheusRemoteWrite(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("prom_metric_%d", rand.Int())
+	now := time.Now().Unix()
+
+	code := postPromWrite(t, promWriteRequest(promSeries{
+		labels: map[string]string{
+			"__name__": metric,
+			"ksid":     ksMycenae,
+			"ttl":      "1",
+			"job":      "node",
+		},
+		samples: []promSample{
+			{value: 1.5, timestamp: (now - 60) * 1000},
+			{value: 2.5, timestamp: now * 1000},
+		},
+	}))
+
+	assert.Equal(t, http.StatusNoContent, code)
+
+	time.Sleep(tools.Sleep3)
+
+	tsid := promTSID(metric, ksMycenae, map[string]string{"job": "node"})
+
+	assertMycenae(t, ksMycenae, now-60, now-60, 1.5, tsid)
+	assertMycenae(t, ksMycenae, now, now, 2.5, tsid)
+}
+
+func TestPrometheusRemoteWriteIgnoresReservedLabels(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("prom_metric_%d", rand.Int())
+	now := time.Now().Unix()
+
+	code := postPromWrite(t, promWriteRequest(promSeries{
+		labels: map[string]string{
+			"__name__":   metric,
+			"__scheme__": "http",
+			"ksid":       ksMycenae,
+			"instance":   "localhost",
+		},
+		samples: []promSample{{value: 3, timestamp: now * 1000}},
+	}))
+
+	assert.Equal(t, http.StatusNoContent, code)
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenae(t, ksMycenae, now, now, 3, promTSID(metric, ksMycenae, map[string]string{"instance": "localhost"}))
+}
+
+func TestPrometheusRemoteWriteStalenessMarker(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("prom_metric_%d", rand.Int())
+	now := time.Now().Unix()
+
+	code := postPromWrite(t, promWriteRequest(promSeries{
+		labels:  map[string]string{"__name__": metric, "ksid": ksMycenae},
+		samples: []promSample{{value: math.NaN(), timestamp: now * 1000}},
+	}))
+
+	assert.Equal(t, http.StatusNoContent, code)
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenaeEmpty(t, ksMycenae, now, now, promTSID(metric, ksMycenae, nil))
+}
+
+func TestPrometheusRemoteWriteStoresTheValidSeries(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("prom_metric_%d", rand.Int())
+	now := time.Now().Unix()
+
+	code := postPromWrite(t, promWriteRequest(
+		promSeries{
+			labels:  map[string]string{"ksid": ksMycenae, "job": "without_name"},
+			samples: []promSample{{value: 1, timestamp: now * 1000}},
+		},
+		promSeries{
+			labels:  map[string]string{"__name__": metric, "ksid": ksMycenae},
+			samples: []promSample{{value: 7, timestamp: now * 1000}},
+		},
+	))
+
+	// the whole request is answered as bad, prometheus does not resend it
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	time.Sleep(tools.Sleep3)
+
+	assertMycenae(t, ksMycenae, now, now, 7, promTSID(metric, ksMycenae, nil))
+}
+
+func TestPrometheusRemoteWriteInvalid(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Unix() * 1000
+	sample := []promSample{{value: 1, timestamp: now}}
+
+	cases := []struct {
+		name    string
+		payload []byte
+	}{
+		{"NotSnappy", []byte("not a snappy payload")},
+		{"TruncatedProtobuf", snappy.Encode(nil, []byte{0x0a, 0x10, 0x0a})},
+		{"NoMetricName", promWriteRequest(promSeries{labels: map[string]string{"ksid": ksMycenae}, samples: sample})},
+		{"NoKeyset", promWriteRequest(promSeries{labels: map[string]string{"__name__": "prom_metric"}, samples: sample})},
+		{"UnknownKeyset", promWriteRequest(promSeries{labels: map[string]string{"__name__": "prom_metric", "ksid": "unknown_keyset"}, samples: sample})},
+		{"InvalidTTL", promWriteRequest(promSeries{labels: map[string]string{"__name__": "prom_metric", "ksid": ksMycenae, "ttl": "x"}, samples: sample})},
+		{"InvalidLabelValue", promWriteRequest(promSeries{labels: map[string]string{"__name__": "prom_metric", "ksid": ksMycenae, "host": "a b"}, samples: sample})},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, http.StatusBadRequest, postPromWrite(t, c.payload), c.name)
+	}
+}
+
+// promReadRequest - encodes and compresses a remote read request with one query
+func promReadRequest(start, end int64, matchers ...prompb.LabelMatcher) []byte {
+
+	query := protowire.NewWriter(256)
+	query.Int64(1, start)
+	query.Int64(2, end)
+
+	for _, m := range matchers {
+		matcher := protowire.NewWriter(64)
+		matcher.Varint(1, uint64(m.Type))
+		matcher.String(2, m.Name)
+		matcher.String(3, m.Value)
+		query.BytesField(3, matcher.Bytes())
+	}
+
+	request := protowire.NewWriter(256)
+	request.BytesField(1, query.Bytes())
+
+	return snappy.Encode(nil, request.Bytes())
+}
+
+// postPromRead - sends the remote read request and decodes the timeseries of the first result
+func postPromRead(t *testing.T, keyset string, payload []byte) (int, []prompb.TimeSeries) {
+
+	code, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/api/prom/read", keyset), payload)
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	if code != http.StatusOK {
+		return code, nil
+	}
+
+	data, err := snappy.Decode(nil, resp)
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	r := protowire.NewReader(data)
+
+	if _, _, err = r.Next(); err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	result, err := r.Bytes()
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	// the query result has the same layout of the write request
+	decoded := prompb.WriteRequest{}
+	if err = decoded.Unmarshal(result); err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	return code, decoded.Timeseries
+}
+
+func TestPrometheusRemoteRead(t *testing.T) {
+	t.Parallel()
+
+	metric := fmt.Sprintf("prom_metric_%d", rand.Int())
+	now := time.Now().Unix() * 1000
+
+	code := postPromWrite(t, promWriteRequest(
+		promSeries{
+			labels:  map[string]string{"__name__": metric, "ksid": ksMycenae, "job": "node"},
+			samples: []promSample{{value: 1, timestamp: now - 30000}, {value: 2, timestamp: now}},
+		},
+		promSeries{
+			labels:  map[string]string{"__name__": metric, "ksid": ksMycenae, "job": "api"},
+			samples: []promSample{{value: 3, timestamp: now}},
+		},
+	))
+
+	if !assert.Equal(t, http.StatusNoContent, code) {
+		return
+	}
+
+	time.Sleep(tools.Sleep3)
+
+	nodeSeries := prompb.TimeSeries{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: metric},
+			{Name: "job", Value: "node"},
+			{Name: "ttl", Value: "1"},
+		},
+		Samples: []prompb.Sample{{Value: 1, Timestamp: now - 30000}, {Value: 2, Timestamp: now}},
+	}
+
+	metricMatcher := prompb.LabelMatcher{Type: prompb.MatchEqual, Name: "__name__", Value: metric}
+
+	cases := []struct {
+		name     string
+		matchers []prompb.LabelMatcher
+		start    int64
+		series   int
+	}{
+		{"MetricOnly", []prompb.LabelMatcher{metricMatcher}, now - 60000, 2},
+		{"Equal", []prompb.LabelMatcher{metricMatcher, {Type: prompb.MatchEqual, Name: "job", Value: "node"}}, now - 60000, 1},
+		{"NotEqual", []prompb.LabelMatcher{metricMatcher, {Type: prompb.MatchNotEqual, Name: "job", Value: "api"}}, now - 60000, 1},
+		{"Regexp", []prompb.LabelMatcher{metricMatcher, {Type: prompb.MatchRegexp, Name: "job", Value: "no.*"}}, now - 60000, 1},
+		{"NotRegexp", []prompb.LabelMatcher{metricMatcher, {Type: prompb.MatchNotRegexp, Name: "job", Value: "a.*"}}, now - 60000, 1},
+		{"MetricRegexp", []prompb.LabelMatcher{{Type: prompb.MatchRegexp, Name: "__name__", Value: metric + ".*"}, {Type: prompb.MatchEqual, Name: "job", Value: "node"}}, now - 60000, 1},
+		{"NoMatch", []prompb.LabelMatcher{metricMatcher, {Type: prompb.MatchEqual, Name: "job", Value: "other"}}, now - 60000, 0},
+		{"OutOfRange", []prompb.LabelMatcher{metricMatcher}, now - 3600000, 0},
+	}
+
+	for _, c := range cases {
+
+		end := now
+		if c.name == "OutOfRange" {
+			end = now - 1800000
+		}
+
+		code, series := postPromRead(t, ksMycenae, promReadRequest(c.start, end, c.matchers...))
+
+		if !assert.Equal(t, http.StatusOK, code, c.name) || !assert.Len(t, series, c.series, c.name) {
+			continue
+		}
+
+		if c.series == 1 {
+			assert.Equal(t, nodeSeries, series[0], c.name)
+		}
+	}
+}
+
+func TestPrometheusRemoteReadInvalid(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Unix() * 1000
+
+	cases := []struct {
+		name    string
+		keyset  string
+		payload []byte
+		code    int
+	}{
+		{"UnknownKeyset", "unknown_keyset", promReadRequest(now-60000, now, prompb.LabelMatcher{Name: "__name__", Value: "up"}), http.StatusNotFound},
+		{"NotSnappy", ksMycenae, []byte("not a snappy payload"), http.StatusBadRequest},
+		{"TruncatedProtobuf", ksMycenae, snappy.Encode(nil, []byte{0x0a, 0x10, 0x08}), http.StatusBadRequest},
+		{"MetricNotEqual", ksMycenae, promReadRequest(now-60000, now, prompb.LabelMatcher{Type: prompb.MatchNotEqual, Name: "__name__", Value: "up"}), http.StatusBadRequest},
+		{"InvalidTTL", ksMycenae, promReadRequest(now-60000, now, prompb.LabelMatcher{Name: "__name__", Value: "up"}, prompb.LabelMatcher{Name: "ttl", Value: "x"}), http.StatusBadRequest},
+		{"UnknownTTL", ksMycenae, promReadRequest(now-60000, now, prompb.LabelMatcher{Name: "__name__", Value: "up"}, prompb.LabelMatcher{Name: "ttl", Value: "12345"}), http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+
+		code, _ := postPromRead(t, c.keyset, c.payload)
+		assert.Equal(t, c.code, code, c.name)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/tests/tools"
+)
+
+type rawStreamPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type rawStreamSerie struct {
+	Metadata struct {
+		Metric string            `json:"metric"`
+		Tags   map[string]string `json:"tags"`
+	} `json:"metadata"`
+	Points []rawStreamPoint `json:"points"`
+	Error  string           `json:"error"`
+}
+
+// sendRawStreamPoints - stores two points for each host and returns the metric name and the timestamps
+func sendRawStreamPoints(t *testing.T, hosts ...string) (string, []int64) {
+
+	metric := fmt.Sprintf("raw_stream_%d", rand.Int())
+	now := time.Now().Unix()
+	timestamps := []int64{now - 120, now - 60}
+
+	points := []tools.Point{}
+	for i, host := range hosts {
+		for j, ts := range timestamps {
+			points = append(points, tools.Point{
+				Value:     float32(i*10 + j),
+				Metric:    metric,
+				Tags:      map[string]string{"ksid": ksMycenae, "ttl": "1", "host": host},
+				Timestamp: ts,
+			})
+		}
+	}
+
+	payload, err := json.Marshal(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := mycenaeTools.HTTP.POST("api/put", payload)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("error storing the points: %d %v", code, err)
+	}
+
+	time.Sleep(tools.Sleep3)
+
+	return metric, timestamps
+}
+
+func postRawStream(t *testing.T, metric, stream string) (int, []byte) {
+
+	query := fmt.Sprintf(`{"type":"number","metric":"%s","since":"1h","stream":"%s","tags":{"ksid":"%s","ttl":"1"}}`, metric, stream, ksMycenae)
+
+	code, resp, err := mycenaeTools.HTTP.POST("api/query/raw", []byte(query))
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	return code, resp
+}
+
+// rawStreamByHost - groups the points of the decoded pages by the host tag
+func rawStreamByHost(series []rawStreamSerie) map[string][]rawStreamPoint {
+
+	byHost := map[string][]rawStreamPoint{}
+	for _, s := range series {
+		byHost[s.Metadata.Tags["host"]] = append(byHost[s.Metadata.Tags["host"]], s.Points...)
+	}
+
+	return byHost
+}
+
+func TestRawQueryStreamNDJSON(t *testing.T) {
+	t.Parallel()
+
+	metric, ts := sendRawStreamPoints(t, "a", "b")
+
+	code, resp := postRawStream(t, metric, "ndjson")
+	assert.Equal(t, http.StatusOK, code)
+
+	series := []rawStreamSerie{}
+	scanner := bufio.NewScanner(bytes.NewReader(resp))
+	for scanner.Scan() {
+		s := rawStreamSerie{}
+		if !assert.NoError(t, json.Unmarshal(scanner.Bytes(), &s), scanner.Text()) {
+			return
+		}
+		assert.Empty(t, s.Error)
+		assert.Equal(t, metric, s.Metadata.Metric)
+		series = append(series, s)
+	}
+
+	expected := map[string][]rawStreamPoint{
+		"a": {{ts[0] * 1000, 0}, {ts[1] * 1000, 1}},
+		"b": {{ts[0] * 1000, 10}, {ts[1] * 1000, 11}},
+	}
+
+	assert.Equal(t, expected, rawStreamByHost(series))
+}
+
+func TestRawQueryStreamJSON(t *testing.T) {
+	t.Parallel()
+
+	metric, ts := sendRawStreamPoints(t, "a", "b", "c")
+
+	code, resp := postRawStream(t, metric, "json")
+	assert.Equal(t, http.StatusOK, code)
+
+	result := struct {
+		Results []rawStreamSerie `json:"results"`
+		Error   string           `json:"error"`
+		Total   int              `json:"total"`
+	}{}
+
+	if !assert.NoError(t, json.Unmarshal(resp, &result), string(resp)) {
+		return
+	}
+
+	assert.Empty(t, result.Error)
+	assert.Equal(t, 3, result.Total)
+	assert.Len(t, result.Results, 3, "one result for each serie")
+
+	byHost := rawStreamByHost(result.Results)
+	for i, host := range []string{"a", "b", "c"} {
+		assert.Equal(t, []rawStreamPoint{{ts[0] * 1000, float64(i * 10)}, {ts[1] * 1000, float64(i*10 + 1)}}, byHost[host], host)
+	}
+}
+
+func TestRawQueryStreamNoContent(t *testing.T) {
+	t.Parallel()
+
+	code, resp := postRawStream(t, fmt.Sprintf("raw_stream_%d", rand.Int()), "ndjson")
+
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Empty(t, resp)
+}
+
+func TestRawQueryStreamInvalidFormat(t *testing.T) {
+	t.Parallel()
+
+	code, resp := postRawStream(t, "raw_stream", "csv")
+
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	respErr := tools.Error{}
+	if assert.NoError(t, json.Unmarshal(resp, &respErr), string(resp)) {
+		assert.Equal(t, `parameter "stream" must be "ndjson" or "json"`, respErr.Message)
+	}
+}
@@ -112,8 +112,21 @@ type TextPoint struct {
 }
 
 type ResponseMeta struct {
-	TotalRecord int      `json:"totalRecords"`
-	Payload     []TsMeta `json:"payload"`
+	TotalRecord int        `json:"totalRecords"`
+	Payload     []TsMeta   `json:"payload"`
+	Job         *DeleteJob `json:"job,omitempty"`
+}
+
+type DeleteJob struct {
+	ID        string            `json:"id"`
+	Keyset    string            `json:"keyset"`
+	Kind      string            `json:"kind"`
+	Status    string            `json:"status"`
+	Total     int               `json:"total"`
+	Deleted   int               `json:"deleted"`
+	Failed    int               `json:"failed"`
+	Remaining int               `json:"remaining"`
+	Failures  map[string]string `json:"failures,omitempty"`
 }
 
 type TsMeta struct {
@@ -168,6 +181,8 @@ type TSDBquery struct {
 	Order       []string          `json:"order"`
 	FilterValue string            `json:"filterValue"`
 	Filters     []TSDBfilter      `json:"filters"`
+	UseCalendar bool              `json:"useCalendar"`
+	Timezone    string            `json:"timezone"`
 }
 
 type TSDBrateOptions struct {
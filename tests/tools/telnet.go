@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"time"
+)
+
+// TelnetTool - sends lines to one of the telnet servers
+type TelnetTool struct {
+	address string
+	timeout time.Duration
+}
+
+// NewTelnetTool - creates a tool for the telnet server listening in the host and port
+func NewTelnetTool(hostname string, port string, timeout time.Duration) *TelnetTool {
+
+	return &TelnetTool{
+		address: net.JoinHostPort(hostname, port),
+		timeout: timeout,
+	}
+}
+
+// Send - opens a connection, writes the lines and closes it
+func (tt *TelnetTool) Send(lines ...string) error {
+
+	_, err := tt.request(false, lines...)
+
+	return err
+}
+
+// Request - writes the lines and returns the lines answered by the server until the timeout
+func (tt *TelnetTool) Request(lines ...string) ([]string, error) {
+
+	return tt.request(true, lines...)
+}
+
+func (tt *TelnetTool) request(read bool, lines ...string) ([]string, error) {
+
+	conn, err := net.DialTimeout("tcp", tt.address, tt.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(tt.timeout)); err != nil {
+		return nil, err
+	}
+
+	if _, err = conn.Write([]byte(strings.Join(lines, "\n") + "\n")); err != nil {
+		return nil, err
+	}
+
+	if !read {
+		return nil, nil
+	}
+
+	answered := []string{}
+	scanner := bufio.NewScanner(conn)
+
+	for scanner.Scan() {
+		answered = append(answered, scanner.Text())
+	}
+
+	if err, ok := scanner.Err().(net.Error); ok && !err.Timeout() {
+		return answered, err
+	}
+
+	return answered, nil
+}